@@ -2,15 +2,22 @@ package config
 
 import (
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config struct holds application configuration
 // This is a simple way to make config accessible globally.
 // A more advanced approach might use dependency injection.
 type Config struct {
-	JWTSecret        string
-	LocalStorageOnly bool
+	JWTSecret                string
+	LocalStorageOnly         bool
+	ExpiryAlertLeadDays      []int
+	ExpiryScanInterval       time.Duration
+	ReconciliationInterval   time.Duration
+	ReservationSweepInterval time.Duration
 }
 
 // AppConfig holds the application-wide configuration
@@ -24,3 +31,29 @@ func LoadBoolEnv(key string) bool {
 		return false
 	}
 }
+
+// LoadIntListEnv parses a comma-separated list of non-negative integers, largest first.
+// The fallback is returned when the variable is unset or contains no valid values.
+func LoadIntListEnv(key string, fallback []int) []int {
+	var values []int
+	for _, part := range strings.Split(os.Getenv(key), ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err == nil && value >= 0 {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return fallback
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(values)))
+	return values
+}
+
+// LoadDurationEnv parses a Go duration such as "6h", returning fallback when unset or invalid.
+func LoadDurationEnv(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(strings.TrimSpace(os.Getenv(key)))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -123,6 +123,489 @@ func EnsureSyncSchema(ctx context.Context) error {
 			factor NUMERIC(20,8) NOT NULL CHECK (factor > 0),
 			UNIQUE (stock_item_id, from_unit_id, to_unit_id)
 		)`,
+		`ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname='sale_items_quantity_returned_check') THEN ALTER TABLE sale_items ADD CONSTRAINT sale_items_quantity_returned_check CHECK (quantity_returned >= 0); END IF; END $$`,
+		`CREATE TABLE IF NOT EXISTS sale_item_batches (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			sale_item_id UUID NOT NULL REFERENCES sale_items(id) ON DELETE CASCADE,
+			batch_id UUID NOT NULL REFERENCES inventory_batches(id) ON DELETE RESTRICT,
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_returned >= 0 AND quantity_returned <= quantity),
+			unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sale_item_batches_item ON sale_item_batches (sale_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sale_item_batches_batch ON sale_item_batches (batch_id)`,
+		`ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL`,
+		`ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS reason_code VARCHAR(50)`,
+		`CREATE TABLE IF NOT EXISTS inventory_expiry_alerts (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			batch_id UUID NOT NULL REFERENCES inventory_batches(id) ON DELETE CASCADE,
+			lead_days INTEGER NOT NULL,
+			sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (batch_id, lead_days)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_batches_expiry ON inventory_batches (expiry_date) WHERE quantity_remaining > 0`,
+		`CREATE TABLE IF NOT EXISTS inventory_serial_events (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			serial_id UUID NOT NULL REFERENCES inventory_serials(id) ON DELETE CASCADE,
+			event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('RECEIVED', 'TRANSFERRED', 'SOLD', 'RETURNED', 'ADJUSTED')),
+			shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+			reference_type VARCHAR(30),
+			reference_id UUID,
+			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			notes TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_serial_events_serial ON inventory_serial_events (serial_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS product_kits (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+			stock_item_id UUID NOT NULL UNIQUE REFERENCES stock_items(id) ON DELETE CASCADE,
+			name VARCHAR(255) NOT NULL,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS product_kit_components (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			kit_id UUID NOT NULL REFERENCES product_kits(id) ON DELETE CASCADE,
+			stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE RESTRICT,
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			UNIQUE (kit_id, stock_item_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS sale_item_components (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			sale_item_id UUID NOT NULL REFERENCES sale_items(id) ON DELETE CASCADE,
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE RESTRICT,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			quantity_per_kit NUMERIC(15,3) NOT NULL CHECK (quantity_per_kit > 0),
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_returned >= 0 AND quantity_returned <= quantity),
+			unit_cost NUMERIC(15,2) NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`ALTER TABLE sale_item_batches ADD COLUMN IF NOT EXISTS sale_item_component_id UUID REFERENCES sale_item_components(id) ON DELETE CASCADE`,
+		`CREATE INDEX IF NOT EXISTS idx_product_kits_merchant ON product_kits (merchant_id, name)`,
+		`CREATE INDEX IF NOT EXISTS idx_product_kit_components_kit ON product_kit_components (kit_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sale_item_components_item ON sale_item_components (sale_item_id)`,
+		`ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS base_quantity NUMERIC(20,8)`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name='sale_items' AND column_name='base_quantity' AND numeric_scale<>8) THEN ALTER TABLE sale_items ALTER COLUMN base_quantity TYPE NUMERIC(20,8); END IF; END $$`,
+		`ALTER TABLE stock_item_units ADD COLUMN IF NOT EXISTS is_report_unit BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_item_units_report ON stock_item_units (stock_item_id) WHERE is_report_unit`,
+		`ALTER TABLE suppliers ADD COLUMN IF NOT EXISTS lead_time_days INTEGER NOT NULL DEFAULT 7 CHECK (lead_time_days >= 0)`,
+		`ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS safety_stock NUMERIC(15,3) CHECK (safety_stock >= 0)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_order_items_stock_item ON purchase_order_items (stock_item_id)`,
+		`ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS average_cost NUMERIC(15,4) CHECK (average_cost >= 0)`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name='inventory_movements' AND column_name='unit_cost' AND numeric_scale<>4) THEN ALTER TABLE inventory_movements ALTER COLUMN unit_cost TYPE NUMERIC(15,4); END IF; END $$`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name='sale_item_components' AND column_name='unit_cost' AND numeric_scale<>4) THEN ALTER TABLE sale_item_components ALTER COLUMN unit_cost TYPE NUMERIC(15,4); END IF; END $$`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name='sale_item_batches' AND column_name='unit_cost' AND numeric_scale<>4) THEN ALTER TABLE sale_item_batches ALTER COLUMN unit_cost TYPE NUMERIC(15,4); END IF; END $$`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema=current_schema() AND table_name='inventory_batches' AND column_name='unit_cost' AND numeric_scale<>4) THEN ALTER TABLE inventory_batches ALTER COLUMN unit_cost TYPE NUMERIC(15,4); END IF; END $$`,
+		`ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(15,4)`,
+		`ALTER TABLE sale_items ADD COLUMN IF NOT EXISTS cost_total NUMERIC(15,2)`,
+		`CREATE TABLE IF NOT EXISTS inventory_cost_layers (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+			quantity_received NUMERIC(15,3) NOT NULL CHECK (quantity_received > 0),
+			quantity_remaining NUMERIC(15,3) NOT NULL CHECK (quantity_remaining >= 0),
+			unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+			received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS merchant_costing_settings (
+			merchant_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			costing_method VARCHAR(20) NOT NULL DEFAULT 'WEIGHTED_AVERAGE' CHECK (costing_method IN ('WEIGHTED_AVERAGE', 'FIFO')),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_cost_layers_open ON inventory_cost_layers (inventory_item_id, received_at) WHERE quantity_remaining > 0`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_movements_item_date ON inventory_movements (shop_id, inventory_item_id, movement_date)`,
+		`CREATE TABLE IF NOT EXISTS inventory_reconciliation_exceptions (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			recorded_quantity NUMERIC(15,3) NOT NULL,
+			ledger_quantity NUMERIC(15,3) NOT NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'RESOLVED', 'CLEARED')),
+			resolution VARCHAR(30) CHECK (resolution IN ('REBUILD_FROM_LEDGER', 'CORRECTING_MOVEMENT')),
+			notes TEXT,
+			detected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_checked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			resolved_at TIMESTAMPTZ,
+			resolved_by UUID REFERENCES users(id) ON DELETE SET NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_movements_item ON inventory_movements (inventory_item_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_inventory_reconciliation_open ON inventory_reconciliation_exceptions (inventory_item_id) WHERE status = 'OPEN'`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_reconciliation_shop_status ON inventory_reconciliation_exceptions (shop_id, status, detected_at DESC)`,
+		`ALTER TABLE inventory_reservations ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
+		`ALTER TABLE inventory_reservations ADD COLUMN IF NOT EXISTS sale_id UUID REFERENCES sales(id) ON DELETE SET NULL`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname='inventory_reservations_status_check' AND pg_get_constraintdef(oid) LIKE '%''CONSUMED''%') THEN ALTER TABLE inventory_reservations DROP CONSTRAINT IF EXISTS inventory_reservations_status_check; ALTER TABLE inventory_reservations ADD CONSTRAINT inventory_reservations_status_check CHECK (status IN ('ACTIVE', 'RELEASED', 'EXPIRED', 'CONSUMED')); END IF; END $$`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_reservations_expiry ON inventory_reservations (expires_at) WHERE status = 'ACTIVE' AND expires_at IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS barcode_sequences (
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			symbology VARCHAR(20) NOT NULL CHECK (symbology IN ('EAN13', 'CODE128')),
+			prefix VARCHAR(20) NOT NULL,
+			last_value BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (merchant_id, symbology, prefix)
+		)`,
+		`CREATE TABLE IF NOT EXISTS scale_barcode_formats (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			prefix VARCHAR(3) NOT NULL CHECK (prefix ~ '^2[0-9]{0,2}$'),
+			item_code_length INTEGER NOT NULL CHECK (item_code_length BETWEEN 1 AND 8),
+			value_mode VARCHAR(10) NOT NULL CHECK (value_mode IN ('WEIGHT', 'PRICE')),
+			value_decimals INTEGER NOT NULL DEFAULT 3 CHECK (value_decimals BETWEEN 0 AND 4),
+			unit_id UUID REFERENCES unit_definitions(id) ON DELETE RESTRICT,
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (merchant_id, prefix)
+		)`,
+		`CREATE TABLE IF NOT EXISTS scale_plu_codes (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			plu_code VARCHAR(8) NOT NULL,
+			stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (merchant_id, plu_code)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_scale_plu_codes_stock_item ON scale_plu_codes (stock_item_id)`,
+		`ALTER TABLE stock_item_configurations ADD COLUMN IF NOT EXISTS warranty_months INTEGER CHECK (warranty_months BETWEEN 0 AND 240)`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname='inventory_assets_status_check' AND pg_get_constraintdef(oid) LIKE '%''RENTED''%') THEN ALTER TABLE inventory_assets DROP CONSTRAINT IF EXISTS inventory_assets_status_check; ALTER TABLE inventory_assets ADD CONSTRAINT inventory_assets_status_check CHECK (status IN ('AVAILABLE', 'RESERVED', 'SOLD', 'RETURNED', 'INACTIVE', 'RENTED')); END IF; END $$`,
+		`CREATE TABLE IF NOT EXISTS inventory_asset_events (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			asset_id UUID NOT NULL REFERENCES inventory_assets(id) ON DELETE CASCADE,
+			event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('RECEIVED', 'STATUS_CHANGED', 'RENTED', 'RENTAL_RETURNED')),
+			from_status VARCHAR(20),
+			to_status VARCHAR(20) NOT NULL,
+			shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+			reference_type VARCHAR(30),
+			reference_id UUID,
+			actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			notes TEXT,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS inventory_warranties (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			serial_id UUID REFERENCES inventory_serials(id) ON DELETE CASCADE,
+			asset_id UUID REFERENCES inventory_assets(id) ON DELETE CASCADE,
+			sale_id UUID REFERENCES sales(id) ON DELETE SET NULL,
+			customer_id UUID REFERENCES shop_customers(id) ON DELETE SET NULL,
+			starts_on DATE NOT NULL,
+			ends_on DATE NOT NULL,
+			status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'VOID')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (serial_id IS NOT NULL OR asset_id IS NOT NULL),
+			CHECK (ends_on >= starts_on)
+		)`,
+		`CREATE TABLE IF NOT EXISTS asset_rentals (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			asset_id UUID NOT NULL REFERENCES inventory_assets(id) ON DELETE CASCADE,
+			customer_id UUID NOT NULL REFERENCES shop_customers(id) ON DELETE RESTRICT,
+			status VARCHAR(10) NOT NULL DEFAULT 'OUT' CHECK (status IN ('OUT', 'RETURNED')),
+			checked_out_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			due_at TIMESTAMPTZ NOT NULL,
+			returned_at TIMESTAMPTZ,
+			checked_out_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			returned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			notes TEXT,
+			return_notes TEXT,
+			CHECK (due_at > checked_out_at)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_asset_events_asset ON inventory_asset_events (asset_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_warranties_serial ON inventory_warranties (serial_id) WHERE serial_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_warranties_asset ON inventory_warranties (asset_id) WHERE asset_id IS NOT NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_asset_rentals_open ON asset_rentals (asset_id) WHERE status = 'OUT'`,
+		`CREATE INDEX IF NOT EXISTS idx_asset_rentals_due ON asset_rentals (merchant_id, due_at) WHERE status = 'OUT'`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname='inventory_serial_events_event_type_check' AND pg_get_constraintdef(oid) LIKE '%''RMA''%') THEN ALTER TABLE inventory_serial_events DROP CONSTRAINT IF EXISTS inventory_serial_events_event_type_check; ALTER TABLE inventory_serial_events ADD CONSTRAINT inventory_serial_events_event_type_check CHECK (event_type IN ('RECEIVED', 'TRANSFERRED', 'SOLD', 'RETURNED', 'ADJUSTED', 'RMA')); END IF; END $$`,
+		`DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname='inventory_asset_events_event_type_check' AND pg_get_constraintdef(oid) LIKE '%''SOLD''%' AND pg_get_constraintdef(oid) LIKE '%''RMA''%') THEN ALTER TABLE inventory_asset_events DROP CONSTRAINT IF EXISTS inventory_asset_events_event_type_check; ALTER TABLE inventory_asset_events ADD CONSTRAINT inventory_asset_events_event_type_check CHECK (event_type IN ('RECEIVED', 'STATUS_CHANGED', 'SOLD', 'RENTED', 'RENTAL_RETURNED', 'RMA')); END IF; END $$`,
+		`CREATE TABLE IF NOT EXISTS rma_cases (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE RESTRICT,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			serial_id UUID REFERENCES inventory_serials(id) ON DELETE RESTRICT,
+			asset_id UUID REFERENCES inventory_assets(id) ON DELETE RESTRICT,
+			sale_id UUID REFERENCES sales(id) ON DELETE SET NULL,
+			customer_id UUID REFERENCES shop_customers(id) ON DELETE SET NULL,
+			warranty_id UUID REFERENCES inventory_warranties(id) ON DELETE SET NULL,
+			under_warranty BOOLEAN NOT NULL DEFAULT FALSE,
+			supplier_id UUID REFERENCES suppliers(id) ON DELETE RESTRICT,
+			supplier_reference VARCHAR(100),
+			status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'SENT_TO_SUPPLIER', 'REPAIRED', 'REPLACED', 'REJECTED', 'CLOSED')),
+			fault_description TEXT NOT NULL,
+			replacement_serial_id UUID REFERENCES inventory_serials(id) ON DELETE SET NULL,
+			replacement_asset_id UUID REFERENCES inventory_assets(id) ON DELETE SET NULL,
+			opened_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			closed_at TIMESTAMPTZ,
+			CHECK ((serial_id IS NOT NULL) <> (asset_id IS NOT NULL))
+		)`,
+		`CREATE TABLE IF NOT EXISTS rma_case_notes (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			rma_case_id UUID NOT NULL REFERENCES rma_cases(id) ON DELETE CASCADE,
+			from_status VARCHAR(20),
+			to_status VARCHAR(20),
+			note TEXT NOT NULL,
+			author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS rma_case_attachments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			rma_case_id UUID NOT NULL REFERENCES rma_cases(id) ON DELETE CASCADE,
+			filename VARCHAR(255) NOT NULL,
+			content_type VARCHAR(100) NOT NULL,
+			size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+			url TEXT NOT NULL,
+			storage_provider VARCHAR(20) NOT NULL,
+			storage_public_id TEXT,
+			storage_object_name TEXT,
+			uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_rma_cases_merchant_status ON rma_cases (merchant_id, status, created_at)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_rma_cases_open_serial ON rma_cases (serial_id) WHERE status NOT IN ('REJECTED', 'CLOSED') AND serial_id IS NOT NULL`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_rma_cases_open_asset ON rma_cases (asset_id) WHERE status NOT IN ('REJECTED', 'CLOSED') AND asset_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_rma_case_notes_case ON rma_case_notes (rma_case_id, created_at)`,
+		`ALTER TABLE shops ADD COLUMN IF NOT EXISTS negative_stock_policy VARCHAR(20) NOT NULL DEFAULT 'BLOCK' CHECK (negative_stock_policy IN ('BLOCK', 'WARN', 'ALLOW_NEGATIVE'))`,
+		`ALTER TABLE stock_item_configurations ADD COLUMN IF NOT EXISTS negative_stock_policy VARCHAR(20) CHECK (negative_stock_policy IN ('BLOCK', 'WARN', 'ALLOW_NEGATIVE'))`,
+		`ALTER TABLE inventory_items DROP CONSTRAINT IF EXISTS inventory_items_quantity_on_hand_check`,
+		`CREATE TABLE IF NOT EXISTS inventory_backorders (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			reference_type VARCHAR(30) NOT NULL,
+			reference_id UUID,
+			quantity NUMERIC(20,8) NOT NULL CHECK (quantity > 0),
+			settled_quantity NUMERIC(20,8) NOT NULL DEFAULT 0 CHECK (settled_quantity >= 0),
+			status VARCHAR(10) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'SETTLED')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			settled_at TIMESTAMPTZ
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_backorders_open ON inventory_backorders (inventory_item_id, created_at) WHERE status = 'OPEN'`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_backorders_merchant ON inventory_backorders (merchant_id, shop_id, status)`,
+		`ALTER TABLE shops ADD COLUMN IF NOT EXISTS location_type VARCHAR(20) NOT NULL DEFAULT 'STORE' CHECK (location_type IN ('STORE', 'WAREHOUSE', 'VAN'))`,
+		`CREATE TABLE IF NOT EXISTS storage_bins (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			code VARCHAR(50) NOT NULL,
+			name VARCHAR(255),
+			bin_type VARCHAR(10) NOT NULL DEFAULT 'BIN' CHECK (bin_type IN ('BIN', 'SHELF')),
+			is_active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (shop_id, code)
+		)`,
+		`ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS putaway_bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL`,
+		`ALTER TABLE inventory_items ADD COLUMN IF NOT EXISTS pick_bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL`,
+		`ALTER TABLE inventory_movements ADD COLUMN IF NOT EXISTS bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL`,
+		`CREATE TABLE IF NOT EXISTS inventory_bin_balances (
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+			bin_id UUID NOT NULL REFERENCES storage_bins(id) ON DELETE RESTRICT,
+			quantity NUMERIC(15,3) NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (inventory_item_id, bin_id)
+		)`,
+		`DO $$ BEGIN IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname='inventory_bin_balances_bin_id_fkey' AND confdeltype='c') THEN ALTER TABLE inventory_bin_balances DROP CONSTRAINT inventory_bin_balances_bin_id_fkey; ALTER TABLE inventory_bin_balances ADD CONSTRAINT inventory_bin_balances_bin_id_fkey FOREIGN KEY (bin_id) REFERENCES storage_bins(id) ON DELETE RESTRICT; END IF; END $$`,
+		`CREATE INDEX IF NOT EXISTS idx_storage_bins_merchant ON storage_bins (merchant_id, shop_id)`,
+		`ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS approved_by UUID REFERENCES users(id) ON DELETE SET NULL`,
+		`ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS approved_at TIMESTAMPTZ`,
+		`ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ`,
+		`ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS cancel_reason TEXT`,
+		`ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS purchase_order_item_id UUID REFERENCES purchase_order_items(id) ON DELETE SET NULL`,
+		`CREATE TABLE IF NOT EXISTS merchant_purchasing_settings (
+			merchant_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+			over_receipt_tolerance_percent NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (over_receipt_tolerance_percent BETWEEN 0 AND 100),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS invoice_number VARCHAR(100)`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS invoice_date DATE NOT NULL DEFAULT CURRENT_DATE`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS due_date DATE`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS tax_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0)`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS amount_paid NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_paid >= 0)`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS match_status VARCHAR(20) NOT NULL DEFAULT 'MATCHED' CHECK (match_status IN ('MATCHED', 'VARIANCE'))`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS notes TEXT`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP`,
+		`CREATE TABLE IF NOT EXISTS supplier_invoice_items (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			supplier_invoice_id UUID NOT NULL REFERENCES supplier_invoices(id) ON DELETE CASCADE,
+			purchase_order_item_id UUID NOT NULL REFERENCES purchase_order_items(id) ON DELETE RESTRICT,
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
+			total_cost NUMERIC(15,2) NOT NULL CHECK (total_cost >= 0),
+			received_quantity NUMERIC(15,3) NOT NULL DEFAULT 0,
+			ordered_unit_cost NUMERIC(15,2) NOT NULL DEFAULT 0,
+			quantity_variance NUMERIC(15,3) NOT NULL DEFAULT 0,
+			price_variance NUMERIC(15,2) NOT NULL DEFAULT 0
+		)`,
+		`ALTER TABLE supplier_payments ADD COLUMN IF NOT EXISTS reference VARCHAR(100)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_supplier_invoices_number ON supplier_invoices (merchant_id, supplier_id, invoice_number) WHERE invoice_number IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_invoices_supplier ON supplier_invoices (merchant_id, supplier_id, invoice_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_invoice_items_order_item ON supplier_invoice_items (purchase_order_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_payments_invoice ON supplier_payments (supplier_invoice_id, payment_date)`,
+		`ALTER TABLE supplier_invoices ADD COLUMN IF NOT EXISTS amount_credited NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_credited >= 0)`,
+		`CREATE TABLE IF NOT EXISTS supplier_returns (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE RESTRICT,
+			supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+			goods_receipt_id UUID REFERENCES goods_receipts(id) ON DELETE SET NULL,
+			reason TEXT,
+			total_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (total_amount >= 0),
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS supplier_return_items (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			supplier_return_id UUID NOT NULL REFERENCES supplier_returns(id) ON DELETE CASCADE,
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE RESTRICT,
+			product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+			stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+			batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
+			unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			base_quantity NUMERIC(20,8) NOT NULL CHECK (base_quantity > 0),
+			unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+			total_cost NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (total_cost >= 0)
+		)`,
+		`CREATE TABLE IF NOT EXISTS supplier_credit_notes (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+			supplier_return_id UUID UNIQUE REFERENCES supplier_returns(id) ON DELETE RESTRICT,
+			amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+			amount_applied NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_applied >= 0),
+			status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'APPLIED')),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CHECK (amount_applied <= amount)
+		)`,
+		`CREATE TABLE IF NOT EXISTS supplier_credit_applications (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			credit_note_id UUID NOT NULL REFERENCES supplier_credit_notes(id) ON DELETE CASCADE,
+			supplier_invoice_id UUID NOT NULL REFERENCES supplier_invoices(id) ON DELETE CASCADE,
+			amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_returns_merchant ON supplier_returns (merchant_id, supplier_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_return_items_return ON supplier_return_items (supplier_return_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_credit_notes_open ON supplier_credit_notes (merchant_id, supplier_id, created_at) WHERE status = 'OPEN'`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_credit_applications_invoice ON supplier_credit_applications (supplier_invoice_id)`,
+		`ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS weight NUMERIC(15,3) CHECK (weight >= 0)`,
+		`ALTER TABLE goods_receipt_items ADD COLUMN IF NOT EXISTS landed_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (landed_cost >= 0)`,
+		`ALTER TABLE inventory_cost_layers ADD COLUMN IF NOT EXISTS goods_receipt_item_id UUID REFERENCES goods_receipt_items(id) ON DELETE SET NULL`,
+		`CREATE TABLE IF NOT EXISTS goods_receipt_costs (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			goods_receipt_id UUID NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+			cost_type VARCHAR(20) NOT NULL CHECK (cost_type IN ('FREIGHT', 'DUTY', 'CUSTOMS', 'INSURANCE', 'OTHER')),
+			description TEXT,
+			amount NUMERIC(15,2) NOT NULL CHECK (amount >= 0),
+			allocation_method VARCHAR(20) NOT NULL DEFAULT 'VALUE' CHECK (allocation_method IN ('VALUE', 'QUANTITY', 'WEIGHT')),
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS goods_receipt_cost_allocations (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			goods_receipt_cost_id UUID NOT NULL REFERENCES goods_receipt_costs(id) ON DELETE CASCADE,
+			goods_receipt_item_id UUID NOT NULL REFERENCES goods_receipt_items(id) ON DELETE CASCADE,
+			amount NUMERIC(15,4) NOT NULL CHECK (amount >= 0),
+			UNIQUE (goods_receipt_cost_id, goods_receipt_item_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS inventory_cost_adjustments (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+			goods_receipt_item_id UUID REFERENCES goods_receipt_items(id) ON DELETE SET NULL,
+			batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
+			reference_type VARCHAR(30) NOT NULL,
+			reference_id UUID,
+			unit_cost_delta NUMERIC(15,4) NOT NULL,
+			revalued_quantity NUMERIC(20,8) NOT NULL DEFAULT 0,
+			inventory_amount NUMERIC(15,2) NOT NULL DEFAULT 0,
+			consumed_quantity NUMERIC(20,8) NOT NULL DEFAULT 0,
+			consumed_amount NUMERIC(15,2) NOT NULL DEFAULT 0,
+			created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_goods_receipt_costs_receipt ON goods_receipt_costs (goods_receipt_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_goods_receipt_cost_allocations_item ON goods_receipt_cost_allocations (goods_receipt_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_cost_layers_receipt_item ON inventory_cost_layers (goods_receipt_item_id) WHERE goods_receipt_item_id IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_inventory_cost_adjustments_item ON inventory_cost_adjustments (merchant_id, inventory_item_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS supplier_products (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE CASCADE,
+			stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+			supplier_sku VARCHAR(100),
+			unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
+			last_cost NUMERIC(15,4) CHECK (last_cost >= 0),
+			min_order_quantity NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (min_order_quantity >= 0),
+			pack_size NUMERIC(15,3) NOT NULL DEFAULT 1 CHECK (pack_size > 0),
+			lead_time_days INTEGER CHECK (lead_time_days >= 0),
+			is_preferred BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (supplier_id, stock_item_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_supplier_products_stock_item ON supplier_products (merchant_id, stock_item_id)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_supplier_products_preferred ON supplier_products (stock_item_id) WHERE is_preferred`,
+		`ALTER TABLE purchase_orders ADD COLUMN IF NOT EXISTS last_sent_at TIMESTAMPTZ`,
+		`ALTER TABLE merchant_purchasing_settings ADD COLUMN IF NOT EXISTS letterhead_name VARCHAR(255)`,
+		`ALTER TABLE merchant_purchasing_settings ADD COLUMN IF NOT EXISTS letterhead_address TEXT`,
+		`ALTER TABLE merchant_purchasing_settings ADD COLUMN IF NOT EXISTS letterhead_phone VARCHAR(50)`,
+		`ALTER TABLE merchant_purchasing_settings ADD COLUMN IF NOT EXISTS letterhead_email VARCHAR(255)`,
+		`ALTER TABLE merchant_purchasing_settings ADD COLUMN IF NOT EXISTS letterhead_tax_id VARCHAR(100)`,
+		`CREATE TABLE IF NOT EXISTS purchase_order_sends (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			purchase_order_id UUID NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+			channel VARCHAR(20) NOT NULL,
+			recipient VARCHAR(255) NOT NULL,
+			status VARCHAR(20) NOT NULL CHECK (status IN ('SENT', 'FAILED')),
+			reference TEXT,
+			error TEXT,
+			document_sha256 CHAR(64) NOT NULL,
+			sent_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_purchase_order_sends_order ON purchase_order_sends (purchase_order_id, sent_at DESC)`,
+		`CREATE TABLE IF NOT EXISTS replenishment_requests (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+			requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			status VARCHAR(20) NOT NULL DEFAULT 'SUBMITTED' CHECK (status IN ('SUBMITTED', 'REJECTED', 'CANCELLED', 'ORDERED', 'TRANSFERRED')),
+			notes TEXT,
+			needed_by DATE,
+			review_note TEXT,
+			reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+			reviewed_at TIMESTAMPTZ,
+			purchase_order_id UUID REFERENCES purchase_orders(id) ON DELETE SET NULL,
+			source_shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS replenishment_request_items (
+			id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+			request_id UUID NOT NULL REFERENCES replenishment_requests(id) ON DELETE CASCADE,
+			stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+			quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+			approved_quantity NUMERIC(15,3) CHECK (approved_quantity >= 0),
+			note TEXT,
+			UNIQUE (request_id, stock_item_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_replenishment_requests_merchant_status ON replenishment_requests (merchant_id, status, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_replenishment_requests_shop ON replenishment_requests (shop_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_sales_client_merchant ON sales (merchant_id, client_sale_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_purchase_orders_merchant_created ON purchase_orders (merchant_id, created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_suppliers_merchant_created ON suppliers (merchant_id, created_at DESC)`,
@@ -214,6 +214,11 @@ func HandleCreateShop(c *fiber.Ctx) error {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "clientOperationId is required"})
 	}
 
+	locationType, err := normalizeLocationType(trimmedString(req.LocationType))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+
 	// Convert empty strings to nil for optional fields
 	var addressVal interface{}
 	if req.Address != nil && *req.Address != "" {
@@ -246,9 +251,9 @@ func HandleCreateShop(c *fiber.Ctx) error {
 		}
 	}
 	query := `
-		INSERT INTO shops (name, merchant_id, address, phone, tax_rate, is_active, is_primary)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, name, merchant_id, address, phone, tax_rate, is_active, is_primary, created_at, updated_at
+		INSERT INTO shops (name, merchant_id, address, phone, tax_rate, is_active, is_primary, location_type)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, name, merchant_id, address, phone, tax_rate, is_active, is_primary, location_type, created_at, updated_at
 	`
 
 	var newShop models.Shop
@@ -258,8 +263,8 @@ func HandleCreateShop(c *fiber.Ctx) error {
 	if req.TaxRate != nil {
 		taxRate = *req.TaxRate
 	}
-	err = tx.QueryRow(ctx, query, req.Name, req.MerchantID, addressVal, phoneVal, taxRate, req.IsActive, req.IsPrimary).Scan(
-		&newShop.ID, &newShop.Name, &newShop.MerchantID, &address, &phone, &newShop.TaxRate, &newShop.IsActive, &newShop.IsPrimary, &newShop.CreatedAt, &newShop.UpdatedAt,
+	err = tx.QueryRow(ctx, query, req.Name, req.MerchantID, addressVal, phoneVal, taxRate, req.IsActive, req.IsPrimary, locationType).Scan(
+		&newShop.ID, &newShop.Name, &newShop.MerchantID, &address, &phone, &newShop.TaxRate, &newShop.IsActive, &newShop.IsPrimary, &newShop.LocationType, &newShop.CreatedAt, &newShop.UpdatedAt,
 	)
 
 	if err != nil {
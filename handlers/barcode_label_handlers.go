@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	defaultEAN13Prefix   = "20"
+	defaultCode128Prefix = "INT"
+	maxLabelsPerRequest  = 1000
+)
+
+var errBarcodeSequenceExhausted = errors.New("no generated barcodes remain for this prefix")
+
+// validateBarcodePrefix checks a generator prefix. EAN-13 prefixes must sit in the
+// GS1 restricted-circulation range 20-29 so internal codes never clash with real GTINs.
+func validateBarcodePrefix(symbology, prefix string) (string, error) {
+	prefix = strings.ToUpper(strings.TrimSpace(prefix))
+	switch symbology {
+	case symbologyEAN13:
+		if prefix == "" {
+			return defaultEAN13Prefix, nil
+		}
+		if len(prefix) != 2 || prefix[0] != '2' || !isDigits(prefix) {
+			return "", fiber.NewError(400, "EAN-13 prefix must be an in-store prefix from 20 to 29")
+		}
+	case symbologyCode128:
+		if prefix == "" {
+			return defaultCode128Prefix, nil
+		}
+		if len(prefix) > 10 {
+			return "", fiber.NewError(400, "Code128 prefix must be at most 10 characters")
+		}
+		if _, err := code128Values(prefix); err != nil {
+			return "", fiber.NewError(400, err.Error())
+		}
+	}
+	return prefix, nil
+}
+
+// generatedBarcode builds the code for the given sequence number: EAN-13 codes are
+// the prefix, a zero-padded sequence and the check digit; Code128 codes are the
+// prefix and an eight-digit sequence.
+func generatedBarcode(symbology, prefix string, sequence int64) (string, error) {
+	if symbology == symbologyCode128 {
+		return fmt.Sprintf("%s%08d", prefix, sequence), nil
+	}
+	body := fmt.Sprintf("%s%0*d", prefix, 12-len(prefix), sequence)
+	if len(body) != 12 {
+		return "", errBarcodeSequenceExhausted
+	}
+	check, err := ean13CheckDigit(body)
+	if err != nil {
+		return "", err
+	}
+	return body + string(check), nil
+}
+
+// HandleGenerateMerchantBarcode issues the next internal barcode for an owner and
+// registers it as generated.
+func HandleGenerateMerchantBarcode(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.BarcodeGenerateRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.OwnerID) == "" {
+		return fiber.NewError(400, "ownerType and ownerId are required")
+	}
+	req.OwnerType = strings.ToUpper(strings.TrimSpace(req.OwnerType))
+	req.Symbology = strings.ToUpper(strings.TrimSpace(req.Symbology))
+	if req.Symbology == "" {
+		req.Symbology = symbologyEAN13
+	}
+	if !barcodeSymbologies[req.Symbology] {
+		return fiber.NewError(400, "symbology must be EAN13 or CODE128")
+	}
+	prefix, err := validateBarcodePrefix(req.Symbology, req.Prefix)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	ok, err := barcodeOwnerExists(ctx, merchantID, req.OwnerType, req.OwnerID)
+	if err != nil {
+		return fiber.NewError(500, "failed to validate barcode owner")
+	}
+	if !ok {
+		return fiber.NewError(400, "barcode owner does not belong to this merchant")
+	}
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start barcode generation")
+	}
+	defer tx.Rollback(ctx)
+	metadata, _ := json.Marshal(map[string]interface{}{"symbology": req.Symbology, "prefix": prefix})
+	var item models.BarcodeRegistryEntry
+	// Codes registered by hand may already use the next number; skip past them.
+	for attempt := 0; ; attempt++ {
+		if attempt == 50 {
+			return fiber.NewError(409, "could not find a free barcode for this prefix")
+		}
+		var sequence int64
+		if err = tx.QueryRow(ctx, `INSERT INTO barcode_sequences(merchant_id,symbology,prefix,last_value) VALUES($1,$2,$3,1) ON CONFLICT (merchant_id,symbology,prefix) DO UPDATE SET last_value=barcode_sequences.last_value+1 RETURNING last_value`, merchantID, req.Symbology, prefix).Scan(&sequence); err != nil {
+			return fiber.NewError(500, "failed to reserve barcode number")
+		}
+		code, genErr := generatedBarcode(req.Symbology, prefix, sequence)
+		if genErr != nil {
+			return fiber.NewError(409, genErr.Error())
+		}
+		var raw []byte
+		err = tx.QueryRow(ctx, `INSERT INTO barcode_registry(merchant_id,code,normalized_code,owner_type,owner_id,is_primary,is_generated,is_active,metadata) VALUES($1,$2,$2,$3,$4,$5,TRUE,TRUE,$6) ON CONFLICT (merchant_id,normalized_code) DO NOTHING RETURNING id,merchant_id,code,normalized_code,owner_type,owner_id,is_primary,is_generated,is_active,metadata,created_at`, merchantID, code, req.OwnerType, req.OwnerID, req.IsPrimary, metadata).Scan(&item.ID, &item.MerchantID, &item.Code, &item.NormalizedCode, &item.OwnerType, &item.OwnerID, &item.IsPrimary, &item.IsGenerated, &item.IsActive, &raw, &item.CreatedAt)
+		if err == pgx.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to register generated barcode")
+		}
+		item.Metadata = map[string]interface{}{}
+		_ = json.Unmarshal(raw, &item.Metadata)
+		break
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit generated barcode")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// barcodeSymbology is the symbology recorded when a code was generated, or the detected one.
+func barcodeSymbology(code string, metadata []byte) string {
+	var meta struct {
+		Symbology string `json:"symbology"`
+	}
+	if len(metadata) > 0 && json.Unmarshal(metadata, &meta) == nil && barcodeSymbologies[meta.Symbology] {
+		return meta.Symbology
+	}
+	return detectSymbology(code)
+}
+
+// HandleRenderMerchantBarcode draws a registered barcode as SVG (default) or PNG.
+func HandleRenderMerchantBarcode(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var code string
+	var metadata []byte
+	err = database.GetDB().QueryRow(context.Background(), `SELECT code,metadata FROM barcode_registry WHERE id=$1 AND merchant_id=$2`, c.Params("barcodeId"), merchantID).Scan(&code, &metadata)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "barcode not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load barcode")
+	}
+	moduleWidth := c.QueryInt("moduleWidth", 2)
+	height := c.QueryInt("height", 80)
+	if moduleWidth < 1 || moduleWidth > 10 || height < 10 || height > 1000 {
+		return fiber.NewError(400, "moduleWidth must be 1-10 and height 10-1000")
+	}
+	modules, err := encodeBarcode(barcodeSymbology(code, metadata), code)
+	if err != nil {
+		return fiber.NewError(422, err.Error())
+	}
+	switch strings.ToLower(c.Query("format", "svg")) {
+	case "svg":
+		c.Set(fiber.HeaderContentType, "image/svg+xml")
+		return c.Send(renderBarcodeSVG(modules, code, moduleWidth, height))
+	case "png":
+		img, err := renderBarcodePNG(modules, moduleWidth, height)
+		if err != nil {
+			return fiber.NewError(500, "failed to render barcode")
+		}
+		c.Set(fiber.HeaderContentType, "image/png")
+		return c.Send(img)
+	}
+	return fiber.NewError(400, "format must be svg or png")
+}
+
+// labelSubjectQuery returns the name and retail price printed for a barcode owner.
+// Variant and shop prices win over product-wide ones.
+func labelSubjectQuery(ownerType string) string {
+	price := func(product, variant string) string {
+		return `(SELECT pp.selling_price::float8 FROM product_prices pp WHERE pp.product_id=` + product + ` AND (pp.variant_id=` + variant + ` OR pp.variant_id IS NULL) AND (pp.shop_id::text=$3 OR pp.shop_id IS NULL) AND pp.price_type='RETAIL' ORDER BY pp.variant_id NULLS LAST,pp.shop_id NULLS LAST,pp.created_at DESC LIMIT 1)`
+	}
+	switch ownerType {
+	case "PRODUCT":
+		return `SELECT p.name,` + price("p.id", "NULL") + ` FROM products p WHERE p.id=$1 AND p.merchant_id=$2`
+	case "VARIANT":
+		return `SELECT p.name||' '||v.name,` + price("v.product_id", "v.id") + ` FROM product_variants v JOIN products p ON p.id=v.product_id WHERE v.id=$1 AND v.merchant_id=$2`
+	case "STOCK_ITEM":
+		return `SELECT si.name,` + price("si.product_id", "si.variant_id") + ` FROM stock_items si WHERE si.id=$1 AND si.merchant_id=$2`
+	case "BATCH":
+		return `SELECT p.name||' #'||b.batch_code,` + price("b.product_id", "NULL") + ` FROM inventory_batches b JOIN products p ON p.id=b.product_id WHERE b.id=$1 AND b.merchant_id=$2`
+	case "ASSET":
+		return `SELECT p.name||' '||a.asset_tag,` + price("ii.product_id", "ii.variant_id") + ` FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id JOIN products p ON p.id=ii.product_id WHERE a.id=$1 AND a.merchant_id=$2`
+	}
+	return ""
+}
+
+// HandleRenderBarcodeLabels renders shelf or price labels for registered barcodes
+// as a PDF (one label per page) or as ZPL for thermal label printers.
+func HandleRenderBarcodeLabels(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.BarcodeLabelRequest
+	if err := c.BodyParser(&req); err != nil || len(req.Items) == 0 {
+		return fiber.NewError(400, "items are required")
+	}
+	format := strings.ToUpper(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = "PDF"
+	}
+	if format != "PDF" && format != "ZPL" {
+		return fiber.NewError(400, "format must be PDF or ZPL")
+	}
+	templateName := strings.ToUpper(strings.TrimSpace(req.Template))
+	if templateName == "" {
+		templateName = "SHELF"
+	}
+	tpl, ok := labelTemplates[templateName]
+	if !ok {
+		return fiber.NewError(400, "template must be SHELF or PRICE")
+	}
+	if req.ShopID != "" {
+		if err := authorizeShopAccess(c, req.ShopID); err != nil {
+			return err
+		}
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	labels := make([]barcodeLabel, 0, len(req.Items))
+	total := 0
+	for _, entry := range req.Items {
+		if entry.Copies == 0 {
+			entry.Copies = 1
+		}
+		if entry.Copies < 0 {
+			return fiber.NewError(400, "copies must be positive")
+		}
+		total += entry.Copies
+		if total > maxLabelsPerRequest {
+			return fiber.NewError(400, fmt.Sprintf("at most %d labels can be printed at once", maxLabelsPerRequest))
+		}
+		var label barcodeLabel
+		var ownerType, ownerID string
+		var metadata []byte
+		err = db.QueryRow(ctx, `SELECT code,owner_type,owner_id,metadata FROM barcode_registry WHERE id=$1 AND merchant_id=$2 AND is_active`, entry.BarcodeID, merchantID).Scan(&label.Code, &ownerType, &ownerID, &metadata)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(404, "barcode "+entry.BarcodeID+" not found")
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to load barcode")
+		}
+		label.Symbology = barcodeSymbology(label.Code, metadata)
+		label.Copies = entry.Copies
+		if query := labelSubjectQuery(ownerType); query != "" {
+			err = db.QueryRow(ctx, query, ownerID, merchantID, req.ShopID).Scan(&label.Name, &label.Price)
+		} else {
+			err = db.QueryRow(ctx, `SELECT name FROM unit_definitions WHERE id=$1`, ownerID).Scan(&label.Name)
+		}
+		if err != nil && err != pgx.ErrNoRows {
+			return fiber.NewError(500, "failed to load label details")
+		}
+		labels = append(labels, label)
+	}
+	if format == "ZPL" {
+		out, err := renderLabelsZPL(labels, tpl)
+		if err != nil {
+			return fiber.NewError(422, err.Error())
+		}
+		c.Set(fiber.HeaderContentType, "text/plain; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="labels.zpl"`)
+		return c.SendString(out)
+	}
+	out, err := renderLabelsPDF(labels, tpl)
+	if err != nil {
+		return fiber.NewError(422, err.Error())
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="labels.pdf"`)
+	return c.Send(out)
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"app/utils"
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// barcodeQuietZone is the blank margin, in modules, kept on each side of the bars.
+const barcodeQuietZone = 11
+
+// barRun is a bar of consecutive dark modules starting at module Start.
+type barRun struct {
+	Start, Width int
+}
+
+func barRuns(modules []bool) []barRun {
+	var runs []barRun
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < len(modules) && modules[j] {
+			j++
+		}
+		runs = append(runs, barRun{Start: i, Width: j - i})
+		i = j
+	}
+	return runs
+}
+
+// renderBarcodeSVG draws the bars with the human-readable code underneath.
+func renderBarcodeSVG(modules []bool, text string, moduleWidth, height int) []byte {
+	width := (len(modules) + 2*barcodeQuietZone) * moduleWidth
+	fontSize, textHeight := 6*moduleWidth, 0
+	if text != "" {
+		textHeight = fontSize + 4
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, width, height+textHeight, width, height+textHeight)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, width, height+textHeight)
+	for _, run := range barRuns(modules) {
+		fmt.Fprintf(&b, `<rect x="%d" y="0" width="%d" height="%d" fill="#000"/>`, (run.Start+barcodeQuietZone)*moduleWidth, run.Width*moduleWidth, height)
+	}
+	if text != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" font-family="monospace" font-size="%d" text-anchor="middle">%s</text>`, width/2, height+textHeight-2, fontSize, xmlEscape(text))
+	}
+	b.WriteString(`</svg>`)
+	return b.Bytes()
+}
+
+// renderBarcodePNG draws the bars as a greyscale PNG.
+func renderBarcodePNG(modules []bool, moduleWidth, height int) ([]byte, error) {
+	width := (len(modules) + 2*barcodeQuietZone) * moduleWidth
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	for _, run := range barRuns(modules) {
+		for x := (run.Start + barcodeQuietZone) * moduleWidth; x < (run.Start+run.Width+barcodeQuietZone)*moduleWidth; x++ {
+			for y := 0; y < height; y++ {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			}
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func xmlEscape(value string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(value)
+}
+
+// barcodeLabel is one item to print, repeated Copies times.
+type barcodeLabel struct {
+	Name      string
+	Price     *float64
+	Code      string
+	Symbology string
+	Copies    int
+}
+
+// labelTemplate sizes a label; font sizes are in points.
+type labelTemplate struct {
+	WidthMM, HeightMM, BarHeightMM float64
+	NameSize, PriceSize            float64
+}
+
+var labelTemplates = map[string]labelTemplate{
+	"SHELF": {WidthMM: 70, HeightMM: 35, BarHeightMM: 12, NameSize: 9, PriceSize: 14},
+	"PRICE": {WidthMM: 50, HeightMM: 30, BarHeightMM: 10, NameSize: 7, PriceSize: 12},
+}
+
+const (
+	pointsPerMM   = 72 / 25.4
+	labelMarginMM = 2.0
+	labelCodeSize = 6.0
+)
+
+// renderLabelsPDF lays out one label per page, each page sized to the template.
+func renderLabelsPDF(labels []barcodeLabel, tpl labelTemplate) ([]byte, error) {
+	pageW, pageH := tpl.WidthMM*pointsPerMM, tpl.HeightMM*pointsPerMM
+	margin := labelMarginMM * pointsPerMM
+	var pages []string
+	for _, label := range labels {
+		modules, err := encodeBarcode(label.Symbology, label.Code)
+		if err != nil {
+			return nil, err
+		}
+		var s strings.Builder
+		nameY := pageH - margin - tpl.NameSize
+		fmt.Fprintf(&s, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", tpl.NameSize, margin, nameY, pdfText(fitLabelText(label.Name, pageW-2*margin, tpl.NameSize)))
+		if label.Price != nil {
+			fmt.Fprintf(&s, "BT /F2 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", tpl.PriceSize, margin, nameY-2-tpl.PriceSize, pdfText(utils.FormatCurrency(*label.Price)))
+		}
+		quiet := float64(2 * barcodeQuietZone)
+		moduleW := (pageW - 2*margin) / (float64(len(modules)) + quiet)
+		barBottom := margin + labelCodeSize + 1
+		barH := tpl.BarHeightMM * pointsPerMM
+		for _, run := range barRuns(modules) {
+			fmt.Fprintf(&s, "%.3f %.2f %.3f %.2f re f\n", margin+(float64(run.Start)+quiet/2)*moduleW, barBottom, float64(run.Width)*moduleW, barH)
+		}
+		fmt.Fprintf(&s, "BT /F1 %.1f Tf %.2f %.2f Td (%s) Tj ET\n", labelCodeSize, margin+quiet/2*moduleW, margin, pdfText(label.Code))
+		for i := 0; i < label.Copies; i++ {
+			pages = append(pages, s.String())
+		}
+	}
+	return buildPDF(pages, pageW, pageH), nil
+}
+
+// buildPDF writes a minimal PDF with one content stream per page and the two
+// standard Helvetica faces as /F1 and /F2.
+func buildPDF(pages []string, width, height float64) []byte {
+	var b bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	b.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", width, height, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return b.Bytes()
+}
+
+// pdfText escapes a string for a PDF literal. The standard fonts only cover
+// Latin text, so other characters print as '?'.
+func pdfText(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 32 || r > 126:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// fitLabelText trims text to roughly fit width points at the given font size.
+func fitLabelText(value string, width, size float64) string {
+	max := int(width / (size * 0.55))
+	runes := []rune(value)
+	if max < 4 || len(runes) <= max {
+		return value
+	}
+	return string(runes[:max-3]) + "..."
+}
+
+// ZPL label dimensions assume a 203 dpi printer.
+const zplDotsPerMM = 8
+
+// renderLabelsZPL emits one ZPL format per label, printing Copies of each with ^PQ.
+// The printer draws the symbol itself, including the check digits.
+func renderLabelsZPL(labels []barcodeLabel, tpl labelTemplate) (string, error) {
+	width, height := int(tpl.WidthMM*zplDotsPerMM), int(tpl.HeightMM*zplDotsPerMM)
+	margin := int(labelMarginMM * zplDotsPerMM)
+	nameDots, priceDots := int(tpl.NameSize*zplDotsPerMM*25.4/72), int(tpl.PriceSize*zplDotsPerMM*25.4/72)
+	barDots := int(tpl.BarHeightMM * zplDotsPerMM)
+	var b strings.Builder
+	for _, label := range labels {
+		modules, err := encodeBarcode(label.Symbology, label.Code)
+		if err != nil {
+			return "", err
+		}
+		moduleDots := 2
+		if (len(modules)+2*barcodeQuietZone)*moduleDots > width-2*margin {
+			moduleDots = 1
+		}
+		fmt.Fprintf(&b, "^XA^CI28^PW%d^LL%d\n", width, height)
+		fmt.Fprintf(&b, "^FO%d,%d^A0N,%d,%d^FB%d,1,0,L^FD%s^FS\n", margin, margin, nameDots, nameDots, width-2*margin, zplText(label.Name))
+		if label.Price != nil {
+			fmt.Fprintf(&b, "^FO%d,%d^A0N,%d,%d^FD%s^FS\n", margin, margin+nameDots+4, priceDots, priceDots, zplText(utils.FormatCurrency(*label.Price)))
+		}
+		barTop := height - margin - barDots - nameDots
+		fmt.Fprintf(&b, "^FO%d,%d^BY%d", margin+barcodeQuietZone*moduleDots, barTop, moduleDots)
+		if detectedOr(label.Symbology, label.Code) == symbologyEAN13 {
+			fmt.Fprintf(&b, "^BEN,%d,Y,N^FD%s^FS\n", barDots, label.Code[:12])
+		} else {
+			fmt.Fprintf(&b, "^BCN,%d,Y,N,N^FD%s^FS\n", barDots, zplText(label.Code))
+		}
+		fmt.Fprintf(&b, "^PQ%d^XZ\n", label.Copies)
+	}
+	return b.String(), nil
+}
+
+func detectedOr(symbology, code string) string {
+	if symbology != "" {
+		return symbology
+	}
+	return detectSymbology(code)
+}
+
+// zplText drops the ZPL command prefixes so field data cannot start a new command.
+func zplText(value string) string {
+	return strings.NewReplacer("^", " ", "~", " ").Replace(value)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	symbologyEAN13   = "EAN13"
+	symbologyCode128 = "CODE128"
+)
+
+var barcodeSymbologies = map[string]bool{symbologyEAN13: true, symbologyCode128: true}
+
+var (
+	errEAN13Digits     = errors.New("EAN-13 codes must be 12 or 13 digits")
+	errEAN13CheckDigit = errors.New("EAN-13 check digit is incorrect")
+	errCode128Chars    = errors.New("Code128 codes may only contain printable ASCII characters")
+)
+
+// ean13CheckDigit computes the GS1 mod-10 check digit for the first 12 digits of an EAN-13.
+func ean13CheckDigit(digits string) (byte, error) {
+	if len(digits) != 12 || !isDigits(digits) {
+		return 0, errEAN13Digits
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+// normalizeEAN13 accepts 12 digits (and appends the check digit) or 13 digits (and verifies it).
+func normalizeEAN13(code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if (len(code) != 12 && len(code) != 13) || !isDigits(code) {
+		return "", errEAN13Digits
+	}
+	check, err := ean13CheckDigit(code[:12])
+	if err != nil {
+		return "", err
+	}
+	if len(code) == 13 && code[12] != check {
+		return "", errEAN13CheckDigit
+	}
+	return code[:12] + string(check), nil
+}
+
+// detectSymbology picks EAN-13 for valid 13-digit codes and Code128 for everything else.
+func detectSymbology(code string) string {
+	if len(code) == 13 {
+		if _, err := normalizeEAN13(code); err == nil {
+			return symbologyEAN13
+		}
+	}
+	return symbologyCode128
+}
+
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// EAN-13 digit patterns: L (odd parity) and G (even parity) on the left half, R on the right.
+var (
+	ean13L      = []string{"0001101", "0011001", "0010011", "0111101", "0100011", "0110001", "0101111", "0111011", "0110111", "0001011"}
+	ean13G      = []string{"0100111", "0110011", "0011011", "0100001", "0011101", "0111001", "0000101", "0010001", "0001001", "0010111"}
+	ean13R      = []string{"1110010", "1100110", "1101100", "1000010", "1011100", "1001110", "1010000", "1000100", "1001000", "1110100"}
+	ean13Parity = []string{"LLLLLL", "LLGLGG", "LLGGLG", "LLGGGL", "LGLLGG", "LGGLLG", "LGGGLL", "LGLGLG", "LGLGGL", "LGGLGL"}
+)
+
+// encodeEAN13 returns the 95 modules of an EAN-13 symbol, true for a bar.
+func encodeEAN13(code string) ([]bool, error) {
+	code, err := normalizeEAN13(code)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("101")
+	parity := ean13Parity[code[0]-'0']
+	for i := 1; i <= 6; i++ {
+		d := code[i] - '0'
+		if parity[i-1] == 'L' {
+			b.WriteString(ean13L[d])
+		} else {
+			b.WriteString(ean13G[d])
+		}
+	}
+	b.WriteString("01010")
+	for i := 7; i <= 12; i++ {
+		b.WriteString(ean13R[code[i]-'0'])
+	}
+	b.WriteString("101")
+	return modulesFromPattern(b.String()), nil
+}
+
+// code128Widths are the bar/space widths of Code128 symbol values 0-106 (106 is the stop).
+var code128Widths = []string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128Stop   = 106
+)
+
+// code128Values encodes text in code set B and appends the mod-103 check symbol.
+func code128Values(text string) ([]int, error) {
+	if text == "" {
+		return nil, errCode128Chars
+	}
+	values := []int{code128StartB}
+	sum := code128StartB
+	for i, r := range text {
+		if r < 32 || r > 126 {
+			return nil, errCode128Chars
+		}
+		v := int(r) - 32
+		values = append(values, v)
+		sum += v * (i + 1)
+	}
+	return append(values, sum%103, code128Stop), nil
+}
+
+// encodeCode128 returns the modules of a Code128 symbol, true for a bar.
+func encodeCode128(text string) ([]bool, error) {
+	values, err := code128Values(text)
+	if err != nil {
+		return nil, err
+	}
+	var modules []bool
+	for _, v := range values {
+		for i, w := range code128Widths[v] {
+			for n := 0; n < int(w-'0'); n++ {
+				modules = append(modules, i%2 == 0)
+			}
+		}
+	}
+	return modules, nil
+}
+
+// encodeBarcode renders a code in the given symbology, or the detected one when empty.
+func encodeBarcode(symbology, code string) ([]bool, error) {
+	if symbology == "" {
+		symbology = detectSymbology(code)
+	}
+	switch symbology {
+	case symbologyEAN13:
+		return encodeEAN13(code)
+	case symbologyCode128:
+		return encodeCode128(code)
+	}
+	return nil, fmt.Errorf("unsupported symbology %q", symbology)
+}
+
+func modulesFromPattern(pattern string) []bool {
+	modules := make([]bool, len(pattern))
+	for i := range pattern {
+		modules[i] = pattern[i] == '1'
+	}
+	return modules
+}
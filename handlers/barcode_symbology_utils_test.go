@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEAN13CheckDigit(t *testing.T) {
+	cases := map[string]byte{"400638133393": '1', "590123412345": '7', "200000000001": '5'}
+	for digits, want := range cases {
+		got, err := ean13CheckDigit(digits)
+		if err != nil || got != want {
+			t.Fatalf("%s: expected %c, got %c (%v)", digits, want, got, err)
+		}
+	}
+	if _, err := normalizeEAN13("4006381333932"); err != errEAN13CheckDigit {
+		t.Fatalf("expected check digit error, got %v", err)
+	}
+	if code, err := normalizeEAN13("400638133393"); err != nil || code != "4006381333931" {
+		t.Fatalf("expected check digit appended, got %s (%v)", code, err)
+	}
+}
+
+func TestEncodeEAN13(t *testing.T) {
+	modules, err := encodeEAN13("4006381333931")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modules) != 95 {
+		t.Fatalf("expected 95 modules, got %d", len(modules))
+	}
+	var b strings.Builder
+	for _, m := range modules[:10] {
+		if m {
+			b.WriteByte('1')
+		} else {
+			b.WriteByte('0')
+		}
+	}
+	// Start guard, then digit 0 in L parity (the leading 4 selects LGLLGG).
+	if b.String() != "1010001101" {
+		t.Fatalf("unexpected leading modules %s", b.String())
+	}
+}
+
+func TestCode128(t *testing.T) {
+	for value, widths := range code128Widths {
+		sum := 0
+		for _, w := range widths {
+			sum += int(w - '0')
+		}
+		if (value == code128Stop && sum != 13) || (value != code128Stop && sum != 11) {
+			t.Fatalf("symbol %d has %d modules", value, sum)
+		}
+	}
+	values, err := code128Values("PJJ123C")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Start B + 7 characters + check + stop; check symbol for PJJ123C is 55.
+	if len(values) != 10 || values[8] != 55 {
+		t.Fatalf("unexpected values %v", values)
+	}
+	modules, _ := encodeCode128("PJJ123C")
+	if len(modules) != 11*9+13 {
+		t.Fatalf("expected %d modules, got %d", 11*9+13, len(modules))
+	}
+	if _, err := code128Values("café"); err != errCode128Chars {
+		t.Fatalf("expected character error, got %v", err)
+	}
+}
+
+func TestGeneratedBarcode(t *testing.T) {
+	code, err := generatedBarcode(symbologyEAN13, "20", 1)
+	if err != nil || code != "2000000000015" {
+		t.Fatalf("unexpected EAN-13 %s (%v)", code, err)
+	}
+	if detectSymbology(code) != symbologyEAN13 {
+		t.Fatalf("expected generated code to detect as EAN13")
+	}
+	if _, err := generatedBarcode(symbologyEAN13, "20", 10000000000); err != errBarcodeSequenceExhausted {
+		t.Fatalf("expected exhausted sequence, got %v", err)
+	}
+	if code, _ := generatedBarcode(symbologyCode128, "INT", 42); code != "INT00000042" {
+		t.Fatalf("unexpected Code128 %s", code)
+	}
+	if _, err := validateBarcodePrefix(symbologyEAN13, "40"); err == nil {
+		t.Fatalf("expected GTIN prefix to be rejected")
+	}
+}
+
+func TestRenderLabels(t *testing.T) {
+	price := 1500.0
+	labels := []barcodeLabel{{Name: "Tea (Green)", Price: &price, Code: "2000000000015", Copies: 2}, {Name: "Bolt", Code: "INT00000042", Copies: 1}}
+	pdf, err := renderLabelsPDF(labels, labelTemplates["SHELF"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.Contains(pdf, []byte("/Count 3")) || !bytes.Contains(pdf, []byte(`Tea \(Green\)`)) {
+		t.Fatalf("unexpected PDF output")
+	}
+	zpl, err := renderLabelsZPL(labels, labelTemplates["PRICE"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(zpl, "^BEN,80,Y,N^FD200000000001^FS") || !strings.Contains(zpl, "^BCN,80,Y,N,N^FDINT00000042^FS") || !strings.Contains(zpl, "^PQ2^XZ") {
+		t.Fatalf("unexpected ZPL output:\n%s", zpl)
+	}
+}
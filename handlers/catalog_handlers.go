@@ -38,6 +38,14 @@ func nullableString(value string) *string {
 	return &value
 }
 
+// trimmedString dereferences an optional request field, treating nil as empty.
+func trimmedString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return strings.TrimSpace(*value)
+}
+
 // validateCatalogParent enforces same-merchant ownership and prevents cycles
 // when categories are moved in the unlimited hierarchy.
 func validateCatalogParent(ctx context.Context, q inventoryOperationQuerier, merchantID, parentID, movingID string) error {
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 )
 
 // DBRow is a minimal wrapper to support Scan in tests and real pgx rows.
@@ -11,6 +12,11 @@ type DBRow interface {
 	Scan(dest ...interface{}) error
 }
 
+// rowQuerier runs multi-row queries; it is satisfied by the pool and by a transaction.
+type rowQuerier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
 // DBTx defines the minimal methods used by the offline sync logic.
 type DBTx interface {
 	QueryRow(ctx context.Context, sql string, args ...interface{}) DBRow
@@ -40,3 +46,20 @@ func (p pgxTxAdapter) Exec(ctx context.Context, sql string, args ...interface{})
 	}
 	return ct.RowsAffected(), nil
 }
+
+// pgxPoolAdapter adapts a connection pool to DBTx for lookups made outside a transaction.
+type pgxPoolAdapter struct {
+	pool *pgxpool.Pool
+}
+
+func (p pgxPoolAdapter) QueryRow(ctx context.Context, sql string, args ...interface{}) DBRow {
+	return pgxRowAdapter{row: p.pool.QueryRow(ctx, sql, args...)}
+}
+
+func (p pgxPoolAdapter) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	ct, err := p.pool.Exec(ctx, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+	return ct.RowsAffected(), nil
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	errAssetStatusInvalid   = errors.New("status must be AVAILABLE, RESERVED, SOLD, RETURNED or INACTIVE")
+	errAssetStatusUnchanged = errors.New("asset already has this status")
+	errAssetTransition      = errors.New("asset cannot move between these statuses")
+	errAssetRentalOnly      = errors.New("rented assets change status only through rental checkout and return")
+	errAssetNotFound        = errors.New("inventory asset not found")
+	errWarrantyDateOrder    = errors.New("warrantyEndsOn must not be before warrantyStartsOn")
+	errRentalCondition      = errors.New("condition must be AVAILABLE or INACTIVE")
+	errAssetSaleQuantity    = errors.New("a line selling an asset must sell exactly one unit")
+)
+
+// assetTransitions lists the statuses an asset may move to from each status.
+// RENTED is entered and left only by the rental flow.
+var assetTransitions = map[string][]string{
+	"AVAILABLE": {"RESERVED", "SOLD", "INACTIVE", "RENTED"},
+	"RESERVED":  {"AVAILABLE", "SOLD", "INACTIVE"},
+	"SOLD":      {"RETURNED"},
+	"RETURNED":  {"AVAILABLE", "SOLD", "INACTIVE", "RENTED"},
+	"INACTIVE":  {"AVAILABLE"},
+	"RENTED":    {"AVAILABLE", "INACTIVE"},
+}
+
+func isAssetError(err error) bool {
+	return errors.Is(err, errAssetStatusInvalid) || errors.Is(err, errAssetStatusUnchanged) || errors.Is(err, errAssetTransition) ||
+		errors.Is(err, errAssetRentalOnly) || errors.Is(err, errWarrantyDateOrder) || errors.Is(err, errRentalCondition) ||
+		errors.Is(err, errAssetSaleQuantity)
+}
+
+// validateAssetTransition checks a status change; rental is true for rental checkout and return.
+func validateAssetTransition(from, to string, rental bool) error {
+	if _, ok := assetTransitions[to]; !ok {
+		return errAssetStatusInvalid
+	}
+	if from == to {
+		return errAssetStatusUnchanged
+	}
+	if (from == "RENTED" || to == "RENTED") != rental {
+		return errAssetRentalOnly
+	}
+	for _, next := range assetTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return errAssetTransition
+}
+
+// assetTransition describes one status change and what caused it.
+type assetTransition struct {
+	AssetID       string
+	MerchantID    string
+	ToStatus      string
+	EventType     string
+	ReferenceType string
+	ReferenceID   string
+	ActorID       string
+	Notes         string
+	// Optional warranty dates used when the asset is sold.
+	WarrantyStartsOn *time.Time
+	WarrantyEndsOn   *time.Time
+}
+
+// transitionInventoryAsset locks the asset, applies the status change and logs it.
+// Selling starts the warranty and taking a sold asset back voids it. It returns the
+// asset's shop and previous status.
+func transitionInventoryAsset(ctx context.Context, tx DBTx, t assetTransition) (shopID, fromStatus string, err error) {
+	if err := tx.QueryRow(ctx, `SELECT shop_id,status FROM inventory_assets WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, t.AssetID, t.MerchantID).Scan(&shopID, &fromStatus); err != nil {
+		if isNoRows(err) {
+			return "", "", errAssetNotFound
+		}
+		return "", "", err
+	}
+	rental := t.EventType == "RENTED" || t.EventType == "RENTAL_RETURNED"
+	if err := validateAssetTransition(fromStatus, t.ToStatus, rental); err != nil {
+		return shopID, fromStatus, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE inventory_assets SET status=$1 WHERE id=$2`, t.ToStatus, t.AssetID); err != nil {
+		return shopID, fromStatus, err
+	}
+	if err := recordAssetEvent(ctx, tx, t.AssetID, t.EventType, fromStatus, t.ToStatus, shopID, t.ReferenceType, t.ReferenceID, t.ActorID, t.Notes); err != nil {
+		return shopID, fromStatus, err
+	}
+	switch {
+	case t.ToStatus == "SOLD":
+		saleID := ""
+		if t.ReferenceType == "SALE" {
+			saleID = t.ReferenceID
+		}
+		err = startWarranty(ctx, tx, "", t.AssetID, saleID, t.WarrantyStartsOn, t.WarrantyEndsOn)
+	case fromStatus == "SOLD":
+		_, err = tx.Exec(ctx, `UPDATE inventory_warranties SET status='VOID' WHERE asset_id=$1 AND status='ACTIVE'`, t.AssetID)
+	}
+	return shopID, fromStatus, err
+}
+
+// sellInventoryAsset marks the asset sold on a checkout line SOLD against the sale, which
+// starts its warranty. The asset must be one unit of the line's shop balance.
+func sellInventoryAsset(ctx context.Context, tx DBTx, assetID, merchantID, inventoryItemID, saleID, actorID string, quantity float64) error {
+	if assetID == "" {
+		return nil
+	}
+	if quantity != 1 {
+		return errAssetSaleQuantity
+	}
+	var onBalance bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM inventory_assets WHERE id::text=$1 AND inventory_item_id=$2)`, assetID, inventoryItemID).Scan(&onBalance); err != nil {
+		return err
+	}
+	if !onBalance {
+		return errAssetNotFound
+	}
+	_, _, err := transitionInventoryAsset(ctx, tx, assetTransition{AssetID: assetID, MerchantID: merchantID, ToStatus: "SOLD", EventType: "SOLD", ReferenceType: "SALE", ReferenceID: saleID, ActorID: actorID})
+	return err
+}
+
+// recordAssetEvent appends an entry to an asset's lifecycle history.
+func recordAssetEvent(ctx context.Context, tx DBTx, assetID, eventType, fromStatus, toStatus, shopID, referenceType, referenceID, actorID, notes string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO inventory_asset_events(asset_id,event_type,from_status,to_status,shop_id,reference_type,reference_id,actor_id,notes) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9)`, assetID, eventType, nullableString(fromStatus), toStatus, nullableString(shopID), nullableString(referenceType), nullableString(referenceID), nullableString(actorID), nullableString(notes))
+	return err
+}
+
+// startWarranty records warranty cover for a sold serial or asset. Explicit dates win;
+// otherwise cover runs from the sale date for the stock item's warranty_months, and
+// items without a warranty period get no row.
+func startWarranty(ctx context.Context, tx DBTx, serialID, assetID, saleID string, startsOn, endsOn *time.Time) error {
+	if startsOn != nil && endsOn != nil && endsOn.Before(*startsOn) {
+		return errWarrantyDateOrder
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO inventory_warranties(merchant_id,stock_item_id,serial_id,asset_id,sale_id,customer_id,starts_on,ends_on)
+		SELECT u.merchant_id,u.stock_item_id,$1::uuid,$2::uuid,sa.id,sa.customer_id,w.starts_on,
+			COALESCE($5::date,(w.starts_on+make_interval(months=>cfg.warranty_months))::date)
+		FROM (SELECT s.merchant_id,s.stock_item_id FROM inventory_serials s WHERE s.id=$1::uuid
+			UNION ALL
+			SELECT a.merchant_id,ii.stock_item_id FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id WHERE a.id=$2::uuid) u
+		LEFT JOIN stock_item_configurations cfg ON cfg.stock_item_id=u.stock_item_id
+		LEFT JOIN sales sa ON sa.id=$3::uuid
+		CROSS JOIN LATERAL (SELECT COALESCE($4::date,sa.sale_date::date,CURRENT_DATE) AS starts_on) w
+		WHERE $5::date IS NOT NULL OR cfg.warranty_months>0`, nullableString(serialID), nullableString(assetID), nullableString(saleID), startsOn, endsOn)
+	return err
+}
+
+// warrantyCoverage reports whether a warranty covers today.
+func warrantyCoverage(status string, startsOn, endsOn, today time.Time) string {
+	switch {
+	case status == "VOID":
+		return "VOID"
+	case today.Before(startsOn):
+		return "NOT_STARTED"
+	case today.After(endsOn):
+		return "EXPIRED"
+	}
+	return "ACTIVE"
+}
+
+// rentalReturnStatus is the status a returned rental asset goes back to.
+func rentalReturnStatus(condition *string) (string, error) {
+	if condition == nil || strings.TrimSpace(*condition) == "" {
+		return "AVAILABLE", nil
+	}
+	switch status := strings.ToUpper(strings.TrimSpace(*condition)); status {
+	case "AVAILABLE", "INACTIVE":
+		return status, nil
+	}
+	return "", errRentalCondition
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidateAssetTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		rental   bool
+		want     error
+	}{
+		{"AVAILABLE", "SOLD", false, nil},
+		{"SOLD", "RETURNED", false, nil},
+		{"SOLD", "AVAILABLE", false, errAssetTransition},
+		{"AVAILABLE", "AVAILABLE", false, errAssetStatusUnchanged},
+		{"AVAILABLE", "LOST", false, errAssetStatusInvalid},
+		{"AVAILABLE", "RENTED", false, errAssetRentalOnly},
+		{"AVAILABLE", "RENTED", true, nil},
+		{"RENTED", "SOLD", true, errAssetTransition},
+		{"RENTED", "INACTIVE", false, errAssetRentalOnly},
+		{"RENTED", "INACTIVE", true, nil},
+	}
+	for _, tc := range cases {
+		if got := validateAssetTransition(tc.from, tc.to, tc.rental); got != tc.want {
+			t.Fatalf("%s -> %s (rental %v): expected %v, got %v", tc.from, tc.to, tc.rental, tc.want, got)
+		}
+	}
+}
+
+func TestWarrantyCoverage(t *testing.T) {
+	starts := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	ends := starts.AddDate(1, 0, 0)
+	cases := map[string]struct {
+		status string
+		today  time.Time
+	}{
+		"ACTIVE":      {"ACTIVE", ends},
+		"NOT_STARTED": {"ACTIVE", starts.AddDate(0, 0, -1)},
+		"EXPIRED":     {"ACTIVE", ends.AddDate(0, 0, 1)},
+		"VOID":        {"VOID", starts},
+	}
+	for want, tc := range cases {
+		if got := warrantyCoverage(tc.status, starts, ends, tc.today); got != want {
+			t.Fatalf("expected %s, got %s", want, got)
+		}
+	}
+}
+
+func TestRentalReturnStatus(t *testing.T) {
+	damaged := " inactive "
+	if status, err := rentalReturnStatus(&damaged); err != nil || status != "INACTIVE" {
+		t.Fatalf("expected INACTIVE, got %s (%v)", status, err)
+	}
+	if status, _ := rentalReturnStatus(nil); status != "AVAILABLE" {
+		t.Fatalf("expected AVAILABLE by default, got %s", status)
+	}
+	sold := "SOLD"
+	if _, err := rentalReturnStatus(&sold); err != errRentalCondition {
+		t.Fatalf("expected condition error, got %v", err)
+	}
+}
+
+func TestSellInventoryAssetChecksLine(t *testing.T) {
+	ctx := context.Background()
+	if err := sellInventoryAsset(ctx, nil, "", "merchant", "item", "sale", "actor", 3); err != nil {
+		t.Fatalf("expected lines without an asset to pass, got %v", err)
+	}
+	if err := sellInventoryAsset(ctx, nil, "asset", "merchant", "item", "sale", "actor", 2); err != errAssetSaleQuantity {
+		t.Fatalf("expected quantity error, got %v", err)
+	}
+}
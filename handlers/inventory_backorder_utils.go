@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Negative-stock policies, set per shop and optionally overridden per stock item.
+const (
+	stockPolicyBlock         = "BLOCK"
+	stockPolicyWarn          = "WARN"
+	stockPolicyAllowNegative = "ALLOW_NEGATIVE"
+)
+
+var (
+	errInsufficientStock  = errors.New("insufficient stock")
+	errStockPolicyInvalid = errors.New("negativeStockPolicy must be BLOCK, WARN or ALLOW_NEGATIVE")
+)
+
+func validStockPolicy(policy string) bool {
+	return policy == stockPolicyBlock || policy == stockPolicyWarn || policy == stockPolicyAllowNegative
+}
+
+// roundBaseQuantity trims float noise to the 8 decimals base quantities are stored with.
+func roundBaseQuantity(value float64) float64 {
+	return math.Round(value*1e8) / 1e8
+}
+
+// planStockDeduction decides whether quantity can be sold from a balance with available
+// units free. Under WARN and ALLOW_NEGATIVE the shortfall is sold ahead of receipts and
+// returned as the backordered quantity; under BLOCK it is refused.
+func planStockDeduction(policy string, available, quantity float64) (float64, error) {
+	if quantity <= available {
+		return 0, nil
+	}
+	if policy != stockPolicyWarn && policy != stockPolicyAllowNegative {
+		return 0, errInsufficientStock
+	}
+	return roundBaseQuantity(quantity - math.Max(available, 0)), nil
+}
+
+// stockDeduction is the outcome of taking sold stock off a balance.
+type stockDeduction struct {
+	Policy      string
+	Backordered float64
+}
+
+// warning is the cashier-facing message for a line sold short under the WARN policy.
+func (d stockDeduction) warning(label string) string {
+	if d.Policy != stockPolicyWarn || d.Backordered <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s units sold beyond available stock and backordered", label, strconv.FormatFloat(d.Backordered, 'f', -1, 64))
+}
+
+// batched is the part of a deducted quantity drawn from stock on hand. The backordered
+// shortfall has no batch to come from until it is received.
+func (d stockDeduction) batched(quantity float64) float64 {
+	return roundBaseQuantity(math.Max(quantity-d.Backordered, 0))
+}
+
+// deductSaleStock takes quantity (in base units) off a balance under the shop's or stock
+// item's negative-stock policy and records any shortfall as a backorder against the
+// sale. It returns errInsufficientStock when the policy blocks the sale.
+func deductSaleStock(ctx context.Context, tx DBTx, inventoryItemID string, quantity float64, referenceType, referenceID string) (stockDeduction, error) {
+	var d stockDeduction
+	var available float64
+	var merchantID, shopID, stockItemID string
+	var pickBinID *string
+	err := tx.QueryRow(ctx, `SELECT ii.merchant_id,ii.shop_id,ii.stock_item_id,ii.pick_bin_id::text,`+unreservedStockSQL("ii")+`::float8,COALESCE(cfg.negative_stock_policy,s.negative_stock_policy)
+		FROM inventory_items ii JOIN shops s ON s.id=ii.shop_id LEFT JOIN stock_item_configurations cfg ON cfg.stock_item_id=ii.stock_item_id
+		WHERE ii.id=$1 FOR UPDATE OF ii`, inventoryItemID).Scan(&merchantID, &shopID, &stockItemID, &pickBinID, &available, &d.Policy)
+	if isNoRows(err) {
+		return d, errInsufficientStock
+	}
+	if err != nil {
+		return d, err
+	}
+	if d.Backordered, err = planStockDeduction(d.Policy, available, quantity); err != nil {
+		return d, err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2`, quantity, inventoryItemID); err != nil {
+		return d, err
+	}
+	// Sold stock is picked from the balance's pick bin.
+	if err := adjustBinBalance(ctx, tx, inventoryItemID, pickBinID, -quantity); err != nil {
+		return d, err
+	}
+	if d.Backordered > 0 {
+		_, err = tx.Exec(ctx, `INSERT INTO inventory_backorders(merchant_id,shop_id,inventory_item_id,stock_item_id,reference_type,reference_id,quantity) VALUES($1,$2,$3,$4,$5,$6,$7)`, merchantID, shopID, inventoryItemID, stockItemID, referenceType, nullableString(referenceID), d.Backordered)
+	}
+	return d, err
+}
+
+// backorderCoverage is how much of the outstanding backorders a balance now covers: the
+// part of them no longer reflected in a shortfall of available stock.
+func backorderCoverage(outstanding, available float64) float64 {
+	covered := outstanding - math.Max(-available, 0)
+	return roundBaseQuantity(math.Max(math.Min(covered, outstanding), 0))
+}
+
+// allocateBackorderSettlement spreads covered units over open backorders oldest first.
+func allocateBackorderSettlement(outstanding []float64, covered float64) []float64 {
+	settled := make([]float64, len(outstanding))
+	for i, open := range outstanding {
+		if covered <= 0 {
+			break
+		}
+		settled[i] = roundBaseQuantity(math.Min(open, covered))
+		covered = roundBaseQuantity(covered - settled[i])
+	}
+	return settled
+}
+
+// settleBackorders marks open backorders on a balance settled as far as received stock
+// now covers them, and tells the merchant. Call it after stock is added to the balance.
+func settleBackorders(ctx context.Context, tx DBTx, inventoryItemID string) error {
+	var raw string
+	var available float64
+	var merchantID, itemName string
+	err := tx.QueryRow(ctx, `SELECT ii.merchant_id,si.name,`+unreservedStockSQL("ii")+`::float8,
+		COALESCE((SELECT json_agg(json_build_array(b.id,(b.quantity-b.settled_quantity)::float8) ORDER BY b.created_at,b.id) FROM inventory_backorders b WHERE b.inventory_item_id=ii.id AND b.status='OPEN'),'[]'::json)::text
+		FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id WHERE ii.id=$1`, inventoryItemID).Scan(&merchantID, &itemName, &available, &raw)
+	if err != nil {
+		return err
+	}
+	var open [][2]interface{}
+	if err := json.Unmarshal([]byte(raw), &open); err != nil || len(open) == 0 {
+		return err
+	}
+	ids := make([]string, len(open))
+	outstanding := make([]float64, len(open))
+	var total float64
+	for i, row := range open {
+		ids[i], _ = row[0].(string)
+		outstanding[i], _ = row[1].(float64)
+		total += outstanding[i]
+	}
+	covered := backorderCoverage(roundBaseQuantity(total), available)
+	if covered <= 0 {
+		return nil
+	}
+	for i, settle := range allocateBackorderSettlement(outstanding, covered) {
+		if settle <= 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `UPDATE inventory_backorders SET settled_quantity=settled_quantity+$1,
+			status=CASE WHEN settled_quantity+$1>=quantity THEN 'SETTLED' ELSE status END,
+			settled_at=CASE WHEN settled_quantity+$1>=quantity THEN NOW() ELSE settled_at END WHERE id=$2`, settle, ids[i]); err != nil {
+			return err
+		}
+	}
+	remaining := roundBaseQuantity(total - covered)
+	message := fmt.Sprintf("Received stock settled %s backordered units of %s.", strconv.FormatFloat(covered, 'f', -1, 64), itemName)
+	if remaining > 0 {
+		message += fmt.Sprintf(" %s units are still backordered.", strconv.FormatFloat(remaining, 'f', -1, 64))
+	}
+	return createNotification(ctx, tx, merchantID, "Backorders settled for "+itemName, message, "INVENTORY_BACKORDER", "INVENTORY_ITEM", inventoryItemID)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanStockDeduction(t *testing.T) {
+	cases := []struct {
+		policy              string
+		available, quantity float64
+		want                float64
+		wantErr             error
+	}{
+		{stockPolicyBlock, 5, 5, 0, nil},
+		{stockPolicyBlock, 5, 6, 0, errInsufficientStock},
+		{stockPolicyWarn, 5, 8, 3, nil},
+		{stockPolicyAllowNegative, 0, 2.5, 2.5, nil},
+		// A balance already short only backorders the new quantity.
+		{stockPolicyAllowNegative, -4, 1, 1, nil},
+		{"", 1, 2, 0, errInsufficientStock},
+	}
+	for _, tc := range cases {
+		got, err := planStockDeduction(tc.policy, tc.available, tc.quantity)
+		if got != tc.want || err != tc.wantErr {
+			t.Fatalf("%s available %v quantity %v: expected %v/%v, got %v/%v", tc.policy, tc.available, tc.quantity, tc.want, tc.wantErr, got, err)
+		}
+	}
+}
+
+func TestStockDeductionWarning(t *testing.T) {
+	if got := (stockDeduction{Policy: stockPolicyAllowNegative, Backordered: 2}).warning("Rice"); got != "" {
+		t.Fatalf("expected no warning under ALLOW_NEGATIVE, got %q", got)
+	}
+	want := "Rice: 1.5 units sold beyond available stock and backordered"
+	if got := (stockDeduction{Policy: stockPolicyWarn, Backordered: 1.5}).warning("Rice"); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestBackorderCoverage(t *testing.T) {
+	cases := []struct{ outstanding, available, want float64 }{
+		{5, -5, 0},
+		{5, -2, 3},
+		{5, 0, 5},
+		{5, 10, 5},
+	}
+	for _, tc := range cases {
+		if got := backorderCoverage(tc.outstanding, tc.available); got != tc.want {
+			t.Fatalf("outstanding %v available %v: expected %v, got %v", tc.outstanding, tc.available, tc.want, got)
+		}
+	}
+}
+
+func TestAllocateBackorderSettlement(t *testing.T) {
+	got := allocateBackorderSettlement([]float64{2, 3, 4}, 4)
+	if want := []float64{2, 2, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestStockDeductionBatched(t *testing.T) {
+	cases := []struct{ backordered, quantity, want float64 }{
+		{0, 5, 5},
+		{2, 5, 3},
+		{5, 5, 0},
+	}
+	for _, tc := range cases {
+		d := stockDeduction{Policy: stockPolicyWarn, Backordered: tc.backordered}
+		if got := d.batched(tc.quantity); got != tc.want {
+			t.Fatalf("backordered %v of %v: expected %v batched, got %v", tc.backordered, tc.quantity, tc.want, got)
+		}
+	}
+}
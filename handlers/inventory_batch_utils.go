@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	errBatchNotFound          = errors.New("batch not found for this item")
+	errBatchExpired           = errors.New("batch is expired and cannot be consumed")
+	errInsufficientBatchStock = errors.New("insufficient unexpired batch stock")
+	errBatchNotTracked        = errors.New("batch selection is only allowed for batch-tracked items")
+	errBatchCodeRequired      = errors.New("batchCode is required for batch-tracked items")
+	errExpiryDateInvalid      = errors.New("expiryDate must be a date in YYYY-MM-DD format")
+)
+
+// batchCandidate is a batch that can supply stock for a consuming movement.
+type batchCandidate struct {
+	ID                string  `json:"id"`
+	BatchCode         string  `json:"batch_code"`
+	QuantityRemaining float64 `json:"quantity_remaining"`
+	UnitCost          float64 `json:"unit_cost"`
+	ManufactureDate   *string `json:"manufacture_date"`
+	ExpiryDate        *string `json:"expiry_date"`
+}
+
+// batchAllocation is the quantity taken from one batch by a consuming movement.
+type batchAllocation struct {
+	BatchID         string  `json:"batchId"`
+	BatchCode       string  `json:"batchCode"`
+	Quantity        float64 `json:"quantity"`
+	UnitCost        float64 `json:"unitCost"`
+	ManufactureDate *string `json:"manufactureDate,omitempty"`
+	ExpiryDate      *string `json:"expiryDate,omitempty"`
+}
+
+func (b batchCandidate) expiredOn(today time.Time) bool {
+	if b.ExpiryDate == nil {
+		return false
+	}
+	expiry, err := time.Parse("2006-01-02", *b.ExpiryDate)
+	if err != nil {
+		return false
+	}
+	return expiry.Before(time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+// planBatchAllocation picks batches first-expiry-first-out, or the explicitly requested batch.
+// Expired batches are never consumed; batches without an expiry date are used last.
+func planBatchAllocation(candidates []batchCandidate, quantity float64, batchID string, today time.Time) ([]batchAllocation, error) {
+	if batchID != "" {
+		for _, candidate := range candidates {
+			if candidate.ID != batchID {
+				continue
+			}
+			if candidate.expiredOn(today) {
+				return nil, errBatchExpired
+			}
+			if candidate.QuantityRemaining < quantity {
+				return nil, errInsufficientBatchStock
+			}
+			return []batchAllocation{candidate.allocate(quantity)}, nil
+		}
+		return nil, errBatchNotFound
+	}
+
+	ordered := append([]batchCandidate(nil), candidates...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].ExpiryDate, ordered[j].ExpiryDate
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return *a < *b
+	})
+	var allocations []batchAllocation
+	remaining := quantity
+	for _, candidate := range ordered {
+		if remaining <= 0 {
+			break
+		}
+		if candidate.QuantityRemaining <= 0 || candidate.expiredOn(today) {
+			continue
+		}
+		take := candidate.QuantityRemaining
+		if take > remaining {
+			take = remaining
+		}
+		allocations = append(allocations, candidate.allocate(take))
+		remaining -= take
+	}
+	if remaining > 0.0005 {
+		return nil, errInsufficientBatchStock
+	}
+	return allocations, nil
+}
+
+func (b batchCandidate) allocate(quantity float64) batchAllocation {
+	return batchAllocation{BatchID: b.ID, BatchCode: b.BatchCode, Quantity: quantity, UnitCost: b.UnitCost, ManufactureDate: b.ManufactureDate, ExpiryDate: b.ExpiryDate}
+}
+
+// stockItemTracksBatches reports whether consuming movements for the stock item must draw from batches.
+func stockItemTracksBatches(ctx context.Context, tx DBTx, stockItemID string) (bool, error) {
+	var tracked bool
+	err := tx.QueryRow(ctx, `SELECT COALESCE((SELECT track_batches FROM stock_item_configurations WHERE stock_item_id=$1),FALSE) OR EXISTS(SELECT 1 FROM stock_items WHERE id=$1 AND tracking_mode='BATCH')`, stockItemID).Scan(&tracked)
+	return tracked, err
+}
+
+// consumeInventoryBatches deducts quantity from the batches of an inventory balance.
+// It returns no allocations for items that do not track batches or for a zero quantity,
+// as when a sale line is wholly backordered.
+func consumeInventoryBatches(ctx context.Context, tx DBTx, stockItemID, inventoryItemID string, quantity float64, batchID string) ([]batchAllocation, error) {
+	tracked, err := stockItemTracksBatches(ctx, tx, stockItemID)
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		if batchID != "" {
+			return nil, errBatchNotTracked
+		}
+		return nil, nil
+	}
+	if quantity <= 0 {
+		return nil, nil
+	}
+	var raw string
+	if err = tx.QueryRow(ctx, `SELECT COALESCE(json_agg(b ORDER BY b.expiry_date NULLS LAST, b.created_at),'[]'::json)::text FROM (SELECT id,batch_code,quantity_remaining,unit_cost,manufacture_date,expiry_date,created_at FROM inventory_batches WHERE inventory_item_id=$1 AND quantity_remaining > 0 FOR UPDATE) b`, inventoryItemID).Scan(&raw); err != nil {
+		return nil, err
+	}
+	var candidates []batchCandidate
+	if err = json.Unmarshal([]byte(raw), &candidates); err != nil {
+		return nil, err
+	}
+	allocations, err := planBatchAllocation(candidates, quantity, batchID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	for _, allocation := range allocations {
+		updated, err := tx.Exec(ctx, `UPDATE inventory_batches SET quantity_remaining=quantity_remaining-$1 WHERE id=$2 AND quantity_remaining >= $1`, allocation.Quantity, allocation.BatchID)
+		if err != nil {
+			return nil, err
+		}
+		if updated != 1 {
+			return nil, errInsufficientBatchStock
+		}
+	}
+	return allocations, nil
+}
+
+// receiveTransferredBatches mirrors consumed batches into the destination balance of a transfer.
+func receiveTransferredBatches(ctx context.Context, tx DBTx, merchantID, shopID, inventoryItemID, productID, stockItemID string, allocations []batchAllocation) error {
+	for _, allocation := range allocations {
+		if _, err := tx.Exec(ctx, `INSERT INTO inventory_batches(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,batch_code,quantity_received,quantity_remaining,unit_cost,manufacture_date,expiry_date) VALUES($1,$2,$3,$4,$5,$6,$7,$7,$8,$9::date,$10::date) ON CONFLICT (shop_id,stock_item_id,batch_code) DO UPDATE SET quantity_received=inventory_batches.quantity_received+EXCLUDED.quantity_received,quantity_remaining=inventory_batches.quantity_remaining+EXCLUDED.quantity_remaining`, merchantID, shopID, inventoryItemID, productID, stockItemID, allocation.BatchCode, allocation.Quantity, allocation.UnitCost, allocation.ManufactureDate, allocation.ExpiryDate); err != nil {
+			return fmt.Errorf("could not receive batch %s: %w", allocation.BatchCode, err)
+		}
+	}
+	return nil
+}
+
+// validateExpiryDate checks an optional YYYY-MM-DD expiry date.
+func validateExpiryDate(expiryDate *string) error {
+	if expiryDate == nil {
+		return nil
+	}
+	if _, err := time.Parse("2006-01-02", *expiryDate); err != nil {
+		return errExpiryDateInvalid
+	}
+	return nil
+}
+
+// receiveInventoryBatch books received stock into a batch of the balance, adding to the
+// batch when the code was received before. A topped-up batch is costed at the
+// quantity-weighted average of the units it still holds and the new ones. It returns no
+// batch for items that do not track batches.
+func receiveInventoryBatch(ctx context.Context, tx DBTx, merchantID, shopID, inventoryItemID, productID, stockItemID, batchCode string, quantity, unitCost float64, expiryDate *string) (string, error) {
+	tracked, err := stockItemTracksBatches(ctx, tx, stockItemID)
+	if err != nil || !tracked {
+		return "", err
+	}
+	batchCode = strings.TrimSpace(batchCode)
+	if batchCode == "" {
+		return "", errBatchCodeRequired
+	}
+	if err = validateExpiryDate(expiryDate); err != nil {
+		return "", err
+	}
+	var batchID string
+	err = tx.QueryRow(ctx, `INSERT INTO inventory_batches(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,batch_code,quantity_received,quantity_remaining,unit_cost,expiry_date) VALUES($1,$2,$3,$4,$5,$6,$7,$7,$8,$9::date) ON CONFLICT (shop_id,stock_item_id,batch_code) DO UPDATE SET quantity_received=inventory_batches.quantity_received+EXCLUDED.quantity_received,quantity_remaining=inventory_batches.quantity_remaining+EXCLUDED.quantity_remaining,unit_cost=COALESCE((GREATEST(inventory_batches.quantity_remaining,0)*inventory_batches.unit_cost+EXCLUDED.quantity_remaining*EXCLUDED.unit_cost)/NULLIF(GREATEST(inventory_batches.quantity_remaining,0)+EXCLUDED.quantity_remaining,0),EXCLUDED.unit_cost),expiry_date=COALESCE(EXCLUDED.expiry_date,inventory_batches.expiry_date) RETURNING id`, merchantID, shopID, inventoryItemID, productID, stockItemID, batchCode, quantity, unitCost, expiryDate).Scan(&batchID)
+	return batchID, err
+}
+
+// recordSaleItemBatches links a sale line to the batches it consumed.
+func recordSaleItemBatches(ctx context.Context, tx DBTx, saleItemID string, allocations []batchAllocation) error {
+	return recordSaleItemComponentBatches(ctx, tx, saleItemID, "", allocations)
+}
+
+// recordSaleItemComponentBatches links a kit component of a sale line to the batches it consumed.
+func recordSaleItemComponentBatches(ctx context.Context, tx DBTx, saleItemID, componentID string, allocations []batchAllocation) error {
+	for _, allocation := range allocations {
+		if _, err := tx.Exec(ctx, `INSERT INTO sale_item_batches(sale_item_id,batch_id,quantity,unit_cost,sale_item_component_id) VALUES($1,$2,$3,$4,$5)`, saleItemID, allocation.BatchID, allocation.Quantity, allocation.UnitCost, nullableString(componentID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBatchConsumptionError reports whether err is a client-facing batch selection failure.
+func isBatchConsumptionError(err error) bool {
+	return errors.Is(err, errBatchNotFound) || errors.Is(err, errBatchExpired) || errors.Is(err, errInsufficientBatchStock) || errors.Is(err, errBatchNotTracked)
+}
+
+// isBatchReceiptError reports whether err is a client-facing batch receipt failure.
+func isBatchReceiptError(err error) bool {
+	return errors.Is(err, errBatchCodeRequired) || errors.Is(err, errExpiryDateInvalid)
+}
+
+// selectedBatchID normalizes an optional client batch selection.
+func selectedBatchID(batchID *string) string {
+	if batchID == nil {
+		return ""
+	}
+	return strings.TrimSpace(*batchID)
+}
+
+// saleBatchLine is a consumed batch line that can still accept returned quantity.
+type saleBatchLine struct {
+	ID       string  `json:"id"`
+	BatchID  string  `json:"batch_id"`
+	Open     float64 `json:"open"`
+	Returned float64 `json:"-"`
+}
+
+// planBatchReturn spreads a returned quantity over consumed batch lines, most recent line first.
+func planBatchReturn(lines []saleBatchLine, quantity float64) []saleBatchLine {
+	var planned []saleBatchLine
+	remaining := quantity
+	for i := len(lines) - 1; i >= 0 && remaining > 0; i-- {
+		if lines[i].Open <= 0 {
+			continue
+		}
+		take := lines[i].Open
+		if take > remaining {
+			take = remaining
+		}
+		line := lines[i]
+		line.Returned = take
+		planned = append(planned, line)
+		remaining -= take
+	}
+	return planned
+}
+
+// restoreSaleItemBatches puts returned stock back into the batches a sale item consumed.
+func restoreSaleItemBatches(ctx context.Context, tx DBTx, saleItemID string, quantity float64) error {
+	return restoreBatchLines(ctx, tx, `SELECT COALESCE(json_agg(l ORDER BY l.created_at, l.id),'[]'::json)::text FROM (SELECT id,batch_id,quantity-quantity_returned AS open,created_at FROM sale_item_batches WHERE sale_item_id=$1 AND sale_item_component_id IS NULL FOR UPDATE) l`, saleItemID, quantity)
+}
+
+// restoreSaleItemComponentBatches puts returned kit component stock back into the batches it consumed.
+func restoreSaleItemComponentBatches(ctx context.Context, tx DBTx, componentID string, quantity float64) error {
+	return restoreBatchLines(ctx, tx, `SELECT COALESCE(json_agg(l ORDER BY l.created_at, l.id),'[]'::json)::text FROM (SELECT id,batch_id,quantity-quantity_returned AS open,created_at FROM sale_item_batches WHERE sale_item_component_id=$1 FOR UPDATE) l`, componentID, quantity)
+}
+
+func restoreBatchLines(ctx context.Context, tx DBTx, linesQuery, ownerID string, quantity float64) error {
+	var raw string
+	if err := tx.QueryRow(ctx, linesQuery, ownerID).Scan(&raw); err != nil {
+		return err
+	}
+	var lines []saleBatchLine
+	if err := json.Unmarshal([]byte(raw), &lines); err != nil {
+		return err
+	}
+	for _, line := range planBatchReturn(lines, quantity) {
+		if _, err := tx.Exec(ctx, `UPDATE sale_item_batches SET quantity_returned=quantity_returned+$1 WHERE id=$2`, line.Returned, line.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE inventory_batches SET quantity_remaining=quantity_remaining+$1 WHERE id=$2`, line.Returned, line.BatchID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func batchDate(value string) *string { return &value }
+
+func TestPlanBatchAllocationUsesEarliestExpiryFirst(t *testing.T) {
+	today := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	candidates := []batchCandidate{
+		{ID: "no-expiry", QuantityRemaining: 10},
+		{ID: "late", QuantityRemaining: 5, ExpiryDate: batchDate("2026-06-01")},
+		{ID: "expired", QuantityRemaining: 5, ExpiryDate: batchDate("2026-02-28")},
+		{ID: "early", QuantityRemaining: 3, ExpiryDate: batchDate("2026-03-01")},
+	}
+	allocations, err := planBatchAllocation(candidates, 10, "", today)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []struct {
+		id  string
+		qty float64
+	}{{"early", 3}, {"late", 5}, {"no-expiry", 2}}
+	if len(allocations) != len(want) {
+		t.Fatalf("expected %d allocations, got %+v", len(want), allocations)
+	}
+	for i, w := range want {
+		if allocations[i].BatchID != w.id || allocations[i].Quantity != w.qty {
+			t.Fatalf("allocation %d: expected %s x%v, got %+v", i, w.id, w.qty, allocations[i])
+		}
+	}
+}
+
+func TestPlanBatchAllocationRefusesExpiredStock(t *testing.T) {
+	today := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []batchCandidate{{ID: "expired", QuantityRemaining: 5, ExpiryDate: batchDate("2026-02-01")}}
+	if _, err := planBatchAllocation(candidates, 1, "", today); err != errInsufficientBatchStock {
+		t.Fatalf("expected insufficient stock, got %v", err)
+	}
+	if _, err := planBatchAllocation(candidates, 1, "expired", today); err != errBatchExpired {
+		t.Fatalf("expected expired batch error, got %v", err)
+	}
+	if _, err := planBatchAllocation(candidates, 1, "missing", today); err != errBatchNotFound {
+		t.Fatalf("expected batch not found, got %v", err)
+	}
+}
+
+func TestPlanBatchReturnRestoresMostRecentLinesFirst(t *testing.T) {
+	lines := []saleBatchLine{{ID: "a", Open: 2}, {ID: "b", Open: 3}}
+	planned := planBatchReturn(lines, 4)
+	if len(planned) != 2 || planned[0].ID != "b" || planned[0].Returned != 3 || planned[1].ID != "a" || planned[1].Returned != 1 {
+		t.Fatalf("unexpected return plan: %+v", planned)
+	}
+}
+
+func TestValidateExpiryDate(t *testing.T) {
+	valid, invalid := "2027-03-31", "31/03/2027"
+	if err := validateExpiryDate(nil); err != nil {
+		t.Fatalf("expected no expiry to be valid, got %v", err)
+	}
+	if err := validateExpiryDate(&valid); err != nil {
+		t.Fatalf("expected %s to be valid, got %v", valid, err)
+	}
+	if err := validateExpiryDate(&invalid); err != errExpiryDateInvalid {
+		t.Fatalf("expected errExpiryDateInvalid, got %v", err)
+	}
+}
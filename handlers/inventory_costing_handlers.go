@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseValuationAsOf reads the asOf query value. A plain date values stock at the end of that day.
+func parseValuationAsOf(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fiber.NewError(400, "asOf must be a date (YYYY-MM-DD) or an RFC3339 timestamp")
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// HandleGetCostingMethod returns the merchant's inventory costing method.
+func HandleGetCostingMethod(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var method string
+	if err = database.GetDB().QueryRow(context.Background(), costingMethodQuery, merchantID).Scan(&method); err != nil {
+		return fiber.NewError(500, "failed to load costing method")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"costingMethod": method}})
+}
+
+// HandleUpdateCostingMethod switches the merchant between weighted average and FIFO.
+// Cost layers are kept under both methods, so the switch applies from the next issue.
+func HandleUpdateCostingMethod(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.CostingMethodRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	method := strings.ToUpper(strings.TrimSpace(req.CostingMethod))
+	if !costingMethods[method] {
+		return fiber.NewError(400, "costingMethod must be WEIGHTED_AVERAGE or FIFO")
+	}
+	if _, err = database.GetDB().Exec(context.Background(), `INSERT INTO merchant_costing_settings(merchant_id,costing_method) VALUES($1,$2) ON CONFLICT (merchant_id) DO UPDATE SET costing_method=EXCLUDED.costing_method,updated_at=NOW()`, merchantID, method); err != nil {
+		return fiber.NewError(500, "failed to update costing method")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"costingMethod": method}})
+}
+
+// HandleGetInventoryValuation values a shop's stock as of a point in time by replaying
+// its movements at the per-unit cost each one was recorded with, plus the landed-cost
+// revaluations of stock on hand made up to then.
+func HandleGetInventoryValuation(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	asOf, err := parseValuationAsOf(c.Query("asOf"), time.Now())
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	db := database.GetDB()
+	var merchantID string
+	if err = db.QueryRow(ctx, `SELECT merchant_id FROM shops WHERE id=$1`, shopID).Scan(&merchantID); err != nil {
+		return fiber.NewError(404, "shop not found")
+	}
+	var method string
+	if err = db.QueryRow(ctx, costingMethodQuery, merchantID).Scan(&method); err != nil {
+		return fiber.NewError(500, "failed to load costing method")
+	}
+	rows, err := db.Query(ctx, `SELECT ii.id,ii.stock_item_id,si.name,si.sku,COALESCE(v.qty,0)::float8,(COALESCE(v.value,0)+adj.value)::float8,ru.factor,ru.code
+		FROM inventory_items ii
+		JOIN stock_items si ON si.id=ii.stock_item_id
+		JOIN products p ON p.id=ii.product_id
+		JOIN LATERAL (SELECT SUM(x.sign*x.qty) AS qty, SUM(x.sign*x.qty*COALESCE(x.unit_cost,p.cost_price,0)) AS value FROM (
+				SELECT `+ledgerSignSQL+` AS sign, COALESCE(m.base_quantity,m.quantity) AS qty, m.unit_cost
+				FROM inventory_movements m WHERE m.inventory_item_id=ii.id AND m.movement_date <= $2
+			) x) v ON TRUE
+		JOIN LATERAL (SELECT COALESCE(SUM(a.inventory_amount),0) AS value FROM inventory_cost_adjustments a
+			WHERE a.inventory_item_id=ii.id AND a.created_at <= $2) adj ON TRUE`+reportUnitJoin("ii.stock_item_id")+`
+		WHERE ii.shop_id=$1 AND (v.qty <> 0 OR COALESCE(v.value,0)+adj.value <> 0)
+		ORDER BY si.name, ii.id`, shopID, asOf)
+	if err != nil {
+		return fiber.NewError(500, "failed to compute inventory valuation")
+	}
+	defer rows.Close()
+	lines := make([]models.InventoryValuationLine, 0)
+	var total float64
+	for rows.Next() {
+		var line models.InventoryValuationLine
+		var factor *float64
+		if err := rows.Scan(&line.InventoryItemID, &line.StockItemID, &line.Name, &line.SKU, &line.Quantity, &line.Value, &factor, &line.ReportUnit); err != nil {
+			return fiber.NewError(500, "failed to read inventory valuation")
+		}
+		line.Quantity = math.Round(line.Quantity*1000) / 1000
+		line.Value = math.Round(line.Value*100) / 100
+		line.ReportQuantity = line.Quantity
+		if factor != nil {
+			line.ReportQuantity = reportQuantity(line.Quantity, *factor)
+		}
+		if line.Quantity > 0 {
+			unitCost := roundCost(line.Value / line.Quantity)
+			line.UnitCost = &unitCost
+		}
+		total += line.Value
+		lines = append(lines, line)
+	}
+	if err = rows.Err(); err != nil {
+		return fiber.NewError(500, "failed to read inventory valuation")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"shopId": shopID, "asOf": asOf, "costingMethod": method, "totalValue": math.Round(total*100) / 100, "items": lines}})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+)
+
+const (
+	costingWeightedAverage = "WEIGHTED_AVERAGE"
+	costingFIFO            = "FIFO"
+)
+
+var costingMethods = map[string]bool{costingWeightedAverage: true, costingFIFO: true}
+
+// costLayer is an open receipt of stock that FIFO issues draw from, oldest first.
+type costLayer struct {
+	ID                string  `json:"id"`
+	QuantityRemaining float64 `json:"quantity_remaining"`
+	UnitCost          float64 `json:"unit_cost"`
+}
+
+// planLayerConsumption takes quantity from layers in order. It returns the quantity
+// taken from each layer, the cost of what was taken and the quantity no layer covered.
+func planLayerConsumption(layers []costLayer, quantity float64) ([]float64, float64, float64) {
+	taken := make([]float64, len(layers))
+	var cost float64
+	remaining := quantity
+	for i, layer := range layers {
+		if remaining <= 0 {
+			break
+		}
+		take := math.Min(layer.QuantityRemaining, remaining)
+		if take <= 0 {
+			continue
+		}
+		taken[i] = take
+		cost += take * layer.UnitCost
+		remaining -= take
+	}
+	if remaining < 0.0005 {
+		remaining = 0
+	}
+	return taken, cost, remaining
+}
+
+// movingAverageCost blends a receipt into the average cost of the stock already on hand.
+func movingAverageCost(onHandBefore, averageBefore, quantityIn, unitCostIn float64) float64 {
+	if onHandBefore < 0 {
+		onHandBefore = 0
+	}
+	total := onHandBefore + quantityIn
+	if total <= 0 {
+		return roundCost(unitCostIn)
+	}
+	return roundCost((onHandBefore*averageBefore + quantityIn*unitCostIn) / total)
+}
+
+func roundCost(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+// costingMethodQuery selects a merchant's costing method, weighted average by default.
+const costingMethodQuery = `SELECT COALESCE((SELECT costing_method FROM merchant_costing_settings WHERE merchant_id=$1),'WEIGHTED_AVERAGE')`
+
+// merchantCostingMethod returns the merchant's costing method.
+func merchantCostingMethod(ctx context.Context, tx DBTx, merchantID string) (string, error) {
+	var method string
+	err := tx.QueryRow(ctx, costingMethodQuery, merchantID).Scan(&method)
+	return method, err
+}
+
+// inventoryAverageCost is the balance's moving average, falling back to the product cost price.
+func inventoryAverageCost(ctx context.Context, tx DBTx, inventoryItemID string) (float64, error) {
+	var cost float64
+	err := tx.QueryRow(ctx, `SELECT COALESCE(ii.average_cost,p.cost_price,0)::float8 FROM inventory_items ii JOIN products p ON p.id=ii.product_id WHERE ii.id=$1`, inventoryItemID).Scan(&cost)
+	return cost, err
+}
+
+// receiveInventoryCost opens a FIFO layer for stock added to a balance and moves its
+// average cost. Call it after quantity_on_hand has been increased. A nil unitCost
+// receives the stock at the current average. It returns the unit cost applied.
+func receiveInventoryCost(ctx context.Context, tx DBTx, inventoryItemID string, quantity float64, unitCost *float64) (float64, error) {
+	return receiveInventoryCostLayer(ctx, tx, inventoryItemID, quantity, unitCost, "")
+}
+
+// receiveInventoryCostLayer is receiveInventoryCost for stock coming in on a goods
+// receipt line, which the layer remembers so landed costs can revalue it later.
+func receiveInventoryCostLayer(ctx context.Context, tx DBTx, inventoryItemID string, quantity float64, unitCost *float64, goodsReceiptItemID string) (float64, error) {
+	average, err := inventoryAverageCost(ctx, tx, inventoryItemID)
+	if err != nil {
+		return 0, err
+	}
+	cost := average
+	if unitCost != nil {
+		cost = roundCost(*unitCost)
+	}
+	if quantity <= 0 {
+		return cost, nil
+	}
+	var onHand float64
+	if err = tx.QueryRow(ctx, `SELECT quantity_on_hand::float8 FROM inventory_items WHERE id=$1`, inventoryItemID).Scan(&onHand); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET average_cost=$1 WHERE id=$2`, movingAverageCost(onHand-quantity, average, quantity, cost), inventoryItemID); err != nil {
+		return 0, err
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO inventory_cost_layers(inventory_item_id,quantity_received,quantity_remaining,unit_cost,goods_receipt_item_id) VALUES($1,$2,$2,$3,$4)`, inventoryItemID, quantity, cost, nullableString(goodsReceiptItemID)); err != nil {
+		return 0, err
+	}
+	return cost, nil
+}
+
+// issueInventoryCost consumes FIFO layers for stock leaving a balance and returns
+// the unit cost of the issue under the merchant's costing method. Under FIFO,
+// batch allocations price the issue when the item tracks batches.
+func issueInventoryCost(ctx context.Context, tx DBTx, merchantID, inventoryItemID string, quantity float64, batches []batchAllocation) (float64, error) {
+	method, err := merchantCostingMethod(ctx, tx, merchantID)
+	if err != nil {
+		return 0, err
+	}
+	average, err := inventoryAverageCost(ctx, tx, inventoryItemID)
+	if err != nil {
+		return 0, err
+	}
+	var raw string
+	if err = tx.QueryRow(ctx, `SELECT COALESCE(json_agg(l ORDER BY l.received_at,l.id),'[]'::json)::text FROM (SELECT id,quantity_remaining,unit_cost,received_at FROM inventory_cost_layers WHERE inventory_item_id=$1 AND quantity_remaining > 0 ORDER BY received_at,id FOR UPDATE) l`, inventoryItemID).Scan(&raw); err != nil {
+		return 0, err
+	}
+	var layers []costLayer
+	if err = json.Unmarshal([]byte(raw), &layers); err != nil {
+		return 0, err
+	}
+	taken, layerCost, uncovered := planLayerConsumption(layers, quantity)
+	for i, take := range taken {
+		if take <= 0 {
+			continue
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_cost_layers SET quantity_remaining=GREATEST(quantity_remaining-$1,0) WHERE id=$2`, take, layers[i].ID); err != nil {
+			return 0, err
+		}
+	}
+	if method != costingFIFO || quantity <= 0 {
+		return average, nil
+	}
+	if len(batches) > 0 {
+		var batchQty, batchCost float64
+		for _, batch := range batches {
+			batchQty += batch.Quantity
+			batchCost += batch.Quantity * batch.UnitCost
+		}
+		if batchQty > 0 {
+			return roundCost(batchCost / batchQty), nil
+		}
+	}
+	return roundCost((layerCost + uncovered*average) / quantity), nil
+}
+
+// saleLineCost is the cost of goods sold for baseQuantity issued at unitCost.
+func saleLineCost(baseQuantity, unitCost float64) float64 {
+	return math.Round(baseQuantity*unitCost*100) / 100
+}
+
+// recordSaleItemCost stores the cost of a sale line written before its stock was issued,
+// such as a kit line whose cost is only known once its components are sold.
+func recordSaleItemCost(ctx context.Context, tx DBTx, saleItemID string, baseQuantity, costTotal float64) error {
+	var unitCost float64
+	if baseQuantity > 0 {
+		unitCost = roundCost(costTotal / baseQuantity)
+	}
+	_, err := tx.Exec(ctx, `UPDATE sale_items SET unit_cost=$1,cost_total=$2 WHERE id=$3`, unitCost, costTotal, saleItemID)
+	return err
+}
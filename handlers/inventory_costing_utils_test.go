@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanLayerConsumption(t *testing.T) {
+	layers := []costLayer{{ID: "a", QuantityRemaining: 4, UnitCost: 2}, {ID: "b", QuantityRemaining: 0, UnitCost: 9}, {ID: "c", QuantityRemaining: 10, UnitCost: 3}}
+	taken, cost, uncovered := planLayerConsumption(layers, 6)
+	if taken[0] != 4 || taken[1] != 0 || taken[2] != 2 {
+		t.Fatalf("expected oldest layers to be consumed first, got %v", taken)
+	}
+	if cost != 14 || uncovered != 0 {
+		t.Fatalf("expected cost 14 with nothing uncovered, got %v and %v", cost, uncovered)
+	}
+	if _, cost, uncovered = planLayerConsumption(layers, 20); cost != 38 || uncovered != 6 {
+		t.Fatalf("expected cost 38 with 6 uncovered, got %v and %v", cost, uncovered)
+	}
+}
+
+func TestMovingAverageCost(t *testing.T) {
+	if got := movingAverageCost(10, 2, 10, 4); got != 3 {
+		t.Fatalf("expected average of 3, got %v", got)
+	}
+	if got := movingAverageCost(-2, 5, 4, 1.5); got != 1.5 {
+		t.Fatalf("expected a negative prior balance to be ignored, got %v", got)
+	}
+	if got := saleLineCost(3, 1.23456); got != 3.7 {
+		t.Fatalf("expected line cost rounded to cents, got %v", got)
+	}
+}
+
+func TestParseValuationAsOf(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if got, err := parseValuationAsOf("", now); err != nil || !got.Equal(now) {
+		t.Fatalf("expected now for an empty value, got %v %v", got, err)
+	}
+	got, err := parseValuationAsOf("2026-02-10", now)
+	if err != nil || got.Day() != 10 || got.Hour() != 23 {
+		t.Fatalf("expected the end of the given day, got %v %v", got, err)
+	}
+	if _, err = parseValuationAsOf("10/02/2026", now); err == nil {
+		t.Fatal("expected an invalid date to be rejected")
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"app/config"
+	"app/database"
+	"app/middleware"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// expiredAlertStage is the alert stage recorded once a batch is past its expiry date.
+const expiredAlertStage = -1
+
+var defaultExpiryAlertLeadDays = []int{30, 7, 1}
+
+var expiryWriteOffReasonCodes = map[string]bool{"EXPIRED": true, "DAMAGED": true, "RECALLED": true, "DISPOSED": true, "DONATED": true}
+
+type expiringBatch struct {
+	ID                string     `json:"batchId"`
+	ShopID            string     `json:"shopId"`
+	InventoryItemID   string     `json:"inventoryItemId"`
+	StockItemID       *string    `json:"stockItemId,omitempty"`
+	ItemName          string     `json:"itemName"`
+	BatchCode         string     `json:"batchCode"`
+	QuantityRemaining float64    `json:"quantityRemaining"`
+	UnitCost          float64    `json:"unitCost"`
+	ExpiryDate        time.Time  `json:"expiryDate"`
+	DaysToExpiry      int        `json:"daysToExpiry"`
+	Status            string     `json:"status"`
+	LastAlertedAt     *time.Time `json:"lastAlertedAt,omitempty"`
+}
+
+func expiryAlertLeadDays() []int {
+	if len(config.AppConfig.ExpiryAlertLeadDays) > 0 {
+		return config.AppConfig.ExpiryAlertLeadDays
+	}
+	return defaultExpiryAlertLeadDays
+}
+
+// expiryAlertStage returns the tightest configured lead time a batch has reached.
+// Lead days must be sorted largest first; expired batches map to expiredAlertStage.
+func expiryAlertStage(daysToExpiry int, leadDays []int) (int, bool) {
+	if daysToExpiry < 0 {
+		return expiredAlertStage, true
+	}
+	stage, reached := 0, false
+	for _, lead := range leadDays {
+		if daysToExpiry <= lead {
+			stage, reached = lead, true
+		}
+	}
+	return stage, reached
+}
+
+// RunExpiryAlertScan notifies merchants about batches reaching a configured lead time before expiry.
+// Each batch alerts at most once per stage, so repeated scans are safe.
+func RunExpiryAlertScan(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	leadDays := expiryAlertLeadDays()
+	maxLead := 0
+	for _, lead := range leadDays {
+		if lead > maxLead {
+			maxLead = lead
+		}
+	}
+	rows, err := db.Query(ctx, `SELECT b.id,b.merchant_id,s.name,si.name,b.batch_code,b.quantity_remaining,b.expiry_date,(b.expiry_date-CURRENT_DATE) FROM inventory_batches b JOIN shops s ON s.id=b.shop_id JOIN inventory_items ii ON ii.id=b.inventory_item_id JOIN stock_items si ON si.id=ii.stock_item_id WHERE b.quantity_remaining > 0 AND b.expiry_date IS NOT NULL AND b.expiry_date <= CURRENT_DATE + $1::int`, maxLead)
+	if err != nil {
+		return err
+	}
+	type candidate struct {
+		batchID, merchantID, shopName, itemName, batchCode string
+		quantity                                           float64
+		expiry                                             time.Time
+		days                                               int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var item candidate
+		if err := rows.Scan(&item.batchID, &item.merchantID, &item.shopName, &item.itemName, &item.batchCode, &item.quantity, &item.expiry, &item.days); err != nil {
+			rows.Close()
+			return err
+		}
+		candidates = append(candidates, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sent := 0
+	for _, item := range candidates {
+		stage, reached := expiryAlertStage(item.days, leadDays)
+		if !reached {
+			continue
+		}
+		tx, err := db.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		adapter := pgxTxAdapter{tx: tx}
+		inserted, err := adapter.Exec(ctx, `INSERT INTO inventory_expiry_alerts(batch_id,lead_days) VALUES($1,$2) ON CONFLICT (batch_id,lead_days) DO NOTHING`, item.batchID, stage)
+		if err == nil && inserted == 1 {
+			title := fmt.Sprintf("%s expires in %d day(s)", item.itemName, item.days)
+			if stage == expiredAlertStage {
+				title = fmt.Sprintf("%s has expired", item.itemName)
+			}
+			message := fmt.Sprintf("Batch %s at %s (%.3f remaining) expires on %s.", item.batchCode, item.shopName, item.quantity, item.expiry.Format("2006-01-02"))
+			err = createNotification(ctx, adapter, item.merchantID, title, message, "INVENTORY_EXPIRY", "INVENTORY_BATCH", item.batchID)
+			sent++
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			tx.Rollback(ctx)
+			return err
+		}
+	}
+	if sent > 0 {
+		log.Printf("expiry alert scan sent %d notification(s)", sent)
+	}
+	return nil
+}
+
+// HandleListExpiringStock lists batches in a shop that are expired or expire within the window.
+func HandleListExpiringStock(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	withinDays := c.QueryInt("withinDays", expiryAlertLeadDays()[0])
+	if withinDays < 0 || withinDays > 3650 {
+		return fiber.NewError(400, "withinDays must be between 0 and 3650")
+	}
+	where := " WHERE b.shop_id=$1 AND b.quantity_remaining > 0 AND b.expiry_date IS NOT NULL AND b.expiry_date <= CURRENT_DATE + $2::int"
+	switch strings.ToUpper(strings.TrimSpace(c.Query("status"))) {
+	case "":
+	case "EXPIRED":
+		where += " AND b.expiry_date < CURRENT_DATE"
+	case "NEAR_EXPIRY":
+		where += " AND b.expiry_date >= CURRENT_DATE"
+	default:
+		return fiber.NewError(400, "status must be EXPIRED or NEAR_EXPIRY")
+	}
+	db, ctx := database.GetDB(), context.Background()
+	rows, err := db.Query(ctx, `SELECT b.id,b.shop_id,b.inventory_item_id,b.stock_item_id,si.name,b.batch_code,b.quantity_remaining,b.unit_cost,b.expiry_date,(b.expiry_date-CURRENT_DATE),(SELECT MAX(sent_at) FROM inventory_expiry_alerts a WHERE a.batch_id=b.id) FROM inventory_batches b JOIN inventory_items ii ON ii.id=b.inventory_item_id JOIN stock_items si ON si.id=ii.stock_item_id`+where+` ORDER BY b.expiry_date ASC, b.batch_code ASC`, shopID, withinDays)
+	if err != nil {
+		return fiber.NewError(500, "failed to list expiring stock")
+	}
+	defer rows.Close()
+	items := make([]expiringBatch, 0)
+	var expiredQty, nearQty float64
+	for rows.Next() {
+		var item expiringBatch
+		if err := rows.Scan(&item.ID, &item.ShopID, &item.InventoryItemID, &item.StockItemID, &item.ItemName, &item.BatchCode, &item.QuantityRemaining, &item.UnitCost, &item.ExpiryDate, &item.DaysToExpiry, &item.LastAlertedAt); err != nil {
+			return fiber.NewError(500, "failed to read expiring stock")
+		}
+		item.Status = "NEAR_EXPIRY"
+		nearQty += item.QuantityRemaining
+		if item.DaysToExpiry < 0 {
+			item.Status = "EXPIRED"
+			expiredQty += item.QuantityRemaining
+			nearQty -= item.QuantityRemaining
+		}
+		items = append(items, item)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items, "summary": fiber.Map{"expiredQuantity": expiredQty, "nearExpiryQuantity": nearQty, "withinDays": withinDays}})
+}
+
+type expiryWriteOffRequest struct {
+	ClientOperationID string   `json:"clientOperationId"`
+	ReasonCode        string   `json:"reasonCode"`
+	Notes             string   `json:"notes"`
+	BatchIDs          []string `json:"batchIds"`
+}
+
+// HandleWriteOffExpiredStock removes the remaining quantity of expired batches from stock.
+// Each batch posts one ADJUSTMENT movement carrying the reason code.
+func HandleWriteOffExpiredStock(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req expiryWriteOffRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	req.ReasonCode = strings.ToUpper(strings.TrimSpace(req.ReasonCode))
+	if req.ReasonCode == "" {
+		req.ReasonCode = "EXPIRED"
+	}
+	if strings.TrimSpace(req.ClientOperationID) == "" || len(req.BatchIDs) == 0 || len(req.BatchIDs) > 100 {
+		return fiber.NewError(400, "clientOperationId and between 1 and 100 batchIds are required")
+	}
+	if !expiryWriteOffReasonCodes[req.ReasonCode] {
+		return fiber.NewError(400, "reasonCode must be one of EXPIRED, DAMAGED, RECALLED, DISPOSED, DONATED")
+	}
+	db, ctx := database.GetDB(), context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start write-off")
+	}
+	defer tx.Rollback(ctx)
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "expiry_write_off", claims.UserID, &shopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to start write-off")
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Write-off already processed"})
+	}
+	type writtenOff struct {
+		BatchID   string  `json:"batchId"`
+		BatchCode string  `json:"batchCode"`
+		Quantity  float64 `json:"quantity"`
+		UnitCost  float64 `json:"unitCost"`
+	}
+	results := make([]writtenOff, 0, len(req.BatchIDs))
+	for _, batchID := range req.BatchIDs {
+		var merchantID, inventoryID, productID, batchCode string
+		var stockItemID *string
+		var qty, unitCost float64
+		var expired bool
+		err := tx.QueryRow(ctx, `SELECT merchant_id,inventory_item_id,product_id,stock_item_id,batch_code,quantity_remaining,unit_cost,COALESCE(expiry_date < CURRENT_DATE,FALSE) FROM inventory_batches WHERE id::text=$1 AND shop_id=$2 FOR UPDATE`, batchID, shopID).Scan(&merchantID, &inventoryID, &productID, &stockItemID, &batchCode, &qty, &unitCost, &expired)
+		if isNoRows(err) {
+			return fiber.NewError(404, fmt.Sprintf("batch %s not found in this shop", batchID))
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to load batch")
+		}
+		if req.ReasonCode == "EXPIRED" && !expired {
+			return fiber.NewError(409, fmt.Sprintf("batch %s has not expired", batchCode))
+		}
+		if qty <= 0 {
+			continue
+		}
+		updated, err := tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2 AND quantity_on_hand >= $1`, qty, inventoryID)
+		if err != nil {
+			return fiber.NewError(500, "failed to update stock")
+		}
+		if updated.RowsAffected() == 0 {
+			return fiber.NewError(409, fmt.Sprintf("on-hand stock is lower than batch %s remaining quantity", batchCode))
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_batches SET quantity_remaining=0 WHERE id=$1`, batchID); err != nil {
+			return fiber.NewError(500, "failed to write off batch")
+		}
+		if unitCost, err = issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, inventoryID, qty, []batchAllocation{{BatchID: batchID, BatchCode: batchCode, Quantity: qty, UnitCost: unitCost}}); err != nil {
+			return fiber.NewError(500, "failed to cost write-off")
+		}
+		notes := fmt.Sprintf("Write-off of batch %s (%s)", batchCode, req.ReasonCode)
+		if strings.TrimSpace(req.Notes) != "" {
+			notes += ": " + strings.TrimSpace(req.Notes)
+		}
+		binID, err := moveDefaultBinStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, -qty)
+		if err != nil {
+			return fiber.NewError(500, "failed to update bin balance")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,batch_id,reason_code,bin_id) VALUES($1,$2,$3,$4,$5,'ADJUSTMENT',$6,$6,$7,'WRITE_OFF',$8,$9,$10,$8,$11,$12)`, merchantID, shopID, inventoryID, productID, stockItemID, qty, unitCost, batchID, fmt.Sprintf("%s:%s", req.ClientOperationID, batchID), notes, req.ReasonCode, binID); err != nil {
+			return fiber.NewError(500, "failed to record write-off movement")
+		}
+		results = append(results, writtenOff{BatchID: batchID, BatchCode: batchCode, Quantity: qty, UnitCost: unitCost})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit write-off")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": results})
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import "testing"
+
+func TestExpiryAlertStage(t *testing.T) {
+	leads := []int{30, 7, 1}
+	cases := []struct {
+		days    int
+		stage   int
+		reached bool
+	}{
+		{45, 0, false},
+		{30, 30, true},
+		{12, 30, true},
+		{7, 7, true},
+		{1, 1, true},
+		{0, 1, true},
+		{-3, expiredAlertStage, true},
+	}
+	for _, tc := range cases {
+		stage, reached := expiryAlertStage(tc.days, leads)
+		if stage != tc.stage || reached != tc.reached {
+			t.Fatalf("days=%d: expected (%d,%v), got (%d,%v)", tc.days, tc.stage, tc.reached, stage, reached)
+		}
+	}
+}
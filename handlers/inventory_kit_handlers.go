@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var kitSortFields = map[string]string{"name": "name", "createdAt": "created_at", "updatedAt": "updated_at"}
+
+const productKitColumns = `id,merchant_id,product_id,stock_item_id,name,is_active,created_at,updated_at`
+
+func scanProductKit(scan func(...interface{}) error) (models.ProductKit, error) {
+	var item models.ProductKit
+	err := scan(&item.ID, &item.MerchantID, &item.ProductID, &item.StockItemID, &item.Name, &item.IsActive, &item.CreatedAt, &item.UpdatedAt)
+	item.Components = []models.ProductKitComponent{}
+	return item, err
+}
+
+// attachKitComponents loads kit components and, when shopID is set, the number of kits the shop can make.
+func attachKitComponents(ctx context.Context, kits []models.ProductKit, shopID string) error {
+	if len(kits) == 0 {
+		return nil
+	}
+	ids := make([]string, len(kits))
+	index := make(map[string]int, len(kits))
+	for i, kit := range kits {
+		ids[i] = kit.ID
+		index[kit.ID] = i
+	}
+	rows, err := database.GetDB().Query(ctx, `SELECT c.kit_id,c.id,c.stock_item_id,si.name,c.quantity,COALESCE(`+unreservedStockSQL("ii")+`,0) FROM product_kit_components c JOIN stock_items si ON si.id=c.stock_item_id LEFT JOIN inventory_items ii ON ii.stock_item_id=c.stock_item_id AND ii.shop_id::text=$2 WHERE c.kit_id::text=ANY($1) ORDER BY si.name,c.id`, ids, shopID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	perKit := make(map[string][]float64, len(kits))
+	available := make(map[string][]float64, len(kits))
+	for rows.Next() {
+		var kitID string
+		var component models.ProductKitComponent
+		var onHand float64
+		if err := rows.Scan(&kitID, &component.ID, &component.StockItemID, &component.StockItemName, &component.Quantity, &onHand); err != nil {
+			return err
+		}
+		if shopID != "" {
+			component.Available = &onHand
+		}
+		kit := &kits[index[kitID]]
+		kit.Components = append(kit.Components, component)
+		perKit[kitID] = append(perKit[kitID], component.Quantity)
+		available[kitID] = append(available[kitID], onHand)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if shopID != "" {
+		for i := range kits {
+			kitsAvailable := kitAvailability(perKit[kits[i].ID], available[kits[i].ID])
+			kits[i].Available = &kitsAvailable
+		}
+	}
+	return nil
+}
+
+// replaceKitComponents validates and stores the component list of a kit.
+func replaceKitComponents(ctx context.Context, tx pgx.Tx, merchantID, kitID, kitStockItemID string, components []models.ProductKitComponentRequest) error {
+	if len(components) == 0 || len(components) > 50 {
+		return fiber.NewError(400, "between 1 and 50 components are required")
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM product_kit_components WHERE kit_id=$1`, kitID); err != nil {
+		return fiber.NewError(500, "failed to replace kit components")
+	}
+	seen := make(map[string]struct{}, len(components))
+	for _, component := range components {
+		stockItemID := strings.TrimSpace(component.StockItemID)
+		if stockItemID == "" || component.Quantity <= 0 {
+			return fiber.NewError(400, "each component needs a stockItemId and a positive quantity")
+		}
+		if stockItemID == kitStockItemID {
+			return fiber.NewError(400, "a kit cannot contain itself")
+		}
+		if _, exists := seen[stockItemID]; exists {
+			return fiber.NewError(400, "each stock item may appear only once in a kit")
+		}
+		seen[stockItemID] = struct{}{}
+		var trackingMode string
+		var nestedKit bool
+		err := tx.QueryRow(ctx, `SELECT si.tracking_mode,EXISTS(SELECT 1 FROM product_kits k WHERE k.stock_item_id=si.id) FROM stock_items si WHERE si.id=$1 AND si.merchant_id=$2`, stockItemID, merchantID).Scan(&trackingMode, &nestedKit)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(404, fmt.Sprintf("component stock item %s not found", stockItemID))
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to validate kit component")
+		}
+		if trackingMode == "SERIAL" {
+			return fiber.NewError(400, "serial-tracked items cannot be kit components")
+		}
+		if nestedKit {
+			return fiber.NewError(400, "kits cannot contain other kits")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO product_kit_components(kit_id,stock_item_id,quantity) VALUES($1,$2,$3)`, kitID, stockItemID, component.Quantity); err != nil {
+			return fiber.NewError(500, "failed to save kit component")
+		}
+	}
+	return nil
+}
+
+func HandleListProductKits(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "name", kitSortFields)
+	where := " WHERE merchant_id=$1"
+	args := []interface{}{merchantID}
+	if v := strings.TrimSpace(c.Query("isActive")); v != "" {
+		where += " AND is_active=$" + itoa(len(args)+1)
+		args = append(args, v == "true")
+	}
+	if q.Search != "" {
+		where += " AND name ILIKE $" + itoa(len(args)+1)
+		args = append(args, "%"+q.Search+"%")
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM product_kits"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count kits")
+	}
+	rows, err := db.Query(ctx, "SELECT "+productKitColumns+" FROM product_kits"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list kits")
+	}
+	items := make([]models.ProductKit, 0)
+	for rows.Next() {
+		item, scanErr := scanProductKit(rows.Scan)
+		if scanErr != nil {
+			rows.Close()
+			return fiber.NewError(500, "failed to read kit")
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := attachKitComponents(ctx, items, strings.TrimSpace(c.Query("shopId"))); err != nil {
+		return fiber.NewError(500, "failed to load kit components")
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleGetProductKit(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	item, err := scanProductKit(database.GetDB().QueryRow(ctx, "SELECT "+productKitColumns+" FROM product_kits WHERE id=$1 AND merchant_id=$2", c.Params("kitId"), merchantID).Scan)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "kit not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load kit")
+	}
+	kits := []models.ProductKit{item}
+	if err := attachKitComponents(ctx, kits, strings.TrimSpace(c.Query("shopId"))); err != nil {
+		return fiber.NewError(500, "failed to load kit components")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": kits[0]})
+}
+
+// HandleCreateProductKit defines a kit for a product. The product is sold through its stock item,
+// which is created when missing and given an empty balance in every shop of the merchant.
+func HandleCreateProductKit(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.ProductKitRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.ProductID) == "" {
+		return fiber.NewError(400, "productId and components are required")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start kit transaction")
+	}
+	defer tx.Rollback(ctx)
+	var productName string
+	if err = tx.QueryRow(ctx, `SELECT name FROM products WHERE id=$1 AND merchant_id=$2`, req.ProductID, merchantID).Scan(&productName); err != nil {
+		return fiber.NewError(404, "product not found")
+	}
+	name := productName
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		name = strings.TrimSpace(*req.Name)
+	}
+	var stockItemID string
+	err = tx.QueryRow(ctx, `SELECT id FROM stock_items WHERE product_id=$1 AND merchant_id=$2 ORDER BY created_at LIMIT 1`, req.ProductID, merchantID).Scan(&stockItemID)
+	if err == pgx.ErrNoRows {
+		err = tx.QueryRow(ctx, `INSERT INTO stock_items(merchant_id,product_id,name,track_inventory) VALUES($1,$2,$3,FALSE) RETURNING id`, merchantID, req.ProductID, productName).Scan(&stockItemID)
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to resolve kit stock item")
+	}
+	// The kit's stock item must not be a component elsewhere, since sales do not expand
+	// nested kits, nor hold stock, which the kit would no longer count or sell.
+	var component, stocked bool
+	if err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM product_kit_components WHERE stock_item_id=$1),EXISTS(SELECT 1 FROM inventory_items WHERE stock_item_id=$1 AND (quantity_on_hand<>0 OR reserved_quantity<>0))`, stockItemID).Scan(&component, &stocked); err != nil {
+		return fiber.NewError(500, "failed to validate kit stock item")
+	}
+	if component {
+		return fiber.NewError(400, "this product is a component of another kit and cannot become a kit")
+	}
+	if stocked {
+		return fiber.NewError(400, "this product still holds stock; adjust it to zero before making it a kit")
+	}
+	isActive := req.IsActive == nil || *req.IsActive
+	item, err := scanProductKit(tx.QueryRow(ctx, `INSERT INTO product_kits(merchant_id,product_id,stock_item_id,name,is_active) VALUES($1,$2,$3,$4,$5) RETURNING `+productKitColumns, merchantID, req.ProductID, stockItemID, name, isActive).Scan)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "a kit already exists for this product")
+		}
+		return fiber.NewError(500, "failed to create kit")
+	}
+	if err := replaceKitComponents(ctx, tx, merchantID, item.ID, stockItemID, req.Components); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) SELECT $1,s.id,$2,$3,0 FROM shops s WHERE s.merchant_id=$1 ON CONFLICT (shop_id,stock_item_id) DO NOTHING`, merchantID, req.ProductID, stockItemID); err != nil {
+		return fiber.NewError(500, "failed to prepare kit balances")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit kit")
+	}
+	kits := []models.ProductKit{item}
+	if err := attachKitComponents(ctx, kits, ""); err != nil {
+		return fiber.NewError(500, "failed to load kit components")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": kits[0]})
+}
+
+func HandleUpdateProductKit(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.ProductKitRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start kit transaction")
+	}
+	defer tx.Rollback(ctx)
+	item, err := scanProductKit(tx.QueryRow(ctx, `UPDATE product_kits SET name=COALESCE(NULLIF(TRIM($1),''),name),is_active=COALESCE($2,is_active),updated_at=NOW() WHERE id=$3 AND merchant_id=$4 RETURNING `+productKitColumns, nullableStringValue(req.Name), req.IsActive, c.Params("kitId"), merchantID).Scan)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "kit not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to update kit")
+	}
+	if req.Components != nil {
+		if err := replaceKitComponents(ctx, tx, merchantID, item.ID, item.StockItemID, req.Components); err != nil {
+			return err
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit kit")
+	}
+	kits := []models.ProductKit{item}
+	if err := attachKitComponents(ctx, kits, ""); err != nil {
+		return fiber.NewError(500, "failed to load kit components")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": kits[0]})
+}
+
+func HandleDeleteProductKit(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	result, err := database.GetDB().Exec(context.Background(), `DELETE FROM product_kits WHERE id=$1 AND merchant_id=$2`, c.Params("kitId"), merchantID)
+	if err != nil {
+		return fiber.NewError(500, "failed to delete kit")
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "kit not found")
+	}
+	return c.SendStatus(204)
+}
+
+// HandleListShopKits lists the active kits a shop can sell with availability computed from component stock.
+func HandleListShopKits(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	rows, err := database.GetDB().Query(ctx, `SELECT `+productKitColumns+` FROM product_kits WHERE merchant_id=(SELECT merchant_id FROM shops WHERE id=$1) AND is_active ORDER BY name,id`, shopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to list kits")
+	}
+	items := make([]models.ProductKit, 0)
+	for rows.Next() {
+		item, scanErr := scanProductKit(rows.Scan)
+		if scanErr != nil {
+			rows.Close()
+			return fiber.NewError(500, "failed to read kit")
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := attachKitComponents(ctx, items, shopID); err != nil {
+		return fiber.NewError(500, "failed to load kit components")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
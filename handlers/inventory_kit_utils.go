@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// kitComponentStockError names the kit component that is short of stock.
+type kitComponentStockError struct {
+	Component string
+}
+
+func (e kitComponentStockError) Error() string {
+	return fmt.Sprintf("insufficient stock of kit component %s", e.Component)
+}
+
+// isKitSaleError reports whether err is a client-facing kit stock failure.
+func isKitSaleError(err error) bool {
+	var shortage kitComponentStockError
+	return errors.As(err, &shortage)
+}
+
+// kitComponent is one component line of a kit sold at checkout.
+type kitComponent struct {
+	StockItemID string  `json:"stock_item_id"`
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+}
+
+// kitDefinition is an active kit resolved from the stock item being sold.
+type kitDefinition struct {
+	ID         string
+	Name       string
+	Components []kitComponent
+}
+
+// kitAvailability returns how many whole kits the component balances can make.
+func kitAvailability(perKit, available []float64) float64 {
+	if len(perKit) == 0 || len(perKit) != len(available) {
+		return 0
+	}
+	kits := math.Inf(1)
+	for i := range perKit {
+		if perKit[i] <= 0 {
+			continue
+		}
+		n := math.Floor(available[i]/perKit[i] + 1e-9)
+		if n < kits {
+			kits = n
+		}
+	}
+	if math.IsInf(kits, 1) || kits < 0 {
+		return 0
+	}
+	return kits
+}
+
+// availableStockJoin adds stock.available to a query over inventory_items ii: the unreserved
+// balance, or for an active kit the number of whole kits the shop's component balances can
+// make (the SQL form of kitAvailability).
+var availableStockJoin = `LEFT JOIN LATERAL (SELECT COALESCE(
+		(SELECT GREATEST(0,MIN(FLOOR(COALESCE(` + unreservedStockSQL("ci") + `,0)/c.quantity)))
+		FROM product_kits k JOIN product_kit_components c ON c.kit_id=k.id
+		LEFT JOIN inventory_items ci ON ci.shop_id=ii.shop_id AND ci.stock_item_id=c.stock_item_id
+		WHERE k.stock_item_id=ii.stock_item_id AND k.is_active),
+		` + unreservedStockSQL("ii") + `)::float8 AS available) stock ON TRUE`
+
+// loadStockItemKit returns the active kit sold through a stock item, or nil when the item is not a kit.
+func loadStockItemKit(ctx context.Context, tx DBTx, stockItemID string) (*kitDefinition, error) {
+	var kit kitDefinition
+	var raw string
+	err := tx.QueryRow(ctx, `SELECT k.id,k.name,COALESCE(json_agg(json_build_object('stock_item_id',c.stock_item_id,'name',si.name,'quantity',c.quantity) ORDER BY si.name) FILTER (WHERE c.id IS NOT NULL),'[]'::json)::text FROM product_kits k LEFT JOIN product_kit_components c ON c.kit_id=k.id LEFT JOIN stock_items si ON si.id=c.stock_item_id WHERE k.stock_item_id=$1 AND k.is_active GROUP BY k.id,k.name`, stockItemID).Scan(&kit.ID, &kit.Name, &raw)
+	if isNoRows(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal([]byte(raw), &kit.Components); err != nil {
+		return nil, err
+	}
+	return &kit, nil
+}
+
+// sellKitComponents deducts the component stock for a kit sale line under each component's
+// negative-stock policy and posts one OUT movement per component. It returns the cost of
+// the components consumed and the cashier warnings for components sold short.
+func sellKitComponents(ctx context.Context, tx DBTx, kit *kitDefinition, merchantID, shopID, saleID, saleItemID, referenceType string, quantity float64) (float64, []string, error) {
+	var total float64
+	var warnings []string
+	for _, component := range kit.Components {
+		required := component.Quantity * quantity
+		var inventoryID, productID string
+		err := tx.QueryRow(ctx, `SELECT id,product_id FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2`, shopID, component.StockItemID).Scan(&inventoryID, &productID)
+		if isNoRows(err) {
+			return 0, nil, kitComponentStockError{Component: component.Name}
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		deduction, err := deductSaleStock(ctx, tx, inventoryID, required, referenceType, saleID)
+		if err == errInsufficientStock {
+			return 0, nil, kitComponentStockError{Component: component.Name}
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if warning := deduction.warning(component.Name); warning != "" {
+			warnings = append(warnings, warning)
+		}
+		allocations, err := consumeInventoryBatches(ctx, tx, component.StockItemID, inventoryID, deduction.batched(required), "")
+		if err != nil {
+			return 0, nil, err
+		}
+		unitCost, err := issueInventoryCost(ctx, tx, merchantID, inventoryID, required, allocations)
+		if err != nil {
+			return 0, nil, err
+		}
+		total += required * unitCost
+		var componentID string
+		if err = tx.QueryRow(ctx, `INSERT INTO sale_item_components(sale_item_id,inventory_item_id,stock_item_id,quantity_per_kit,quantity,unit_cost) VALUES($1,$2,$3,$4,$5,$6) RETURNING id`, saleItemID, inventoryID, component.StockItemID, component.Quantity, required, unitCost).Scan(&componentID); err != nil {
+			return 0, nil, err
+		}
+		if err = recordSaleItemComponentBatches(ctx, tx, saleItemID, componentID, allocations); err != nil {
+			return 0, nil, err
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,'OUT',$6,$6,$7,$8,$9,$10,$11)`, merchantID, shopID, inventoryID, productID, component.StockItemID, required, unitCost, referenceType, saleID, fmt.Sprintf("%s:%s", saleItemID, component.StockItemID), fmt.Sprintf("Kit %s in sale #%s", kit.Name, saleID)); err != nil {
+			return 0, nil, err
+		}
+	}
+	return math.Round(total*100) / 100, warnings, nil
+}
+
+// saleComponentLine is a kit component consumed by a sale line.
+type saleComponentLine struct {
+	ID              string  `json:"id"`
+	InventoryItemID string  `json:"inventory_item_id"`
+	ProductID       string  `json:"product_id"`
+	StockItemID     *string `json:"stock_item_id"`
+	QuantityPerKit  float64 `json:"quantity_per_kit"`
+	UnitCost        float64 `json:"unit_cost"`
+}
+
+// returnKitComponents restocks the components of a returned kit line.
+// It reports false when the sale line did not consume kit components.
+func returnKitComponents(ctx context.Context, tx DBTx, merchantID, shopID, saleID, saleItemID, operationID, notes string, quantity float64) (bool, error) {
+	var raw string
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(json_agg(c ORDER BY c.id),'[]'::json)::text FROM (SELECT sic.id,sic.inventory_item_id,ii.product_id,sic.stock_item_id,sic.quantity_per_kit,sic.unit_cost FROM sale_item_components sic JOIN inventory_items ii ON ii.id=sic.inventory_item_id WHERE sic.sale_item_id=$1 FOR UPDATE OF sic,ii) c`, saleItemID).Scan(&raw); err != nil {
+		return false, err
+	}
+	var lines []saleComponentLine
+	if err := json.Unmarshal([]byte(raw), &lines); err != nil {
+		return false, err
+	}
+	for _, line := range lines {
+		returned := line.QuantityPerKit * quantity
+		if _, err := tx.Exec(ctx, `UPDATE sale_item_components SET quantity_returned=quantity_returned+$1 WHERE id=$2`, returned, line.ID); err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, returned, line.InventoryItemID); err != nil {
+			return false, err
+		}
+		if err := restoreSaleItemComponentBatches(ctx, tx, line.ID, returned); err != nil {
+			return false, err
+		}
+		if _, err := receiveInventoryCost(ctx, tx, line.InventoryItemID, returned, &line.UnitCost); err != nil {
+			return false, err
+		}
+		binID, err := moveDefaultBinStock(ctx, tx, line.InventoryItemID, returned)
+		if err != nil {
+			return false, err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES($1,$2,$3,$4,$5,'RETURN',$6,$6,$7,'SALE_RETURN',$8,$9,$10,$11)`, merchantID, shopID, line.InventoryItemID, line.ProductID, line.StockItemID, returned, line.UnitCost, saleID, fmt.Sprintf("%s:%s", operationID, line.ID), notes, binID); err != nil {
+			return false, err
+		}
+	}
+	return len(lines) > 0, nil
+}
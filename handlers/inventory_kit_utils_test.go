@@ -0,0 +1,22 @@
+package handlers
+
+import "testing"
+
+func TestKitAvailability(t *testing.T) {
+	cases := []struct {
+		perKit    []float64
+		available []float64
+		expected  float64
+	}{
+		{[]float64{1, 2}, []float64{10, 9}, 4},
+		{[]float64{0.5}, []float64{3}, 6},
+		{[]float64{1, 1}, []float64{5, 0}, 0},
+		{[]float64{1}, []float64{-2}, 0},
+		{nil, nil, 0},
+	}
+	for _, tc := range cases {
+		if got := kitAvailability(tc.perKit, tc.available); got != tc.expected {
+			t.Fatalf("perKit=%v available=%v: expected %v, got %v", tc.perKit, tc.available, tc.expected, got)
+		}
+	}
+}
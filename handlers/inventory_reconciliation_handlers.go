@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	reconcileRebuild    = "REBUILD_FROM_LEDGER"
+	reconcileCorrection = "CORRECTING_MOVEMENT"
+)
+
+// ledgerSignSQL is the direction a movement aliased m applies to on-hand stock.
+const ledgerSignSQL = `CASE WHEN m.movement_type IN ('IN','RETURN') THEN 1 ELSE -1 END`
+
+// ledgerQuantitySQL sums the movement ledger of the inventory item in column ii.id.
+const ledgerQuantitySQL = `COALESCE((SELECT SUM(` + ledgerSignSQL + `*COALESCE(m.base_quantity,m.quantity)) FROM inventory_movements m WHERE m.inventory_item_id=ii.id),0)::float8`
+
+// ledgerMismatch reports whether a balance differs from its ledger by more than stock precision.
+func ledgerMismatch(recorded, ledger float64) bool {
+	return math.Abs(recorded-ledger) >= 0.0005
+}
+
+// ledgerCorrection is the movement that brings the ledger in line with the recorded balance.
+func ledgerCorrection(recorded, ledger float64) (string, float64) {
+	diff := math.Round((recorded-ledger)*1000) / 1000
+	if diff >= 0 {
+		return "IN", diff
+	}
+	return "ADJUSTMENT", -diff
+}
+
+// reconcileInventory compares every balance in scope with its movement ledger, opening or
+// refreshing an exception for each mismatch and clearing exceptions that no longer apply.
+// An empty shopID checks every shop. With notify set, merchants hear about new exceptions.
+func reconcileInventory(ctx context.Context, tx pgx.Tx, shopID string, notify bool) (models.InventoryReconciliationRun, error) {
+	var run models.InventoryReconciliationRun
+	rows, err := tx.Query(ctx, `SELECT ii.id,ii.merchant_id,ii.shop_id,ii.stock_item_id,si.name,s.name,ii.quantity_on_hand::float8,`+ledgerQuantitySQL+`
+		FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN shops s ON s.id=ii.shop_id
+		WHERE ($1='' OR ii.shop_id::text=$1)`, shopID)
+	if err != nil {
+		return run, err
+	}
+	type mismatch struct {
+		inventoryID, merchantID, shopID, stockItemID, itemName, shopName string
+		recorded, ledger                                                 float64
+	}
+	var found []mismatch
+	for rows.Next() {
+		var m mismatch
+		if err := rows.Scan(&m.inventoryID, &m.merchantID, &m.shopID, &m.stockItemID, &m.itemName, &m.shopName, &m.recorded, &m.ledger); err != nil {
+			rows.Close()
+			return run, err
+		}
+		run.Checked++
+		if ledgerMismatch(m.recorded, m.ledger) {
+			found = append(found, m)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return run, err
+	}
+
+	adapter := pgxTxAdapter{tx: tx}
+	for _, m := range found {
+		var exceptionID string
+		var inserted bool
+		if err := tx.QueryRow(ctx, `INSERT INTO inventory_reconciliation_exceptions(merchant_id,shop_id,inventory_item_id,stock_item_id,recorded_quantity,ledger_quantity) VALUES($1,$2,$3,$4,$5,$6)
+			ON CONFLICT (inventory_item_id) WHERE status='OPEN' DO UPDATE SET recorded_quantity=EXCLUDED.recorded_quantity,ledger_quantity=EXCLUDED.ledger_quantity,last_checked_at=NOW()
+			RETURNING id,(xmax=0)`, m.merchantID, m.shopID, m.inventoryID, m.stockItemID, m.recorded, m.ledger).Scan(&exceptionID, &inserted); err != nil {
+			return run, err
+		}
+		run.Mismatched++
+		if !inserted {
+			continue
+		}
+		run.Opened++
+		if notify {
+			message := fmt.Sprintf("%s at %s shows %.3f on hand but its movements add up to %.3f.", m.itemName, m.shopName, m.recorded, m.ledger)
+			if err := createNotification(ctx, adapter, m.merchantID, fmt.Sprintf("Stock ledger mismatch for %s", m.itemName), message, "INVENTORY_RECONCILIATION", "INVENTORY_RECONCILIATION", exceptionID); err != nil {
+				return run, err
+			}
+		}
+	}
+	// Exceptions not refreshed by this pass have come back into balance.
+	cleared, err := adapter.Exec(ctx, `UPDATE inventory_reconciliation_exceptions SET status='CLEARED',resolved_at=NOW() WHERE status='OPEN' AND last_checked_at < NOW() AND ($1='' OR shop_id::text=$1)`, shopID)
+	if err != nil {
+		return run, err
+	}
+	run.Cleared = int(cleared)
+	return run, nil
+}
+
+// RunInventoryReconciliation checks every shop balance against the movement ledger.
+func RunInventoryReconciliation(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err = reconcileInventory(ctx, tx, "", true); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// HandleRunInventoryReconciliation checks one shop against the movement ledger on demand.
+func HandleRunInventoryReconciliation(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start reconciliation")
+	}
+	defer tx.Rollback(ctx)
+	run, err := reconcileInventory(ctx, tx, shopID, false)
+	if err != nil {
+		return fiber.NewError(500, "failed to reconcile inventory")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit reconciliation")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": run})
+}
+
+// HandleListReconciliationExceptions lists a shop's ledger exceptions, open ones by default.
+func HandleListReconciliationExceptions(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	status := strings.ToUpper(strings.TrimSpace(c.Query("status", "OPEN")))
+	if status != "ALL" && status != "OPEN" && status != "RESOLVED" && status != "CLEARED" {
+		return fiber.NewError(400, "status must be OPEN, RESOLVED, CLEARED or ALL")
+	}
+	rows, err := database.GetDB().Query(context.Background(), `SELECT e.id,e.shop_id,e.inventory_item_id,e.stock_item_id,si.name,e.recorded_quantity::float8,e.ledger_quantity::float8,e.status,e.resolution,e.notes,e.detected_at,e.last_checked_at,e.resolved_at
+		FROM inventory_reconciliation_exceptions e LEFT JOIN stock_items si ON si.id=e.stock_item_id
+		WHERE e.shop_id=$1 AND ($2='ALL' OR e.status=$2) ORDER BY e.detected_at DESC LIMIT 500`, shopID, status)
+	if err != nil {
+		return fiber.NewError(500, "failed to list reconciliation exceptions")
+	}
+	defer rows.Close()
+	items := make([]models.InventoryReconciliationException, 0)
+	for rows.Next() {
+		var e models.InventoryReconciliationException
+		if err := rows.Scan(&e.ID, &e.ShopID, &e.InventoryItemID, &e.StockItemID, &e.StockItemName, &e.RecordedQuantity, &e.LedgerQuantity, &e.Status, &e.Resolution, &e.Notes, &e.DetectedAt, &e.LastCheckedAt, &e.ResolvedAt); err != nil {
+			return fiber.NewError(500, "failed to read reconciliation exceptions")
+		}
+		e.Difference = math.Round((e.RecordedQuantity-e.LedgerQuantity)*1000) / 1000
+		items = append(items, e)
+	}
+	if err = rows.Err(); err != nil {
+		return fiber.NewError(500, "failed to read reconciliation exceptions")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
+
+// HandleResolveReconciliationException fixes an open exception either by resetting the
+// balance to the ledger or by posting a movement that brings the ledger to the balance.
+// The fix is refused when either figure has moved since the exception was last checked.
+func HandleResolveReconciliationException(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.ReconciliationResolveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	req.ClientOperationID = strings.TrimSpace(req.ClientOperationID)
+	req.Action = strings.ToUpper(strings.TrimSpace(req.Action))
+	if req.ClientOperationID == "" {
+		return fiber.NewError(400, "clientOperationId is required")
+	}
+	if req.Action != reconcileRebuild && req.Action != reconcileCorrection {
+		return fiber.NewError(400, "action must be REBUILD_FROM_LEDGER or CORRECTING_MOVEMENT")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start resolution")
+	}
+	defer tx.Rollback(ctx)
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "reconciliation_resolve", merchantID, &shopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to start resolution")
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Resolution already processed"})
+	}
+	exceptionID := c.Params("exceptionId")
+	var inventoryID, status string
+	var expectedRecorded, expectedLedger float64
+	err = tx.QueryRow(ctx, `SELECT inventory_item_id,status,recorded_quantity::float8,ledger_quantity::float8 FROM inventory_reconciliation_exceptions WHERE id=$1 AND shop_id=$2 FOR UPDATE`, exceptionID, shopID).Scan(&inventoryID, &status, &expectedRecorded, &expectedLedger)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "reconciliation exception not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load reconciliation exception")
+	}
+	if status != "OPEN" {
+		return fiber.NewError(409, "reconciliation exception is not open")
+	}
+	var productID, stockItemID, itemMerchantID string
+	var recorded, ledger float64
+	if err = tx.QueryRow(ctx, `SELECT ii.merchant_id,ii.product_id,ii.stock_item_id,ii.quantity_on_hand::float8,`+ledgerQuantitySQL+` FROM inventory_items ii WHERE ii.id=$1 FOR UPDATE`, inventoryID).Scan(&itemMerchantID, &productID, &stockItemID, &recorded, &ledger); err != nil {
+		return fiber.NewError(500, "failed to lock inventory balance")
+	}
+	if ledgerMismatch(recorded, expectedRecorded) || ledgerMismatch(ledger, expectedLedger) {
+		return fiber.NewError(409, "stock has moved since this exception was checked; run the reconciliation again")
+	}
+
+	adapter := pgxTxAdapter{tx: tx}
+	switch req.Action {
+	case reconcileRebuild:
+		if ledger < 0 {
+			return fiber.NewError(409, "the ledger adds up to a negative balance and cannot be used as on-hand stock")
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, ledger, inventoryID); err != nil {
+			return fiber.NewError(500, "failed to rebuild balance")
+		}
+		if ledger > recorded {
+			_, err = receiveInventoryCost(ctx, adapter, inventoryID, ledger-recorded, nil)
+		} else {
+			_, err = issueInventoryCost(ctx, adapter, itemMerchantID, inventoryID, recorded-ledger, nil)
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to cost rebuilt balance")
+		}
+	case reconcileCorrection:
+		movementType, qty := ledgerCorrection(recorded, ledger)
+		unitCost, err := inventoryAverageCost(ctx, adapter, inventoryID)
+		if err != nil {
+			return fiber.NewError(500, "failed to cost correction")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,reason_code) VALUES($1,$2,$3,$4,$5,$6,$7,$7,$8,'RECONCILIATION',$9,$10,'Ledger correction','LEDGER_CORRECTION')`, itemMerchantID, shopID, inventoryID, productID, stockItemID, movementType, qty, unitCost, exceptionID, req.ClientOperationID); err != nil {
+			return fiber.NewError(500, "failed to post correcting movement")
+		}
+	}
+	var e models.InventoryReconciliationException
+	if err = tx.QueryRow(ctx, `UPDATE inventory_reconciliation_exceptions SET status='RESOLVED',resolution=$1,notes=$2,resolved_at=NOW(),resolved_by=$3 WHERE id=$4
+		RETURNING id,shop_id,inventory_item_id,stock_item_id,recorded_quantity::float8,ledger_quantity::float8,status,resolution,notes,detected_at,last_checked_at,resolved_at`, req.Action, req.Notes, merchantID, exceptionID).Scan(&e.ID, &e.ShopID, &e.InventoryItemID, &e.StockItemID, &e.RecordedQuantity, &e.LedgerQuantity, &e.Status, &e.Resolution, &e.Notes, &e.DetectedAt, &e.LastCheckedAt, &e.ResolvedAt); err != nil {
+		return fiber.NewError(500, "failed to resolve reconciliation exception")
+	}
+	e.Difference = math.Round((e.RecordedQuantity-e.LedgerQuantity)*1000) / 1000
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit resolution")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": e})
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import "testing"
+
+func TestLedgerMismatch(t *testing.T) {
+	if ledgerMismatch(10, 10.0002) {
+		t.Fatal("expected differences below stock precision to be ignored")
+	}
+	if !ledgerMismatch(10, 9) {
+		t.Fatal("expected a one unit difference to be a mismatch")
+	}
+}
+
+func TestLedgerCorrection(t *testing.T) {
+	if typ, qty := ledgerCorrection(12, 10); typ != "IN" || qty != 2 {
+		t.Fatalf("expected an IN of 2 when the balance is above the ledger, got %s %v", typ, qty)
+	}
+	if typ, qty := ledgerCorrection(7.5, 10); typ != "ADJUSTMENT" || qty != 2.5 {
+		t.Fatalf("expected an ADJUSTMENT of 2.5 when the balance is below the ledger, got %s %v", typ, qty)
+	}
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// reorderParams tunes the replenishment calculation for one request.
+type reorderParams struct {
+	LookbackDays    int
+	CoverDays       int
+	SafetyDays      int
+	DefaultLeadDays int
+}
+
+func parseReorderParams(c *fiber.Ctx) (reorderParams, error) {
+	p := reorderParams{
+		LookbackDays:    c.QueryInt("lookbackDays", 30),
+		CoverDays:       c.QueryInt("coverDays", 14),
+		SafetyDays:      c.QueryInt("safetyDays", 3),
+		DefaultLeadDays: c.QueryInt("leadTimeDays", 7),
+	}
+	if p.LookbackDays < 1 || p.LookbackDays > 365 {
+		return p, fiber.NewError(400, "lookbackDays must be between 1 and 365")
+	}
+	if p.CoverDays < 0 || p.CoverDays > 365 || p.SafetyDays < 0 || p.SafetyDays > 365 || p.DefaultLeadDays < 0 || p.DefaultLeadDays > 365 {
+		return p, fiber.NewError(400, "coverDays, safetyDays and leadTimeDays must be between 0 and 365")
+	}
+	return p, nil
+}
+
+// reorderPoint is the balance at which an item must be reordered so that stock
+// lasts through the supplier lead time. The shop's low-stock threshold is a floor.
+func reorderPoint(dailyVelocity float64, leadTimeDays int, safetyStock, threshold float64) float64 {
+	point := dailyVelocity*float64(leadTimeDays) + safetyStock
+	if threshold > point {
+		point = threshold
+	}
+	return math.Round(point*1000) / 1000
+}
+
+// reorderQuantity is the whole quantity that lifts the stock position (on hand
+// plus on order) back above the reorder point with coverDays of demand on top.
+func reorderQuantity(onHand, onOrder, point, dailyVelocity float64, coverDays int) float64 {
+	position := onHand + onOrder
+	if point <= 0 || position > point {
+		return 0
+	}
+	qty := point + dailyVelocity*float64(coverDays) - position
+	if qty <= 0 {
+		return 0
+	}
+	return math.Ceil(qty - 1e-9)
+}
+
+// loadReorderSuggestions computes replenishment figures for every active stocked item in a shop.
+func loadReorderSuggestions(ctx context.Context, q rowQuerier, merchantID, shopID string, p reorderParams) ([]models.ReorderSuggestion, error) {
+	rows, err := q.Query(ctx, `SELECT ii.id,ii.stock_item_id,si.product_id,si.name,si.sku,ii.quantity_on_hand::float8,COALESCE(ii.low_stock_threshold,0)::float8,ii.safety_stock::float8,
+			COALESCE(sold.qty,0)::float8,COALESCE(ord.qty,0)::float8,ls.supplier_id::text,ls.name,ls.lead_time_days,ls.unit_cost::float8
+		FROM inventory_items ii
+		JOIN stock_items si ON si.id=ii.stock_item_id
+		LEFT JOIN LATERAL (SELECT SUM(x.qty) AS qty FROM (
+				SELECT COALESCE(it.base_quantity,it.quantity_sold)*(1-it.quantity_returned/it.quantity_sold) AS qty FROM sale_items it JOIN sales s ON s.id=it.sale_id
+				WHERE s.shop_id=ii.shop_id AND it.stock_item_id=ii.stock_item_id AND s.sale_date >= NOW()-make_interval(days => $3)
+				UNION ALL
+				SELECT c.quantity-c.quantity_returned FROM sale_item_components c JOIN sale_items it ON it.id=c.sale_item_id JOIN sales s ON s.id=it.sale_id
+				WHERE s.shop_id=ii.shop_id AND c.stock_item_id=ii.stock_item_id AND s.sale_date >= NOW()-make_interval(days => $3)
+			) x) sold ON TRUE
+		LEFT JOIN LATERAL (SELECT SUM(GREATEST(COALESCE(poi.base_quantity,poi.quantity)*(1-poi.received_quantity/poi.quantity),0)) AS qty
+			FROM purchase_order_items poi JOIN purchase_orders po ON po.id=poi.purchase_order_id
+			WHERE po.shop_id=ii.shop_id AND poi.stock_item_id=ii.stock_item_id AND po.status IN ('DRAFT','APPROVED','PARTIALLY_RECEIVED')) ord ON TRUE
+		LEFT JOIN LATERAL (SELECT x.supplier_id,x.name,x.lead_time_days,x.unit_cost FROM (
+				SELECT m.supplier_id,sp.name,COALESCE(m.lead_time_days,sp.lead_time_days) AS lead_time_days,m.last_cost/COALESCE(su.conversion_to_base,1) AS unit_cost,0 AS rank
+				FROM supplier_products m JOIN suppliers sp ON sp.id=m.supplier_id LEFT JOIN stock_item_units su ON su.stock_item_id=m.stock_item_id AND su.unit_id=m.unit_id
+				WHERE m.merchant_id=ii.merchant_id AND m.stock_item_id=ii.stock_item_id AND m.is_preferred
+				UNION ALL
+				(SELECT po.supplier_id,sp.name,COALESCE(m.lead_time_days,sp.lead_time_days),poi.total_cost/NULLIF(COALESCE(poi.base_quantity,poi.quantity),0),1
+				FROM purchase_order_items poi JOIN purchase_orders po ON po.id=poi.purchase_order_id JOIN suppliers sp ON sp.id=po.supplier_id
+				LEFT JOIN supplier_products m ON m.supplier_id=po.supplier_id AND m.stock_item_id=poi.stock_item_id
+				WHERE po.merchant_id=ii.merchant_id AND poi.stock_item_id=ii.stock_item_id AND po.status IN ('APPROVED','PARTIALLY_RECEIVED','RECEIVED')
+				ORDER BY po.created_at DESC LIMIT 1)
+			) x ORDER BY x.rank LIMIT 1) ls ON TRUE
+		WHERE ii.shop_id=$1 AND ii.merchant_id=$2 AND ii.is_active
+			AND NOT EXISTS (SELECT 1 FROM product_kits k WHERE k.stock_item_id=ii.stock_item_id)
+		ORDER BY si.name, ii.id`, shopID, merchantID, p.LookbackDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := make([]models.ReorderSuggestion, 0)
+	for rows.Next() {
+		var item models.ReorderSuggestion
+		var threshold, sold float64
+		var safety *float64
+		var leadDays *int
+		if err := rows.Scan(&item.InventoryItemID, &item.StockItemID, &item.ProductID, &item.Name, &item.SKU, &item.QuantityOnHand, &threshold, &safety, &sold, &item.QuantityOnOrder, &item.SupplierID, &item.SupplierName, &leadDays, &item.LastUnitCost); err != nil {
+			return nil, err
+		}
+		item.DailyVelocity = math.Round(sold/float64(p.LookbackDays)*1000) / 1000
+		item.LeadTimeDays = p.DefaultLeadDays
+		if leadDays != nil {
+			item.LeadTimeDays = *leadDays
+		}
+		item.SafetyStock = math.Round(item.DailyVelocity*float64(p.SafetyDays)*1000) / 1000
+		if safety != nil {
+			item.SafetyStock = *safety
+		}
+		item.ReorderPoint = reorderPoint(item.DailyVelocity, item.LeadTimeDays, item.SafetyStock, threshold)
+		item.SuggestedQuantity = reorderQuantity(item.QuantityOnHand, item.QuantityOnOrder, item.ReorderPoint, item.DailyVelocity, p.CoverDays)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// HandleListReorderSuggestions returns the items in a shop that have reached their reorder point.
+func HandleListReorderSuggestions(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	params, err := parseReorderParams(c)
+	if err != nil {
+		return err
+	}
+	items, err := loadReorderSuggestions(context.Background(), database.GetDB(), merchantID, shopID, params)
+	if err != nil {
+		return fiber.NewError(500, "failed to compute reorder suggestions")
+	}
+	if c.Query("all") != "true" {
+		due := items[:0]
+		for _, item := range items {
+			if item.SuggestedQuantity > 0 {
+				due = append(due, item)
+			}
+		}
+		items = due
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
+
+// HandleCreateReorderPurchaseOrders turns a shop's reorder suggestions into DRAFT
+// purchase orders, one per supplier: the item's preferred supplier, or else the one it
+// was last bought from.
+func HandleCreateReorderPurchaseOrders(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	params, err := parseReorderParams(c)
+	if err != nil {
+		return err
+	}
+	var req models.ReorderPurchaseOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	req.ClientOperationID = strings.TrimSpace(req.ClientOperationID)
+	if req.ClientOperationID == "" {
+		return fiber.NewError(400, "clientOperationId is required")
+	}
+	selected := make(map[string]bool, len(req.StockItemIDs))
+	for _, id := range req.StockItemIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			selected[id] = true
+		}
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start purchase orders")
+	}
+	defer tx.Rollback(ctx)
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "reorder_purchase_orders", merchantID, &shopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to start purchase orders")
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Reorder purchase orders already processed"})
+	}
+	items, err := loadReorderSuggestions(ctx, tx, merchantID, shopID, params)
+	if err != nil {
+		return fiber.NewError(500, "failed to compute reorder suggestions")
+	}
+	bySupplier := make(map[string][]models.ReorderSuggestion)
+	unassigned := make([]models.ReorderSuggestion, 0)
+	for _, item := range items {
+		if item.SuggestedQuantity <= 0 || (len(selected) > 0 && !selected[item.StockItemID]) {
+			continue
+		}
+		if item.SupplierID == nil {
+			unassigned = append(unassigned, item)
+			continue
+		}
+		bySupplier[*item.SupplierID] = append(bySupplier[*item.SupplierID], item)
+	}
+	supplierIDs := make([]string, 0, len(bySupplier))
+	for id := range bySupplier {
+		supplierIDs = append(supplierIDs, id)
+	}
+	sort.Strings(supplierIDs)
+	orders := make([]fiber.Map, 0, len(supplierIDs))
+	for _, supplierID := range supplierIDs {
+		lines := bySupplier[supplierID]
+		var subtotal float64
+		for _, line := range lines {
+			subtotal += line.SuggestedQuantity * reorderUnitCost(line)
+		}
+		var orderID string
+		if err = tx.QueryRow(ctx, `INSERT INTO purchase_orders(merchant_id,shop_id,supplier_id,status,subtotal,total) VALUES($1,$2,$3,'DRAFT',$4,$4) RETURNING id`, merchantID, shopID, supplierID, subtotal).Scan(&orderID); err != nil {
+			return fiber.NewError(500, "failed to create purchase order")
+		}
+		for _, line := range lines {
+			cost := reorderUnitCost(line)
+			if _, err = tx.Exec(ctx, `INSERT INTO purchase_order_items(purchase_order_id,product_id,stock_item_id,quantity,base_quantity,unit_cost,total_cost) VALUES($1,$2,$3,$4,$4,$5,$6)`, orderID, line.ProductID, line.StockItemID, line.SuggestedQuantity, cost, line.SuggestedQuantity*cost); err != nil {
+				return fiber.NewError(500, "failed to create purchase order item")
+			}
+		}
+		orders = append(orders, fiber.Map{"id": orderID, "supplierId": supplierID, "supplierName": lines[0].SupplierName, "status": "DRAFT", "subtotal": subtotal, "itemCount": len(lines)})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit purchase orders")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"purchaseOrders": orders, "unassigned": unassigned}})
+}
+
+func reorderUnitCost(item models.ReorderSuggestion) float64 {
+	if item.LastUnitCost == nil {
+		return 0
+	}
+	return math.Round(*item.LastUnitCost*100) / 100
+}
+
+// HandleUpdateReorderSettings sets the low-stock threshold and safety stock of one shop balance.
+func HandleUpdateReorderSettings(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	var req models.ReorderSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	if (req.LowStockThreshold != nil && *req.LowStockThreshold < 0) || (req.SafetyStock != nil && *req.SafetyStock < 0) {
+		return fiber.NewError(400, "lowStockThreshold and safetyStock cannot be negative")
+	}
+	var id string
+	var threshold, safety *float64
+	err := database.GetDB().QueryRow(context.Background(), `UPDATE inventory_items SET low_stock_threshold=$1,safety_stock=$2,updated_at=NOW() WHERE (id::text=$3 OR stock_item_id::text=$3) AND shop_id=$4 RETURNING id,low_stock_threshold::float8,safety_stock::float8`, req.LowStockThreshold, req.SafetyStock, c.Params("inventoryItemId"), shopID).Scan(&id, &threshold, &safety)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "inventory item not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to update reorder settings")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"inventoryItemId": id, "lowStockThreshold": threshold, "safetyStock": safety}})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestReorderPoint(t *testing.T) {
+	if got := reorderPoint(2, 7, 6, 0); got != 20 {
+		t.Fatalf("expected lead-time demand plus safety stock of 20, got %v", got)
+	}
+	if got := reorderPoint(0.5, 4, 0, 10); got != 10 {
+		t.Fatalf("expected the low-stock threshold to act as a floor, got %v", got)
+	}
+}
+
+func TestReorderQuantity(t *testing.T) {
+	cases := []struct {
+		onHand, onOrder, point, velocity float64
+		cover                            int
+		expected                         float64
+	}{
+		{onHand: 5, onOrder: 0, point: 20, velocity: 2, cover: 14, expected: 43},
+		{onHand: 5, onOrder: 16, point: 20, velocity: 2, cover: 14, expected: 0},
+		{onHand: 20, onOrder: 0, point: 20, velocity: 2, cover: 0, expected: 0},
+		{onHand: 3, onOrder: 0, point: 10, velocity: 0.3, cover: 5, expected: 9},
+		{onHand: 0, onOrder: 0, point: 0, velocity: 0, cover: 14, expected: 0},
+	}
+	for _, tc := range cases {
+		if got := reorderQuantity(tc.onHand, tc.onOrder, tc.point, tc.velocity, tc.cover); got != tc.expected {
+			t.Fatalf("%+v: expected %v, got %v", tc, tc.expected, got)
+		}
+	}
+}
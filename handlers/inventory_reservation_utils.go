@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"app/database"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	errReservationExpiryConflict = errors.New("provide either ttlMinutes or expiresAt, not both")
+	errReservationTTLInvalid     = errors.New("ttlMinutes must be positive")
+	errReservationExpiryPast     = errors.New("expiresAt must be in the future")
+	errReservationUnavailable    = errors.New("reservation is not active for this item")
+)
+
+// reservationExpiry resolves when a new reservation lapses. A nil result holds the
+// stock until the reservation is released by hand.
+func reservationExpiry(now time.Time, ttlMinutes *int, expiresAt *time.Time) (*time.Time, error) {
+	if ttlMinutes != nil && expiresAt != nil {
+		return nil, errReservationExpiryConflict
+	}
+	if ttlMinutes != nil {
+		if *ttlMinutes <= 0 {
+			return nil, errReservationTTLInvalid
+		}
+		at := now.Add(time.Duration(*ttlMinutes) * time.Minute)
+		return &at, nil
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errReservationExpiryPast
+	}
+	return expiresAt, nil
+}
+
+// reservationBaseQuantity is the stock a reservation holds back, in the item's base unit.
+func reservationBaseQuantity(quantity float64, baseQuantity *float64) float64 {
+	if baseQuantity != nil && *baseQuantity > 0 {
+		return *baseQuantity
+	}
+	return quantity
+}
+
+// unreservedStockSQL is the balance of inventory_items row alias less the stock held by
+// reservations that have not lapsed, so expired holds stop blocking sales before the
+// sweep releases them.
+func unreservedStockSQL(alias string) string {
+	return fmt.Sprintf(`(%[1]s.quantity_on_hand-COALESCE((SELECT SUM(COALESCE(r.base_quantity,r.quantity)) FROM inventory_reservations r WHERE r.inventory_item_id=%[1]s.id AND r.status='ACTIVE' AND (r.expires_at IS NULL OR r.expires_at>NOW())),0))`, alias)
+}
+
+// consumeInventoryReservation settles the reservation a checkout line sells against, so the
+// stock it holds is available to the sale in the same transaction. ref is the reservation id
+// or key; the whole hold is consumed even when the line sells less.
+func consumeInventoryReservation(ctx context.Context, tx DBTx, ref, inventoryItemID, saleID string) error {
+	if ref == "" {
+		return nil
+	}
+	var quantity float64
+	err := tx.QueryRow(ctx, `UPDATE inventory_reservations SET status='CONSUMED',sale_id=$3,released_at=NOW(),updated_at=NOW()
+		WHERE (id::text=$1 OR reservation_key=$1) AND inventory_item_id=$2 AND status='ACTIVE' AND (expires_at IS NULL OR expires_at>NOW())
+		RETURNING COALESCE(base_quantity,quantity)::float8`, ref, inventoryItemID, saleID).Scan(&quantity)
+	if isNoRows(err) {
+		return errReservationUnavailable
+	}
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE inventory_items SET reserved_quantity=GREATEST(0,reserved_quantity-$1),updated_at=NOW() WHERE id=$2`, quantity, inventoryItemID)
+	return err
+}
+
+// expireInventoryReservations marks lapsed active reservations EXPIRED and returns their
+// stock to the available pool. An empty inventoryItemID sweeps every item.
+func expireInventoryReservations(ctx context.Context, tx DBTx, inventoryItemID string) (int64, error) {
+	return tx.Exec(ctx, `WITH expired AS (
+		UPDATE inventory_reservations SET status='EXPIRED',released_at=NOW(),updated_at=NOW()
+		WHERE status='ACTIVE' AND expires_at IS NOT NULL AND expires_at <= NOW() AND ($1::text='' OR inventory_item_id::text=$1)
+		RETURNING inventory_item_id,COALESCE(base_quantity,quantity) AS quantity
+	)
+	UPDATE inventory_items ii SET reserved_quantity=GREATEST(0,ii.reserved_quantity-e.quantity),updated_at=NOW()
+	FROM (SELECT inventory_item_id,SUM(quantity) AS quantity FROM expired GROUP BY inventory_item_id) e
+	WHERE ii.id=e.inventory_item_id`, inventoryItemID)
+}
+
+// RunReservationExpirySweep releases every reservation whose TTL has passed.
+func RunReservationExpirySweep(ctx context.Context) error {
+	db := database.GetDB()
+	if db == nil {
+		return nil
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	items, err := expireInventoryReservations(ctx, pgxTxAdapter{tx: tx}, "")
+	if err != nil {
+		return err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	if items > 0 {
+		log.Printf("reservation expiry sweep released stock on %d inventory item(s)", items)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReservationExpiry(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	ttl, zero := 30, 0
+	future, past := now.Add(time.Hour), now.Add(-time.Minute)
+
+	if at, err := reservationExpiry(now, nil, nil); err != nil || at != nil {
+		t.Fatalf("expected no expiry, got %v %v", at, err)
+	}
+	if at, err := reservationExpiry(now, &ttl, nil); err != nil || !at.Equal(now.Add(30*time.Minute)) {
+		t.Fatalf("expected ttl expiry, got %v %v", at, err)
+	}
+	if at, err := reservationExpiry(now, nil, &future); err != nil || !at.Equal(future) {
+		t.Fatalf("expected explicit expiry, got %v %v", at, err)
+	}
+	if _, err := reservationExpiry(now, &zero, nil); err != errReservationTTLInvalid {
+		t.Fatalf("expected ttl error, got %v", err)
+	}
+	if _, err := reservationExpiry(now, nil, &past); err != errReservationExpiryPast {
+		t.Fatalf("expected past expiry error, got %v", err)
+	}
+	if _, err := reservationExpiry(now, &ttl, &future); err != errReservationExpiryConflict {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestReservationBaseQuantity(t *testing.T) {
+	base, zero := 24.0, 0.0
+	if got := reservationBaseQuantity(2, &base); got != 24 {
+		t.Fatalf("expected base quantity 24, got %v", got)
+	}
+	if got := reservationBaseQuantity(2, &zero); got != 2 {
+		t.Fatalf("expected quantity fallback 2, got %v", got)
+	}
+	if got := reservationBaseQuantity(3, nil); got != 3 {
+		t.Fatalf("expected quantity 3, got %v", got)
+	}
+}
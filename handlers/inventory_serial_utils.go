@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	errSerialsRequired   = errors.New("serial numbers are required for serial-tracked items, one per unit")
+	errSerialsNotTracked = errors.New("serial numbers are only accepted for serial-tracked items")
+	errDuplicateSerial   = errors.New("serial numbers must be unique")
+)
+
+// serialUnavailableError names the serial that could not change state.
+type serialUnavailableError struct {
+	SerialNumber string
+	Action       string
+}
+
+func (e serialUnavailableError) Error() string {
+	return fmt.Sprintf("serial %s is not available to %s", e.SerialNumber, e.Action)
+}
+
+// isSerialError reports whether err is a client-facing serial capture failure.
+func isSerialError(err error) bool {
+	var unavailable serialUnavailableError
+	return errors.Is(err, errSerialsRequired) || errors.Is(err, errSerialsNotTracked) || errors.Is(err, errDuplicateSerial) || errors.As(err, &unavailable)
+}
+
+// normalizeSerialNumbers trims serial numbers and checks there is exactly one per unit.
+func normalizeSerialNumbers(serials []string, quantity float64) ([]string, error) {
+	normalized := make([]string, 0, len(serials))
+	seen := make(map[string]struct{}, len(serials))
+	for _, serial := range serials {
+		serial = strings.TrimSpace(serial)
+		if serial == "" {
+			return nil, errSerialsRequired
+		}
+		if _, exists := seen[serial]; exists {
+			return nil, errDuplicateSerial
+		}
+		seen[serial] = struct{}{}
+		normalized = append(normalized, serial)
+	}
+	if float64(len(normalized)) != quantity {
+		return nil, errSerialsRequired
+	}
+	return normalized, nil
+}
+
+// stockItemTracksSerials reports whether the stock item requires serial capture.
+func stockItemTracksSerials(ctx context.Context, tx DBTx, stockItemID string) (bool, error) {
+	var tracked bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stock_items WHERE id=$1 AND tracking_mode='SERIAL')`, stockItemID).Scan(&tracked)
+	return tracked, err
+}
+
+// resolveSerialCapture validates the serial numbers supplied for a stock movement.
+// It returns nil for items that are not serial-tracked.
+func resolveSerialCapture(ctx context.Context, tx DBTx, stockItemID string, quantity float64, serials []string) ([]string, error) {
+	tracked, err := stockItemTracksSerials(ctx, tx, stockItemID)
+	if err != nil {
+		return nil, err
+	}
+	if !tracked {
+		if len(serials) > 0 {
+			return nil, errSerialsNotTracked
+		}
+		return nil, nil
+	}
+	return normalizeSerialNumbers(serials, quantity)
+}
+
+// recordSerialEvent appends an entry to a serial's traceability history.
+func recordSerialEvent(ctx context.Context, tx DBTx, serialID, eventType, shopID, referenceType, referenceID, actorID, notes string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO inventory_serial_events(serial_id,event_type,shop_id,reference_type,reference_id,actor_id,notes) VALUES($1,$2,$3,$4,$5,$6,$7)`, serialID, eventType, shopID, nullableString(referenceType), nullableString(referenceID), nullableString(actorID), nullableString(notes))
+	return err
+}
+
+// receiveInventorySerials registers the captured serials as AVAILABLE in the balance,
+// referencing the goods receipt that brought them in.
+func receiveInventorySerials(ctx context.Context, tx DBTx, stockItemID, inventoryItemID, shopID, receiptID, actorID string, quantity float64, serials []string) ([]string, error) {
+	captured, err := resolveSerialCapture(ctx, tx, stockItemID, quantity, serials)
+	if err != nil || captured == nil {
+		return nil, err
+	}
+	for _, serial := range captured {
+		var serialID string
+		if err := tx.QueryRow(ctx, `INSERT INTO inventory_serials(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id) SELECT merchant_id,shop_id,id,product_id,stock_item_id,$2,'AVAILABLE',$3 FROM inventory_items WHERE id=$1 ON CONFLICT (serial_number) DO NOTHING RETURNING id`, inventoryItemID, serial, receiptID).Scan(&serialID); err != nil {
+			if isNoRows(err) {
+				return nil, serialUnavailableError{SerialNumber: serial, Action: "receive"}
+			}
+			return nil, err
+		}
+		if err := recordSerialEvent(ctx, tx, serialID, "RECEIVED", shopID, "GOODS_RECEIPT", receiptID, actorID, ""); err != nil {
+			return nil, err
+		}
+	}
+	return captured, nil
+}
+
+// sellInventorySerials marks the captured serials SOLD against a sale and starts their warranty.
+func sellInventorySerials(ctx context.Context, tx DBTx, stockItemID, inventoryItemID, shopID, saleID, actorID string, quantity float64, serials []string) ([]string, error) {
+	captured, err := resolveSerialCapture(ctx, tx, stockItemID, quantity, serials)
+	if err != nil || captured == nil {
+		return nil, err
+	}
+	for _, serial := range captured {
+		var serialID string
+		if err := tx.QueryRow(ctx, `UPDATE inventory_serials SET status='SOLD',reference_id=$1 WHERE inventory_item_id=$2 AND serial_number=$3 AND status IN ('AVAILABLE','RETURNED') RETURNING id`, saleID, inventoryItemID, serial).Scan(&serialID); err != nil {
+			if isNoRows(err) {
+				return nil, serialUnavailableError{SerialNumber: serial, Action: "sell"}
+			}
+			return nil, err
+		}
+		if err := recordSerialEvent(ctx, tx, serialID, "SOLD", shopID, "SALE", saleID, actorID, ""); err != nil {
+			return nil, err
+		}
+		if err := startWarranty(ctx, tx, serialID, "", saleID, nil, nil); err != nil {
+			return nil, err
+		}
+	}
+	return captured, nil
+}
+
+// transferInventorySerials moves the captured serials to the destination shop balance.
+func transferInventorySerials(ctx context.Context, tx DBTx, stockItemID, fromInventoryID, toInventoryID, toShopID, transferID, actorID string, quantity float64, serials []string) ([]string, error) {
+	captured, err := resolveSerialCapture(ctx, tx, stockItemID, quantity, serials)
+	if err != nil || captured == nil {
+		return nil, err
+	}
+	for _, serial := range captured {
+		var serialID, fromShopID string
+		if err := tx.QueryRow(ctx, `UPDATE inventory_serials s SET shop_id=$1,inventory_item_id=$2 FROM inventory_items src WHERE src.id=s.inventory_item_id AND s.inventory_item_id=$3 AND s.serial_number=$4 AND s.status IN ('AVAILABLE','RETURNED') RETURNING s.id,src.shop_id`, toShopID, toInventoryID, fromInventoryID, serial).Scan(&serialID, &fromShopID); err != nil {
+			if isNoRows(err) {
+				return nil, serialUnavailableError{SerialNumber: serial, Action: "transfer"}
+			}
+			return nil, err
+		}
+		if err := recordSerialEvent(ctx, tx, serialID, "TRANSFERRED", toShopID, "TRANSFER", transferID, actorID, fmt.Sprintf("Transferred from shop %s", fromShopID)); err != nil {
+			return nil, err
+		}
+	}
+	return captured, nil
+}
+
+// returnInventorySerials marks serials sold on a sale as RETURNED and voids their warranty.
+func returnInventorySerials(ctx context.Context, tx DBTx, stockItemID, inventoryItemID, shopID, saleID, actorID string, quantity float64, serials []string) ([]string, error) {
+	captured, err := resolveSerialCapture(ctx, tx, stockItemID, quantity, serials)
+	if err != nil || captured == nil {
+		return nil, err
+	}
+	for _, serial := range captured {
+		var serialID string
+		if err := tx.QueryRow(ctx, `UPDATE inventory_serials SET status='RETURNED' WHERE inventory_item_id=$1 AND serial_number=$2 AND status='SOLD' AND reference_id=$3 RETURNING id`, inventoryItemID, serial, saleID).Scan(&serialID); err != nil {
+			if isNoRows(err) {
+				return nil, serialUnavailableError{SerialNumber: serial, Action: "return against this sale"}
+			}
+			return nil, err
+		}
+		if err := recordSerialEvent(ctx, tx, serialID, "RETURNED", shopID, "SALE_RETURN", saleID, actorID, ""); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE inventory_warranties SET status='VOID' WHERE serial_id=$1 AND status='ACTIVE'`, serialID); err != nil {
+			return nil, err
+		}
+	}
+	return captured, nil
+}
+
+// serialErrorStatus maps serial capture failures to HTTP status codes.
+func serialErrorStatus(err error) int {
+	var unavailable serialUnavailableError
+	if errors.As(err, &unavailable) {
+		return 409
+	}
+	return 400
+}
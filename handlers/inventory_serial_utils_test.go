@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeSerialNumbers(t *testing.T) {
+	serials, err := normalizeSerialNumbers([]string{" SN-1 ", "SN-2"}, 2)
+	if err != nil || len(serials) != 2 || serials[0] != "SN-1" {
+		t.Fatalf("unexpected result %v, %v", serials, err)
+	}
+	if _, err := normalizeSerialNumbers([]string{"SN-1"}, 2); !errors.Is(err, errSerialsRequired) {
+		t.Fatalf("expected missing serials error, got %v", err)
+	}
+	if _, err := normalizeSerialNumbers([]string{"SN-1", " SN-1"}, 2); !errors.Is(err, errDuplicateSerial) {
+		t.Fatalf("expected duplicate serial error, got %v", err)
+	}
+	if _, err := normalizeSerialNumbers([]string{"SN-1", ""}, 2); !errors.Is(err, errSerialsRequired) {
+		t.Fatalf("expected blank serial error, got %v", err)
+	}
+}
+
+func TestSerialErrorStatus(t *testing.T) {
+	if status := serialErrorStatus(serialUnavailableError{SerialNumber: "SN-1", Action: "sell"}); status != 409 {
+		t.Fatalf("expected 409, got %d", status)
+	}
+	if status := serialErrorStatus(errSerialsRequired); status != 400 {
+		t.Fatalf("expected 400, got %d", status)
+	}
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"app/database"
+	"app/middleware"
+	"app/models"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var transformationTypes = map[string]bool{"PACK_BREAK": true, "REPACK": true, "ASSEMBLY": true}
+var transformationSortFields = map[string]string{"createdAt": "created_at", "transformationType": "transformation_type"}
+
+var errPartialPack = errors.New("input quantity does not make a whole number of packs")
+
+// convertedOutputQuantity applies a unit conversion factor to the input of a pack-break or repack.
+// Repacking must produce whole packs.
+func convertedOutputQuantity(transformationType string, inputQuantity, factor float64) (float64, error) {
+	output := math.Round(inputQuantity*factor*1000) / 1000
+	if output <= 0 {
+		return 0, errPartialPack
+	}
+	if transformationType == "REPACK" && math.Abs(output-math.Round(output)) > 0.0005 {
+		return 0, errPartialPack
+	}
+	return output, nil
+}
+
+// carriedUnitCost spreads the cost consumed by a transformation over its output quantity.
+func carriedUnitCost(totalCost, outputQuantity float64) float64 {
+	if outputQuantity <= 0 {
+		return 0
+	}
+	return totalCost / outputQuantity
+}
+
+// transformationLine is a resolved input or output of a transformation run.
+type transformationLine struct {
+	models.InventoryTransformationLineRequest
+	Direction       string
+	ProductID       string
+	InventoryItemID string
+	BaseQuantity    float64
+	Cost            float64
+	BinID           *string
+}
+
+func (l transformationLine) unitCost() float64 {
+	if l.Quantity <= 0 {
+		return 0
+	}
+	return l.Cost / l.Quantity
+}
+
+// baseUnitCost is the line cost per base unit, as recorded on its movement.
+func (l transformationLine) baseUnitCost() float64 {
+	if l.BaseQuantity <= 0 {
+		return 0
+	}
+	return l.Cost / l.BaseQuantity
+}
+
+// selectedUnitID normalizes an optional client unit selection.
+func selectedUnitID(unitID *string) string {
+	return selectedBatchID(unitID)
+}
+
+// resolveTransformationLine checks the stock item belongs to the merchant and converts the line to base units.
+func resolveTransformationLine(ctx context.Context, tx pgx.Tx, merchantID string, line *transformationLine) error {
+	var trackingMode string
+	var toBase *float64
+	err := tx.QueryRow(ctx, `SELECT si.product_id,si.tracking_mode,CASE WHEN $3='' THEN 1 ELSE COALESCE((SELECT conversion_to_base FROM stock_item_units WHERE stock_item_id=si.id AND unit_id::text=$3),CASE WHEN si.base_unit_id::text=$3 THEN 1 END) END FROM stock_items si WHERE si.id=$1 AND si.merchant_id=$2`, line.StockItemID, merchantID, selectedUnitID(line.UnitID)).Scan(&line.ProductID, &trackingMode, &toBase)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, fmt.Sprintf("stock item %s not found", line.StockItemID))
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load stock item")
+	}
+	if trackingMode == "SERIAL" {
+		return fiber.NewError(400, "serial-tracked items cannot be transformed")
+	}
+	if toBase == nil {
+		return fiber.NewError(400, fmt.Sprintf("unit is not configured for stock item %s", line.StockItemID))
+	}
+	line.BaseQuantity = line.Quantity * *toBase
+	return nil
+}
+
+// HandleCreateInventoryTransformation breaks packs, repacks units or assembles components within one shop.
+// Inputs are posted as OUT movements and outputs as IN movements under one transformation,
+// and the cost consumed from the inputs is carried to the output.
+func HandleCreateInventoryTransformation(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.InventoryTransformationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	req.ClientOperationID = strings.TrimSpace(req.ClientOperationID)
+	req.TransformationType = strings.ToUpper(strings.TrimSpace(req.TransformationType))
+	if req.ClientOperationID == "" {
+		return fiber.NewError(400, "clientOperationId is required")
+	}
+	if !transformationTypes[req.TransformationType] {
+		return fiber.NewError(400, "transformationType must be one of PACK_BREAK, REPACK, ASSEMBLY")
+	}
+	packOperation := req.TransformationType != "ASSEMBLY"
+	if packOperation {
+		if len(req.Inputs) != 1 || len(req.Outputs) != 1 {
+			return fiber.NewError(400, "pack breaking and repacking take exactly one input and one output")
+		}
+		if selectedUnitID(req.Inputs[0].UnitID) == "" || selectedUnitID(req.Outputs[0].UnitID) == "" {
+			return fiber.NewError(400, "unitId is required on the input and the output")
+		}
+	} else if len(req.Inputs) == 0 || len(req.Inputs) > 50 || len(req.Outputs) != 1 {
+		return fiber.NewError(400, "assembly takes between 1 and 50 inputs and exactly one output")
+	}
+	lines := make([]transformationLine, 0, len(req.Inputs)+1)
+	seen := make(map[string]struct{}, len(req.Inputs)+1)
+	for _, group := range []struct {
+		direction string
+		items     []models.InventoryTransformationLineRequest
+	}{{"OUT", req.Inputs}, {"IN", req.Outputs}} {
+		for _, item := range group.items {
+			item.StockItemID = strings.TrimSpace(item.StockItemID)
+			if item.StockItemID == "" || item.Quantity < 0 || (item.Quantity == 0 && (group.direction == "OUT" || !packOperation)) {
+				return fiber.NewError(400, "each line needs a stockItemId and a positive quantity")
+			}
+			if _, exists := seen[item.StockItemID]; exists {
+				return fiber.NewError(400, "each stock item may appear only once in a transformation")
+			}
+			seen[item.StockItemID] = struct{}{}
+			lines = append(lines, transformationLine{InventoryTransformationLineRequest: item, Direction: group.direction})
+		}
+	}
+	output := &lines[len(lines)-1]
+
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start transformation")
+	}
+	defer tx.Rollback(ctx)
+	var merchantID string
+	if err = tx.QueryRow(ctx, `SELECT merchant_id FROM shops WHERE id=$1`, shopID).Scan(&merchantID); err != nil {
+		return fiber.NewError(404, "shop not found")
+	}
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "inventory_transformation", claims.UserID, &shopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to start transformation")
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Transformation already processed"})
+	}
+
+	if packOperation {
+		input := lines[0]
+		if req.TransformationType == "PACK_BREAK" {
+			var allowed bool
+			if err = tx.QueryRow(ctx, `SELECT COALESCE((SELECT allow_pack_breaking FROM stock_item_configurations WHERE stock_item_id=$1),FALSE)`, input.StockItemID).Scan(&allowed); err != nil {
+				return fiber.NewError(500, "failed to load stock configuration")
+			}
+			if !allowed {
+				return fiber.NewError(409, "pack breaking is not enabled for this stock item")
+			}
+		}
+		var factor float64
+		err = tx.QueryRow(ctx, `SELECT CASE WHEN from_unit_id=$3 THEN factor ELSE 1/factor END FROM stock_item_unit_conversions WHERE stock_item_id IN ($1,$2) AND ((from_unit_id=$3 AND to_unit_id=$4) OR (from_unit_id=$4 AND to_unit_id=$3)) ORDER BY (from_unit_id=$3) DESC,(stock_item_id=$1) DESC LIMIT 1`, input.StockItemID, output.StockItemID, selectedUnitID(input.UnitID), selectedUnitID(output.UnitID)).Scan(&factor)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(400, "no unit conversion is defined between the input and output units")
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to load unit conversion")
+		}
+		quantity, convErr := convertedOutputQuantity(req.TransformationType, input.Quantity, factor)
+		if convErr != nil {
+			return fiber.NewError(400, convErr.Error())
+		}
+		if output.Quantity > 0 && math.Abs(output.Quantity-quantity) > 0.0005 {
+			return fiber.NewError(400, fmt.Sprintf("output quantity must be %g for this unit conversion", quantity))
+		}
+		output.Quantity = quantity
+	}
+	for i := range lines {
+		if err := resolveTransformationLine(ctx, tx, merchantID, &lines[i]); err != nil {
+			return err
+		}
+	}
+
+	var transformation models.InventoryTransformation
+	if err = tx.QueryRow(ctx, `INSERT INTO inventory_transformations(merchant_id,shop_id,transformation_type,reference_id,notes,created_by) VALUES($1,$2,$3,$4,$5,$6) RETURNING id,merchant_id,shop_id,transformation_type,reference_id,notes,created_by,created_at`, merchantID, shopID, req.TransformationType, nullableStringValue(req.ReferenceID), nullableStringValue(req.Notes), claims.UserID).Scan(&transformation.ID, &transformation.MerchantID, &transformation.ShopID, &transformation.TransformationType, &transformation.ReferenceID, &transformation.Notes, &transformation.CreatedBy, &transformation.CreatedAt); err != nil {
+		return fiber.NewError(500, "failed to create transformation")
+	}
+
+	var totalCost float64
+	var earliestExpiry *string
+	for i := range lines[:len(lines)-1] {
+		line := &lines[i]
+		var onHand float64
+		err = tx.QueryRow(ctx, `SELECT id,quantity_on_hand FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, shopID, line.StockItemID).Scan(&line.InventoryItemID, &onHand)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(404, fmt.Sprintf("stock item %s is not stocked in this shop", line.StockItemID))
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to lock input stock")
+		}
+		if onHand < line.BaseQuantity {
+			return fiber.NewError(409, fmt.Sprintf("insufficient stock for stock item %s", line.StockItemID))
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2`, line.BaseQuantity, line.InventoryItemID); err != nil {
+			return fiber.NewError(500, "failed to update input stock")
+		}
+		allocations, err := consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, line.StockItemID, line.InventoryItemID, line.BaseQuantity, selectedBatchID(line.BatchID))
+		if err != nil {
+			if isBatchConsumptionError(err) {
+				return fiber.NewError(409, fmt.Sprintf("stock item %s: %v", line.StockItemID, err))
+			}
+			return fiber.NewError(500, "failed to update input batches")
+		}
+		issueCost, err := issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, line.InventoryItemID, line.BaseQuantity, allocations)
+		if err != nil {
+			return fiber.NewError(500, "failed to cost input stock")
+		}
+		line.Cost = line.BaseQuantity * issueCost
+		for _, allocation := range allocations {
+			if allocation.ExpiryDate != nil && (earliestExpiry == nil || *allocation.ExpiryDate < *earliestExpiry) {
+				earliestExpiry = allocation.ExpiryDate
+			}
+		}
+		totalCost += line.Cost
+	}
+
+	output.Cost = totalCost
+	var outputQty float64
+	err = tx.QueryRow(ctx, `SELECT id,quantity_on_hand FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, shopID, output.StockItemID).Scan(&output.InventoryItemID, &outputQty)
+	if err == pgx.ErrNoRows {
+		err = tx.QueryRow(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES($1,$2,$3,$4,$5) RETURNING id`, merchantID, shopID, output.ProductID, output.StockItemID, output.BaseQuantity).Scan(&output.InventoryItemID)
+	} else if err == nil {
+		_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, output.BaseQuantity, output.InventoryItemID)
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to update output stock")
+	}
+	outputCost := carriedUnitCost(totalCost, output.BaseQuantity)
+	if _, err = receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, output.InventoryItemID, output.BaseQuantity, &outputCost); err != nil {
+		return fiber.NewError(500, "failed to cost output stock")
+	}
+	tracked, err := stockItemTracksBatches(ctx, pgxTxAdapter{tx: tx}, output.StockItemID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load output configuration")
+	}
+	if tracked {
+		batch := batchAllocation{BatchCode: "XF-" + strings.ToUpper(transformation.ID[:8]), Quantity: output.BaseQuantity, UnitCost: outputCost, ExpiryDate: earliestExpiry}
+		if err = receiveTransferredBatches(ctx, pgxTxAdapter{tx: tx}, merchantID, shopID, output.InventoryItemID, output.ProductID, output.StockItemID, []batchAllocation{batch}); err != nil {
+			return fiber.NewError(500, "failed to create output batch")
+		}
+	}
+
+	notes := fmt.Sprintf("%s transformation", strings.ReplaceAll(strings.ToLower(req.TransformationType), "_", " "))
+	if transformation.Notes != nil {
+		notes = fmt.Sprintf("%s: %s", notes, *transformation.Notes)
+	}
+	for _, line := range lines {
+		record := models.InventoryTransformationLine{TransformationID: transformation.ID, InventoryItemID: line.InventoryItemID, Direction: line.Direction, Quantity: line.Quantity}
+		if err = tx.QueryRow(ctx, `INSERT INTO inventory_transformation_lines(transformation_id,inventory_item_id,stock_item_id,unit_id,direction,quantity,base_quantity,unit_cost) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id,stock_item_id,unit_id,base_quantity,unit_cost`, transformation.ID, line.InventoryItemID, line.StockItemID, nullableStringValue(line.UnitID), line.Direction, line.Quantity, line.BaseQuantity, line.unitCost()).Scan(&record.ID, &record.StockItemID, &record.UnitID, &record.BaseQuantity, &record.UnitCost); err != nil {
+			return fiber.NewError(500, "failed to record transformation line")
+		}
+		delta := line.BaseQuantity
+		if line.Direction == "OUT" {
+			delta = -delta
+		}
+		if line.BinID, err = moveDefaultBinStock(ctx, pgxTxAdapter{tx: tx}, line.InventoryItemID, delta); err != nil {
+			return fiber.NewError(500, "failed to update bin balance")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,'TRANSFORMATION',$11,$12,$13,$14)`, merchantID, shopID, line.InventoryItemID, line.ProductID, line.StockItemID, nullableStringValue(line.UnitID), line.Direction, line.Quantity, line.BaseQuantity, line.baseUnitCost(), transformation.ID, fmt.Sprintf("%s:%s", req.ClientOperationID, line.StockItemID), notes, line.BinID); err != nil {
+			return fiber.NewError(500, "failed to record stock movement")
+		}
+		transformation.Lines = append(transformation.Lines, record)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit transformation")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": transformation})
+}
+
+func scanInventoryTransformation(scan func(...interface{}) error) (models.InventoryTransformation, error) {
+	var item models.InventoryTransformation
+	err := scan(&item.ID, &item.MerchantID, &item.ShopID, &item.TransformationType, &item.ReferenceID, &item.Notes, &item.CreatedBy, &item.CreatedAt)
+	return item, err
+}
+
+func HandleListInventoryTransformations(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "createdAt", transformationSortFields)
+	where := " WHERE shop_id=$1"
+	args := []interface{}{shopID}
+	if v := strings.ToUpper(strings.TrimSpace(c.Query("transformationType"))); v != "" {
+		where += " AND transformation_type=$" + itoa(len(args)+1)
+		args = append(args, v)
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM inventory_transformations"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count transformations")
+	}
+	rows, err := db.Query(ctx, "SELECT id,merchant_id,shop_id,transformation_type,reference_id,notes,created_by,created_at FROM inventory_transformations"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list transformations")
+	}
+	defer rows.Close()
+	items := make([]models.InventoryTransformation, 0)
+	for rows.Next() {
+		item, scanErr := scanInventoryTransformation(rows.Scan)
+		if scanErr != nil {
+			return fiber.NewError(500, "failed to read transformation")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleGetInventoryTransformation(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	item, err := scanInventoryTransformation(db.QueryRow(ctx, `SELECT id,merchant_id,shop_id,transformation_type,reference_id,notes,created_by,created_at FROM inventory_transformations WHERE id=$1 AND shop_id=$2`, c.Params("transformationId"), shopID).Scan)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "transformation not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load transformation")
+	}
+	rows, err := db.Query(ctx, `SELECT id,transformation_id,inventory_item_id,stock_item_id,unit_id,direction,quantity,base_quantity,unit_cost FROM inventory_transformation_lines WHERE transformation_id=$1 ORDER BY direction DESC,id`, item.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load transformation lines")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.InventoryTransformationLine
+		if err := rows.Scan(&line.ID, &line.TransformationID, &line.InventoryItemID, &line.StockItemID, &line.UnitID, &line.Direction, &line.Quantity, &line.BaseQuantity, &line.UnitCost); err != nil {
+			return fiber.NewError(500, "failed to read transformation line")
+		}
+		item.Lines = append(item.Lines, line)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
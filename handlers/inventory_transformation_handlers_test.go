@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestConvertedOutputQuantity(t *testing.T) {
+	if qty, err := convertedOutputQuantity("PACK_BREAK", 2, 24); err != nil || qty != 48 {
+		t.Fatalf("expected 48 units, got %v, %v", qty, err)
+	}
+	if qty, err := convertedOutputQuantity("REPACK", 12, 1.0/6); err != nil || qty != 2 {
+		t.Fatalf("expected 2 packs, got %v, %v", qty, err)
+	}
+	if _, err := convertedOutputQuantity("REPACK", 10, 1.0/6); !errors.Is(err, errPartialPack) {
+		t.Fatalf("expected partial pack error, got %v", err)
+	}
+}
+
+func TestCarriedUnitCost(t *testing.T) {
+	if cost := carriedUnitCost(120, 48); cost != 2.5 {
+		t.Fatalf("expected 2.5, got %v", cost)
+	}
+	if cost := carriedUnitCost(120, 0); cost != 0 {
+		t.Fatalf("expected 0 for empty output, got %v", cost)
+	}
+}
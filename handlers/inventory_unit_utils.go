@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+)
+
+var (
+	errUnitNotConfigured  = errors.New("unit is not configured for this stock item")
+	errFractionalQuantity = errors.New("quantity must be a whole number in this unit")
+)
+
+// isUnitError reports whether err is a client-facing unit conversion failure.
+func isUnitError(err error) bool {
+	return errors.Is(err, errUnitNotConfigured) || errors.Is(err, errFractionalQuantity)
+}
+
+// unitQuantity is a line quantity in the unit it was entered in and in base units.
+type unitQuantity struct {
+	UnitID       *string
+	Quantity     float64
+	BaseQuantity float64
+	Factor       float64
+}
+
+// checkUnitQuantity rejects fractional quantities for units that only allow whole numbers.
+func checkUnitQuantity(quantity float64, allowsFractional bool) error {
+	if allowsFractional {
+		return nil
+	}
+	if math.Abs(quantity-math.Round(quantity)) > 1e-9 {
+		return errFractionalQuantity
+	}
+	return nil
+}
+
+// baseQuantityFor converts a unit quantity to base units at stock balance precision.
+func baseQuantityFor(quantity, factor float64) float64 {
+	return math.Round(quantity*factor*1000) / 1000
+}
+
+// resolveUnitQuantity converts quantity in unitID to the stock item's base unit.
+// An empty unitID means the quantity is already in the base unit.
+func resolveUnitQuantity(ctx context.Context, tx DBTx, stockItemID string, unitID *string, quantity float64) (unitQuantity, error) {
+	requested := ""
+	if unitID != nil {
+		requested = strings.TrimSpace(*unitID)
+	}
+	var resolvedUnit *string
+	var factor float64
+	var allowsFractional, configured bool
+	err := tx.QueryRow(ctx, `SELECT x.unit_id, COALESCE(su.conversion_to_base,1)::float8, COALESCE(su.allows_fractional,u.allows_decimal,TRUE), su.id IS NOT NULL OR x.unit_id IS NOT DISTINCT FROM si.base_unit_id::text
+		FROM stock_items si
+		CROSS JOIN LATERAL (SELECT COALESCE(NULLIF($2,''), si.base_unit_id::text) AS unit_id) x
+		LEFT JOIN stock_item_units su ON su.stock_item_id=si.id AND su.unit_id::text=x.unit_id
+		LEFT JOIN unit_definitions u ON u.id::text=x.unit_id
+		WHERE si.id=$1`, stockItemID, requested).Scan(&resolvedUnit, &factor, &allowsFractional, &configured)
+	if err != nil {
+		return unitQuantity{}, err
+	}
+	if !configured {
+		return unitQuantity{}, errUnitNotConfigured
+	}
+	if err := checkUnitQuantity(quantity, allowsFractional); err != nil {
+		return unitQuantity{}, err
+	}
+	return unitQuantity{UnitID: resolvedUnit, Quantity: quantity, BaseQuantity: baseQuantityFor(quantity, factor), Factor: factor}, nil
+}
+
+// reportQuantity expresses a base quantity in a report unit worth factor base units.
+func reportQuantity(baseQuantity, factor float64) float64 {
+	if factor <= 0 {
+		factor = 1
+	}
+	return math.Round(baseQuantity/factor*1000) / 1000
+}
+
+// reportUnitJoin joins the merchant's preferred report unit for the stock item
+// in stockItemColumn as ru(factor, code), falling back to the base unit.
+func reportUnitJoin(stockItemColumn string) string {
+	return ` LEFT JOIN LATERAL (SELECT COALESCE(su.conversion_to_base,1)::float8 AS factor, u.code FROM stock_items rsi LEFT JOIN stock_item_units su ON su.stock_item_id=rsi.id AND su.is_report_unit LEFT JOIN unit_definitions u ON u.id=COALESCE(su.unit_id,rsi.base_unit_id) WHERE rsi.id=` + stockItemColumn + ` LIMIT 1) ru ON TRUE`
+}
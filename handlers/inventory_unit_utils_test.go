@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestCheckUnitQuantity(t *testing.T) {
+	if err := checkUnitQuantity(2, false); err != nil {
+		t.Fatalf("expected whole quantity to pass, got %v", err)
+	}
+	if err := checkUnitQuantity(1.5, false); err != errFractionalQuantity {
+		t.Fatalf("expected errFractionalQuantity, got %v", err)
+	}
+	if err := checkUnitQuantity(0.25, true); err != nil {
+		t.Fatalf("expected fractional unit to accept 0.25, got %v", err)
+	}
+}
+
+func TestUnitQuantityConversions(t *testing.T) {
+	if got := baseQuantityFor(3, 12); got != 36 {
+		t.Fatalf("expected 3 dozen to be 36 base units, got %v", got)
+	}
+	if got := baseQuantityFor(250, 0.001); got != 0.25 {
+		t.Fatalf("expected 250 g to be 0.25 kg, got %v", got)
+	}
+	if got := reportQuantity(30, 12); got != 2.5 {
+		t.Fatalf("expected 30 units to report as 2.5 dozen, got %v", got)
+	}
+	if got := reportQuantity(7, 0); got != 7 {
+		t.Fatalf("expected a missing factor to report base quantity, got %v", got)
+	}
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+)
+
+const (
+	landedCostByValue    = "VALUE"
+	landedCostByQuantity = "QUANTITY"
+	landedCostByWeight   = "WEIGHT"
+)
+
+var landedCostTypes = map[string]bool{"FREIGHT": true, "DUTY": true, "CUSTOMS": true, "INSURANCE": true, "OTHER": true}
+
+var landedCostMethods = map[string]bool{landedCostByValue: true, landedCostByQuantity: true, landedCostByWeight: true}
+
+var (
+	errLandedCostType   = errors.New("costType must be FREIGHT, DUTY, CUSTOMS, INSURANCE or OTHER")
+	errLandedCostMethod = errors.New("allocationMethod must be VALUE, QUANTITY or WEIGHT")
+	errLandedCostAmount = errors.New("landed cost amount cannot be negative")
+	errLandedCostBasis  = errors.New("receipt lines have nothing to allocate this cost by; weight allocation needs line weights")
+)
+
+// validateLandedCost normalizes a landed cost line, allocating by value unless told otherwise.
+func validateLandedCost(req *models.GoodsReceiptCostRequest) error {
+	req.CostType = strings.ToUpper(strings.TrimSpace(req.CostType))
+	req.AllocationMethod = strings.ToUpper(strings.TrimSpace(req.AllocationMethod))
+	if req.AllocationMethod == "" {
+		req.AllocationMethod = landedCostByValue
+	}
+	switch {
+	case !landedCostTypes[req.CostType]:
+		return errLandedCostType
+	case !landedCostMethods[req.AllocationMethod]:
+		return errLandedCostMethod
+	case req.Amount < 0:
+		return errLandedCostAmount
+	}
+	req.Amount = roundMoney(req.Amount)
+	return nil
+}
+
+// isLandedCostError reports whether err is a client-facing landed cost failure.
+func isLandedCostError(err error) bool {
+	return errors.Is(err, errLandedCostType) || errors.Is(err, errLandedCostMethod) || errors.Is(err, errLandedCostAmount) || errors.Is(err, errLandedCostBasis)
+}
+
+// landedCostLine is a goods receipt line as landed costs see it. Value is the supplier
+// cost of the line, Quantity its base quantity and LandedCost what it carries so far.
+type landedCostLine struct {
+	ID          string  `json:"id"`
+	StockItemID string  `json:"stock_item_id"`
+	Value       float64 `json:"value"`
+	Quantity    float64 `json:"quantity"`
+	Weight      float64 `json:"weight"`
+	LandedCost  float64 `json:"landed_cost"`
+}
+
+func (l landedCostLine) basis(method string) float64 {
+	switch method {
+	case landedCostByQuantity:
+		return l.Quantity
+	case landedCostByWeight:
+		return l.Weight
+	}
+	return l.Value
+}
+
+// allocateLandedCost splits amount across lines in proportion to their basis. The
+// rounding remainder goes to the line with the largest basis so the shares add up.
+func allocateLandedCost(lines []landedCostLine, amount float64, method string) ([]float64, error) {
+	shares := make([]float64, len(lines))
+	if amount == 0 {
+		return shares, nil
+	}
+	var total float64
+	for _, line := range lines {
+		if b := line.basis(method); b > 0 {
+			total += b
+		}
+	}
+	if total <= 0 {
+		return nil, errLandedCostBasis
+	}
+	var allocated float64
+	largest := -1
+	for i, line := range lines {
+		b := line.basis(method)
+		if b <= 0 {
+			continue
+		}
+		shares[i] = roundCost(amount * b / total)
+		allocated += shares[i]
+		if largest < 0 || b > lines[largest].basis(method) {
+			largest = i
+		}
+	}
+	shares[largest] = roundCost(shares[largest] + amount - allocated)
+	return shares, nil
+}
+
+// costRevaluation splits a change in a receipt line's landed cost between the stock
+// from that line still on hand and the stock already issued.
+type costRevaluation struct {
+	UnitDelta        float64
+	RevaluedQuantity float64
+	InventoryAmount  float64
+	ConsumedQuantity float64
+	ConsumedAmount   float64
+}
+
+func landedCostRevaluation(baseQuantity, remaining, change float64) costRevaluation {
+	if baseQuantity <= 0 {
+		return costRevaluation{}
+	}
+	remaining = math.Max(0, math.Min(remaining, baseQuantity))
+	rev := costRevaluation{
+		UnitDelta:        roundCost(change / baseQuantity),
+		RevaluedQuantity: remaining,
+		InventoryAmount:  roundMoney(change * remaining / baseQuantity),
+		ConsumedQuantity: roundBaseQuantity(baseQuantity - remaining),
+	}
+	rev.ConsumedAmount = roundMoney(change - rev.InventoryAmount)
+	return rev
+}
+
+// revaluedAverageCost spreads a change in stock value over the quantity on hand.
+func revaluedAverageCost(average, onHand, amount float64) float64 {
+	if onHand <= 0 {
+		return average
+	}
+	return math.Max(0, roundCost(average+amount/onHand))
+}
+
+// receiptLandedCostLines loads the lines of a goods receipt in a stable order.
+func receiptLandedCostLines(ctx context.Context, tx DBTx, receiptID string) ([]landedCostLine, error) {
+	var raw string
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(json_agg(l ORDER BY l.id),'[]'::json)::text FROM (SELECT id,COALESCE(stock_item_id::text,'') AS stock_item_id,(unit_cost*quantity)::float8 AS value,COALESCE(base_quantity,quantity)::float8 AS quantity,COALESCE(weight,0)::float8 AS weight,landed_cost::float8 AS landed_cost FROM goods_receipt_items WHERE goods_receipt_id=$1 FOR UPDATE) l`, receiptID).Scan(&raw); err != nil {
+		return nil, err
+	}
+	var lines []landedCostLine
+	err := json.Unmarshal([]byte(raw), &lines)
+	return lines, err
+}
+
+// insertLandedCostAllocations records each line's share of one landed cost.
+func insertLandedCostAllocations(ctx context.Context, tx DBTx, costID string, lines []landedCostLine, shares []float64) error {
+	for i, share := range shares {
+		if share <= 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO goods_receipt_cost_allocations(goods_receipt_cost_id,goods_receipt_item_id,amount) VALUES($1,$2,$3)`, costID, lines[i].ID, share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reallocateLandedCosts spreads every landed cost of a receipt over its lines again and
+// revalues the stock of each line whose share changed, posting a cost adjustment for it.
+func reallocateLandedCosts(ctx context.Context, tx DBTx, merchantID, shopID, receiptID, referenceID, userID string) error {
+	lines, err := receiptLandedCostLines(ctx, tx, receiptID)
+	if err != nil {
+		return err
+	}
+	var raw string
+	if err = tx.QueryRow(ctx, `SELECT COALESCE(json_agg(c ORDER BY c.created_at,c.id),'[]'::json)::text FROM (SELECT id,amount::float8 AS amount,allocation_method,created_at FROM goods_receipt_costs WHERE goods_receipt_id=$1) c`, receiptID).Scan(&raw); err != nil {
+		return err
+	}
+	var costs []struct {
+		ID               string  `json:"id"`
+		Amount           float64 `json:"amount"`
+		AllocationMethod string  `json:"allocation_method"`
+	}
+	if err = json.Unmarshal([]byte(raw), &costs); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM goods_receipt_cost_allocations WHERE goods_receipt_cost_id IN (SELECT id FROM goods_receipt_costs WHERE goods_receipt_id=$1)`, receiptID); err != nil {
+		return err
+	}
+	totals := make([]float64, len(lines))
+	for _, cost := range costs {
+		shares, err := allocateLandedCost(lines, cost.Amount, cost.AllocationMethod)
+		if err != nil {
+			return err
+		}
+		if err = insertLandedCostAllocations(ctx, tx, cost.ID, lines, shares); err != nil {
+			return err
+		}
+		for i, share := range shares {
+			totals[i] += share
+		}
+	}
+	for i, line := range lines {
+		change := roundCost(totals[i] - line.LandedCost)
+		if change == 0 {
+			continue
+		}
+		if err = revalueReceiptLine(ctx, tx, merchantID, shopID, receiptID, line, change, referenceID, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revalueReceiptLine moves the cost of the stock a receipt line brought in by change in
+// total: its FIFO layer, its batch and the balance's average take the part still on
+// hand, and the rest is recorded as already consumed.
+func revalueReceiptLine(ctx context.Context, tx DBTx, merchantID, shopID, receiptID string, line landedCostLine, change float64, referenceID, userID string) error {
+	if _, err := tx.Exec(ctx, `UPDATE goods_receipt_items SET landed_cost=GREATEST(landed_cost+$1,0) WHERE id=$2`, change, line.ID); err != nil {
+		return err
+	}
+	var invID string
+	var batchID *string
+	err := tx.QueryRow(ctx, `SELECT inventory_item_id,batch_id FROM inventory_movements WHERE reference_type='GOODS_RECEIPT' AND reference_id=$1 AND stock_item_id::text=$2 ORDER BY movement_date LIMIT 1`, receiptID, line.StockItemID).Scan(&invID, &batchID)
+	if isNoRows(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var onHand float64
+	if err = tx.QueryRow(ctx, `SELECT quantity_on_hand::float8 FROM inventory_items WHERE id=$1 FOR UPDATE`, invID).Scan(&onHand); err != nil {
+		return err
+	}
+	var layerID string
+	var remaining float64
+	err = tx.QueryRow(ctx, `SELECT id,quantity_remaining::float8 FROM inventory_cost_layers WHERE goods_receipt_item_id=$1 FOR UPDATE`, line.ID).Scan(&layerID, &remaining)
+	if isNoRows(err) {
+		// Receipts from before layers were linked: assume what is on hand came in last.
+		remaining, err = math.Max(0, math.Min(onHand, line.Quantity)), nil
+	}
+	if err != nil {
+		return err
+	}
+	rev := landedCostRevaluation(line.Quantity, remaining, change)
+	if layerID != "" {
+		if _, err = tx.Exec(ctx, `UPDATE inventory_cost_layers SET unit_cost=GREATEST(unit_cost+$1,0) WHERE id=$2`, rev.UnitDelta, layerID); err != nil {
+			return err
+		}
+	}
+	average, err := inventoryAverageCost(ctx, tx, invID)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET average_cost=$1,updated_at=NOW() WHERE id=$2`, revaluedAverageCost(average, onHand, rev.InventoryAmount), invID); err != nil {
+		return err
+	}
+	if batchID != nil {
+		if _, err = tx.Exec(ctx, `UPDATE inventory_batches SET unit_cost=GREATEST(unit_cost+$1*LEAST($2,quantity_remaining)/quantity_remaining,0) WHERE id=$3 AND quantity_remaining>0`, rev.UnitDelta, rev.RevaluedQuantity, *batchID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO inventory_cost_adjustments(merchant_id,shop_id,inventory_item_id,goods_receipt_item_id,batch_id,reference_type,reference_id,unit_cost_delta,revalued_quantity,inventory_amount,consumed_quantity,consumed_amount,created_by) VALUES($1,$2,$3,$4,$5,'LANDED_COST',$6,$7,$8,$9,$10,$11,$12)`, merchantID, shopID, invID, line.ID, batchID, nullableString(referenceID), rev.UnitDelta, rev.RevaluedQuantity, rev.InventoryAmount, rev.ConsumedQuantity, rev.ConsumedAmount, nullableString(userID))
+	return err
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"app/models"
+	"testing"
+)
+
+func TestAllocateLandedCost(t *testing.T) {
+	lines := []landedCostLine{
+		{Value: 300, Quantity: 10, Weight: 5},
+		{Value: 100, Quantity: 30, Weight: 0},
+		{Value: 600, Quantity: 20, Weight: 15},
+	}
+	cases := []struct {
+		method string
+		amount float64
+		want   []float64
+	}{
+		{landedCostByValue, 100, []float64{30, 10, 60}},
+		{landedCostByQuantity, 120, []float64{20, 60, 40}},
+		{landedCostByWeight, 40, []float64{10, 0, 30}},
+		{landedCostByQuantity, 10, []float64{1.6667, 5, 3.3333}},
+		{landedCostByValue, 0, []float64{0, 0, 0}},
+	}
+	for _, tc := range cases {
+		got, err := allocateLandedCost(lines, tc.amount, tc.method)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.method, err)
+		}
+		var sum float64
+		for i := range tc.want {
+			if got[i] != tc.want[i] {
+				t.Fatalf("%s %v: expected %v, got %v", tc.method, tc.amount, tc.want, got)
+			}
+			sum += got[i]
+		}
+		if roundCost(sum) != tc.amount {
+			t.Fatalf("%s %v: shares add up to %v", tc.method, tc.amount, sum)
+		}
+	}
+	if _, err := allocateLandedCost([]landedCostLine{{Value: 10, Quantity: 1}}, 5, landedCostByWeight); err != errLandedCostBasis {
+		t.Fatalf("expected missing weight basis, got %v", err)
+	}
+}
+
+func TestLandedCostRevaluation(t *testing.T) {
+	rev := landedCostRevaluation(10, 4, 25)
+	if rev.UnitDelta != 2.5 || rev.RevaluedQuantity != 4 || rev.InventoryAmount != 10 || rev.ConsumedQuantity != 6 || rev.ConsumedAmount != 15 {
+		t.Fatalf("unexpected revaluation %+v", rev)
+	}
+	rev = landedCostRevaluation(10, 12, -5)
+	if rev.UnitDelta != -0.5 || rev.RevaluedQuantity != 10 || rev.InventoryAmount != -5 || rev.ConsumedAmount != 0 {
+		t.Fatalf("unexpected revaluation %+v", rev)
+	}
+	if got := revaluedAverageCost(2, 20, 10); got != 2.5 {
+		t.Fatalf("expected average 2.5, got %v", got)
+	}
+	if got := revaluedAverageCost(2, 0, 10); got != 2 {
+		t.Fatalf("expected average unchanged without stock, got %v", got)
+	}
+}
+
+func TestValidateLandedCost(t *testing.T) {
+	req := models.GoodsReceiptCostRequest{CostType: " freight ", Amount: 12.345}
+	if err := validateLandedCost(&req); err != nil || req.CostType != "FREIGHT" || req.AllocationMethod != landedCostByValue || req.Amount != 12.35 {
+		t.Fatalf("unexpected normalization %+v, %v", req, err)
+	}
+	for _, bad := range []models.GoodsReceiptCostRequest{
+		{CostType: "TIPS", Amount: 1},
+		{CostType: "DUTY", Amount: 1, AllocationMethod: "VOLUME"},
+		{CostType: "DUTY", Amount: -1},
+	} {
+		if err := validateLandedCost(&bad); !isLandedCostError(err) {
+			t.Fatalf("%+v: expected a landed cost error, got %v", bad, err)
+		}
+	}
+}
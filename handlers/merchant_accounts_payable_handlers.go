@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var supplierInvoiceSortFields = map[string]string{"invoiceDate": "invoice_date", "dueDate": "due_date", "totalAmount": "total_amount", "createdAt": "created_at"}
+
+const supplierInvoiceColumns = "id,supplier_id,purchase_order_id,invoice_number,invoice_date,due_date,tax_amount::float8,total_amount::float8,amount_paid::float8,amount_credited::float8,status,match_status,notes,created_at"
+
+func scanSupplierInvoice(row pgx.Row, item *models.SupplierInvoice) error {
+	if err := row.Scan(&item.ID, &item.SupplierID, &item.PurchaseOrderID, &item.InvoiceNumber, &item.InvoiceDate, &item.DueDate, &item.TaxAmount, &item.TotalAmount, &item.AmountPaid, &item.AmountCredited, &item.Status, &item.MatchStatus, &item.Notes, &item.CreatedAt); err != nil {
+		return err
+	}
+	item.Outstanding = roundMoney(item.TotalAmount - item.AmountPaid - item.AmountCredited)
+	return nil
+}
+
+// HandleCreateSupplierInvoice enters a supplier bill against a purchase order. Each line
+// is matched against the quantity received and not yet billed on its order line and
+// against the ordered price; any difference flags the invoice as VARIANCE. Open supplier
+// credit notes are applied to the new invoice.
+func HandleCreateSupplierInvoice(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.SupplierInvoiceRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.PurchaseOrderID) == "" || len(req.Items) == 0 {
+		return fiber.NewError(400, "purchaseOrderId and items are required")
+	}
+	if req.TaxAmount < 0 {
+		return fiber.NewError(400, "taxAmount must not be negative")
+	}
+	invoiceDate, err := parseOptionalDate(req.InvoiceDate)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	dueDate, err := parseOptionalDate(req.DueDate)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	if invoiceDate == nil {
+		today := time.Now().UTC()
+		invoiceDate = &today
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start invoice")
+	}
+	defer tx.Rollback(ctx)
+	var supplierID, orderStatus string
+	err = tx.QueryRow(ctx, `SELECT supplier_id,status FROM purchase_orders WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, req.PurchaseOrderID, merchantID).Scan(&supplierID, &orderStatus)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "purchase order not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load purchase order")
+	}
+	if !billableOrderStatus(orderStatus) {
+		return fiber.NewError(409, errInvoiceOrderNotBillable.Error())
+	}
+	invoice := models.SupplierInvoice{SupplierID: supplierID, PurchaseOrderID: req.PurchaseOrderID, InvoiceNumber: nullableString(trimmedString(req.InvoiceNumber)), InvoiceDate: *invoiceDate, DueDate: dueDate, TaxAmount: roundMoney(req.TaxAmount), Status: "UNPAID", MatchStatus: "MATCHED", Notes: nullableString(trimmedString(req.Notes))}
+	subtotal := 0.0
+	for _, line := range req.Items {
+		if line.Quantity <= 0 || line.UnitCost < 0 {
+			return fiber.NewError(400, "invoice lines need a positive quantity and a non-negative unitCost")
+		}
+		var received, billed, orderedCost float64
+		err = tx.QueryRow(ctx, `SELECT poi.received_quantity::float8,poi.unit_cost::float8,COALESCE((SELECT SUM(sii.quantity) FROM supplier_invoice_items sii WHERE sii.purchase_order_item_id=poi.id),0)::float8
+			FROM purchase_order_items poi WHERE poi.id::text=$1 AND poi.purchase_order_id=$2 FOR UPDATE`, line.PurchaseOrderItemID, req.PurchaseOrderID).Scan(&received, &orderedCost, &billed)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(400, errInvoiceLineNotOnOrder.Error())
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to match invoice line")
+		}
+		billable := received - billed
+		if billable < 0 {
+			billable = 0
+		}
+		variance := matchInvoiceLine(billable, line.Quantity, orderedCost, line.UnitCost)
+		if variance.flagged() {
+			invoice.MatchStatus = "VARIANCE"
+		}
+		total := roundMoney(line.Quantity * line.UnitCost)
+		subtotal += total
+		invoice.Items = append(invoice.Items, models.SupplierInvoiceItem{PurchaseOrderItemID: line.PurchaseOrderItemID, Quantity: line.Quantity, UnitCost: line.UnitCost, TotalCost: total, ReceivedQuantity: billable, OrderedUnitCost: orderedCost, QuantityVariance: variance.Quantity, PriceVariance: variance.Price})
+	}
+	invoice.TotalAmount = roundMoney(subtotal + invoice.TaxAmount)
+	invoice.Outstanding = invoice.TotalAmount
+	err = tx.QueryRow(ctx, `INSERT INTO supplier_invoices(merchant_id,supplier_id,purchase_order_id,invoice_number,invoice_date,due_date,tax_amount,total_amount,match_status,notes) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id,created_at`, merchantID, supplierID, req.PurchaseOrderID, invoice.InvoiceNumber, invoice.InvoiceDate, invoice.DueDate, invoice.TaxAmount, invoice.TotalAmount, invoice.MatchStatus, invoice.Notes).Scan(&invoice.ID, &invoice.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "this supplier invoice number is already entered")
+		}
+		return fiber.NewError(500, "failed to create invoice")
+	}
+	for n, line := range invoice.Items {
+		if err = tx.QueryRow(ctx, `INSERT INTO supplier_invoice_items(supplier_invoice_id,purchase_order_item_id,quantity,unit_cost,total_cost,received_quantity,ordered_unit_cost,quantity_variance,price_variance) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) RETURNING id`, invoice.ID, line.PurchaseOrderItemID, line.Quantity, line.UnitCost, line.TotalCost, line.ReceivedQuantity, line.OrderedUnitCost, line.QuantityVariance, line.PriceVariance).Scan(&invoice.Items[n].ID); err != nil {
+			return fiber.NewError(500, "failed to record invoice line")
+		}
+	}
+	if invoice.AmountCredited, err = applySupplierCredits(ctx, pgxTxAdapter{tx: tx}, merchantID, supplierID, invoice.ID, invoice.TotalAmount); err != nil {
+		return fiber.NewError(500, "failed to apply supplier credits")
+	}
+	if invoice.AmountCredited > 0 {
+		invoice.Status = supplierInvoiceStatus(invoice.TotalAmount, invoice.AmountCredited)
+		invoice.Outstanding = roundMoney(invoice.TotalAmount - invoice.AmountCredited)
+		if _, err = tx.Exec(ctx, `UPDATE supplier_invoices SET amount_credited=$1,status=$2 WHERE id=$3`, invoice.AmountCredited, invoice.Status, invoice.ID); err != nil {
+			return fiber.NewError(500, "failed to apply supplier credits")
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to create invoice")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": invoice})
+}
+
+// HandleListSupplierInvoices lists supplier bills, filtered by supplier, order, payment status or match status.
+func HandleListSupplierInvoices(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "invoiceDate", supplierInvoiceSortFields)
+	where := " WHERE merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, filter := range [][2]string{{"supplierId", "supplier_id"}, {"purchaseOrderId", "purchase_order_id"}, {"status", "status"}, {"matchStatus", "match_status"}} {
+		if v := c.Query(filter[0]); v != "" {
+			where += " AND " + filter[1] + "::text=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	if q.Search != "" {
+		where += " AND invoice_number ILIKE $" + itoa(len(args)+1)
+		args = append(args, "%"+q.Search+"%")
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM supplier_invoices"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count invoices")
+	}
+	rows, err := db.Query(ctx, "SELECT "+supplierInvoiceColumns+" FROM supplier_invoices"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list invoices")
+	}
+	defer rows.Close()
+	items := make([]models.SupplierInvoice, 0)
+	for rows.Next() {
+		var item models.SupplierInvoice
+		if err := scanSupplierInvoice(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read invoice")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+// HandleGetSupplierInvoice returns a bill with its matched lines and payments.
+func HandleGetSupplierInvoice(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var invoice models.SupplierInvoice
+	err = scanSupplierInvoice(db.QueryRow(ctx, "SELECT "+supplierInvoiceColumns+" FROM supplier_invoices WHERE id=$1 AND merchant_id=$2", c.Params("invoiceId"), merchantID), &invoice)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "invoice not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load invoice")
+	}
+	rows, err := db.Query(ctx, `SELECT id,purchase_order_item_id,quantity::float8,unit_cost::float8,total_cost::float8,received_quantity::float8,ordered_unit_cost::float8,quantity_variance::float8,price_variance::float8 FROM supplier_invoice_items WHERE supplier_invoice_id=$1 ORDER BY id`, invoice.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load invoice lines")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.SupplierInvoiceItem
+		if err := rows.Scan(&line.ID, &line.PurchaseOrderItemID, &line.Quantity, &line.UnitCost, &line.TotalCost, &line.ReceivedQuantity, &line.OrderedUnitCost, &line.QuantityVariance, &line.PriceVariance); err != nil {
+			return fiber.NewError(500, "failed to read invoice line")
+		}
+		invoice.Items = append(invoice.Items, line)
+	}
+	payments, err := db.Query(ctx, `SELECT id,supplier_invoice_id,amount::float8,payment_method,reference,payment_date FROM supplier_payments WHERE supplier_invoice_id=$1 ORDER BY payment_date,id`, invoice.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load invoice payments")
+	}
+	defer payments.Close()
+	for payments.Next() {
+		var payment models.SupplierPayment
+		if err := payments.Scan(&payment.ID, &payment.SupplierInvoiceID, &payment.Amount, &payment.PaymentMethod, &payment.Reference, &payment.PaymentDate); err != nil {
+			return fiber.NewError(500, "failed to read invoice payment")
+		}
+		invoice.Payments = append(invoice.Payments, payment)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": invoice})
+}
+
+// HandleCreateSupplierPayment records a full or partial payment of a supplier bill.
+func HandleCreateSupplierPayment(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.SupplierPaymentRequest
+	if err := c.BodyParser(&req); err != nil || req.Amount <= 0 || strings.TrimSpace(req.PaymentMethod) == "" {
+		return fiber.NewError(400, "a positive amount and paymentMethod are required")
+	}
+	paymentDate, err := parseOptionalDate(req.PaymentDate)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start payment")
+	}
+	defer tx.Rollback(ctx)
+	var total, paid, credited float64
+	err = tx.QueryRow(ctx, `SELECT total_amount::float8,amount_paid::float8,amount_credited::float8 FROM supplier_invoices WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, c.Params("invoiceId"), merchantID).Scan(&total, &paid, &credited)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "invoice not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load invoice")
+	}
+	amount := roundMoney(req.Amount)
+	if amount > roundMoney(total-paid-credited) {
+		return fiber.NewError(400, errPaymentExceedsBalance.Error())
+	}
+	payment := models.SupplierPayment{SupplierInvoiceID: c.Params("invoiceId"), Amount: amount, PaymentMethod: strings.ToUpper(strings.TrimSpace(req.PaymentMethod)), Reference: nullableString(trimmedString(req.Reference))}
+	if err = tx.QueryRow(ctx, `INSERT INTO supplier_payments(supplier_invoice_id,amount,payment_method,reference,payment_date) VALUES($1,$2,$3,$4,COALESCE($5,NOW())) RETURNING id,payment_date`, payment.SupplierInvoiceID, amount, payment.PaymentMethod, payment.Reference, paymentDate).Scan(&payment.ID, &payment.PaymentDate); err != nil {
+		return fiber.NewError(500, "failed to record payment")
+	}
+	paid = roundMoney(paid + amount)
+	status := supplierInvoiceStatus(total, paid+credited)
+	if _, err = tx.Exec(ctx, `UPDATE supplier_invoices SET amount_paid=$1,status=$2,updated_at=NOW() WHERE id=$3`, paid, status, payment.SupplierInvoiceID); err != nil {
+		return fiber.NewError(500, "failed to update invoice")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to record payment")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"payment": payment, "invoiceStatus": status, "amountPaid": paid, "outstanding": roundMoney(total - paid - credited)}})
+}
+
+// HandleGetSupplierStatement lists a supplier's invoices, payments and credit notes between two dates
+// with the running balance owed, starting from the balance before the period.
+func HandleGetSupplierStatement(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	fromValue, toValue := c.Query("from"), c.Query("to")
+	from, err := parseOptionalDate(&fromValue)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	to, err := parseOptionalDate(&toValue)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	if to == nil {
+		today := time.Now().UTC()
+		to = &today
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -90)
+		from = &start
+	}
+	db, ctx := database.GetDB(), context.Background()
+	supplierID := c.Params("supplierId")
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM suppliers WHERE id::text=$1 AND merchant_id=$2)`, supplierID, merchantID).Scan(&exists); err != nil {
+		return fiber.NewError(500, "failed to load supplier")
+	}
+	if !exists {
+		return fiber.NewError(404, "supplier not found")
+	}
+	var opening float64
+	if err := db.QueryRow(ctx, `SELECT COALESCE((SELECT SUM(total_amount) FROM supplier_invoices WHERE merchant_id=$1 AND supplier_id=$2 AND invoice_date<$3),0)::float8
+		- COALESCE((SELECT SUM(sp.amount) FROM supplier_payments sp JOIN supplier_invoices si ON si.id=sp.supplier_invoice_id WHERE si.merchant_id=$1 AND si.supplier_id=$2 AND sp.payment_date::date<$3),0)::float8
+		- COALESCE((SELECT SUM(amount) FROM supplier_credit_notes WHERE merchant_id=$1 AND supplier_id=$2 AND created_at::date<$3),0)::float8`, merchantID, supplierID, *from).Scan(&opening); err != nil {
+		return fiber.NewError(500, "failed to compute opening balance")
+	}
+	rows, err := db.Query(ctx, `SELECT invoice_date::timestamptz,'INVOICE',id,NULL::uuid,invoice_number,total_amount::float8 FROM supplier_invoices WHERE merchant_id=$1 AND supplier_id=$2 AND invoice_date BETWEEN $3 AND $4
+		UNION ALL
+		SELECT sp.payment_date,'PAYMENT',sp.id,si.id,sp.reference,-sp.amount::float8 FROM supplier_payments sp JOIN supplier_invoices si ON si.id=sp.supplier_invoice_id WHERE si.merchant_id=$1 AND si.supplier_id=$2 AND sp.payment_date::date BETWEEN $3 AND $4
+		UNION ALL
+		SELECT created_at,'CREDIT',id,NULL::uuid,NULL,-amount::float8 FROM supplier_credit_notes WHERE merchant_id=$1 AND supplier_id=$2 AND created_at::date BETWEEN $3 AND $4
+		ORDER BY 1,2`, merchantID, supplierID, *from, *to)
+	if err != nil {
+		return fiber.NewError(500, "failed to load statement")
+	}
+	defer rows.Close()
+	balance := roundMoney(opening)
+	entries := make([]models.SupplierStatementEntry, 0)
+	for rows.Next() {
+		var entry models.SupplierStatementEntry
+		if err := rows.Scan(&entry.Date, &entry.EntryType, &entry.DocumentID, &entry.InvoiceID, &entry.Reference, &entry.Amount); err != nil {
+			return fiber.NewError(500, "failed to read statement entry")
+		}
+		balance = roundMoney(balance + entry.Amount)
+		entry.Balance = balance
+		entries = append(entries, entry)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"supplierId": supplierID, "from": from.Format("2006-01-02"), "to": to.Format("2006-01-02"), "openingBalance": roundMoney(opening), "closingBalance": balance, "entries": entries}})
+}
+
+// HandleGetAPAging buckets what is owed to each supplier on asOf by days past the due
+// date, or past the invoice date for bills without one.
+func HandleGetAPAging(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	asOfValue := c.Query("asOf")
+	asOf, err := parseOptionalDate(&asOfValue)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	if asOf == nil {
+		today := time.Now().UTC()
+		asOf = &today
+	}
+	rows, err := database.GetDB().Query(context.Background(), `SELECT s.id,s.name,COALESCE(si.due_date,si.invoice_date),
+		(si.total_amount-COALESCE((SELECT SUM(sp.amount) FROM supplier_payments sp WHERE sp.supplier_invoice_id=si.id AND sp.payment_date::date<=$2),0)
+		-COALESCE((SELECT SUM(ca.amount) FROM supplier_credit_applications ca WHERE ca.supplier_invoice_id=si.id AND ca.applied_at::date<=$2),0))::float8
+		FROM supplier_invoices si JOIN suppliers s ON s.id=si.supplier_id WHERE si.merchant_id=$1 AND si.invoice_date<=$2 ORDER BY s.name,s.id`, merchantID, *asOf)
+	if err != nil {
+		return fiber.NewError(500, "failed to load payables")
+	}
+	defer rows.Close()
+	suppliers := make([]models.APAgingRow, 0)
+	totals := models.APAgingRow{SupplierName: "TOTAL"}
+	for rows.Next() {
+		var supplierID, name string
+		var due time.Time
+		var outstanding float64
+		if err := rows.Scan(&supplierID, &name, &due, &outstanding); err != nil {
+			return fiber.NewError(500, "failed to read payable")
+		}
+		if roundMoney(outstanding) <= 0 {
+			continue
+		}
+		if len(suppliers) == 0 || suppliers[len(suppliers)-1].SupplierID != supplierID {
+			suppliers = append(suppliers, models.APAgingRow{SupplierID: supplierID, SupplierName: name})
+		}
+		days := daysBetween(due, *asOf)
+		addAgedAmount(&suppliers[len(suppliers)-1], days, outstanding)
+		addAgedAmount(&totals, days, outstanding)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"asOf": asOf.Format("2006-01-02"), "suppliers": suppliers, "totals": totals}})
+}
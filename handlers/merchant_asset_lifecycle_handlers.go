@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var rentalSortFields = map[string]string{"dueAt": "due_at", "checkedOutAt": "checked_out_at", "status": "status"}
+
+func assetErrorResponse(err error, fallback string) error {
+	if err == errAssetNotFound {
+		return fiber.NewError(404, err.Error())
+	}
+	if isAssetError(err) {
+		return fiber.NewError(409, err.Error())
+	}
+	return fiber.NewError(500, fallback)
+}
+
+// HandleTransitionInventoryAsset moves an asset to a new status under the lifecycle rules
+// and records the change. Selling an asset attaches its warranty.
+func HandleTransitionInventoryAsset(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.InventoryAssetTransitionRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Status) == "" {
+		return fiber.NewError(400, "status is required")
+	}
+	t := assetTransition{AssetID: c.Params("assetId"), MerchantID: merchantID, ToStatus: strings.ToUpper(strings.TrimSpace(req.Status)), EventType: "STATUS_CHANGED", ReferenceType: "MANUAL", ActorID: merchantID, WarrantyStartsOn: req.WarrantyStartsOn, WarrantyEndsOn: req.WarrantyEndsOn}
+	if req.Notes != nil {
+		t.Notes = strings.TrimSpace(*req.Notes)
+	}
+	ctx := context.Background()
+	db := database.GetDB()
+	if req.SaleID != nil && strings.TrimSpace(*req.SaleID) != "" {
+		var ok bool
+		if err := db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM sales WHERE id=$1 AND merchant_id=$2)`, strings.TrimSpace(*req.SaleID), merchantID).Scan(&ok); err != nil || !ok {
+			return fiber.NewError(400, "saleId is invalid")
+		}
+		t.ReferenceType, t.ReferenceID = "SALE", strings.TrimSpace(*req.SaleID)
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to update asset status")
+	}
+	defer tx.Rollback(ctx)
+	if _, _, err := transitionInventoryAsset(ctx, pgxTxAdapter{tx: tx}, t); err != nil {
+		return assetErrorResponse(err, "failed to update asset status")
+	}
+	item, err := scanInventoryAsset(tx.QueryRow(ctx, `SELECT id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at FROM inventory_assets WHERE id=$1`, t.AssetID).Scan)
+	if err != nil {
+		return fiber.NewError(500, "failed to update asset status")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to update asset status")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleGetInventoryAssetHistory returns an asset with its lifecycle events and warranties.
+// The asset can be addressed by id or by asset tag.
+func HandleGetInventoryAssetHistory(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	asset, err := scanInventoryAsset(db.QueryRow(ctx, `SELECT id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at FROM inventory_assets WHERE (id::text=$1 OR asset_tag=$1) AND merchant_id=$2 LIMIT 1`, c.Params("assetId"), merchantID).Scan)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "inventory asset not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load inventory asset")
+	}
+	rows, err := db.Query(ctx, `SELECT e.id,e.asset_id,e.event_type,e.from_status,e.to_status,e.shop_id,s.name,e.reference_type,e.reference_id,e.actor_id,e.notes,e.created_at FROM inventory_asset_events e LEFT JOIN shops s ON s.id=e.shop_id WHERE e.asset_id=$1 ORDER BY e.created_at,e.id`, asset.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load asset history")
+	}
+	defer rows.Close()
+	events := make([]models.InventoryAssetEvent, 0)
+	for rows.Next() {
+		var event models.InventoryAssetEvent
+		if err := rows.Scan(&event.ID, &event.AssetID, &event.EventType, &event.FromStatus, &event.ToStatus, &event.ShopID, &event.ShopName, &event.ReferenceType, &event.ReferenceID, &event.ActorID, &event.Notes, &event.CreatedAt); err != nil {
+			return fiber.NewError(500, "failed to read asset history")
+		}
+		events = append(events, event)
+	}
+	warranties, err := queryWarranties(ctx, "w.asset_id=$2", merchantID, asset.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load asset warranties")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"asset": asset, "events": events, "warranties": warranties}})
+}
+
+// queryWarranties lists the merchant's ($1) warranties matching filter, newest first.
+func queryWarranties(ctx context.Context, filter string, args ...interface{}) ([]models.InventoryWarranty, error) {
+	rows, err := database.GetDB().Query(ctx, `SELECT w.id,w.stock_item_id,si.name,w.serial_id,sr.serial_number,w.asset_id,a.asset_tag,w.sale_id,w.customer_id,sc.name,w.starts_on,w.ends_on,w.status,w.created_at
+		FROM inventory_warranties w
+		LEFT JOIN stock_items si ON si.id=w.stock_item_id
+		LEFT JOIN inventory_serials sr ON sr.id=w.serial_id
+		LEFT JOIN inventory_assets a ON a.id=w.asset_id
+		LEFT JOIN shop_customers sc ON sc.id=w.customer_id
+		WHERE w.merchant_id=$1 AND `+filter+` ORDER BY w.created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	today := time.Now().Truncate(24 * time.Hour)
+	items := make([]models.InventoryWarranty, 0)
+	for rows.Next() {
+		var item models.InventoryWarranty
+		if err := rows.Scan(&item.ID, &item.StockItemID, &item.ItemName, &item.SerialID, &item.SerialNumber, &item.AssetID, &item.AssetTag, &item.SaleID, &item.CustomerID, &item.CustomerName, &item.StartsOn, &item.EndsOn, &item.Status, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		item.Coverage = warrantyCoverage(item.Status, item.StartsOn, item.EndsOn, today)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// HandleLookupWarranty finds warranty cover by serial number, asset tag or asset identifier.
+func HandleLookupWarranty(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	code := strings.ToUpper(strings.TrimSpace(c.Query("code")))
+	if code == "" {
+		return fiber.NewError(400, "code is required")
+	}
+	warranties, err := queryWarranties(context.Background(), `(UPPER(sr.serial_number)=$2 OR UPPER(a.asset_tag)=$2
+		OR w.asset_id IN (SELECT ai.asset_id FROM inventory_asset_identifiers ai WHERE ai.normalized_value=$2))`, merchantID, code)
+	if err != nil {
+		return fiber.NewError(500, "failed to look up warranty")
+	}
+	if len(warranties) == 0 {
+		return fiber.NewError(404, "no warranty found for this code")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": warranties[0], "history": warranties[1:]})
+}
+
+const assetRentalColumns = `r.id,r.merchant_id,r.shop_id,r.asset_id,a.asset_tag,r.customer_id,sc.name,r.status,r.checked_out_at,r.due_at,r.returned_at,r.checked_out_by,r.returned_by,r.notes,r.return_notes`
+
+func scanAssetRental(scan func(...interface{}) error, now time.Time) (models.AssetRental, error) {
+	var item models.AssetRental
+	err := scan(&item.ID, &item.MerchantID, &item.ShopID, &item.AssetID, &item.AssetTag, &item.CustomerID, &item.CustomerName, &item.Status, &item.CheckedOutAt, &item.DueAt, &item.ReturnedAt, &item.CheckedOutBy, &item.ReturnedBy, &item.Notes, &item.ReturnNotes)
+	item.Overdue = item.Status == "OUT" && now.After(item.DueAt)
+	return item, err
+}
+
+func loadAssetRental(ctx context.Context, q pgx.Tx, rentalID string) (models.AssetRental, error) {
+	return scanAssetRental(q.QueryRow(ctx, `SELECT `+assetRentalColumns+` FROM asset_rentals r JOIN inventory_assets a ON a.id=r.asset_id JOIN shop_customers sc ON sc.id=r.customer_id WHERE r.id=$1`, rentalID).Scan, time.Now())
+}
+
+// HandleCreateAssetRental checks an asset out to a shop customer until the due date.
+func HandleCreateAssetRental(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.AssetRentalRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.CustomerID) == "" || req.DueAt.IsZero() {
+		return fiber.NewError(400, "customerId and dueAt are required")
+	}
+	if !req.DueAt.After(time.Now()) {
+		return fiber.NewError(400, "dueAt must be in the future")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to rent asset")
+	}
+	defer tx.Rollback(ctx)
+	rentalID := generateUUID()
+	notes := ""
+	if req.Notes != nil {
+		notes = strings.TrimSpace(*req.Notes)
+	}
+	shopID, _, err := transitionInventoryAsset(ctx, pgxTxAdapter{tx: tx}, assetTransition{AssetID: c.Params("assetId"), MerchantID: merchantID, ToStatus: "RENTED", EventType: "RENTED", ReferenceType: "RENTAL", ReferenceID: rentalID, ActorID: merchantID, Notes: notes})
+	if err != nil {
+		return assetErrorResponse(err, "failed to rent asset")
+	}
+	var customerOK bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM shop_customers WHERE id=$1 AND shop_id=$2 AND merchant_id=$3)`, req.CustomerID, shopID, merchantID).Scan(&customerOK); err != nil {
+		return fiber.NewError(500, "failed to verify customer")
+	}
+	if !customerOK {
+		return fiber.NewError(400, "customer does not belong to the asset's shop")
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO asset_rentals(id,merchant_id,shop_id,asset_id,customer_id,due_at,checked_out_by,notes) VALUES($1,$2,$3,$4,$5,$6,$7,$8)`, rentalID, merchantID, shopID, c.Params("assetId"), req.CustomerID, req.DueAt, merchantID, nullableString(notes)); err != nil {
+		if isUniqueViolation(err) {
+			return fiber.NewError(409, "asset is already rented out")
+		}
+		return fiber.NewError(500, "failed to rent asset")
+	}
+	rental, err := loadAssetRental(ctx, tx, rentalID)
+	if err != nil {
+		return fiber.NewError(500, "failed to rent asset")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to rent asset")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": rental})
+}
+
+// HandleReturnAssetRental closes an open rental and puts the asset back in circulation,
+// or takes it out of service when it comes back damaged.
+func HandleReturnAssetRental(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.AssetRentalReturnRequest
+	if err := c.BodyParser(&req); err != nil && len(c.Body()) > 0 {
+		return fiber.NewError(400, "invalid request body")
+	}
+	status, err := rentalReturnStatus(req.Condition)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	notes := ""
+	if req.Notes != nil {
+		notes = strings.TrimSpace(*req.Notes)
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to return rental")
+	}
+	defer tx.Rollback(ctx)
+	var assetID string
+	err = tx.QueryRow(ctx, `UPDATE asset_rentals SET status='RETURNED',returned_at=NOW(),returned_by=$1,return_notes=$2 WHERE id=$3 AND merchant_id=$4 AND status='OUT' RETURNING asset_id`, merchantID, nullableString(notes), c.Params("rentalId"), merchantID).Scan(&assetID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "open rental not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to return rental")
+	}
+	if _, _, err := transitionInventoryAsset(ctx, pgxTxAdapter{tx: tx}, assetTransition{AssetID: assetID, MerchantID: merchantID, ToStatus: status, EventType: "RENTAL_RETURNED", ReferenceType: "RENTAL", ReferenceID: c.Params("rentalId"), ActorID: merchantID, Notes: notes}); err != nil {
+		return assetErrorResponse(err, "failed to return rental")
+	}
+	rental, err := loadAssetRental(ctx, tx, c.Params("rentalId"))
+	if err != nil {
+		return fiber.NewError(500, "failed to return rental")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to return rental")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": rental})
+}
+
+// HandleListAssetRentals lists rentals, optionally only the open ones past their due date.
+func HandleListAssetRentals(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "dueAt", rentalSortFields)
+	where := " WHERE r.merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, pair := range []struct{ key, col string }{{"shopId", "r.shop_id"}, {"assetId", "r.asset_id"}, {"customerId", "r.customer_id"}} {
+		if v := strings.TrimSpace(c.Query(pair.key)); v != "" {
+			where += " AND " + pair.col + "=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	if status := strings.ToUpper(strings.TrimSpace(c.Query("status"))); status != "" {
+		where += " AND r.status=$" + itoa(len(args)+1)
+		args = append(args, status)
+	}
+	if c.QueryBool("overdue") {
+		where += " AND r.status='OUT' AND r.due_at<NOW()"
+	}
+	from := " FROM asset_rentals r JOIN inventory_assets a ON a.id=r.asset_id JOIN shop_customers sc ON sc.id=r.customer_id" + where
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count rentals")
+	}
+	rows, err := db.Query(ctx, "SELECT * FROM (SELECT "+assetRentalColumns+from+") r"+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list rentals")
+	}
+	defer rows.Close()
+	now := time.Now()
+	items := make([]models.AssetRental, 0)
+	for rows.Next() {
+		item, err := scanAssetRental(rows.Scan, now)
+		if err != nil {
+			return fiber.NewError(500, "failed to read rental")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleGetShopStockPolicy returns the shop's negative-stock policy.
+func HandleGetShopStockPolicy(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	var policy string
+	if err := database.GetDB().QueryRow(context.Background(), `SELECT negative_stock_policy FROM shops WHERE id=$1`, shopID).Scan(&policy); err != nil {
+		return fiber.NewError(500, "failed to retrieve stock policy")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"shopId": shopID, "negativeStockPolicy": policy}})
+}
+
+// HandleUpdateShopStockPolicy sets what checkout does when a sale exceeds available
+// stock in the shop. Stock items with their own policy keep it.
+func HandleUpdateShopStockPolicy(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	var req models.ShopStockPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	policy := strings.ToUpper(strings.TrimSpace(req.NegativeStockPolicy))
+	if !validStockPolicy(policy) {
+		return fiber.NewError(400, errStockPolicyInvalid.Error())
+	}
+	if _, err := database.GetDB().Exec(context.Background(), `UPDATE shops SET negative_stock_policy=$1,updated_at=NOW() WHERE id=$2`, policy, shopID); err != nil {
+		return fiber.NewError(500, "failed to update stock policy")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"shopId": shopID, "negativeStockPolicy": policy}})
+}
+
+// HandleListShopBackorders lists the shop's backorders, open ones by default.
+func HandleListShopBackorders(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	status := strings.ToUpper(strings.TrimSpace(c.Query("status", "OPEN")))
+	if status != "ALL" && status != "OPEN" && status != "SETTLED" {
+		return fiber.NewError(400, "status must be OPEN, SETTLED or ALL")
+	}
+	rows, err := database.GetDB().Query(context.Background(), `SELECT b.id,b.shop_id,b.inventory_item_id,b.stock_item_id,si.name,b.reference_type,b.reference_id,b.quantity::float8,b.settled_quantity::float8,b.status,b.created_at,b.settled_at
+		FROM inventory_backorders b LEFT JOIN stock_items si ON si.id=b.stock_item_id
+		WHERE b.shop_id=$1 AND ($2='ALL' OR b.status=$2) ORDER BY b.created_at DESC LIMIT 500`, shopID, status)
+	if err != nil {
+		return fiber.NewError(500, "failed to list backorders")
+	}
+	defer rows.Close()
+	items := make([]models.InventoryBackorder, 0)
+	for rows.Next() {
+		var b models.InventoryBackorder
+		if err := rows.Scan(&b.ID, &b.ShopID, &b.InventoryItemID, &b.StockItemID, &b.StockItemName, &b.ReferenceType, &b.ReferenceID, &b.Quantity, &b.SettledQuantity, &b.Status, &b.CreatedAt, &b.SettledAt); err != nil {
+			return fiber.NewError(500, "failed to read backorders")
+		}
+		items = append(items, b)
+	}
+	if err = rows.Err(); err != nil {
+		return fiber.NewError(500, "failed to read backorders")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
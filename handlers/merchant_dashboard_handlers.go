@@ -80,12 +80,13 @@ func HandleGetMerchantDashboardSummary(c *fiber.Ctx) error {
 		SELECT
 			COALESCE(i.id, p.id) AS product_id,
 			COALESCE(i.name, p.name) AS product_name,
-			COALESCE(SUM(si.quantity_sold), 0) AS quantity_sold,
+			COALESCE(SUM(COALESCE(si.base_quantity, si.quantity_sold)), 0)::float8 / COALESCE(MAX(ru.factor), 1) AS quantity_sold,
+			MAX(ru.code) AS report_unit,
 			COALESCE(SUM(si.subtotal), 0) AS revenue
 		FROM sales s
 		JOIN sale_items si ON s.id = si.sale_id
 		LEFT JOIN stock_items i ON si.stock_item_id = i.id
-		LEFT JOIN products p ON si.product_id = p.id
+		LEFT JOIN products p ON si.product_id = p.id` + reportUnitJoin("si.stock_item_id") + `
 		WHERE s.merchant_id = $1
 	`
 	argsTopProducts := []interface{}{merchantID}
@@ -123,7 +124,7 @@ func HandleGetMerchantDashboardSummary(c *fiber.Ctx) error {
 	products := []models.ProductSummary{}
 	for rows.Next() {
 		var p models.ProductSummary
-		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.QuantitySold, &p.Revenue); err != nil {
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.QuantitySold, &p.ReportUnit, &p.Revenue); err != nil {
 			log.Printf("Error scanning top product row: %v", err)
 			continue
 		}
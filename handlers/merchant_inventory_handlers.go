@@ -310,7 +310,7 @@ func HandleCheckDeleteInventoryItem(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"deletable": len(blockers) == 0, "blockers": blockers}})
 }
 
-func adjustCanonicalStock(ctx context.Context, tx pgx.Tx, shopID, stockItemID, merchantID, userID, opID string, delta float64, movementType, reason string) (float64, bool, error) {
+func adjustCanonicalStock(ctx context.Context, tx pgx.Tx, shopID, stockItemID, merchantID, userID, opID string, delta float64, movementType, reason string, binID *string) (float64, bool, error) {
 	claimed, err := claimInventoryOperation(ctx, tx, opID, "stock_adjustment", userID, &shopID)
 	if err != nil || !claimed {
 		return 0, claimed, err
@@ -322,9 +322,13 @@ func adjustCanonicalStock(ctx context.Context, tx pgx.Tx, shopID, stockItemID, m
 		return 0, false, err
 	}
 	newQty := current + delta
-	if newQty < 0 {
+	// Balances sold short may already be negative; only a decrease has to stay covered.
+	if delta < 0 && newQty < 0 {
 		return 0, false, fmt.Errorf("insufficient stock")
 	}
+	if binID, err = resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, inventoryID, binID, delta > 0); err != nil {
+		return 0, false, err
+	}
 	abs := math.Abs(delta)
 	dbType := "ADJUSTMENT"
 	if movementType == "stock_in" || delta > 0 {
@@ -336,7 +340,24 @@ func adjustCanonicalStock(ctx context.Context, tx pgx.Tx, shopID, stockItemID, m
 	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, newQty, inventoryID); err != nil {
 		return 0, false, err
 	}
-	_, err = tx.Exec(ctx, `INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes) VALUES ($1,$2,$3,$4,$5,$6,$7,$7,NULL,NULL,$8,$9)`, merchantID, shopID, inventoryID, productID, stockItemID, dbType, abs, opID, reason)
+	var unitCost float64
+	if delta > 0 {
+		unitCost, err = receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, inventoryID, abs, nil)
+	} else {
+		unitCost, err = issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, inventoryID, abs, nil)
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$7,$8,NULL,NULL,$9,$10,$11)`, merchantID, shopID, inventoryID, productID, stockItemID, dbType, abs, unitCost, opID, reason, binID); err != nil {
+		return 0, false, err
+	}
+	if err = adjustBinBalance(ctx, pgxTxAdapter{tx: tx}, inventoryID, binID, delta); err != nil {
+		return 0, false, err
+	}
+	if delta > 0 {
+		err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, inventoryID)
+	}
 	return newQty, true, err
 }
 
@@ -359,6 +380,8 @@ func handleStockAdjustment(c *fiber.Ctx, stockItemID, shopID, mode string) error
 		QuantityAdded     float64 `json:"quantityAdded"`
 		Reason            string  `json:"reason"`
 		AdjustmentType    string  `json:"adjustmentType"`
+		UnitID            *string `json:"unitId"`
+		BinID             *string `json:"binId"`
 	}
 	if err = c.BodyParser(&req); err != nil {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
@@ -385,11 +408,26 @@ func handleStockAdjustment(c *fiber.Ctx, stockItemID, shopID, mode string) error
 	if req.AdjustmentType == "sale" {
 		delta = -math.Abs(delta)
 	}
-	newQty, processed, err := adjustCanonicalStock(ctx, tx, shopID, stockItemID, merchantID, merchantID, req.ClientOperationID, delta, req.AdjustmentType, req.Reason)
+	// Adjustments entered in another unit are applied to the base balance.
+	qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, stockItemID, req.UnitID, math.Abs(delta))
+	if err != nil {
+		if isUnitError(err) {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		if err == pgx.ErrNoRows {
+			return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Item is not stocked in this shop"})
+		}
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve adjustment unit"})
+	}
+	delta = math.Copysign(qty.BaseQuantity, delta)
+	newQty, processed, err := adjustCanonicalStock(ctx, tx, shopID, stockItemID, merchantID, merchantID, req.ClientOperationID, delta, req.AdjustmentType, req.Reason, req.BinID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Item is not stocked in this shop"})
 		}
+		if err == errStorageBinInvalid {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
 		return c.Status(409).JSON(fiber.Map{"status": "error", "message": err.Error()})
 	}
 	if !processed {
@@ -422,7 +460,7 @@ func HandleGetStockMovementHistory(c *fiber.Ctx) error {
 	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM inventory_movements WHERE shop_id=$1 AND stock_item_id=$2`, c.Params("shopId"), c.Params("itemId")).Scan(&total); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to count stock movement history"})
 	}
-	rows, err := db.Query(ctx, `SELECT id,shop_id,stock_item_id,movement_type,quantity,movement_date,notes FROM inventory_movements WHERE shop_id=$1 AND stock_item_id=$2 ORDER BY movement_date DESC LIMIT $3 OFFSET $4`, c.Params("shopId"), c.Params("itemId"), size, (page-1)*size)
+	rows, err := db.Query(ctx, `SELECT m.id,m.shop_id,m.stock_item_id,m.movement_type,m.quantity,m.unit_id::text,COALESCE(m.base_quantity,m.quantity)::float8,ru.factor,ru.code,m.movement_date,m.notes,m.bin_id::text FROM inventory_movements m`+reportUnitJoin("m.stock_item_id")+` WHERE m.shop_id=$1 AND m.stock_item_id=$2 ORDER BY m.movement_date DESC LIMIT $3 OFFSET $4`, c.Params("shopId"), c.Params("itemId"), size, (page-1)*size)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to retrieve stock movement history"})
 	}
@@ -431,11 +469,13 @@ func HandleGetStockMovementHistory(c *fiber.Ctx) error {
 	for rows.Next() {
 		var h models.StockMovement
 		var qty float64
+		var factor sql.NullFloat64
 		var notes sql.NullString
-		if err := rows.Scan(&h.ID, &h.ShopID, &h.InventoryItemID, &h.MovementType, &qty, &h.MovementDate, &notes); err != nil {
+		if err := rows.Scan(&h.ID, &h.ShopID, &h.InventoryItemID, &h.MovementType, &qty, &h.UnitID, &h.BaseQuantity, &factor, &h.ReportUnit, &h.MovementDate, &notes, &h.BinID); err != nil {
 			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to scan history"})
 		}
 		h.QuantityChanged = int(qty)
+		h.ReportQuantity = reportQuantity(h.BaseQuantity, factor.Float64)
 		if notes.Valid {
 			h.Notes = &notes.String
 		}
@@ -480,7 +520,7 @@ func HandleListInventoryForShop(c *fiber.Ctx) error {
 	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN products p ON p.id=ii.product_id"+where, args...).Scan(&total); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to count shop inventory"})
 	}
-	query := `SELECT ii.id,ii.shop_id,ii.stock_item_id,si.name,si.sku,COALESCE(pp.selling_price,0),ii.quantity_on_hand,ii.created_at,ii.updated_at FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN products p ON p.id=ii.product_id LEFT JOIN LATERAL (SELECT selling_price FROM product_prices WHERE product_id=ii.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1) pp ON TRUE` + where + fmt.Sprintf(" ORDER BY si.name LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
+	query := `SELECT ii.id,ii.shop_id,ii.stock_item_id,si.name,si.sku,COALESCE(pp.selling_price,0),ii.quantity_on_hand,ru.factor,ru.code,ii.putaway_bin_id::text,ii.pick_bin_id::text,ii.created_at,ii.updated_at FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN products p ON p.id=ii.product_id LEFT JOIN LATERAL (SELECT selling_price FROM product_prices WHERE product_id=ii.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1) pp ON TRUE` + reportUnitJoin("ii.stock_item_id") + where + fmt.Sprintf(" ORDER BY si.name LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
 	args = append(args, size, off)
 	rows, err := db.Query(ctx, query, args...)
 	if err != nil {
@@ -491,10 +531,12 @@ func HandleListInventoryForShop(c *fiber.Ctx) error {
 	for rows.Next() {
 		var i models.ShopStockItem
 		var q float64
-		if err := rows.Scan(&i.ID, &i.ShopID, &i.InventoryItemID, &i.ItemName, &i.ItemSku, &i.ItemUnitPrice, &q, &i.CreatedAt, &i.UpdatedAt); err != nil {
+		var factor sql.NullFloat64
+		if err := rows.Scan(&i.ID, &i.ShopID, &i.InventoryItemID, &i.ItemName, &i.ItemSku, &i.ItemUnitPrice, &q, &factor, &i.ReportUnit, &i.PutawayBinID, &i.PickBinID, &i.CreatedAt, &i.UpdatedAt); err != nil {
 			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to scan shop inventory"})
 		}
 		i.Quantity = int(q)
+		i.ReportQuantity = reportQuantity(q, factor.Float64)
 		i.LastStockedInAt = i.UpdatedAt
 		items = append(items, i)
 	}
@@ -540,18 +582,50 @@ func handleBulkStockIn(c *fiber.Ctx, shopID, merchantID, operationType string) e
 		if err = tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id=$1 AND merchant_id=$2`, item.ProductID, merchantID).Scan(&productID); err != nil {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid stock item: " + item.ProductID})
 		}
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, item.ProductID, item.UnitID, item.Quantity)
+		if err != nil {
+			if isUnitError(err) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Stock item %s: %v", item.ProductID, err)})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve stock-in unit"})
+		}
 		var inventoryID string
 		if err = tx.QueryRow(ctx, `SELECT id FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, shopID, item.ProductID).Scan(&inventoryID); err == pgx.ErrNoRows {
-			err = tx.QueryRow(ctx, `INSERT INTO inventory_items (merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES ($1,$2,$3,$4,$5) RETURNING id`, merchantID, shopID, productID, item.ProductID, item.Quantity).Scan(&inventoryID)
+			err = tx.QueryRow(ctx, `INSERT INTO inventory_items (merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES ($1,$2,$3,$4,$5) RETURNING id`, merchantID, shopID, productID, item.ProductID, qty.BaseQuantity).Scan(&inventoryID)
 		} else if err == nil {
-			_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, item.Quantity, inventoryID)
+			_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, qty.BaseQuantity, inventoryID)
 		}
 		if err != nil {
 			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Failed to update stock quantity"})
 		}
-		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes) VALUES ($1,$2,$3,$4,$5,'IN',$6,$6,'STOCK_IN',NULL,$7,$8)`, merchantID, shopID, inventoryID, productID, item.ProductID, item.Quantity, fmt.Sprintf("%s:%s", req.ClientOperationID, item.ProductID), "Bulk stock-in"); err != nil {
+		binID, err := resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, inventoryID, item.BinID, true)
+		if err != nil {
+			if err == errStorageBinInvalid {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Stock item %s: %v", item.ProductID, err)})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve stock-in bin"})
+		}
+		var baseCost *float64
+		if item.UnitCost != nil {
+			if *item.UnitCost < 0 {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": "unitCost cannot be negative"})
+			}
+			perBase := *item.UnitCost / qty.Factor
+			baseCost = &perBase
+		}
+		unitCost, err := receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, inventoryID, qty.BaseQuantity, baseCost)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to cost stock-in"})
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES ($1,$2,$3,$4,$5,$6,'IN',$7,$8,$9,'STOCK_IN',NULL,$10,$11,$12)`, merchantID, shopID, inventoryID, productID, item.ProductID, qty.UnitID, item.Quantity, qty.BaseQuantity, unitCost, fmt.Sprintf("%s:%s", req.ClientOperationID, item.ProductID), "Bulk stock-in", binID); err != nil {
 			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to log stock movement"})
 		}
+		if err = adjustBinBalance(ctx, pgxTxAdapter{tx: tx}, inventoryID, binID, qty.BaseQuantity); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update bin balance"})
+		}
+		if err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, inventoryID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to settle backorders"})
+		}
 	}
 	if err = tx.Commit(ctx); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit stock-in"})
@@ -6,13 +6,14 @@ import (
 	"context"
 	"database/sql"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jackc/pgx/v4"
 )
 
 var batchSortFields = map[string]string{"batchCode": "batch_code", "quantityRemaining": "quantity_remaining", "expiryDate": "expiry_date", "createdAt": "created_at"}
-var reservationSortFields = map[string]string{"quantity": "quantity", "status": "status", "createdAt": "created_at", "updatedAt": "updated_at"}
+var reservationSortFields = map[string]string{"quantity": "quantity", "status": "status", "expiresAt": "expires_at", "createdAt": "created_at", "updatedAt": "updated_at"}
 
 func scanInventoryBatch(scan func(...interface{}) error) (models.InventoryBatch, error) {
 	var item models.InventoryBatch
@@ -136,7 +137,7 @@ func scanInventoryReservation(scan func(...interface{}) error) (models.Inventory
 	var item models.InventoryReservation
 	var stock, unit, ref sql.NullString
 	var base sql.NullFloat64
-	if err := scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &item.ProductID, &stock, &unit, &ref, &item.ReservationKey, &item.Quantity, &base, &item.Status, &item.ReleasedAt, &item.CreatedAt, &item.UpdatedAt); err != nil {
+	if err := scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &item.ProductID, &stock, &unit, &ref, &item.ReservationKey, &item.Quantity, &base, &item.Status, &item.ExpiresAt, &item.SaleID, &item.ReleasedAt, &item.CreatedAt, &item.UpdatedAt); err != nil {
 		return item, err
 	}
 	if stock.Valid {
@@ -174,7 +175,7 @@ func HandleListInventoryReservations(c *fiber.Ctx) error {
 	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM inventory_reservations"+where, args...).Scan(&total); err != nil {
 		return fiber.NewError(500, "failed to count inventory reservations")
 	}
-	rows, err := db.Query(ctx, "SELECT id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity,status,released_at,created_at,updated_at FROM inventory_reservations"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	rows, err := db.Query(ctx, "SELECT id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity,status,expires_at,sale_id,released_at,created_at,updated_at FROM inventory_reservations"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
 	if err != nil {
 		return fiber.NewError(500, "failed to list inventory reservations")
 	}
@@ -199,12 +200,23 @@ func HandleCreateInventoryReservation(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.ShopID) == "" || strings.TrimSpace(req.ReservationKey) == "" || req.Quantity <= 0 {
 		return fiber.NewError(400, "shopId, reservationKey, and positive quantity are required")
 	}
+	if req.BaseQuantity != nil && *req.BaseQuantity <= 0 {
+		return fiber.NewError(400, "baseQuantity must be positive")
+	}
+	expiresAt, err := reservationExpiry(time.Now(), req.TTLMinutes, req.ExpiresAt)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	reserveQty := reservationBaseQuantity(req.Quantity, req.BaseQuantity)
 	ctx := context.Background()
 	tx, err := database.GetDB().Begin(ctx)
 	if err != nil {
 		return fiber.NewError(500, "failed to start reservation transaction")
 	}
 	defer tx.Rollback(ctx)
+	if _, err = expireInventoryReservations(ctx, pgxTxAdapter{tx: tx}, c.Params("inventoryItemId")); err != nil {
+		return fiber.NewError(500, "failed to expire lapsed reservations")
+	}
 	var productID, stockItemID, inventoryShop string
 	var onHand, reserved float64
 	err = tx.QueryRow(ctx, `SELECT product_id,stock_item_id,shop_id,quantity_on_hand,reserved_quantity FROM inventory_items WHERE id=$1 AND shop_id=$2 AND merchant_id=$3 FOR UPDATE`, c.Params("inventoryItemId"), req.ShopID, merchantID).Scan(&productID, &stockItemID, &inventoryShop, &onHand, &reserved)
@@ -214,20 +226,20 @@ func HandleCreateInventoryReservation(c *fiber.Ctx) error {
 	if err != nil {
 		return fiber.NewError(500, "failed to lock inventory item")
 	}
-	if onHand-reserved < req.Quantity {
+	if onHand-reserved < reserveQty {
 		return fiber.NewError(409, "insufficient available inventory")
 	}
 	var item models.InventoryReservation
 	var stock, unit, ref sql.NullString
 	var base sql.NullFloat64
-	err = tx.QueryRow(ctx, `INSERT INTO inventory_reservations(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity,status,released_at,created_at,updated_at`, merchantID, req.ShopID, c.Params("inventoryItemId"), productID, stockItemID, nullableStringValue(req.UnitID), nullableStringValue(req.ReferenceID), req.ReservationKey, req.Quantity, req.BaseQuantity).Scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &item.ProductID, &stock, &unit, &ref, &item.ReservationKey, &item.Quantity, &base, &item.Status, &item.ReleasedAt, &item.CreatedAt, &item.UpdatedAt)
+	err = tx.QueryRow(ctx, `INSERT INTO inventory_reservations(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity,expires_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,reference_id,reservation_key,quantity,base_quantity,status,expires_at,released_at,created_at,updated_at`, merchantID, req.ShopID, c.Params("inventoryItemId"), productID, stockItemID, nullableStringValue(req.UnitID), nullableStringValue(req.ReferenceID), req.ReservationKey, req.Quantity, req.BaseQuantity, expiresAt).Scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &item.ProductID, &stock, &unit, &ref, &item.ReservationKey, &item.Quantity, &base, &item.Status, &item.ExpiresAt, &item.ReleasedAt, &item.CreatedAt, &item.UpdatedAt)
 	if err != nil {
 		if isUniqueViolation(err) {
 			return duplicateResponse(c, "reservation key already exists")
 		}
 		return fiber.NewError(500, "failed to create reservation")
 	}
-	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET reserved_quantity=reserved_quantity+$1,updated_at=NOW() WHERE id=$2`, req.Quantity, c.Params("inventoryItemId")); err != nil {
+	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET reserved_quantity=reserved_quantity+$1,updated_at=NOW() WHERE id=$2`, reserveQty, c.Params("inventoryItemId")); err != nil {
 		return fiber.NewError(500, "failed to update reserved inventory")
 	}
 	if err = tx.Commit(ctx); err != nil {
@@ -262,14 +274,14 @@ func HandleReleaseInventoryReservation(c *fiber.Ctx) error {
 	var inventoryID string
 	var qty float64
 	var status string
-	err = tx.QueryRow(ctx, `SELECT inventory_item_id,quantity,status FROM inventory_reservations WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, c.Params("reservationId"), merchantID).Scan(&inventoryID, &qty, &status)
+	err = tx.QueryRow(ctx, `SELECT inventory_item_id,COALESCE(base_quantity,quantity),status FROM inventory_reservations WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, c.Params("reservationId"), merchantID).Scan(&inventoryID, &qty, &status)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "reservation not found")
 	}
 	if err != nil {
 		return fiber.NewError(500, "failed to lock reservation")
 	}
-	if status == "RELEASED" {
+	if status != "ACTIVE" {
 		return c.SendStatus(204)
 	}
 	if _, err = tx.Exec(ctx, `UPDATE inventory_reservations SET status='RELEASED',released_at=NOW(),updated_at=NOW() WHERE id=$1`, c.Params("reservationId")); err != nil {
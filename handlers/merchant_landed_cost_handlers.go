@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// lockGoodsReceipt locks a merchant's goods receipt so its landed costs are reallocated
+// one change at a time, and returns the shop it was received into.
+func lockGoodsReceipt(ctx context.Context, tx pgx.Tx, receiptID, merchantID string) (string, error) {
+	var shopID string
+	err := tx.QueryRow(ctx, `SELECT po.shop_id FROM goods_receipts gr JOIN purchase_orders po ON po.id=gr.purchase_order_id WHERE gr.id::text=$1 AND po.merchant_id=$2 FOR UPDATE OF gr`, receiptID, merchantID).Scan(&shopID)
+	return shopID, err
+}
+
+func loadGoodsReceiptCosts(ctx context.Context, db rowQuerier, receiptID, costID string) ([]models.GoodsReceiptCost, error) {
+	rows, err := db.Query(ctx, `SELECT id,goods_receipt_id,cost_type,description,amount::float8,allocation_method,created_at,updated_at FROM goods_receipt_costs WHERE goods_receipt_id=$1 AND ($2='' OR id::text=$2) ORDER BY created_at,id`, receiptID, costID)
+	if err != nil {
+		return nil, err
+	}
+	costs := make([]models.GoodsReceiptCost, 0)
+	index := map[string]int{}
+	for rows.Next() {
+		var cost models.GoodsReceiptCost
+		if err := rows.Scan(&cost.ID, &cost.GoodsReceiptID, &cost.CostType, &cost.Description, &cost.Amount, &cost.AllocationMethod, &cost.CreatedAt, &cost.UpdatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		cost.Allocations = make([]models.GoodsReceiptCostAllocation, 0)
+		index[cost.ID] = len(costs)
+		costs = append(costs, cost)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	rows, err = db.Query(ctx, `SELECT a.goods_receipt_cost_id,a.goods_receipt_item_id,gri.stock_item_id,a.amount::float8 FROM goods_receipt_cost_allocations a JOIN goods_receipt_items gri ON gri.id=a.goods_receipt_item_id WHERE gri.goods_receipt_id=$1 ORDER BY gri.id`, receiptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var costID string
+		var allocation models.GoodsReceiptCostAllocation
+		if err := rows.Scan(&costID, &allocation.GoodsReceiptItemID, &allocation.StockItemID, &allocation.Amount); err != nil {
+			return nil, err
+		}
+		if i, ok := index[costID]; ok {
+			costs[i].Allocations = append(costs[i].Allocations, allocation)
+		}
+	}
+	return costs, rows.Err()
+}
+
+// HandleListGoodsReceiptCosts lists the landed costs of a goods receipt with their allocations.
+func HandleListGoodsReceiptCosts(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var owned bool
+	if err = db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM goods_receipts gr JOIN purchase_orders po ON po.id=gr.purchase_order_id WHERE gr.id::text=$1 AND po.merchant_id=$2)`, c.Params("receiptId"), merchantID).Scan(&owned); err != nil {
+		return fiber.NewError(500, "failed to load goods receipt")
+	}
+	if !owned {
+		return fiber.NewError(404, "goods receipt not found")
+	}
+	costs, err := loadGoodsReceiptCosts(ctx, db, c.Params("receiptId"), "")
+	if err != nil {
+		return fiber.NewError(500, "failed to load landed costs")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": costs})
+}
+
+// HandleCreateGoodsReceiptCost adds a landed cost to a receipt after it was booked. The
+// receipt's costs are reallocated and the stock it brought in is revalued.
+func HandleCreateGoodsReceiptCost(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.GoodsReceiptCostRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	if err := validateLandedCost(&req); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return changeGoodsReceiptCosts(c, merchantID, 201, func(ctx context.Context, tx pgx.Tx, receiptID string) (string, error) {
+		var costID string
+		err := tx.QueryRow(ctx, `INSERT INTO goods_receipt_costs(goods_receipt_id,cost_type,description,amount,allocation_method,created_by) VALUES($1,$2,$3,$4,$5,$6) RETURNING id`, receiptID, req.CostType, nullableStringValue(req.Description), req.Amount, req.AllocationMethod, merchantID).Scan(&costID)
+		return costID, err
+	})
+}
+
+// HandleUpdateGoodsReceiptCost changes the amount or allocation of a landed cost.
+func HandleUpdateGoodsReceiptCost(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.GoodsReceiptCostRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	if err := validateLandedCost(&req); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	return changeGoodsReceiptCosts(c, merchantID, 200, func(ctx context.Context, tx pgx.Tx, receiptID string) (string, error) {
+		tag, err := tx.Exec(ctx, `UPDATE goods_receipt_costs SET cost_type=$1,description=$2,amount=$3,allocation_method=$4,updated_at=NOW() WHERE id::text=$5 AND goods_receipt_id=$6`, req.CostType, nullableStringValue(req.Description), req.Amount, req.AllocationMethod, c.Params("costId"), receiptID)
+		if err == nil && tag.RowsAffected() == 0 {
+			err = pgx.ErrNoRows
+		}
+		return c.Params("costId"), err
+	})
+}
+
+// HandleDeleteGoodsReceiptCost removes a landed cost and takes it back out of the stock.
+func HandleDeleteGoodsReceiptCost(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	return changeGoodsReceiptCosts(c, merchantID, 200, func(ctx context.Context, tx pgx.Tx, receiptID string) (string, error) {
+		tag, err := tx.Exec(ctx, `DELETE FROM goods_receipt_costs WHERE id::text=$1 AND goods_receipt_id=$2`, c.Params("costId"), receiptID)
+		if err == nil && tag.RowsAffected() == 0 {
+			err = pgx.ErrNoRows
+		}
+		return "", err
+	})
+}
+
+// changeGoodsReceiptCosts applies one change to a receipt's landed costs, reallocates
+// them, and responds with the receipt's costs (or the changed one, when it remains).
+func changeGoodsReceiptCosts(c *fiber.Ctx, merchantID string, status int, change func(context.Context, pgx.Tx, string) (string, error)) error {
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start landed cost change")
+	}
+	defer tx.Rollback(ctx)
+	receiptID := c.Params("receiptId")
+	shopID, err := lockGoodsReceipt(ctx, tx, receiptID, merchantID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "goods receipt not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load goods receipt")
+	}
+	costID, err := change(ctx, tx, receiptID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "landed cost not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to save landed cost")
+	}
+	if err = reallocateLandedCosts(ctx, pgxTxAdapter{tx: tx}, merchantID, shopID, receiptID, receiptID, merchantID); err != nil {
+		if isLandedCostError(err) {
+			return fiber.NewError(400, err.Error())
+		}
+		return fiber.NewError(500, "failed to reallocate landed costs")
+	}
+	costs, err := loadGoodsReceiptCosts(ctx, tx, receiptID, costID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load landed costs")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit landed cost change")
+	}
+	if costID != "" && len(costs) == 1 {
+		return c.Status(status).JSON(fiber.Map{"status": "success", "success": true, "data": costs[0]})
+	}
+	return c.Status(status).JSON(fiber.Map{"status": "success", "success": true, "data": costs})
+}
+
+// HandleListInventoryCostAdjustments lists the revaluations posted by landed cost changes.
+func HandleListInventoryCostAdjustments(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "createdAt", map[string]string{"createdAt": "a.created_at", "inventoryAmount": "a.inventory_amount"})
+	where := " WHERE a.merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, filter := range [][2]string{{"shopId", "a.shop_id"}, {"inventoryItemId", "a.inventory_item_id"}, {"goodsReceiptId", "a.reference_id"}} {
+		if v := c.Query(filter[0]); v != "" {
+			where += " AND " + filter[1] + "::text=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM inventory_cost_adjustments a"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count cost adjustments")
+	}
+	rows, err := db.Query(ctx, "SELECT a.id,a.shop_id,a.inventory_item_id,a.goods_receipt_item_id,a.batch_id,a.reference_type,a.reference_id,a.unit_cost_delta::float8,a.revalued_quantity::float8,a.inventory_amount::float8,a.consumed_quantity::float8,a.consumed_amount::float8,a.created_at FROM inventory_cost_adjustments a"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list cost adjustments")
+	}
+	defer rows.Close()
+	items := make([]models.InventoryCostAdjustment, 0)
+	for rows.Next() {
+		var item models.InventoryCostAdjustment
+		if err := rows.Scan(&item.ID, &item.ShopID, &item.InventoryItemID, &item.GoodsReceiptItemID, &item.BatchID, &item.ReferenceType, &item.ReferenceID, &item.UnitCostDelta, &item.RevaluedQuantity, &item.InventoryAmount, &item.ConsumedQuantity, &item.ConsumedAmount, &item.CreatedAt); err != nil {
+			return fiber.NewError(500, "failed to read cost adjustment")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
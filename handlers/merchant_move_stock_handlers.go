@@ -10,11 +10,16 @@ import (
 )
 
 type MoveStockRequest struct {
-	ClientOperationID string `json:"clientOperationId"`
-	ItemID            string `json:"itemId"`
-	FromShopID        string `json:"fromShopId"`
-	ToShopID          string `json:"toShopId"`
-	Quantity          int    `json:"quantity"`
+	ClientOperationID string   `json:"clientOperationId"`
+	ItemID            string   `json:"itemId"`
+	FromShopID        string   `json:"fromShopId"`
+	ToShopID          string   `json:"toShopId"`
+	Quantity          int      `json:"quantity"`
+	BatchID           string   `json:"batchId,omitempty"`
+	SerialNumbers     []string `json:"serialNumbers,omitempty"`
+	// Bins picked from and put away into; omitted bins default to each balance's own.
+	FromBinID *string `json:"fromBinId,omitempty"`
+	ToBinID   *string `json:"toBinId,omitempty"`
 }
 
 func HandleMoveStock(c *fiber.Ctx) error {
@@ -52,43 +57,106 @@ func HandleMoveStock(c *fiber.Ctx) error {
 	if !claimed {
 		return c.JSON(fiber.Map{"status": "success", "message": "Stock transfer already processed"})
 	}
+	result, ferr := moveStock(ctx, tx, claims.UserID, req, float64(req.Quantity))
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"status": "error", "message": ferr.Message})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit transfer"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "message": "Stock moved successfully", "data": result})
+}
+
+// moveStock moves quantity of req.ItemID between two of the merchant's shops inside tx,
+// carrying batches, cost and serials across and recording both movements under
+// req.ClientOperationID, which the caller has already claimed.
+func moveStock(ctx context.Context, tx pgx.Tx, merchantID string, req MoveStockRequest, quantity float64) (fiber.Map, *fiber.Error) {
 	var productID string
-	if err = tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id=$1 AND merchant_id=$2`, req.ItemID, claims.UserID).Scan(&productID); err != nil {
-		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Stock item not found"})
+	err := tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id=$1 AND merchant_id=$2`, req.ItemID, merchantID).Scan(&productID)
+	if err != nil {
+		return nil, fiber.NewError(404, "Stock item not found")
 	}
 	var fromID string
 	var fromQty float64
 	if err = tx.QueryRow(ctx, `SELECT id,quantity_on_hand FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, req.FromShopID, req.ItemID).Scan(&fromID, &fromQty); err != nil {
-		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Item is not stocked in source shop"})
+		return nil, fiber.NewError(404, "Item is not stocked in source shop")
 	}
-	if fromQty < float64(req.Quantity) {
-		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Insufficient stock in source shop"})
+	if fromQty < quantity {
+		return nil, fiber.NewError(409, "Insufficient stock in source shop")
 	}
-	newFrom := fromQty - float64(req.Quantity)
+	newFrom := fromQty - quantity
 	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, newFrom, fromID); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update source stock"})
+		return nil, fiber.NewError(500, "Failed to update source stock")
+	}
+	batches, err := consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, req.ItemID, fromID, quantity, req.BatchID)
+	if err != nil {
+		if isBatchConsumptionError(err) {
+			return nil, fiber.NewError(409, err.Error())
+		}
+		return nil, fiber.NewError(500, "Failed to update source batches")
+	}
+	// The destination receives the stock at the cost it left the source with.
+	transferCost, err := issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, fromID, quantity, batches)
+	if err != nil {
+		return nil, fiber.NewError(500, "Failed to cost source stock")
 	}
 	var toID string
 	var newTo float64
 	if err = tx.QueryRow(ctx, `SELECT id,quantity_on_hand FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, req.ToShopID, req.ItemID).Scan(&toID, &newTo); err == pgx.ErrNoRows {
-		err = tx.QueryRow(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES($1,$2,$3,$4,$5) RETURNING id,quantity_on_hand`, claims.UserID, req.ToShopID, productID, req.ItemID, req.Quantity).Scan(&toID, &newTo)
+		err = tx.QueryRow(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES($1,$2,$3,$4,$5) RETURNING id,quantity_on_hand`, merchantID, req.ToShopID, productID, req.ItemID, quantity).Scan(&toID, &newTo)
 	} else if err == nil {
-		newTo += float64(req.Quantity)
+		newTo += quantity
 		_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, newTo, toID)
 	}
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update destination stock"})
+		return nil, fiber.NewError(500, "Failed to update destination stock")
+	}
+	fromBinID, err := resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, fromID, req.FromBinID, false)
+	var toBinID *string
+	if err == nil {
+		toBinID, err = resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, toID, req.ToBinID, true)
+	}
+	if err != nil {
+		if err == errStorageBinInvalid {
+			return nil, fiber.NewError(400, err.Error())
+		}
+		return nil, fiber.NewError(500, "Failed to resolve transfer bins")
+	}
+	if err = receiveTransferredBatches(ctx, pgxTxAdapter{tx: tx}, merchantID, req.ToShopID, toID, productID, req.ItemID, batches); err != nil {
+		return nil, fiber.NewError(500, "Failed to update destination batches")
+	}
+	if _, err = receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, toID, quantity, &transferCost); err != nil {
+		return nil, fiber.NewError(500, "Failed to cost destination stock")
+	}
+	var transferID string
+	if err = tx.QueryRow(ctx, `SELECT id FROM inventory_operations WHERE client_operation_id=$1`, req.ClientOperationID).Scan(&transferID); err != nil {
+		return nil, fiber.NewError(500, "Failed to record stock transfer")
+	}
+	serials, err := transferInventorySerials(ctx, pgxTxAdapter{tx: tx}, req.ItemID, fromID, toID, req.ToShopID, transferID, merchantID, quantity, req.SerialNumbers)
+	if err != nil {
+		if isSerialError(err) {
+			return nil, fiber.NewError(serialErrorStatus(err), err.Error())
+		}
+		return nil, fiber.NewError(500, "Failed to move serials")
 	}
 	for _, v := range []struct {
 		shop, inv, typ string
 		qty            float64
-	}{{req.FromShopID, fromID, "OUT", float64(req.Quantity)}, {req.ToShopID, toID, "IN", float64(req.Quantity)}} {
-		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,$6,$7,$7,'TRANSFER',NULL,$8,$9)`, claims.UserID, v.shop, v.inv, productID, req.ItemID, v.typ, v.qty, fmt.Sprintf("%s:%s", req.ClientOperationID, v.shop), fmt.Sprintf("Transfer between shops: %s -> %s", req.FromShopID, req.ToShopID)); err != nil {
-			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record stock transfer"})
+		bin            *string
+	}{{req.FromShopID, fromID, "OUT", quantity, fromBinID}, {req.ToShopID, toID, "IN", quantity, toBinID}} {
+		binDelta := v.qty
+		if v.typ == "OUT" {
+			binDelta = -binDelta
+		}
+		if err = adjustBinBalance(ctx, pgxTxAdapter{tx: tx}, v.inv, v.bin, binDelta); err != nil {
+			return nil, fiber.NewError(500, "Failed to update bin balances")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES($1,$2,$3,$4,$5,$6,$7,$7,$11,'TRANSFER',$10,$8,$9,$12)`, merchantID, v.shop, v.inv, productID, req.ItemID, v.typ, v.qty, fmt.Sprintf("%s:%s", req.ClientOperationID, v.shop), fmt.Sprintf("Transfer between shops: %s -> %s", req.FromShopID, req.ToShopID), transferID, transferCost, v.bin); err != nil {
+			return nil, fiber.NewError(500, "Failed to record stock transfer")
 		}
 	}
-	if err = tx.Commit(ctx); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit transfer"})
+	if err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, toID); err != nil {
+		return nil, fiber.NewError(500, "Failed to settle backorders")
 	}
-	return c.JSON(fiber.Map{"status": "success", "message": "Stock moved successfully", "data": fiber.Map{"fromShopNewQuantity": newFrom, "toShopNewQuantity": newTo}})
+	return fiber.Map{"fromShopNewQuantity": newFrom, "toShopNewQuantity": newTo, "batches": batches, "serialNumbers": serials}, nil
 }
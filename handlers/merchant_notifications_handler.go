@@ -168,3 +168,9 @@ func HandleMarkNotificationAsRead(c *fiber.Ctx) error {
 	}
 	return c.JSON(fiber.Map{"success": true, "message": "Notification marked as read"})
 }
+
+// createNotification stores an in-app notification for a user inside the caller's transaction.
+func createNotification(ctx context.Context, tx DBTx, recipientID, title, message, notificationType, entityType, entityID string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO notifications(recipient_user_id,title,message,notification_type,related_entity_type,related_entity_id) VALUES($1,$2,$3,$4,$5,$6)`, recipientID, title, message, notificationType, nullableString(entityType), nullableString(entityID))
+	return err
+}
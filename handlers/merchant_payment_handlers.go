@@ -98,7 +98,7 @@ func HandleCreatePaymentIntent(c *fiber.Ctx) error {
 		}
 
 		// 2. Check stock availability in the specific shop.
-		queryStock := "SELECT quantity_on_hand FROM inventory_items WHERE shop_id = $1 AND stock_item_id = $2 AND merchant_id = $3 FOR UPDATE"
+		queryStock := "SELECT " + unreservedStockSQL("ii") + " FROM inventory_items ii WHERE ii.shop_id = $1 AND ii.stock_item_id = $2 AND ii.merchant_id = $3 FOR UPDATE"
 		err = tx.QueryRow(ctx, queryStock, req.ShopID, item.InventoryItemID, merchantID).Scan(&currentStock)
 		if err != nil {
 			log.Printf("Error fetching stock for item %s in shop %s: %v", item.InventoryItemID, req.ShopID, err)
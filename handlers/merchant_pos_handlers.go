@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
@@ -220,20 +219,24 @@ func HandleSearchProductsForPOS(c *fiber.Ctx) error {
 	if foundMerchantID != merchantID {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"status": "error", "message": "Access to shop denied"})
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, shopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 
 	// Build dynamic query with optional filters
 	baseQuery := `
 		SELECT si.id, si.merchant_id, si.name, p.description, si.sku,
 			COALESCE(pp.selling_price, 0), COALESCE(pp.cost_price, 0),
 			NULL, NULL, p.brand_id, NOT p.is_active, si.created_at, si.updated_at,
-			ii.quantity_on_hand
+			stock.available, ii.reserved_quantity
 		FROM inventory_items ii
 		JOIN stock_items si ON si.id = ii.stock_item_id
 		JOIN products p ON p.id = si.product_id
 		LEFT JOIN LATERAL (SELECT selling_price, cost_price FROM product_prices
 			WHERE product_id = si.product_id AND shop_id IS NULL AND price_type = 'RETAIL'
 			ORDER BY created_at DESC LIMIT 1) pp ON TRUE
-		WHERE ii.merchant_id = $1 AND ii.shop_id = $2 AND ii.quantity_on_hand > 0
+		` + availableStockJoin + `
+		WHERE ii.merchant_id = $1 AND ii.shop_id = $2 AND stock.available > 0
 		  AND p.is_active = TRUE AND (si.name ILIKE $3 OR si.sku ILIKE $3)
 	`
 
@@ -272,12 +275,12 @@ func HandleSearchProductsForPOS(c *fiber.Ctx) error {
 	items := make([]fiber.Map, 0)
 	for rows.Next() {
 		var item models.InventoryItem
-		var stockQuantity float64
+		var stockQuantity, reservedQuantity float64
 		if err := rows.Scan(
 			&item.ID, &item.MerchantID, &item.Name, &item.Description, &item.SKU,
 			&item.SellingPrice, &item.OriginalPrice, &item.LowStockThreshold,
 			&item.CategoryID, &item.BrandID, &item.IsArchived, &item.CreatedAt, &item.UpdatedAt,
-			&stockQuantity,
+			&stockQuantity, &reservedQuantity,
 		); err != nil {
 			log.Printf("Error scanning product item: %v", err)
 			continue
@@ -300,8 +303,9 @@ func HandleSearchProductsForPOS(c *fiber.Ctx) error {
 			"updatedAt":         item.UpdatedAt,
 			"stockInfo": []fiber.Map{
 				{
-					"shopId":   shopID,
-					"quantity": stockQuantity,
+					"shopId":           shopID,
+					"quantity":         stockQuantity,
+					"reservedQuantity": reservedQuantity,
 				},
 			},
 		}
@@ -332,6 +336,9 @@ func HandleCheckout(c *fiber.Ctx) error {
 	if err := authorizeShopAccess(c, req.ShopID); err != nil {
 		return err
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, req.ShopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 	clientSaleID := strings.TrimSpace(req.ClientSaleID)
 	if clientSaleID == "" {
 		clientSaleID = strings.TrimSpace(req.ID)
@@ -345,12 +352,25 @@ func HandleCheckout(c *fiber.Ctx) error {
 	if req.TotalAmount < 0 || req.DiscountAmount < 0 || req.TaxAmount < 0 || req.DeliveryCharge < 0 || req.DiscountAmount > req.TotalAmount {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid sale totals"})
 	}
+	for i, item := range req.Items {
+		scale, err := checkoutScaleLine(ctx, pgxPoolAdapter{pool: db}, merchantID, item.ScaleBarcode)
+		if err != nil {
+			if isScaleBarcodeError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to resolve scale barcode"})
+		}
+		if scale != nil {
+			req.Items[i].ProductID, req.Items[i].Quantity, req.Items[i].UnitID = scale.StockItemID, scale.Quantity, scale.Format.UnitID
+			req.Items[i].SellingPriceAtSale = scale.sellingPrice(item.SellingPriceAtSale)
+		}
+	}
 	var calculatedSubtotal float64
 	for _, item := range req.Items {
 		if strings.TrimSpace(item.ProductID) == "" || item.Quantity <= 0 || item.SellingPriceAtSale < 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid sale item"})
 		}
-		calculatedSubtotal += float64(item.Quantity) * item.SellingPriceAtSale
+		calculatedSubtotal += item.Quantity * item.SellingPriceAtSale
 	}
 	calculatedTotal := calculatedSubtotal - req.DiscountAmount + req.TaxAmount + req.DeliveryCharge
 	if calculatedTotal < req.TotalAmount-0.01 || calculatedTotal > req.TotalAmount+0.01 {
@@ -434,6 +454,7 @@ func HandleCheckout(c *fiber.Ctx) error {
 	}
 
 	// Process each item in the sale
+	stockWarnings := make([]string, 0)
 	for _, item := range req.Items {
 		// Resolve the merchant stock item to the shop-specific inventory balance.
 		var itemName string
@@ -455,42 +476,115 @@ func HandleCheckout(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s not found", item.ProductID)})
 		}
 
-		// 1. Decrement stock and check for sufficiency
-		var newQuantity float64
-		stockUpdateQuery := `
-			UPDATE inventory_items
-			SET quantity_on_hand = quantity_on_hand - $1, updated_at = NOW()
-			WHERE id = $2 AND quantity_on_hand >= $1
-			RETURNING quantity_on_hand
-		`
-		err = tx.QueryRow(ctx, stockUpdateQuery, item.Quantity, inventoryID).Scan(&newQuantity)
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, stockItemID, item.UnitID, item.Quantity)
 		if err != nil {
-			if err == pgx.ErrNoRows {
-				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Insufficient stock for product ID: %s", item.ProductID)})
+			if isUnitError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
 			}
-			log.Printf("Failed to update stock for item %s: %v", item.ProductID, err)
+			log.Printf("Failed to resolve unit for product %s: %v", item.ProductID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
 		}
+		if err = consumeInventoryReservation(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.ReservationID), inventoryID, saleID); err != nil {
+			if err == errReservationUnavailable {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Failed to consume reservation for product %s: %v", item.ProductID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+
+		// Kits are sold as one line but deduct their component stock instead of their own.
+		kit, err := loadStockItemKit(ctx, pgxTxAdapter{tx: tx}, stockItemID)
+		if err != nil {
+			log.Printf("Failed to load kit for product %s: %v", item.ProductID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+
+		// 1. Decrement stock and check for sufficiency
+		var batches []batchAllocation
+		var unitCost float64
+		if kit == nil {
+			deduction, err := deductSaleStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, qty.BaseQuantity, "SALE", saleID)
+			if err != nil {
+				if err == errInsufficientStock {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Insufficient stock for product ID: %s", item.ProductID)})
+				}
+				log.Printf("Failed to update stock for item %s: %v", item.ProductID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+			}
+			if warning := deduction.warning(itemName); warning != "" {
+				stockWarnings = append(stockWarnings, warning)
+			}
+
+			batches, err = consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, stockItemID, inventoryID, deduction.batched(qty.BaseQuantity), selectedBatchID(item.BatchID))
+			if err != nil {
+				if isBatchConsumptionError(err) {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+				}
+				log.Printf("Failed to consume batches for item %s: %v", item.ProductID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+			}
+			unitCost, err = issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, inventoryID, qty.BaseQuantity, batches)
+			if err != nil {
+				log.Printf("Failed to cost stock for item %s: %v", item.ProductID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+			}
+		}
 
 		// 2. Create the sale_items record
 		saleItemQuery := `
-			INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, unit_id, item_name, item_sku, quantity_sold, base_quantity, selling_price_at_sale, original_price_at_sale, subtotal, unit_cost, cost_total)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			RETURNING id
 		`
-		subtotal := float64(item.Quantity) * item.SellingPriceAtSale
-		_, err = tx.Exec(ctx, saleItemQuery, saleID, inventoryID, productID, stockItemID, itemName, itemSKU, item.Quantity, item.SellingPriceAtSale, originalPrice, subtotal)
+		subtotal := item.Quantity * item.SellingPriceAtSale
+		var saleItemID string
+		err = tx.QueryRow(ctx, saleItemQuery, saleID, inventoryID, productID, stockItemID, qty.UnitID, itemName, itemSKU, item.Quantity, qty.BaseQuantity, item.SellingPriceAtSale, originalPrice, subtotal, unitCost, saleLineCost(qty.BaseQuantity, unitCost)).Scan(&saleItemID)
 		if err != nil {
 			log.Printf("Failed to create sale_item record for product %s: %v", item.ProductID, err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record sale item details"})
 		}
+		if kit != nil {
+			kitCost, kitWarnings, err := sellKitComponents(ctx, pgxTxAdapter{tx: tx}, kit, merchantID, req.ShopID, saleID, saleItemID, "SALE", qty.BaseQuantity)
+			if err != nil {
+				if isKitSaleError(err) || isBatchConsumptionError(err) {
+					return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+				}
+				log.Printf("Failed to deduct kit components for product %s: %v", item.ProductID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+			}
+			if err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, kitCost); err != nil {
+				log.Printf("Failed to record kit cost for product %s: %v", item.ProductID, err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record sale item details"})
+			}
+			stockWarnings = append(stockWarnings, kitWarnings...)
+			continue
+		}
+		if err = recordSaleItemBatches(ctx, pgxTxAdapter{tx: tx}, saleItemID, batches); err != nil {
+			log.Printf("Failed to record batch lines for product %s: %v", item.ProductID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record sale item details"})
+		}
+		if _, err = sellInventorySerials(ctx, pgxTxAdapter{tx: tx}, stockItemID, inventoryID, req.ShopID, saleID, merchantID, qty.BaseQuantity, item.SerialNumbers); err != nil {
+			if isSerialError(err) {
+				return c.Status(serialErrorStatus(err)).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Failed to mark serials sold for product %s: %v", item.ProductID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record sale item details"})
+		}
+		if err = sellInventoryAsset(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.AssetID), merchantID, inventoryID, saleID, merchantID, qty.BaseQuantity); err != nil {
+			if err == errAssetNotFound || isAssetError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Failed to mark asset sold for product %s: %v", item.ProductID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record sale item details"})
+		}
 
 		// 3. Create a stock movement record
 		stockMovementQuery := `
-			INSERT INTO inventory_movements (merchant_id, shop_id, inventory_item_id, product_id, stock_item_id, movement_type, quantity, base_quantity, reference_type, reference_id, event_key, notes)
-			VALUES ($1, $2, $3, $4, $5, 'OUT', $6, $6, 'SALE', $7, $8, $9)
+			INSERT INTO inventory_movements (merchant_id, shop_id, inventory_item_id, product_id, stock_item_id, unit_id, movement_type, quantity, base_quantity, unit_cost, reference_type, reference_id, event_key, notes)
+			VALUES ($1, $2, $3, $4, $5, $6, 'OUT', $7, $8, $9, 'SALE', $10, $11, $12)
 		`
 		reason := fmt.Sprintf("Sale #%s", saleID)
-		_, err = tx.Exec(ctx, stockMovementQuery, merchantID, req.ShopID, inventoryID, productID, stockItemID, item.Quantity, saleID, fmt.Sprintf("%s:%s", saleID, stockItemID), reason)
+		_, err = tx.Exec(ctx, stockMovementQuery, merchantID, req.ShopID, inventoryID, productID, stockItemID, qty.UnitID, item.Quantity, qty.BaseQuantity, unitCost, saleID, fmt.Sprintf("%s:%s", saleID, stockItemID), reason)
 		if err != nil {
 			log.Printf("Failed to create stock movement record for product %s: %v", item.ProductID, err)
 			// This is a non-critical error for the customer, but we must log it.
@@ -556,10 +650,10 @@ func HandleCheckout(c *fiber.Ctx) error {
 	if err != nil {
 		log.Printf("Failed to fetch created sale %s: %v", saleID, err)
 		// The sale was successful, so we return a success message even if re-fetch fails.
-		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "message": "Sale completed successfully"})
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "message": "Sale completed successfully", "stockWarnings": stockWarnings})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": createdSale})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": createdSale, "stockWarnings": stockWarnings})
 }
 
 // getSaleByID is a helper function to fetch a sale and its items.
@@ -573,7 +667,7 @@ func getSaleByID(ctx context.Context, db *pgxpool.Pool, saleID string) (*models.
 		return nil, err
 	}
 
-	itemsQuery := `SELECT id, sale_id, inventory_item_id, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal, item_name, item_sku, created_at, updated_at FROM sale_items WHERE sale_id = $1`
+	itemsQuery := `SELECT id, sale_id, inventory_item_id, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal, item_name, item_sku, quantity_returned, unit_cost::float8, cost_total::float8, created_at, updated_at FROM sale_items WHERE sale_id = $1`
 	rows, err := db.Query(ctx, itemsQuery, saleID)
 	if err != nil {
 		return nil, err
@@ -583,11 +677,15 @@ func getSaleByID(ctx context.Context, db *pgxpool.Pool, saleID string) (*models.
 	sale.Items = make([]models.SaleItem, 0)
 	for rows.Next() {
 		var item models.SaleItem
-		if err := rows.Scan(&item.ID, &item.SaleID, &item.InventoryItemID, &item.QuantitySold, &item.SellingPriceAtSale, &item.OriginalPriceAtSale, &item.Subtotal, &item.ItemName, &item.ItemSKU, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		if err := rows.Scan(&item.ID, &item.SaleID, &item.InventoryItemID, &item.QuantitySold, &item.SellingPriceAtSale, &item.OriginalPriceAtSale, &item.Subtotal, &item.ItemName, &item.ItemSKU, &item.QuantityReturned, &item.UnitCost, &item.CostTotal, &item.CreatedAt, &item.UpdatedAt); err != nil {
 			return nil, err
 		}
 		sale.Items = append(sale.Items, item)
 	}
+	rows.Close()
+	if err := attachSaleItemBatches(ctx, db, &sale); err != nil {
+		return nil, err
+	}
 
 	return &sale, nil
 }
@@ -31,6 +31,9 @@ func HandleOpenPOSSession(c *fiber.Ctx) error {
 	if owner != claims.UserID {
 		return c.Status(403).JSON(fiber.Map{"status": "error", "message": "Shop access denied"})
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, req.ShopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 	if req.OpeningCash < 0 {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "openingCash cannot be negative"})
 	}
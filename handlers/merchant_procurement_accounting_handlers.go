@@ -3,9 +3,11 @@ package handlers
 import (
 	"app/database"
 	"app/middleware"
+	"app/models"
 	"context"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
 	"strconv"
 )
 
@@ -13,8 +15,18 @@ type purchaseItemRequest struct {
 	ProductID   string  `json:"productId"`
 	StockItemID string  `json:"stockItemId"`
 	Quantity    float64 `json:"quantity"`
-	UnitCost    float64 `json:"unitCost"`
+	UnitID      *string `json:"unitId,omitempty"`
+	// UnitCost is filled in from the supplier's catalog when omitted.
+	UnitCost *float64 `json:"unitCost,omitempty"`
 }
+
+func (i purchaseItemRequest) cost() float64 {
+	if i.UnitCost == nil {
+		return 0
+	}
+	return *i.UnitCost
+}
+
 type purchaseOrderRequest struct {
 	ShopID            string                `json:"shopId"`
 	SupplierID        string                `json:"supplierId"`
@@ -96,45 +108,102 @@ func HandleCreatePurchaseOrder(c *fiber.Ctx) error {
 	if err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM shops WHERE id=$1 AND merchant_id=$2`, req.ShopID, claims.UserID).Scan(&ok); err != nil || ok == 0 {
 		return c.Status(403).JSON(fiber.Map{"status": "error", "message": "Shop access denied"})
 	}
-	if err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM suppliers WHERE id=$1 AND merchant_id=$2`, req.SupplierID, claims.UserID).Scan(&ok); err != nil || ok == 0 {
-		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Supplier not found"})
+	orderID, subtotal, ferr := createPurchaseOrder(ctx, tx, claims.UserID, req.ShopID, req.SupplierID, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"status": "error", "message": ferr.Message})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit purchase order"})
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "data": fiber.Map{"id": orderID, "status": "DRAFT", "subtotal": subtotal, "total": subtotal}})
+}
+
+// createPurchaseOrder creates a draft order from the supplier for one of the merchant's
+// shops, which the caller has checked, returning its id and subtotal.
+func createPurchaseOrder(ctx context.Context, tx pgx.Tx, merchantID, shopID, supplierID string, items []purchaseItemRequest) (string, float64, *fiber.Error) {
+	var ok int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM suppliers WHERE id=$1 AND merchant_id=$2`, supplierID, merchantID).Scan(&ok); err != nil || ok == 0 {
+		return "", 0, fiber.NewError(400, "Supplier not found")
+	}
+	quantities, subtotal, ferr := resolvePurchaseItems(ctx, tx, merchantID, supplierID, items)
+	if ferr != nil {
+		return "", 0, ferr
+	}
+	var orderID string
+	if err := tx.QueryRow(ctx, `INSERT INTO purchase_orders(merchant_id,shop_id,supplier_id,subtotal,total) VALUES($1,$2,$3,$4,$4) RETURNING id`, merchantID, shopID, supplierID, subtotal).Scan(&orderID); err != nil {
+		return "", 0, fiber.NewError(500, "Failed to create purchase order")
+	}
+	if err := insertPurchaseOrderItems(ctx, tx, orderID, items, quantities); err != nil {
+		return "", 0, fiber.NewError(400, "Invalid purchase item reference")
+	}
+	return orderID, subtotal, nil
+}
+
+// resolvePurchaseItems checks order lines against the merchant's catalog, prices lines
+// without a cost from the supplier's catalog and converts them to base units, returning
+// the order subtotal.
+func resolvePurchaseItems(ctx context.Context, tx pgx.Tx, merchantID, supplierID string, items []purchaseItemRequest) ([]unitQuantity, float64, *fiber.Error) {
+	if err := prefillPurchaseItemCosts(ctx, pgxTxAdapter{tx: tx}, supplierID, items); err != nil {
+		return nil, 0, fiber.NewError(500, "Failed to load supplier costs")
 	}
 	var subtotal float64
-	for _, i := range req.Items {
-		if i.ProductID == "" || i.Quantity <= 0 || i.UnitCost < 0 {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid purchase item"})
+	quantities := make([]unitQuantity, len(items))
+	for n, i := range items {
+		if i.ProductID == "" || i.Quantity <= 0 || i.cost() < 0 {
+			return nil, 0, fiber.NewError(400, "Invalid purchase item")
 		}
 		var validItem int
 		itemQuery := `SELECT COUNT(*) FROM stock_items WHERE merchant_id=$1 AND product_id=$2 AND ($3='' OR id=$3)`
-		if err = tx.QueryRow(ctx, itemQuery, claims.UserID, i.ProductID, i.StockItemID).Scan(&validItem); err != nil || validItem == 0 {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Product or stock item does not belong to this merchant"})
+		if err := tx.QueryRow(ctx, itemQuery, merchantID, i.ProductID, i.StockItemID).Scan(&validItem); err != nil || validItem == 0 {
+			return nil, 0, fiber.NewError(400, "Product or stock item does not belong to this merchant")
 		}
-		subtotal += i.Quantity * i.UnitCost
-	}
-	var orderID string
-	if err = tx.QueryRow(ctx, `INSERT INTO purchase_orders(merchant_id,shop_id,supplier_id,subtotal,total) VALUES($1,$2,$3,$4,$4) RETURNING id`, claims.UserID, req.ShopID, req.SupplierID, subtotal).Scan(&orderID); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to create purchase order"})
-	}
-	for _, i := range req.Items {
-		if _, err = tx.Exec(ctx, `INSERT INTO purchase_order_items(purchase_order_id,product_id,stock_item_id,quantity,base_quantity,unit_cost,total_cost) VALUES($1,$2,$3,$4,$4,$5,$6)`, orderID, i.ProductID, nullableString(i.StockItemID), i.Quantity, i.UnitCost, i.Quantity*i.UnitCost); err != nil {
-			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid purchase item reference"})
+		quantities[n] = unitQuantity{Quantity: i.Quantity, BaseQuantity: i.Quantity, Factor: 1}
+		if i.StockItemID != "" {
+			var err error
+			if quantities[n], err = resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, i.StockItemID, i.UnitID, i.Quantity); err != nil {
+				if isUnitError(err) {
+					return nil, 0, fiber.NewError(400, err.Error())
+				}
+				return nil, 0, fiber.NewError(500, "Failed to resolve purchase unit")
+			}
 		}
+		subtotal += i.Quantity * i.cost()
 	}
-	if err = tx.Commit(ctx); err != nil {
-		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit purchase order"})
+	return quantities, subtotal, nil
+}
+
+func insertPurchaseOrderItems(ctx context.Context, tx pgx.Tx, orderID string, items []purchaseItemRequest, quantities []unitQuantity) error {
+	for n, i := range items {
+		if _, err := tx.Exec(ctx, `INSERT INTO purchase_order_items(purchase_order_id,product_id,stock_item_id,unit_id,quantity,base_quantity,unit_cost,total_cost) VALUES($1,$2,$3,$4,$5,$6,$7,$8)`, orderID, i.ProductID, nullableString(i.StockItemID), quantities[n].UnitID, i.Quantity, quantities[n].BaseQuantity, i.cost(), i.Quantity*i.cost()); err != nil {
+			return err
+		}
 	}
-	return c.Status(201).JSON(fiber.Map{"status": "success", "data": fiber.Map{"id": orderID, "status": "DRAFT", "subtotal": subtotal, "total": subtotal}})
+	return nil
 }
 
 type receiveRequest struct {
 	RequestKey string `json:"requestKey"`
 	Items      []struct {
-		ProductID   string  `json:"productId"`
-		StockItemID string  `json:"stockItemId"`
-		Quantity    float64 `json:"quantity"`
-		UnitCost    float64 `json:"unitCost"`
-		BatchCode   string  `json:"batchCode"`
+		// PurchaseOrderItemID picks the order line; omitted, the item books against
+		// the first line for its stock item with quantity outstanding.
+		PurchaseOrderItemID string  `json:"purchaseOrderItemId"`
+		ProductID           string  `json:"productId"`
+		StockItemID         string  `json:"stockItemId"`
+		Quantity            float64 `json:"quantity"`
+		UnitID              *string `json:"unitId,omitempty"`
+		UnitCost            float64 `json:"unitCost"`
+		BatchCode           string  `json:"batchCode"`
+		// ExpiryDate (YYYY-MM-DD) is kept on the batch created for batch-tracked items.
+		ExpiryDate *string `json:"expiryDate,omitempty"`
+		// SerialNumbers lists one serial per base unit received for serial-tracked items.
+		SerialNumbers []string `json:"serialNumbers,omitempty"`
+		BinID         *string  `json:"binId,omitempty"`
+		// Weight is the line's gross weight, needed when landed costs go by weight.
+		Weight *float64 `json:"weight,omitempty"`
 	} `json:"items"`
+	// LandedCosts such as freight and duty are allocated over the items so their
+	// stock is costed at supplier cost plus its share.
+	LandedCosts []models.GoodsReceiptCostRequest `json:"landedCosts,omitempty"`
 }
 
 func HandleReceivePurchaseOrder(c *fiber.Ctx) error {
@@ -152,50 +221,164 @@ func HandleReceivePurchaseOrder(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to start receipt"})
 	}
 	defer tx.Rollback(ctx)
-	var shopID string
+	var shopID, supplierID, status string
 	var receiptID string
-	if err = tx.QueryRow(ctx, `SELECT po.shop_id FROM purchase_orders po WHERE po.id=$1 AND po.merchant_id=$2`, c.Params("orderId"), claims.UserID).Scan(&shopID); err != nil {
+	if err = tx.QueryRow(ctx, `SELECT po.shop_id,po.supplier_id,po.status FROM purchase_orders po WHERE po.id=$1 AND po.merchant_id=$2 FOR UPDATE`, c.Params("orderId"), claims.UserID).Scan(&shopID, &supplierID, &status); err != nil {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Purchase order not found"})
 	}
+	if status != "APPROVED" && status != "PARTIALLY_RECEIVED" {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": errPurchaseOrderNotOpen.Error()})
+	}
+	tolerance, err := overReceiptTolerance(ctx, pgxTxAdapter{tx: tx}, claims.UserID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to load purchasing settings"})
+	}
 	if err = tx.QueryRow(ctx, `INSERT INTO goods_receipts(request_key,purchase_order_id,received_by) VALUES($1,$2,$3) RETURNING id`, req.RequestKey, c.Params("orderId"), claims.UserID).Scan(&receiptID); err != nil {
 		return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Receipt already processed or invalid"})
 	}
-	for _, i := range req.Items {
+	// Lines name a stock item of the merchant before anything is resolved against it.
+	productIDs := make([]string, len(req.Items))
+	for idx, i := range req.Items {
 		if i.StockItemID == "" {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "stockItemId is required for goods receipt"})
 		}
-		var productID string
-		if i.StockItemID != "" {
-			if err = tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id=$1 AND merchant_id=$2`, i.StockItemID, claims.UserID).Scan(&productID); err != nil {
-				return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Stock item not found"})
+		err = tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id::text=$1 AND merchant_id=$2`, i.StockItemID, claims.UserID).Scan(&productIDs[idx])
+		if isNoRows(err) {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Stock item not found"})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to load stock item"})
+		}
+	}
+	landedLines := make([]landedCostLine, len(req.Items))
+	landed := make([]float64, len(req.Items))
+	landedShares := make([][]float64, len(req.LandedCosts))
+	if len(req.LandedCosts) > 0 {
+		for idx, i := range req.Items {
+			if i.Quantity <= 0 || (i.Weight != nil && *i.Weight < 0) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Receipt quantity must be positive and weight non-negative"})
+			}
+			qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, i.StockItemID, i.UnitID, i.Quantity)
+			if err != nil {
+				if isUnitError(err) {
+					return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+				}
+				return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve receipt unit"})
+			}
+			landedLines[idx] = landedCostLine{Value: i.UnitCost * i.Quantity, Quantity: qty.BaseQuantity}
+			if i.Weight != nil {
+				landedLines[idx].Weight = *i.Weight
+			}
+		}
+		for n := range req.LandedCosts {
+			if err = validateLandedCost(&req.LandedCosts[n]); err != nil {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			if landedShares[n], err = allocateLandedCost(landedLines, req.LandedCosts[n].Amount, req.LandedCosts[n].AllocationMethod); err != nil {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			for idx, share := range landedShares[n] {
+				landed[idx] += share
 			}
-		} else {
-			productID = i.ProductID
 		}
+	}
+	for idx, i := range req.Items {
+		productID := productIDs[idx]
 		if i.Quantity <= 0 {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Receipt quantity must be positive"})
 		}
-		if _, err = tx.Exec(ctx, `INSERT INTO goods_receipt_items(goods_receipt_id,product_id,stock_item_id,quantity,base_quantity,unit_cost,batch_code) VALUES($1,$2,$3,$4,$4,$5,$6)`, receiptID, productID, nullableString(i.StockItemID), i.Quantity, i.UnitCost, nullableString(i.BatchCode)); err != nil {
+		if err = validateExpiryDate(i.ExpiryDate); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, i.StockItemID, i.UnitID, i.Quantity)
+		if err != nil {
+			if isUnitError(err) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve receipt unit"})
+		}
+		line, err := lockPurchaseOrderLine(ctx, pgxTxAdapter{tx: tx}, c.Params("orderId"), i.PurchaseOrderItemID, i.StockItemID, productID)
+		if err == nil {
+			err = receivePurchaseOrderLine(ctx, pgxTxAdapter{tx: tx}, line, qty.BaseQuantity, tolerance)
+		}
+		if err == errReceiptNotOnOrder || err == errOverReceipt {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to match purchase order line"})
+		}
+		if err = tx.QueryRow(ctx, `INSERT INTO goods_receipt_items(goods_receipt_id,purchase_order_item_id,product_id,stock_item_id,unit_id,quantity,base_quantity,unit_cost,batch_code,expiry_date,weight,landed_cost) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10::date,$11,$12) RETURNING id`, receiptID, line.ID, productID, nullableString(i.StockItemID), qty.UnitID, i.Quantity, qty.BaseQuantity, i.UnitCost, nullableString(i.BatchCode), i.ExpiryDate, i.Weight, roundCost(landed[idx])).Scan(&landedLines[idx].ID); err != nil {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid receipt item"})
 		}
 		var invID string
 		if err = tx.QueryRow(ctx, `SELECT id FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, shopID, i.StockItemID).Scan(&invID); err == nil {
-			_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, i.Quantity, invID)
+			_, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, qty.BaseQuantity, invID)
 		} else {
-			err = tx.QueryRow(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES($1,$2,$3,$4,$5) RETURNING id`, claims.UserID, shopID, productID, i.StockItemID, i.Quantity).Scan(&invID)
+			err = tx.QueryRow(ctx, `INSERT INTO inventory_items(merchant_id,shop_id,product_id,stock_item_id,quantity_on_hand) VALUES($1,$2,$3,$4,$5) RETURNING id`, claims.UserID, shopID, productID, i.StockItemID, qty.BaseQuantity).Scan(&invID)
 		}
 		if err != nil {
 			return c.Status(409).JSON(fiber.Map{"status": "error", "message": "Failed to update inventory"})
 		}
-		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,unit_cost) VALUES($1,$2,$3,$4,$5,'IN',$6,$6,'GOODS_RECEIPT',$7,$8,$9)`, claims.UserID, shopID, invID, productID, nullableString(i.StockItemID), i.Quantity, receiptID, fmt.Sprintf("%s:%s", req.RequestKey, i.StockItemID), i.UnitCost); err != nil {
+		binID, err := resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, invID, i.BinID, true)
+		if err != nil {
+			if err == errStorageBinInvalid {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve receipt bin"})
+		}
+		// Receipt costs are entered per receiving unit; balances are costed per base unit,
+		// landed costs included.
+		baseCost := i.UnitCost/qty.Factor + landed[idx]/qty.BaseQuantity
+		if baseCost, err = receiveInventoryCostLayer(ctx, pgxTxAdapter{tx: tx}, invID, qty.BaseQuantity, &baseCost, landedLines[idx].ID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to cost received stock"})
+		}
+		if err = recordSupplierLastCost(ctx, pgxTxAdapter{tx: tx}, supplierID, i.StockItemID, i.UnitCost/qty.Factor); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update supplier cost"})
+		}
+		batchID, err := receiveInventoryBatch(ctx, pgxTxAdapter{tx: tx}, claims.UserID, shopID, invID, productID, i.StockItemID, i.BatchCode, qty.BaseQuantity, baseCost, i.ExpiryDate)
+		if err != nil {
+			if isBatchReceiptError(err) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record received batch"})
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,unit_cost,bin_id,batch_id) VALUES($1,$2,$3,$4,$5,$6,'IN',$7,$8,'GOODS_RECEIPT',$9,$10,$11,$12,$13)`, claims.UserID, shopID, invID, productID, nullableString(i.StockItemID), qty.UnitID, i.Quantity, qty.BaseQuantity, receiptID, fmt.Sprintf("%s:%d", req.RequestKey, idx), baseCost, binID, nullableString(batchID)); err != nil {
 			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record inventory movement"})
 		}
+		if err = adjustBinBalance(ctx, pgxTxAdapter{tx: tx}, invID, binID, qty.BaseQuantity); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update bin balance"})
+		}
+		if _, err = receiveInventorySerials(ctx, pgxTxAdapter{tx: tx}, i.StockItemID, invID, shopID, receiptID, claims.UserID, qty.BaseQuantity, i.SerialNumbers); err != nil {
+			if isSerialError(err) {
+				return c.Status(serialErrorStatus(err)).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record received serials"})
+		}
+		if err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, invID); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to settle backorders"})
+		}
+	}
+	for n, cost := range req.LandedCosts {
+		var costID string
+		if err = tx.QueryRow(ctx, `INSERT INTO goods_receipt_costs(goods_receipt_id,cost_type,description,amount,allocation_method,created_by) VALUES($1,$2,$3,$4,$5,$6) RETURNING id`, receiptID, cost.CostType, nullableStringValue(cost.Description), cost.Amount, cost.AllocationMethod, claims.UserID).Scan(&costID); err == nil {
+			err = insertLandedCostAllocations(ctx, pgxTxAdapter{tx: tx}, costID, landedLines, landedShares[n])
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record landed costs"})
+		}
+	}
+	var outstanding bool
+	if err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM purchase_order_items WHERE purchase_order_id=$1 AND received_quantity<quantity-0.0005)`, c.Params("orderId")).Scan(&outstanding); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to check outstanding quantities"})
+	}
+	status = purchaseOrderReceiptStatus(!outstanding)
+	if _, err = tx.Exec(ctx, `UPDATE purchase_orders SET status=$1,updated_at=NOW() WHERE id=$2`, status, c.Params("orderId")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update purchase order status"})
 	}
-	_, _ = tx.Exec(ctx, `UPDATE purchase_orders SET status='RECEIVED',updated_at=NOW() WHERE id=$1`, c.Params("orderId"))
 	if err = tx.Commit(ctx); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit receipt"})
 	}
-	return c.JSON(fiber.Map{"status": "success", "data": fiber.Map{"receiptId": receiptID, "status": "RECEIVED"}})
+	return c.JSON(fiber.Map{"status": "success", "data": fiber.Map{"receiptId": receiptID, "status": status}})
 }
 
 func HandleListAccounts(c *fiber.Ctx) error {
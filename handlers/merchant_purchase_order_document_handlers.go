@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"app/outbound"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// loadPurchaseOrderDocument reads what is printed on a merchant's purchase order. The
+// letterhead comes from the purchasing settings, falling back to the merchant account,
+// and lines show the supplier's SKU where its catalog has one. It also returns the
+// supplier's contact email, the default recipient.
+func loadPurchaseOrderDocument(ctx context.Context, db *pgxpool.Pool, orderID, merchantID string) (purchaseOrderDocument, string, error) {
+	var doc purchaseOrderDocument
+	var supplierID, letterAddress, letterPhone, letterEmail, taxID, contact, supplierEmail, supplierPhone, supplierAddress, shopAddress, shopPhone string
+	err := db.QueryRow(ctx, `SELECT po.status,po.created_at,po.subtotal::float8,po.tax::float8,po.total::float8,
+			COALESCE(NULLIF(ps.letterhead_name,''),u.name),COALESCE(ps.letterhead_address,''),COALESCE(NULLIF(ps.letterhead_phone,''),u.phone,''),COALESCE(NULLIF(ps.letterhead_email,''),u.email),COALESCE(ps.letterhead_tax_id,''),
+			s.id,s.name,COALESCE(s.contact_name,''),COALESCE(s.contact_email,''),COALESCE(s.contact_phone,''),COALESCE(s.address,''),
+			sh.name,COALESCE(sh.address,''),COALESCE(sh.phone,'')
+		FROM purchase_orders po JOIN users u ON u.id=po.merchant_id JOIN suppliers s ON s.id=po.supplier_id JOIN shops sh ON sh.id=po.shop_id
+		LEFT JOIN merchant_purchasing_settings ps ON ps.merchant_id=po.merchant_id
+		WHERE po.id::text=$1 AND po.merchant_id=$2`, orderID, merchantID).Scan(&doc.Status, &doc.Date, &doc.Subtotal, &doc.Tax, &doc.Total,
+		&doc.Letterhead.Name, &letterAddress, &letterPhone, &letterEmail, &taxID,
+		&supplierID, &doc.Supplier.Name, &contact, &supplierEmail, &supplierPhone, &supplierAddress,
+		&doc.DeliverTo.Name, &shopAddress, &shopPhone)
+	if err != nil {
+		return doc, "", err
+	}
+	doc.Number = purchaseOrderNumber(orderID)
+	doc.Letterhead.Lines = partyLines(letterAddress, prefixed("Tel: ", letterPhone), letterEmail, prefixed("Tax ID: ", taxID))
+	doc.Supplier.Lines = partyLines(prefixed("Attn: ", contact), supplierAddress, prefixed("Tel: ", supplierPhone), supplierEmail)
+	doc.DeliverTo.Lines = partyLines(shopAddress, prefixed("Tel: ", shopPhone))
+	rows, err := db.Query(ctx, `SELECT COALESCE(si.name,p.name),COALESCE(sp.supplier_sku,''),poi.quantity::float8,COALESCE(ud.symbol,ud.code,''),poi.unit_cost::float8,poi.total_cost::float8
+		FROM purchase_order_items poi JOIN products p ON p.id=poi.product_id
+		LEFT JOIN stock_items si ON si.id=poi.stock_item_id
+		LEFT JOIN unit_definitions ud ON ud.id=poi.unit_id
+		LEFT JOIN supplier_products sp ON sp.supplier_id::text=$2 AND sp.stock_item_id=poi.stock_item_id
+		WHERE poi.purchase_order_id::text=$1 ORDER BY poi.id`, orderID, supplierID)
+	if err != nil {
+		return doc, "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line purchaseOrderDocumentLine
+		if err := rows.Scan(&line.Description, &line.SupplierSKU, &line.Quantity, &line.Unit, &line.UnitCost, &line.TotalCost); err != nil {
+			return doc, "", err
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+	return doc, supplierEmail, rows.Err()
+}
+
+func prefixed(prefix, value string) string {
+	if strings.TrimSpace(value) == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// HandleGetPurchaseOrderPDF renders a purchase order as the PDF sent to the supplier.
+func HandleGetPurchaseOrderPDF(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	doc, _, err := loadPurchaseOrderDocument(context.Background(), database.GetDB(), c.Params("orderId"), merchantID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "purchase order not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load purchase order")
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, `inline; filename="`+doc.Number+`.pdf"`)
+	return c.Send(renderPurchaseOrderPDF(doc))
+}
+
+// HandleSendPurchaseOrder sends the order's PDF to the supplier over the configured
+// outbound channel, by default to the supplier's contact email. Drafts and cancelled
+// orders cannot be sent. Every attempt is recorded on the order, failed ones included.
+func HandleSendPurchaseOrder(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req struct {
+		To      string `json:"to"`
+		Message string `json:"message"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(400, "invalid request body")
+		}
+	}
+	orderID := c.Params("orderId")
+	db, ctx := database.GetDB(), context.Background()
+	doc, supplierEmail, err := loadPurchaseOrderDocument(ctx, db, orderID, merchantID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "purchase order not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load purchase order")
+	}
+	if doc.Status == "DRAFT" || doc.Status == "CANCELLED" {
+		return fiber.NewError(409, "only approved purchase orders can be sent")
+	}
+	to := strings.TrimSpace(req.To)
+	if to == "" {
+		to = strings.TrimSpace(supplierEmail)
+	}
+	if to == "" {
+		return fiber.NewError(400, "supplier has no contact email; provide to")
+	}
+	if !outbound.ValidAddress(to) {
+		return fiber.NewError(400, "to must be a valid email address")
+	}
+	pdf := renderPurchaseOrderPDF(doc)
+	sum := sha256.Sum256(pdf)
+	body := strings.TrimSpace(req.Message)
+	if body == "" {
+		body = "Please find attached purchase order " + doc.Number + ".\n\nDeliver to: " + strings.Join(append([]string{doc.DeliverTo.Name}, doc.DeliverTo.Lines...), ", ")
+	}
+	var replyTo string
+	for _, line := range doc.Letterhead.Lines {
+		if outbound.ValidAddress(line) {
+			replyTo = line
+			break
+		}
+	}
+
+	send := models.PurchaseOrderSend{PurchaseOrderID: orderID, Recipient: to, Status: "SENT", DocumentSHA256: hex.EncodeToString(sum[:]), SentBy: &merchantID}
+	channel, err := outbound.NewFromEnv()
+	if err == nil {
+		send.Channel = channel.Name()
+		var receipt outbound.Receipt
+		receipt, err = channel.Send(ctx, outbound.Message{
+			To: []string{to}, ReplyTo: replyTo,
+			Subject:     "Purchase order " + doc.Number + " from " + doc.Letterhead.Name,
+			Body:        body,
+			Attachments: []outbound.Attachment{{Filename: doc.Number + ".pdf", ContentType: "application/pdf", Data: pdf}},
+		})
+		send.Reference = nullableString(receipt.Reference)
+	} else {
+		send.Channel = strings.ToUpper(outbound.LoadConfig().Channel)
+	}
+	if err != nil {
+		send.Status = "FAILED"
+		send.Error = nullableString(err.Error())
+	}
+
+	tx, txErr := db.Begin(ctx)
+	if txErr != nil {
+		return fiber.NewError(500, "failed to start purchase order send record")
+	}
+	defer tx.Rollback(ctx)
+	if txErr = tx.QueryRow(ctx, `INSERT INTO purchase_order_sends(purchase_order_id,channel,recipient,status,reference,error,document_sha256,sent_by) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING id,sent_at`,
+		orderID, send.Channel, send.Recipient, send.Status, send.Reference, send.Error, send.DocumentSHA256, merchantID).Scan(&send.ID, &send.SentAt); txErr != nil {
+		return fiber.NewError(500, "failed to record purchase order send")
+	}
+	if send.Status == "SENT" {
+		if _, txErr = tx.Exec(ctx, `UPDATE purchase_orders SET last_sent_at=$1,updated_at=NOW() WHERE id=$2`, send.SentAt, orderID); txErr != nil {
+			return fiber.NewError(500, "failed to update purchase order")
+		}
+	}
+	if txErr = tx.Commit(ctx); txErr != nil {
+		return fiber.NewError(500, "failed to commit purchase order send record")
+	}
+	if err != nil {
+		// The delivery error stays on the send record; it can name the relay and its replies.
+		return c.Status(502).JSON(fiber.Map{"status": "error", "message": "failed to send purchase order", "sendId": send.ID})
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": send})
+}
+
+// HandleListPurchaseOrderSends lists every attempt to send an order, newest first.
+func HandleListPurchaseOrderSends(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var exists bool
+	if err = db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM purchase_orders WHERE id::text=$1 AND merchant_id=$2)`, c.Params("orderId"), merchantID).Scan(&exists); err != nil {
+		return fiber.NewError(500, "failed to load purchase order")
+	}
+	if !exists {
+		return fiber.NewError(404, "purchase order not found")
+	}
+	rows, err := db.Query(ctx, `SELECT id,purchase_order_id,channel,recipient,status,reference,error,document_sha256,sent_by,sent_at FROM purchase_order_sends WHERE purchase_order_id::text=$1 ORDER BY sent_at DESC,id`, c.Params("orderId"))
+	if err != nil {
+		return fiber.NewError(500, "failed to list purchase order sends")
+	}
+	defer rows.Close()
+	items := make([]models.PurchaseOrderSend, 0)
+	for rows.Next() {
+		var item models.PurchaseOrderSend
+		if err := rows.Scan(&item.ID, &item.PurchaseOrderID, &item.Channel, &item.Recipient, &item.Status, &item.Reference, &item.Error, &item.DocumentSHA256, &item.SentBy, &item.SentAt); err != nil {
+			return fiber.NewError(500, "failed to read purchase order send")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
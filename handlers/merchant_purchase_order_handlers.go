@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"app/database"
+	"app/middleware"
+	"app/outbound"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleGetPurchaseOrder returns an order with its lines and what has been received on each.
+func HandleGetPurchaseOrder(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var shopID, supplierID, status string
+	var subtotal, tax, total float64
+	var approvedBy, cancelReason *string
+	var approvedAt, cancelledAt, lastSentAt *time.Time
+	var created, updated time.Time
+	if err = db.QueryRow(ctx, `SELECT shop_id,supplier_id,status,subtotal,tax,total,approved_by,approved_at,cancelled_at,cancel_reason,last_sent_at,created_at,updated_at FROM purchase_orders WHERE id=$1 AND merchant_id=$2`, c.Params("orderId"), claims.UserID).Scan(&shopID, &supplierID, &status, &subtotal, &tax, &total, &approvedBy, &approvedAt, &cancelledAt, &cancelReason, &lastSentAt, &created, &updated); err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Purchase order not found"})
+	}
+	rows, err := db.Query(ctx, `SELECT id,product_id,stock_item_id,unit_id,quantity::float8,base_quantity::float8,received_quantity::float8,unit_cost,total_cost FROM purchase_order_items WHERE purchase_order_id=$1 ORDER BY id`, c.Params("orderId"))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to load purchase order items"})
+	}
+	defer rows.Close()
+	items := make([]fiber.Map, 0)
+	for rows.Next() {
+		var id, productID string
+		var stockItemID, unitID *string
+		var quantity, received, unitCost, totalCost float64
+		var baseQuantity *float64
+		if err = rows.Scan(&id, &productID, &stockItemID, &unitID, &quantity, &baseQuantity, &received, &unitCost, &totalCost); err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to read purchase order item"})
+		}
+		outstanding := quantity - received
+		if outstanding < 0 {
+			outstanding = 0
+		}
+		items = append(items, fiber.Map{"id": id, "productId": productID, "stockItemId": stockItemID, "unitId": unitID, "quantity": quantity, "baseQuantity": baseQuantity, "receivedQuantity": received, "outstandingQuantity": outstanding, "unitCost": unitCost, "totalCost": totalCost})
+	}
+	return c.JSON(fiber.Map{"status": "success", "data": fiber.Map{"id": c.Params("orderId"), "shopId": shopID, "supplierId": supplierID, "status": status, "subtotal": subtotal, "tax": tax, "total": total, "approvedBy": approvedBy, "approvedAt": approvedAt, "cancelledAt": cancelledAt, "cancelReason": cancelReason, "lastSentAt": lastSentAt, "createdAt": created, "updatedAt": updated, "items": items}})
+}
+
+// HandleUpdatePurchaseOrder replaces the supplier and lines of a draft order.
+func HandleUpdatePurchaseOrder(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req purchaseOrderRequest
+	if err = c.BodyParser(&req); err != nil || req.SupplierID == "" || len(req.Items) == 0 {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "supplierId and items are required"})
+	}
+	db, ctx := database.GetDB(), context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to start purchase order update"})
+	}
+	defer tx.Rollback(ctx)
+	var status string
+	if err = tx.QueryRow(ctx, `SELECT status FROM purchase_orders WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, c.Params("orderId"), claims.UserID).Scan(&status); err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Purchase order not found"})
+	}
+	if status != "DRAFT" {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": errPurchaseOrderNotDraft.Error()})
+	}
+	var ok int
+	if err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM suppliers WHERE id=$1 AND merchant_id=$2`, req.SupplierID, claims.UserID).Scan(&ok); err != nil || ok == 0 {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Supplier not found"})
+	}
+	quantities, subtotal, ferr := resolvePurchaseItems(ctx, tx, claims.UserID, req.SupplierID, req.Items)
+	if ferr != nil {
+		return c.Status(ferr.Code).JSON(fiber.Map{"status": "error", "message": ferr.Message})
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM purchase_order_items WHERE purchase_order_id=$1`, c.Params("orderId")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to replace purchase order items"})
+	}
+	if err = insertPurchaseOrderItems(ctx, tx, c.Params("orderId"), req.Items, quantities); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid purchase item reference"})
+	}
+	if _, err = tx.Exec(ctx, `UPDATE purchase_orders SET supplier_id=$1,subtotal=$2,total=$2+tax,updated_at=NOW() WHERE id=$3`, req.SupplierID, subtotal, c.Params("orderId")); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update purchase order"})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit purchase order"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "data": fiber.Map{"id": c.Params("orderId"), "status": status, "subtotal": subtotal}})
+}
+
+// HandleApprovePurchaseOrder releases a draft order for receiving.
+func HandleApprovePurchaseOrder(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	return transitionPurchaseOrder(c, claims.UserID, "APPROVED", `approved_by=$3,approved_at=NOW()`, claims.UserID)
+}
+
+// HandleCancelPurchaseOrder cancels an order that is not yet fully received. Stock
+// already received on a partially received order stays where it is.
+func HandleCancelPurchaseOrder(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err = c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
+		}
+	}
+	return transitionPurchaseOrder(c, claims.UserID, "CANCELLED", `cancelled_at=NOW(),cancel_reason=$3`, nullableString(strings.TrimSpace(req.Reason)))
+}
+
+// transitionPurchaseOrder moves an order to status, setting the extra columns in set,
+// whose placeholders start at $3.
+func transitionPurchaseOrder(c *fiber.Ctx, merchantID, status, set string, args ...interface{}) error {
+	db, ctx := database.GetDB(), context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to start purchase order update"})
+	}
+	defer tx.Rollback(ctx)
+	var current string
+	if err = tx.QueryRow(ctx, `SELECT status FROM purchase_orders WHERE id=$1 AND merchant_id=$2 FOR UPDATE`, c.Params("orderId"), merchantID).Scan(&current); err != nil {
+		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Purchase order not found"})
+	}
+	if err = validatePurchaseOrderTransition(current, status); err != nil {
+		return c.Status(409).JSON(fiber.Map{"status": "error", "message": err.Error()})
+	}
+	if _, err = tx.Exec(ctx, `UPDATE purchase_orders SET status=$1,`+set+`,updated_at=NOW() WHERE id=$2`, append([]interface{}{status, c.Params("orderId")}, args...)...); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update purchase order"})
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit purchase order"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "data": fiber.Map{"id": c.Params("orderId"), "status": status}})
+}
+
+// purchasingSettings are the merchant's receiving rules and purchase order letterhead.
+// Letterhead fields left empty fall back to the merchant account on the document.
+type purchasingSettings struct {
+	OverReceiptTolerancePercent *float64 `json:"overReceiptTolerancePercent"`
+	LetterheadName              *string  `json:"letterheadName"`
+	LetterheadAddress           *string  `json:"letterheadAddress"`
+	LetterheadPhone             *string  `json:"letterheadPhone"`
+	LetterheadEmail             *string  `json:"letterheadEmail"`
+	LetterheadTaxID             *string  `json:"letterheadTaxId"`
+}
+
+func loadPurchasingSettings(ctx context.Context, merchantID string) (purchasingSettings, error) {
+	var settings purchasingSettings
+	var tolerance float64
+	err := database.GetDB().QueryRow(ctx, `SELECT COALESCE(ps.over_receipt_tolerance_percent::float8,0),ps.letterhead_name,ps.letterhead_address,ps.letterhead_phone,ps.letterhead_email,ps.letterhead_tax_id
+		FROM (SELECT $1::uuid AS merchant_id) m LEFT JOIN merchant_purchasing_settings ps ON ps.merchant_id=m.merchant_id`, merchantID).Scan(&tolerance, &settings.LetterheadName, &settings.LetterheadAddress, &settings.LetterheadPhone, &settings.LetterheadEmail, &settings.LetterheadTaxID)
+	settings.OverReceiptTolerancePercent = &tolerance
+	return settings, err
+}
+
+// HandleGetPurchasingSettings returns the merchant's receiving settings and letterhead.
+func HandleGetPurchasingSettings(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	settings, err := loadPurchasingSettings(context.Background(), claims.UserID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to load purchasing settings"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "data": settings})
+}
+
+// HandleUpdatePurchasingSettings sets how far receipts may exceed ordered quantities and
+// the letterhead printed on purchase orders. Fields left out keep their value and an
+// empty letterhead field clears it.
+func HandleUpdatePurchasingSettings(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req purchasingSettings
+	if err = c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
+	}
+	if req.OverReceiptTolerancePercent != nil && (*req.OverReceiptTolerancePercent < 0 || *req.OverReceiptTolerancePercent > 100) {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "overReceiptTolerancePercent must be between 0 and 100"})
+	}
+	if req.LetterheadEmail != nil && strings.TrimSpace(*req.LetterheadEmail) != "" && !outbound.ValidAddress(*req.LetterheadEmail) {
+		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "letterheadEmail must be a valid email address"})
+	}
+	// Present fields are trimmed to a value or to "" (clear); absent ones stay nil (keep).
+	letterhead := []*string{req.LetterheadName, req.LetterheadAddress, req.LetterheadPhone, req.LetterheadEmail, req.LetterheadTaxID}
+	args := []interface{}{claims.UserID, req.OverReceiptTolerancePercent}
+	for _, field := range letterhead {
+		if field == nil {
+			args = append(args, nil)
+			continue
+		}
+		args = append(args, strings.TrimSpace(*field))
+	}
+	ctx := context.Background()
+	if _, err = database.GetDB().Exec(ctx, `INSERT INTO merchant_purchasing_settings(merchant_id,over_receipt_tolerance_percent,letterhead_name,letterhead_address,letterhead_phone,letterhead_email,letterhead_tax_id)
+		VALUES($1,COALESCE($2,0),NULLIF($3,''),NULLIF($4,''),NULLIF($5,''),NULLIF($6,''),NULLIF($7,''))
+		ON CONFLICT (merchant_id) DO UPDATE SET
+			over_receipt_tolerance_percent=COALESCE($2,merchant_purchasing_settings.over_receipt_tolerance_percent),
+			letterhead_name=CASE WHEN $3::text IS NULL THEN merchant_purchasing_settings.letterhead_name ELSE NULLIF($3,'') END,
+			letterhead_address=CASE WHEN $4::text IS NULL THEN merchant_purchasing_settings.letterhead_address ELSE NULLIF($4,'') END,
+			letterhead_phone=CASE WHEN $5::text IS NULL THEN merchant_purchasing_settings.letterhead_phone ELSE NULLIF($5,'') END,
+			letterhead_email=CASE WHEN $6::text IS NULL THEN merchant_purchasing_settings.letterhead_email ELSE NULLIF($6,'') END,
+			letterhead_tax_id=CASE WHEN $7::text IS NULL THEN merchant_purchasing_settings.letterhead_tax_id ELSE NULLIF($7,'') END,
+			updated_at=NOW()`, args...); err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update purchasing settings"})
+	}
+	settings, err := loadPurchasingSettings(ctx, claims.UserID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to load purchasing settings"})
+	}
+	return c.JSON(fiber.Map{"status": "success", "data": settings})
+}
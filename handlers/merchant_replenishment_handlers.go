@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+// HandleListReplenishmentRequests lists the stock requests raised by the merchant's shops.
+func HandleListReplenishmentRequests(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	return listReplenishmentRequests(c, " WHERE r.merchant_id=$1", merchantID)
+}
+
+// HandleGetReplenishmentRequest returns a request with its lines and the shop's stock of each.
+func HandleGetReplenishmentRequest(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	requestID := c.Params("requestId")
+	item, err := loadReplenishmentRequest(ctx, db, db.QueryRow(ctx, "SELECT "+replenishmentRequestColumns+replenishmentRequestFrom+" WHERE r.id::text=$1 AND r.merchant_id=$2", requestID, merchantID), requestID)
+	if isNoRows(err) {
+		return fiber.NewError(404, "replenishment request not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load replenishment request")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleRejectReplenishmentRequest declines a submitted request, telling the requester why.
+func HandleRejectReplenishmentRequest(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(400, "invalid request body")
+		}
+	}
+	reason := strings.TrimSpace(req.Reason)
+	outcome := "was rejected."
+	if reason != "" {
+		outcome = "was rejected: " + reason
+	}
+	return reviewReplenishmentRequest(c, merchantID, "REJECTED", nil, func(ctx context.Context, tx pgx.Tx, shopID string, lines []replenishmentLine) (fiber.Map, string, *fiber.Error) {
+		if _, err := tx.Exec(ctx, `UPDATE replenishment_requests SET status='REJECTED',review_note=$1,reviewed_by=$2,reviewed_at=NOW(),updated_at=NOW() WHERE id::text=$3`, nullableString(reason), merchantID, c.Params("requestId")); err != nil {
+			return nil, "", fiber.NewError(500, "failed to reject replenishment request")
+		}
+		return fiber.Map{"id": c.Params("requestId"), "status": "REJECTED"}, outcome, nil
+	})
+}
+
+// HandleOrderReplenishmentRequest fills a request with a draft purchase order from the
+// supplier for the requesting shop, priced from the supplier's catalog.
+func HandleOrderReplenishmentRequest(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.FulfillReplenishmentRequest
+	if err := c.BodyParser(&req); err != nil || req.ClientOperationID == "" || strings.TrimSpace(req.SupplierID) == "" {
+		return fiber.NewError(400, "clientOperationId and supplierId are required")
+	}
+	return reviewReplenishmentRequest(c, merchantID, "ORDERED", &req, func(ctx context.Context, tx pgx.Tx, shopID string, lines []replenishmentLine) (fiber.Map, string, *fiber.Error) {
+		claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "replenishment_purchase_order", merchantID, &shopID)
+		if err != nil {
+			return nil, "", fiber.NewError(500, "failed to start purchase operation")
+		}
+		if !claimed {
+			return nil, "", fiber.NewError(409, "operation already processed")
+		}
+		items := make([]purchaseItemRequest, 0, len(lines))
+		for _, line := range lines {
+			if line.Approved > 0 {
+				items = append(items, purchaseItemRequest{ProductID: line.ProductID, StockItemID: line.StockItemID, Quantity: line.Approved})
+			}
+		}
+		orderID, subtotal, ferr := createPurchaseOrder(ctx, tx, merchantID, shopID, strings.TrimSpace(req.SupplierID), items)
+		if ferr != nil {
+			return nil, "", ferr
+		}
+		if _, err = tx.Exec(ctx, `UPDATE replenishment_requests SET status='ORDERED',purchase_order_id=$1,review_note=$2,reviewed_by=$3,reviewed_at=NOW(),updated_at=NOW() WHERE id::text=$4`, orderID, nullableString(trimmedString(req.Note)), merchantID, c.Params("requestId")); err != nil {
+			return nil, "", fiber.NewError(500, "failed to update replenishment request")
+		}
+		return fiber.Map{"id": c.Params("requestId"), "status": "ORDERED", "purchaseOrderId": orderID, "subtotal": subtotal},
+			"was ordered from the supplier on purchase order " + purchaseOrderNumber(orderID) + ".", nil
+	})
+}
+
+// HandleTransferReplenishmentRequest fills a request by moving the stock from another of
+// the merchant's shops, line by line, as HandleMoveStock does. Items pick the batch
+// and serials each line moves.
+func HandleTransferReplenishmentRequest(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.FulfillReplenishmentRequest
+	if err := c.BodyParser(&req); err != nil || req.ClientOperationID == "" || strings.TrimSpace(req.FromShopID) == "" {
+		return fiber.NewError(400, "clientOperationId and fromShopId are required")
+	}
+	fromShopID := strings.TrimSpace(req.FromShopID)
+	return reviewReplenishmentRequest(c, merchantID, "TRANSFERRED", &req, func(ctx context.Context, tx pgx.Tx, shopID string, lines []replenishmentLine) (fiber.Map, string, *fiber.Error) {
+		if fromShopID == shopID {
+			return nil, "", fiber.NewError(400, "fromShopId must be another shop")
+		}
+		var fromName string
+		if err := tx.QueryRow(ctx, `SELECT name FROM shops WHERE id::text=$1 AND merchant_id=$2`, fromShopID, merchantID).Scan(&fromName); err != nil {
+			return nil, "", fiber.NewError(404, "source shop not found")
+		}
+		moves := make([]fiber.Map, 0, len(lines))
+		for _, line := range lines {
+			if line.Approved <= 0 {
+				continue
+			}
+			operationID := req.ClientOperationID + ":" + line.StockItemID
+			claimed, err := claimInventoryOperation(ctx, tx, operationID, "replenishment_transfer", merchantID, &fromShopID)
+			if err != nil {
+				return nil, "", fiber.NewError(500, "failed to start transfer")
+			}
+			if !claimed {
+				return nil, "", fiber.NewError(409, "operation already processed")
+			}
+			result, ferr := moveStock(ctx, tx, merchantID, MoveStockRequest{ClientOperationID: operationID, ItemID: line.StockItemID, FromShopID: fromShopID, ToShopID: shopID, BatchID: line.BatchID, SerialNumbers: line.SerialNumbers}, line.Approved)
+			if ferr != nil {
+				return nil, "", ferr
+			}
+			result["stockItemId"] = line.StockItemID
+			result["quantity"] = line.Approved
+			moves = append(moves, result)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE replenishment_requests SET status='TRANSFERRED',source_shop_id=$1,review_note=$2,reviewed_by=$3,reviewed_at=NOW(),updated_at=NOW() WHERE id::text=$4`, fromShopID, nullableString(trimmedString(req.Note)), merchantID, c.Params("requestId")); err != nil {
+			return nil, "", fiber.NewError(500, "failed to update replenishment request")
+		}
+		return fiber.Map{"id": c.Params("requestId"), "status": "TRANSFERRED", "sourceShopId": fromShopID, "transfers": moves},
+			"was filled with stock moved from " + fromName + ".", nil
+	})
+}
+
+// reviewReplenishmentRequest locks a submitted request, settles its approved quantities
+// from fill, lets review move it to status and notifies everyone involved with the
+// outcome review describes.
+func reviewReplenishmentRequest(c *fiber.Ctx, merchantID, status string, fill *models.FulfillReplenishmentRequest, review func(context.Context, pgx.Tx, string, []replenishmentLine) (fiber.Map, string, *fiber.Error)) error {
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start replenishment request review")
+	}
+	defer tx.Rollback(ctx)
+	requestID := c.Params("requestId")
+	shopID, shopName, lines, err := lockReplenishmentRequest(ctx, tx, requestID, merchantID)
+	if isNoRows(err) {
+		return fiber.NewError(404, "replenishment request not found")
+	}
+	if err == errReplenishmentClosed {
+		return fiber.NewError(409, err.Error())
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load replenishment request")
+	}
+	if fill != nil {
+		if err = approveReplenishmentLines(lines, fill.Items); err != nil {
+			return fiber.NewError(400, err.Error())
+		}
+		for _, line := range lines {
+			if _, err = tx.Exec(ctx, `UPDATE replenishment_request_items SET approved_quantity=$1 WHERE id=$2`, line.Approved, line.ItemID); err != nil {
+				return fiber.NewError(500, "failed to save approved quantities")
+			}
+		}
+	}
+	data, outcome, ferr := review(ctx, tx, shopID, lines)
+	if ferr != nil {
+		return ferr
+	}
+	if err = notifyReplenishmentParties(ctx, pgxTxAdapter{tx: tx}, requestID, merchantID, "Stock request "+strings.ToLower(status), "The stock request from "+shopName+" "+outcome); err != nil {
+		return fiber.NewError(500, "failed to send notifications")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit replenishment request review")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": data})
+}
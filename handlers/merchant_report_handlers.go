@@ -162,7 +162,7 @@ func HandleGetSalesReport(c *fiber.Ctx) error {
 				item.InventoryItemID = itemInventoryID.String
 			}
 			if itemQuantity.Valid {
-				item.QuantitySold = int(itemQuantity.Float64)
+				item.QuantitySold = itemQuantity.Float64
 			}
 			if itemSellingPrice.Valid {
 				item.SellingPriceAtSale = itemSellingPrice.Float64
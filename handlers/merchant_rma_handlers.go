@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"app/storage"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var rmaCaseSortFields = map[string]string{"createdAt": "created_at", "updatedAt": "updated_at", "status": "status"}
+
+const rmaCaseColumns = `r.id,r.merchant_id,r.shop_id,r.stock_item_id,si.name,r.serial_id,s.serial_number,r.asset_id,a.asset_tag,r.sale_id,r.customer_id,sc.name,
+	r.warranty_id,w.ends_on,r.under_warranty,r.supplier_id,sp.name,r.supplier_reference,r.status,r.fault_description,
+	r.replacement_serial_id,rs.serial_number,r.replacement_asset_id,ra.asset_tag,r.opened_by,r.created_at,r.updated_at,r.closed_at`
+
+const rmaCaseFrom = ` FROM rma_cases r
+	LEFT JOIN stock_items si ON si.id=r.stock_item_id
+	LEFT JOIN inventory_serials s ON s.id=r.serial_id
+	LEFT JOIN inventory_assets a ON a.id=r.asset_id
+	LEFT JOIN shop_customers sc ON sc.id=r.customer_id
+	LEFT JOIN inventory_warranties w ON w.id=r.warranty_id
+	LEFT JOIN suppliers sp ON sp.id=r.supplier_id
+	LEFT JOIN inventory_serials rs ON rs.id=r.replacement_serial_id
+	LEFT JOIN inventory_assets ra ON ra.id=r.replacement_asset_id`
+
+func scanRMACase(scan func(...interface{}) error) (models.RMACase, error) {
+	var item models.RMACase
+	err := scan(&item.ID, &item.MerchantID, &item.ShopID, &item.StockItemID, &item.ItemName, &item.SerialID, &item.SerialNumber, &item.AssetID, &item.AssetTag, &item.SaleID, &item.CustomerID, &item.CustomerName,
+		&item.WarrantyID, &item.WarrantyEndsOn, &item.UnderWarranty, &item.SupplierID, &item.SupplierName, &item.SupplierReference, &item.Status, &item.FaultDescription,
+		&item.ReplacementSerialID, &item.ReplacementSerialNumber, &item.ReplacementAssetID, &item.ReplacementAssetTag, &item.OpenedBy, &item.CreatedAt, &item.UpdatedAt, &item.ClosedAt)
+	return item, err
+}
+
+// loadRMACase returns a case with its notes and attachments.
+func loadRMACase(ctx context.Context, q pgx.Tx, caseID string) (models.RMACase, error) {
+	item, err := scanRMACase(q.QueryRow(ctx, `SELECT `+rmaCaseColumns+rmaCaseFrom+` WHERE r.id=$1`, caseID).Scan)
+	if err != nil {
+		return item, err
+	}
+	rows, err := q.Query(ctx, `SELECT id,from_status,to_status,note,author_id,created_at FROM rma_case_notes WHERE rma_case_id=$1 ORDER BY created_at,id`, caseID)
+	if err != nil {
+		return item, err
+	}
+	item.Notes = make([]models.RMACaseNote, 0)
+	for rows.Next() {
+		var note models.RMACaseNote
+		if err := rows.Scan(&note.ID, &note.FromStatus, &note.ToStatus, &note.Note, &note.AuthorID, &note.CreatedAt); err != nil {
+			rows.Close()
+			return item, err
+		}
+		item.Notes = append(item.Notes, note)
+	}
+	rows.Close()
+	rows, err = q.Query(ctx, `SELECT id,filename,content_type,size_bytes,url,storage_provider,uploaded_by,created_at FROM rma_case_attachments WHERE rma_case_id=$1 ORDER BY created_at,id`, caseID)
+	if err != nil {
+		return item, err
+	}
+	defer rows.Close()
+	item.Attachments = make([]models.RMACaseAttachment, 0)
+	for rows.Next() {
+		var attachment models.RMACaseAttachment
+		if err := rows.Scan(&attachment.ID, &attachment.Filename, &attachment.ContentType, &attachment.SizeBytes, &attachment.URL, &attachment.StorageProvider, &attachment.UploadedBy, &attachment.CreatedAt); err != nil {
+			return item, err
+		}
+		item.Attachments = append(item.Attachments, attachment)
+	}
+	return item, rows.Err()
+}
+
+func rmaErrorResponse(err error, fallback string) error {
+	if err == errRMACaseNotFound {
+		return fiber.NewError(404, err.Error())
+	}
+	if err == errRMAStatusInvalid || err == errRMASupplierRequired || err == errRMAReplacementMissing {
+		return fiber.NewError(400, err.Error())
+	}
+	if isRMAError(err) {
+		return fiber.NewError(409, err.Error())
+	}
+	return fiber.NewError(500, fallback)
+}
+
+// merchantOwns reports whether id names a row of table owned by the merchant.
+func merchantOwns(ctx context.Context, tx pgx.Tx, table, id, merchantID string) (bool, error) {
+	var ok bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM `+table+` WHERE id::text=$1 AND merchant_id=$2)`, id, merchantID).Scan(&ok)
+	return ok, err
+}
+
+// HandleCreateRMACase opens a warranty return for a faulty serial or asset. The case is
+// linked to the unit's warranty, and through it to the original sale and customer.
+func HandleCreateRMACase(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.RMACaseRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.FaultDescription) == "" {
+		return fiber.NewError(400, "faultDescription is required")
+	}
+	serialNumber := trimmedString(req.SerialNumber)
+	assetRef := trimmedString(req.AssetID)
+	if (serialNumber == "") == (assetRef == "") {
+		return fiber.NewError(400, "exactly one of serialNumber or assetId is required")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	defer tx.Rollback(ctx)
+	var unitID, shopID string
+	var stockItemID, saleID *string
+	if serialNumber != "" {
+		err = tx.QueryRow(ctx, `SELECT s.id,s.shop_id,s.stock_item_id,(SELECT sa.id FROM sales sa WHERE sa.id=s.reference_id AND sa.merchant_id=s.merchant_id AND s.status='SOLD')
+			FROM inventory_serials s WHERE s.merchant_id=$1 AND s.serial_number=$2`, merchantID, serialNumber).Scan(&unitID, &shopID, &stockItemID, &saleID)
+	} else {
+		err = tx.QueryRow(ctx, `SELECT a.id,a.shop_id,ii.stock_item_id,NULL::uuid FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id
+			WHERE (a.id::text=$1 OR a.asset_tag=$1) AND a.merchant_id=$2 LIMIT 1`, assetRef, merchantID).Scan(&unitID, &shopID, &stockItemID, &saleID)
+	}
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "serial or asset not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	unitColumn := "serial_id"
+	if assetRef != "" {
+		unitColumn = "asset_id"
+	}
+	var warrantyID, warrantySaleID, customerID, supplierID *string
+	var underWarranty bool
+	err = tx.QueryRow(ctx, `SELECT id,sale_id,customer_id,status='ACTIVE' AND CURRENT_DATE BETWEEN starts_on AND ends_on FROM inventory_warranties
+		WHERE `+unitColumn+`=$1 ORDER BY status='ACTIVE' DESC,created_at DESC LIMIT 1`, unitID).Scan(&warrantyID, &warrantySaleID, &customerID, &underWarranty)
+	if err != nil && err != pgx.ErrNoRows {
+		return fiber.NewError(500, "failed to look up warranty")
+	}
+	if saleID == nil {
+		saleID = warrantySaleID
+	}
+	for _, ref := range []struct {
+		value  *string
+		target **string
+		table  string
+		field  string
+	}{{req.SaleID, &saleID, "sales", "saleId"}, {req.CustomerID, &customerID, "shop_customers", "customerId"}, {req.SupplierID, &supplierID, "suppliers", "supplierId"}} {
+		if ref.value == nil || strings.TrimSpace(*ref.value) == "" {
+			continue
+		}
+		id := strings.TrimSpace(*ref.value)
+		ok, err := merchantOwns(ctx, tx, ref.table, id, merchantID)
+		if err != nil {
+			return fiber.NewError(500, "failed to open RMA case")
+		}
+		if !ok {
+			return fiber.NewError(400, ref.field+" is invalid")
+		}
+		*ref.target = &id
+	}
+	caseID := generateUUID()
+	_, err = tx.Exec(ctx, `INSERT INTO rma_cases(id,merchant_id,shop_id,stock_item_id,`+unitColumn+`,sale_id,customer_id,warranty_id,under_warranty,supplier_id,fault_description,opened_by) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)`,
+		caseID, merchantID, shopID, stockItemID, unitID, saleID, customerID, warrantyID, underWarranty, supplierID, strings.TrimSpace(req.FaultDescription), merchantID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fiber.NewError(409, "this unit already has an open RMA case")
+		}
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	ref := rmaCaseRef{ID: caseID, MerchantID: merchantID, ShopID: shopID}
+	if assetRef != "" {
+		ref.AssetID = &unitID
+	} else {
+		ref.SerialID = &unitID
+	}
+	err = addRMANote(ctx, pgxTxAdapter{tx: tx}, caseID, "", "OPEN", "Case opened", merchantID)
+	if err == nil {
+		err = recordRMAUnitEvent(ctx, pgxTxAdapter{tx: tx}, ref, merchantID, "RMA case opened")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	item, err := loadRMACase(ctx, tx, caseID)
+	if err != nil {
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to open RMA case")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleListRMACases lists cases, filtered by status, shop, supplier or customer and
+// searchable by serial number, asset tag or supplier reference.
+func HandleListRMACases(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "createdAt", rmaCaseSortFields)
+	where := " WHERE r.merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, pair := range []struct{ key, col string }{{"shopId", "r.shop_id"}, {"supplierId", "r.supplier_id"}, {"customerId", "r.customer_id"}} {
+		if v := strings.TrimSpace(c.Query(pair.key)); v != "" {
+			where += " AND " + pair.col + "=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	if status := strings.ToUpper(strings.TrimSpace(c.Query("status"))); status != "" {
+		where += " AND r.status=$" + itoa(len(args)+1)
+		args = append(args, status)
+	}
+	if q.Search != "" {
+		n := itoa(len(args) + 1)
+		where += " AND (s.serial_number ILIKE $" + n + " OR a.asset_tag ILIKE $" + n + " OR r.supplier_reference ILIKE $" + n + ")"
+		args = append(args, "%"+q.Search+"%")
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*)"+rmaCaseFrom+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count RMA cases")
+	}
+	rows, err := db.Query(ctx, "SELECT * FROM (SELECT "+rmaCaseColumns+rmaCaseFrom+where+") r"+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list RMA cases")
+	}
+	defer rows.Close()
+	items := make([]models.RMACase, 0)
+	for rows.Next() {
+		item, err := scanRMACase(rows.Scan)
+		if err != nil {
+			return fiber.NewError(500, "failed to read RMA case")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleGetRMACase(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to load RMA case")
+	}
+	defer tx.Rollback(ctx)
+	ok, err := merchantOwns(ctx, tx, "rma_cases", c.Params("caseId"), merchantID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load RMA case")
+	}
+	if !ok {
+		return fiber.NewError(404, errRMACaseNotFound.Error())
+	}
+	item, err := loadRMACase(ctx, tx, c.Params("caseId"))
+	if err != nil {
+		return fiber.NewError(500, "failed to load RMA case")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleUpdateRMACaseStatus moves a case along its workflow. Sending a case to the
+// supplier needs a supplier, given here or when the case was opened.
+func HandleUpdateRMACaseStatus(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.RMACaseStatusRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Status) == "" {
+		return fiber.NewError(400, "status is required")
+	}
+	to := strings.ToUpper(strings.TrimSpace(req.Status))
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to update RMA case")
+	}
+	defer tx.Rollback(ctx)
+	ref, err := lockRMACase(ctx, pgxTxAdapter{tx: tx}, c.Params("caseId"), merchantID)
+	if err == nil {
+		err = validateRMATransition(ref.Status, to, false)
+	}
+	if err != nil {
+		return rmaErrorResponse(err, "failed to update RMA case")
+	}
+	if supplierID := trimmedString(req.SupplierID); supplierID != "" {
+		ok, err := merchantOwns(ctx, tx, "suppliers", supplierID, merchantID)
+		if err != nil {
+			return fiber.NewError(500, "failed to update RMA case")
+		}
+		if !ok {
+			return fiber.NewError(400, "supplierId is invalid")
+		}
+		ref.SupplierID = &supplierID
+	}
+	if to == "SENT_TO_SUPPLIER" && ref.SupplierID == nil {
+		return rmaErrorResponse(errRMASupplierRequired, "failed to update RMA case")
+	}
+	if _, err := tx.Exec(ctx, `UPDATE rma_cases SET supplier_id=$1,supplier_reference=COALESCE($2,supplier_reference) WHERE id=$3`, ref.SupplierID, nullableStringValue(req.SupplierReference), ref.ID); err != nil {
+		return fiber.NewError(500, "failed to update RMA case")
+	}
+	if err := setRMAStatus(ctx, pgxTxAdapter{tx: tx}, ref, to, merchantID, trimmedString(req.Note)); err != nil {
+		return fiber.NewError(500, "failed to update RMA case")
+	}
+	item, err := loadRMACase(ctx, tx, ref.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to update RMA case")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to update RMA case")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleIssueRMAReplacement gives the customer a replacement unit from the case's shop,
+// posts the stock movement for it and marks the case REPLACED.
+func HandleIssueRMAReplacement(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.RMAReplacementRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to issue replacement")
+	}
+	defer tx.Rollback(ctx)
+	dbtx := pgxTxAdapter{tx: tx}
+	ref, err := lockRMACase(ctx, dbtx, c.Params("caseId"), merchantID)
+	if err == nil {
+		err = validateRMATransition(ref.Status, "REPLACED", true)
+	}
+	serialNumber := trimmedString(req.SerialNumber)
+	assetRef := trimmedString(req.AssetID)
+	var column, replacementID string
+	switch {
+	case err != nil:
+	case ref.SerialID != nil && serialNumber != "":
+		column = "replacement_serial_id"
+		replacementID, err = issueRMAReplacementSerial(ctx, dbtx, ref, serialNumber, merchantID)
+	case ref.AssetID != nil && assetRef != "":
+		column = "replacement_asset_id"
+		replacementID, err = issueRMAReplacementAsset(ctx, dbtx, ref, assetRef, merchantID)
+	default:
+		err = errRMAReplacementMissing
+	}
+	if err != nil {
+		return rmaErrorResponse(err, "failed to issue replacement")
+	}
+	if _, err := tx.Exec(ctx, `UPDATE rma_cases SET `+column+`=$1 WHERE id=$2`, replacementID, ref.ID); err != nil {
+		return fiber.NewError(500, "failed to issue replacement")
+	}
+	note := trimmedString(req.Note)
+	if note == "" {
+		note = "Replacement unit issued"
+	}
+	if err := setRMAStatus(ctx, dbtx, ref, "REPLACED", merchantID, note); err != nil {
+		return fiber.NewError(500, "failed to issue replacement")
+	}
+	item, err := loadRMACase(ctx, tx, ref.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to issue replacement")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to issue replacement")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleAddRMACaseNote(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.RMACaseNoteRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Note) == "" {
+		return fiber.NewError(400, "note is required")
+	}
+	var note models.RMACaseNote
+	err = database.GetDB().QueryRow(context.Background(), `INSERT INTO rma_case_notes(rma_case_id,note,author_id)
+		SELECT id,$1,$2 FROM rma_cases WHERE id=$3 AND merchant_id=$2
+		RETURNING id,from_status,to_status,note,author_id,created_at`, strings.TrimSpace(req.Note), merchantID, c.Params("caseId")).
+		Scan(&note.ID, &note.FromStatus, &note.ToStatus, &note.Note, &note.AuthorID, &note.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, errRMACaseNotFound.Error())
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to add RMA note")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": note})
+}
+
+// HandleUploadRMACaseAttachment stores a photo or document (image or PDF) for a case
+// with the configured storage provider.
+func HandleUploadRMACaseAttachment(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	caseID := strings.TrimSpace(c.Params("caseId"))
+	var exists bool
+	if err := database.GetDB().QueryRow(context.Background(), `SELECT EXISTS(SELECT 1 FROM rma_cases WHERE id::text=$1 AND merchant_id=$2)`, caseID, merchantID).Scan(&exists); err != nil {
+		return fiber.NewError(500, "failed to validate RMA case")
+	}
+	if !exists {
+		return fiber.NewError(404, errRMACaseNotFound.Error())
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		return fiber.NewError(400, "multipart file field is required")
+	}
+	if file.Size <= 0 || file.Size > storage.LoadConfig().MaxUploadBytes {
+		return fiber.NewError(400, "file size is invalid or exceeds the configured limit")
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return fiber.NewError(400, "failed to open uploaded file")
+	}
+	defer reader.Close()
+	header := make([]byte, 512)
+	n, readErr := reader.Read(header)
+	if readErr != nil && readErr != io.EOF {
+		return fiber.NewError(400, "failed to inspect uploaded file")
+	}
+	contentType := http.DetectContentType(header[:n])
+	if !strings.HasPrefix(contentType, "image/") && contentType != "application/pdf" {
+		return fiber.NewError(400, "uploaded file must be an image or PDF")
+	}
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return fiber.NewError(400, "uploaded file cannot be rewound")
+	}
+	if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+		return fiber.NewError(400, "failed to read uploaded file")
+	}
+	provider, err := storage.NewFromEnv()
+	if err != nil {
+		return fiber.NewError(503, err.Error())
+	}
+	object, err := provider.Upload(context.Background(), storage.UploadInput{Reader: reader, Size: file.Size, Filename: file.Filename, ContentType: contentType, Folder: "rma/" + caseID})
+	if err != nil {
+		return fiber.NewError(502, "file storage upload failed")
+	}
+	var attachment models.RMACaseAttachment
+	err = database.GetDB().QueryRow(context.Background(), `INSERT INTO rma_case_attachments(rma_case_id,filename,content_type,size_bytes,url,storage_provider,storage_public_id,storage_object_name,uploaded_by) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		RETURNING id,filename,content_type,size_bytes,url,storage_provider,uploaded_by,created_at`, caseID, file.Filename, contentType, file.Size, object.PublicURL, object.Provider, nullableString(object.PublicID), nullableString(object.ObjectName), merchantID).
+		Scan(&attachment.ID, &attachment.Filename, &attachment.ContentType, &attachment.SizeBytes, &attachment.URL, &attachment.StorageProvider, &attachment.UploadedBy, &attachment.CreatedAt)
+	if err != nil {
+		_ = provider.Delete(context.Background(), object)
+		return fiber.NewError(500, "failed to save RMA attachment")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": attachment})
+}
+
+// HandleDeleteRMACaseAttachment removes an attachment and its stored file. The row goes
+// first; a file that fails to delete is left orphaned rather than failing the request.
+func HandleDeleteRMACaseAttachment(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var object storage.Object
+	var publicID, objectName *string
+	err = database.GetDB().QueryRow(context.Background(), `DELETE FROM rma_case_attachments t USING rma_cases r WHERE t.id=$1 AND t.rma_case_id=$2 AND r.id=t.rma_case_id AND r.merchant_id=$3
+		RETURNING t.storage_provider,t.storage_public_id,t.storage_object_name`, c.Params("attachmentId"), c.Params("caseId"), merchantID).Scan(&object.Provider, &publicID, &objectName)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "RMA attachment not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to delete RMA attachment")
+	}
+	object.PublicID, object.ObjectName = trimmedString(publicID), trimmedString(objectName)
+	if provider, err := storage.NewFromEnv(); err == nil && strings.EqualFold(provider.Name(), object.Provider) {
+		_ = provider.Delete(context.Background(), object)
+	}
+	return c.SendStatus(204)
+}
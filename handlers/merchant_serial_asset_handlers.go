@@ -83,7 +83,13 @@ func HandleCreateInventorySerial(c *fiber.Ctx) error {
 	if req.Status != nil && strings.TrimSpace(*req.Status) != "" {
 		status = strings.ToUpper(strings.TrimSpace(*req.Status))
 	}
-	item, err := scanInventorySerial(database.GetDB().QueryRow(context.Background(), `INSERT INTO inventory_serials(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id) SELECT ii.merchant_id,ii.shop_id,ii.id,ii.product_id,ii.stock_item_id,$2,$3,$4 FROM inventory_items ii WHERE ii.id=$1 AND ii.shop_id=$5 AND ii.merchant_id=$6 RETURNING id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id,created_at`, c.Params("inventoryItemId"), strings.TrimSpace(req.SerialNumber), status, nullableStringValue(req.ReferenceID), req.ShopID, merchantID).Scan)
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to create inventory serial")
+	}
+	defer tx.Rollback(ctx)
+	item, err := scanInventorySerial(tx.QueryRow(ctx, `INSERT INTO inventory_serials(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id) SELECT ii.merchant_id,ii.shop_id,ii.id,ii.product_id,ii.stock_item_id,$2,$3,$4 FROM inventory_items ii WHERE ii.id=$1 AND ii.shop_id=$5 AND ii.merchant_id=$6 RETURNING id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id,created_at`, c.Params("inventoryItemId"), strings.TrimSpace(req.SerialNumber), status, nullableStringValue(req.ReferenceID), req.ShopID, merchantID).Scan)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "inventory item not found for this shop")
 	}
@@ -93,6 +99,16 @@ func HandleCreateInventorySerial(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(500, "failed to create inventory serial")
 	}
+	referenceType, referenceID := "MANUAL", ""
+	if item.ReferenceID != nil {
+		referenceType, referenceID = "GOODS_RECEIPT", *item.ReferenceID
+	}
+	if err := recordSerialEvent(ctx, pgxTxAdapter{tx: tx}, item.ID, "RECEIVED", item.ShopID, referenceType, referenceID, merchantID, ""); err != nil {
+		return fiber.NewError(500, "failed to record serial history")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to create inventory serial")
+	}
 	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
 }
 
@@ -145,6 +161,38 @@ func HandleDeleteInventorySerial(c *fiber.Ctx) error {
 	return c.SendStatus(204)
 }
 
+// HandleGetInventorySerialHistory returns a serial with its receive, transfer, sale and return events.
+// The serial can be addressed by id or by serial number.
+func HandleGetInventorySerialHistory(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	serial, err := scanInventorySerial(db.QueryRow(ctx, `SELECT id,merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,serial_number,status,reference_id,created_at FROM inventory_serials WHERE (id::text=$1 OR serial_number=$1) AND merchant_id=$2 ORDER BY created_at DESC LIMIT 1`, c.Params("serialId"), merchantID).Scan)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "inventory serial not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load inventory serial")
+	}
+	rows, err := db.Query(ctx, `SELECT e.id,e.serial_id,e.event_type,e.shop_id,s.name,e.reference_type,e.reference_id,e.actor_id,e.notes,e.created_at FROM inventory_serial_events e LEFT JOIN shops s ON s.id=e.shop_id WHERE e.serial_id=$1 ORDER BY e.created_at,e.id`, serial.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load serial history")
+	}
+	defer rows.Close()
+	events := make([]models.InventorySerialEvent, 0)
+	for rows.Next() {
+		var event models.InventorySerialEvent
+		if err := rows.Scan(&event.ID, &event.SerialID, &event.EventType, &event.ShopID, &event.ShopName, &event.ReferenceType, &event.ReferenceID, &event.ActorID, &event.Notes, &event.CreatedAt); err != nil {
+			return fiber.NewError(500, "failed to read serial history")
+		}
+		events = append(events, event)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"serial": serial, "events": events}})
+}
+
 func scanInventoryAsset(scan func(...interface{}) error) (models.InventoryAsset, error) {
 	var item models.InventoryAsset
 	var batch sql.NullString
@@ -215,8 +263,17 @@ func HandleCreateInventoryAsset(c *fiber.Ctx) error {
 	if req.Status != nil && strings.TrimSpace(*req.Status) != "" {
 		status = strings.ToUpper(strings.TrimSpace(*req.Status))
 	}
+	if status == "RENTED" {
+		return fiber.NewError(400, "assets are rented through the rental checkout")
+	}
 	metadata, _ := json.Marshal(req.Metadata)
-	item, err := scanInventoryAsset(database.GetDB().QueryRow(context.Background(), `INSERT INTO inventory_assets(merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata) SELECT ii.merchant_id,ii.shop_id,ii.id,$2,$3,$4,$5 FROM inventory_items ii WHERE ii.id=$1 AND ii.shop_id=$6 AND ii.merchant_id=$7 RETURNING id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at`, c.Params("inventoryItemId"), nullableStringValue(req.BatchID), strings.TrimSpace(req.AssetTag), status, metadata, req.ShopID, merchantID).Scan)
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to create inventory asset")
+	}
+	defer tx.Rollback(ctx)
+	item, err := scanInventoryAsset(tx.QueryRow(ctx, `INSERT INTO inventory_assets(merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata) SELECT ii.merchant_id,ii.shop_id,ii.id,$2,$3,$4,$5 FROM inventory_items ii WHERE ii.id=$1 AND ii.shop_id=$6 AND ii.merchant_id=$7 RETURNING id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at`, c.Params("inventoryItemId"), nullableStringValue(req.BatchID), strings.TrimSpace(req.AssetTag), status, metadata, req.ShopID, merchantID).Scan)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "inventory item not found for this shop")
 	}
@@ -226,6 +283,12 @@ func HandleCreateInventoryAsset(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(500, "failed to create inventory asset")
 	}
+	if err := recordAssetEvent(ctx, pgxTxAdapter{tx: tx}, item.ID, "RECEIVED", "", item.Status, item.ShopID, "MANUAL", "", merchantID, ""); err != nil {
+		return fiber.NewError(500, "failed to record asset history")
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to create inventory asset")
+	}
 	item.Metadata = req.Metadata
 	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
 }
@@ -239,15 +302,30 @@ func HandleUpdateInventoryAsset(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.AssetTag) == "" {
 		return fiber.NewError(400, "assetTag is required")
 	}
-	status := "AVAILABLE"
+	metadata, _ := json.Marshal(req.Metadata)
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to update inventory asset")
+	}
+	defer tx.Rollback(ctx)
+	// Status changes go through the lifecycle rules so they are checked and logged.
 	if req.Status != nil && strings.TrimSpace(*req.Status) != "" {
-		status = strings.ToUpper(strings.TrimSpace(*req.Status))
+		_, _, err := transitionInventoryAsset(ctx, pgxTxAdapter{tx: tx}, assetTransition{AssetID: c.Params("assetId"), MerchantID: merchantID, ToStatus: strings.ToUpper(strings.TrimSpace(*req.Status)), EventType: "STATUS_CHANGED", ReferenceType: "MANUAL", ActorID: merchantID})
+		if err == errAssetNotFound {
+			return fiber.NewError(404, "inventory asset not found")
+		}
+		if err != nil && err != errAssetStatusUnchanged {
+			if isAssetError(err) {
+				return fiber.NewError(409, err.Error())
+			}
+			return fiber.NewError(500, "failed to update inventory asset")
+		}
 	}
-	metadata, _ := json.Marshal(req.Metadata)
 	var item models.InventoryAsset
 	var batch sql.NullString
 	var raw []byte
-	err = database.GetDB().QueryRow(context.Background(), `UPDATE inventory_assets SET asset_tag=$1,batch_id=$2,status=$3,metadata=$4 WHERE id=$5 AND merchant_id=$6 RETURNING id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at`, strings.TrimSpace(req.AssetTag), nullableStringValue(req.BatchID), status, metadata, c.Params("assetId"), merchantID).Scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &batch, &item.AssetTag, &item.Status, &raw, &item.CreatedAt)
+	err = tx.QueryRow(ctx, `UPDATE inventory_assets SET asset_tag=$1,batch_id=$2,metadata=$3 WHERE id=$4 AND merchant_id=$5 RETURNING id,merchant_id,shop_id,inventory_item_id,batch_id,asset_tag,status,metadata,created_at`, strings.TrimSpace(req.AssetTag), nullableStringValue(req.BatchID), metadata, c.Params("assetId"), merchantID).Scan(&item.ID, &item.MerchantID, &item.ShopID, &item.InventoryItemID, &batch, &item.AssetTag, &item.Status, &raw, &item.CreatedAt)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "inventory asset not found")
 	}
@@ -257,6 +335,9 @@ func HandleUpdateInventoryAsset(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(500, "failed to update inventory asset")
 	}
+	if err := tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to update inventory asset")
+	}
 	if batch.Valid {
 		item.BatchID = &batch.String
 	}
@@ -269,7 +350,7 @@ func HandleDeleteInventoryAsset(c *fiber.Ctx) error {
 	if err != nil {
 		return err
 	}
-	result, err := database.GetDB().Exec(context.Background(), `DELETE FROM inventory_assets WHERE id=$1 AND merchant_id=$2 AND status NOT IN ('SOLD','RESERVED')`, c.Params("assetId"), merchantID)
+	result, err := database.GetDB().Exec(context.Background(), `DELETE FROM inventory_assets WHERE id=$1 AND merchant_id=$2 AND status NOT IN ('SOLD','RESERVED','RENTED')`, c.Params("assetId"), merchantID)
 	if err != nil {
 		return fiber.NewError(500, "failed to delete inventory asset")
 	}
@@ -41,13 +41,17 @@ func HandleListMerchantShops(c *fiber.Ctx) error {
 		where += fmt.Sprintf(" AND s.is_active=$%d", len(args)+1)
 		args = append(args, v == "true")
 	}
+	if v := c.Query("locationType"); v != "" {
+		where += fmt.Sprintf(" AND s.location_type=$%d", len(args)+1)
+		args = append(args, strings.ToUpper(v))
+	}
 	var total int
 	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM shops s"+where, args...).Scan(&total); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to count shops"})
 	}
 	query := `
 		SELECT s.id, s.name, s.address, s.phone, s.tax_rate, s.is_active, s.is_primary,
-		       COALESCE(ps.delivery_charge, 0), s.created_at, s.updated_at
+		       COALESCE(ps.delivery_charge, 0), s.location_type, s.created_at, s.updated_at
 		FROM shops s
 		LEFT JOIN payment_settings ps ON ps.shop_id = s.id
 		` + where + fmt.Sprintf(" ORDER BY s.created_at DESC LIMIT $%d OFFSET $%d", len(args)+1, len(args)+2)
@@ -62,7 +66,7 @@ func HandleListMerchantShops(c *fiber.Ctx) error {
 	shops := make([]models.Shop, 0)
 	for rows.Next() {
 		var shop models.Shop
-		if err := rows.Scan(&shop.ID, &shop.Name, &shop.Address, &shop.Phone, &shop.TaxRate, &shop.IsActive, &shop.IsPrimary, &shop.DeliveryCharge, &shop.CreatedAt, &shop.UpdatedAt); err != nil {
+		if err := rows.Scan(&shop.ID, &shop.Name, &shop.Address, &shop.Phone, &shop.TaxRate, &shop.IsActive, &shop.IsPrimary, &shop.DeliveryCharge, &shop.LocationType, &shop.CreatedAt, &shop.UpdatedAt); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to scan shop data"})
 		}
 		shop.MerchantID = merchantID
@@ -110,17 +114,24 @@ func HandleUpdateMerchantShop(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
 	}
+	// An omitted location type keeps the current one.
+	var locationType string
+	if strings.TrimSpace(req.LocationType) != "" {
+		if locationType, err = normalizeLocationType(req.LocationType); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+	}
 
 	query := `
 		UPDATE shops
-		SET name = $1, address = $2, phone = $3, tax_rate = $4
+		SET name = $1, address = $2, phone = $3, tax_rate = $4, location_type = COALESCE(NULLIF($7, ''), location_type)
 		WHERE id = $5 AND merchant_id = $6
-		RETURNING id, name, address, phone, tax_rate, is_active, is_primary, created_at, updated_at
+		RETURNING id, name, address, phone, tax_rate, is_active, is_primary, location_type, created_at, updated_at
 	`
 
 	var shop models.Shop
-	err = tx.QueryRow(ctx, query, req.Name, req.Address, req.Phone, req.TaxRate, shopID, merchantID).Scan(
-		&shop.ID, &shop.Name, &shop.Address, &shop.Phone, &shop.TaxRate, &shop.IsActive, &shop.IsPrimary, &shop.CreatedAt, &shop.UpdatedAt,
+	err = tx.QueryRow(ctx, query, req.Name, req.Address, req.Phone, req.TaxRate, shopID, merchantID, locationType).Scan(
+		&shop.ID, &shop.Name, &shop.Address, &shop.Phone, &shop.TaxRate, &shop.IsActive, &shop.IsPrimary, &shop.LocationType, &shop.CreatedAt, &shop.UpdatedAt,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update shop"})
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var storageBinSortFields = map[string]string{"code": "code", "name": "name", "createdAt": "created_at"}
+
+const storageBinColumns = "id,merchant_id,shop_id,code,name,bin_type,is_active,created_at,updated_at"
+
+func scanStorageBin(row pgx.Row, item *models.StorageBin) error {
+	return row.Scan(&item.ID, &item.MerchantID, &item.ShopID, &item.Code, &item.Name, &item.BinType, &item.IsActive, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// parseStorageBinRequest validates a bin body, defaulting the type to BIN and the bin to active.
+func parseStorageBinRequest(c *fiber.Ctx) (models.StorageBinRequest, bool, error) {
+	var req models.StorageBinRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		return req, false, fiber.NewError(400, "code is required")
+	}
+	req.Code = strings.TrimSpace(req.Code)
+	if len(req.Code) > 50 {
+		return req, false, fiber.NewError(400, "code must be at most 50 characters")
+	}
+	req.BinType = strings.ToUpper(strings.TrimSpace(req.BinType))
+	if req.BinType == "" {
+		req.BinType = "BIN"
+	}
+	if req.BinType != "BIN" && req.BinType != "SHELF" {
+		return req, false, fiber.NewError(400, "binType must be BIN or SHELF")
+	}
+	req.Name = nullableString(trimmedString(req.Name))
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+	return req, active, nil
+}
+
+// HandleListStorageBins lists the bins and shelves inside a stock location.
+func HandleListStorageBins(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "code", storageBinSortFields)
+	where := " WHERE shop_id=$1"
+	args := []interface{}{shopID}
+	if q.Search != "" {
+		where += " AND (code ILIKE $2 OR name ILIKE $2)"
+		args = append(args, "%"+q.Search+"%")
+	}
+	if v := c.Query("isActive"); v != "" {
+		where += " AND is_active=$" + itoa(len(args)+1)
+		args = append(args, v == "true")
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM storage_bins"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count bins")
+	}
+	rows, err := db.Query(ctx, "SELECT "+storageBinColumns+" FROM storage_bins"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list bins")
+	}
+	defer rows.Close()
+	items := make([]models.StorageBin, 0)
+	for rows.Next() {
+		var item models.StorageBin
+		if err := scanStorageBin(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read bin")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleCreateStorageBin(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	req, active, err := parseStorageBinRequest(c)
+	if err != nil {
+		return err
+	}
+	var item models.StorageBin
+	row := database.GetDB().QueryRow(context.Background(), `INSERT INTO storage_bins(merchant_id,shop_id,code,name,bin_type,is_active) VALUES($1,$2,$3,$4,$5,$6) RETURNING `+storageBinColumns, merchantID, shopID, req.Code, req.Name, req.BinType, active)
+	if err := scanStorageBin(row, &item); err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "a bin with this code already exists in this location")
+		}
+		return fiber.NewError(500, "failed to create bin")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleUpdateStorageBin(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	req, active, err := parseStorageBinRequest(c)
+	if err != nil {
+		return err
+	}
+	var item models.StorageBin
+	row := database.GetDB().QueryRow(context.Background(), `UPDATE storage_bins SET code=$1,name=$2,bin_type=$3,is_active=$4,updated_at=NOW() WHERE id=$5 AND shop_id=$6 RETURNING `+storageBinColumns, req.Code, req.Name, req.BinType, active, c.Params("binId"), shopID)
+	err = scanStorageBin(row, &item)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "bin not found")
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "a bin with this code already exists in this location")
+		}
+		return fiber.NewError(500, "failed to update bin")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleDeleteStorageBin removes an empty bin. Balances using it as their put-away or
+// pick bin fall back to none, and past movements keep their history without it. A bin
+// still holding stock cannot be deleted.
+func HandleDeleteStorageBin(c *fiber.Ctx) error {
+	shopID, binID := c.Params("shopId"), c.Params("binId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to delete bin")
+	}
+	defer tx.Rollback(ctx)
+	var holding bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM inventory_bin_balances bb WHERE bb.bin_id=b.id AND bb.quantity<>0) FROM storage_bins b WHERE b.id=$1 AND b.shop_id=$2 FOR UPDATE OF b`, binID, shopID).Scan(&holding)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "bin not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to delete bin")
+	}
+	if holding {
+		return fiber.NewError(409, "bin still holds stock; move it out before deleting the bin")
+	}
+	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET putaway_bin_id=CASE WHEN putaway_bin_id=$1 THEN NULL ELSE putaway_bin_id END,pick_bin_id=CASE WHEN pick_bin_id=$1 THEN NULL ELSE pick_bin_id END,updated_at=NOW() WHERE putaway_bin_id=$1 OR pick_bin_id=$1`, binID); err != nil {
+		return fiber.NewError(500, "failed to clear bin defaults")
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM inventory_bin_balances WHERE bin_id=$1`, binID); err != nil {
+		return fiber.NewError(500, "failed to delete bin")
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM storage_bins WHERE id=$1`, binID); err != nil {
+		return fiber.NewError(500, "failed to delete bin")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to delete bin")
+	}
+	return c.SendStatus(204)
+}
+
+// HandleUpdateInventoryBins sets the bins a balance is put away into and picked from.
+func HandleUpdateInventoryBins(c *fiber.Ctx) error {
+	shopID := c.Params("shopId")
+	if err := authorizeShopAccess(c, shopID); err != nil {
+		return err
+	}
+	var req models.InventoryBinsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	db, ctx := database.GetDB(), context.Background()
+	putaway, pick := trimmedString(req.PutawayBinID), trimmedString(req.PickBinID)
+	for _, binID := range []string{putaway, pick} {
+		if binID == "" {
+			continue
+		}
+		if err := validateStorageBin(ctx, pgxPoolAdapter{pool: db}, shopID, binID); err != nil {
+			if err == errStorageBinInvalid {
+				return fiber.NewError(400, err.Error())
+			}
+			return fiber.NewError(500, "failed to validate bin")
+		}
+	}
+	var putawayID, pickID *string
+	err := db.QueryRow(ctx, `UPDATE inventory_items SET putaway_bin_id=$1,pick_bin_id=$2,updated_at=NOW() WHERE id=$3 AND shop_id=$4 RETURNING putaway_bin_id,pick_bin_id`, nullableString(putaway), nullableString(pick), c.Params("inventoryItemId"), shopID).Scan(&putawayID, &pickID)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "inventory item not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to update bins")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"inventoryItemId": c.Params("inventoryItemId"), "putawayBinId": putawayID, "pickBinId": pickID}})
+}
@@ -20,7 +20,7 @@ func HandleListMerchantSuppliers(c *fiber.Ctx) error {
 	claims := user.Claims.(jwt.MapClaims)
 	merchantID := claims["userId"].(string)
 
-	query := `SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, created_at, updated_at FROM suppliers WHERE merchant_id = $1`
+	query := `SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days, created_at, updated_at FROM suppliers WHERE merchant_id = $1`
 	rows, err := db.Query(ctx, query, merchantID)
 	if err != nil {
 		log.Printf("Error querying suppliers for merchant %s: %v", merchantID, err)
@@ -31,7 +31,7 @@ func HandleListMerchantSuppliers(c *fiber.Ctx) error {
 	var suppliers []models.Supplier
 	for rows.Next() {
 		var s models.Supplier
-		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &s.ContactName, &s.ContactEmail, &s.ContactPhone, &s.Address, &s.Notes, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &s.ContactName, &s.ContactEmail, &s.ContactPhone, &s.Address, &s.Notes, &s.LeadTimeDays, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			log.Printf("Error scanning supplier row: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Error processing supplier data"})
 		}
@@ -55,8 +55,8 @@ func HandleCreateNewSupplier(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
 	}
 
-	query := `INSERT INTO suppliers (merchant_id, name, contact_name, contact_email, contact_phone, address, notes) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at, updated_at`
-	err := db.QueryRow(ctx, query, merchantID, supplier.Name, supplier.ContactName, supplier.ContactEmail, supplier.ContactPhone, supplier.Address, supplier.Notes).Scan(&supplier.ID, &supplier.CreatedAt, &supplier.UpdatedAt)
+	query := `INSERT INTO suppliers (merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days) VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, 7)) RETURNING id, lead_time_days, created_at, updated_at`
+	err := db.QueryRow(ctx, query, merchantID, supplier.Name, supplier.ContactName, supplier.ContactEmail, supplier.ContactPhone, supplier.Address, supplier.Notes, supplier.LeadTimeDays).Scan(&supplier.ID, &supplier.LeadTimeDays, &supplier.CreatedAt, &supplier.UpdatedAt)
 	if err != nil {
 		log.Printf("Error creating supplier for merchant %s: %v", merchantID, err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create supplier"})
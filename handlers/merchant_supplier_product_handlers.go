@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+var supplierProductSortFields = map[string]string{"name": "si.name", "lastCost": "sp.last_cost", "updatedAt": "sp.updated_at"}
+
+// HandleListSupplierProducts lists what a supplier sells, for browsing its catalog and
+// pre-filling purchase order costs.
+func HandleListSupplierProducts(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "name", supplierProductSortFields)
+	where := " WHERE sp.merchant_id=$1 AND sp.supplier_id::text=$2"
+	args := []interface{}{merchantID, c.Params("supplierId")}
+	if q.Search != "" {
+		where += " AND (si.name ILIKE $3 OR si.sku ILIKE $3 OR sp.supplier_sku ILIKE $3)"
+		args = append(args, "%"+q.Search+"%")
+	}
+	if c.Query("preferred") == "true" {
+		where += " AND sp.is_preferred"
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*)"+supplierProductFrom+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count supplier products")
+	}
+	rows, err := db.Query(ctx, "SELECT "+supplierProductColumns+supplierProductFrom+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list supplier products")
+	}
+	defer rows.Close()
+	items := make([]models.SupplierProduct, 0)
+	for rows.Next() {
+		var item models.SupplierProduct
+		if err := scanSupplierProduct(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read supplier product")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+// HandleUpsertSupplierProduct adds a stock item to a supplier's catalog or updates it.
+// Marking it preferred makes this supplier the only preferred one for the item.
+func HandleUpsertSupplierProduct(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.SupplierProductRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	if err := validateSupplierProduct(&req); err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	supplierID, stockItemID := c.Params("supplierId"), c.Params("stockItemId")
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start supplier product update")
+	}
+	defer tx.Rollback(ctx)
+	if ok, err := merchantOwns(ctx, tx, "suppliers", supplierID, merchantID); err != nil || !ok {
+		return fiber.NewError(404, "supplier not found")
+	}
+	if ok, err := merchantOwns(ctx, tx, "stock_items", stockItemID, merchantID); err != nil || !ok {
+		return fiber.NewError(404, "stock item not found")
+	}
+	if req.UnitID != nil {
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, stockItemID, req.UnitID, 1)
+		if err != nil {
+			if isUnitError(err) {
+				return fiber.NewError(400, err.Error())
+			}
+			return fiber.NewError(500, "failed to resolve purchase unit")
+		}
+		req.UnitID = qty.UnitID
+	}
+	if req.IsPreferred != nil && *req.IsPreferred {
+		if _, err = tx.Exec(ctx, `UPDATE supplier_products SET is_preferred=FALSE,updated_at=NOW() WHERE stock_item_id::text=$1 AND supplier_id::text<>$2 AND is_preferred`, stockItemID, supplierID); err != nil {
+			return fiber.NewError(500, "failed to update preferred supplier")
+		}
+	}
+	var id string
+	err = tx.QueryRow(ctx, `INSERT INTO supplier_products(merchant_id,supplier_id,stock_item_id,supplier_sku,unit_id,last_cost,min_order_quantity,pack_size,lead_time_days,is_preferred)
+		VALUES($1,$2,$3,$4,$5,$6,COALESCE($7,0),COALESCE($8,1),$9,COALESCE($10,FALSE))
+		ON CONFLICT (supplier_id,stock_item_id) DO UPDATE SET
+			supplier_sku=COALESCE(EXCLUDED.supplier_sku,supplier_products.supplier_sku),
+			unit_id=COALESCE(EXCLUDED.unit_id,supplier_products.unit_id),
+			last_cost=COALESCE(EXCLUDED.last_cost,supplier_products.last_cost),
+			min_order_quantity=COALESCE($7,supplier_products.min_order_quantity),
+			pack_size=COALESCE($8,supplier_products.pack_size),
+			lead_time_days=COALESCE(EXCLUDED.lead_time_days,supplier_products.lead_time_days),
+			is_preferred=COALESCE($10,supplier_products.is_preferred),
+			updated_at=NOW()
+		RETURNING id`, merchantID, supplierID, stockItemID, nullableStringValue(req.SupplierSKU), req.UnitID, req.LastCost, req.MinOrderQuantity, req.PackSize, req.LeadTimeDays, req.IsPreferred).Scan(&id)
+	if err != nil {
+		return fiber.NewError(500, "failed to save supplier product")
+	}
+	var item models.SupplierProduct
+	if err = scanSupplierProduct(tx.QueryRow(ctx, "SELECT "+supplierProductColumns+supplierProductFrom+" WHERE sp.id=$1", id), &item); err != nil {
+		return fiber.NewError(500, "failed to load supplier product")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit supplier product")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleDeleteSupplierProduct(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	tag, err := database.GetDB().Exec(context.Background(), `DELETE FROM supplier_products WHERE merchant_id=$1 AND supplier_id::text=$2 AND stock_item_id::text=$3`, merchantID, c.Params("supplierId"), c.Params("stockItemId"))
+	if err != nil {
+		return fiber.NewError(500, "failed to delete supplier product")
+	}
+	if tag.RowsAffected() == 0 {
+		return fiber.NewError(404, "supplier product not found")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true})
+}
+
+// HandleCompareSupplierPrices lists every supplier of a stock item, preferred first and
+// then cheapest per base unit, so costs in different purchase units compare directly.
+func HandleCompareSupplierPrices(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var exists bool
+	if err = db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM stock_items WHERE id::text=$1 AND merchant_id=$2)`, c.Params("stockItemId"), merchantID).Scan(&exists); err != nil {
+		return fiber.NewError(500, "failed to load stock item")
+	}
+	if !exists {
+		return fiber.NewError(404, "stock item not found")
+	}
+	rows, err := db.Query(ctx, "SELECT "+supplierProductColumns+supplierProductFrom+` WHERE sp.merchant_id=$1 AND sp.stock_item_id::text=$2 AND s.is_active
+		ORDER BY sp.is_preferred DESC,sp.last_cost/COALESCE(su.conversion_to_base,1) ASC NULLS LAST,s.name`, merchantID, c.Params("stockItemId"))
+	if err != nil {
+		return fiber.NewError(500, "failed to compare supplier prices")
+	}
+	defer rows.Close()
+	items := make([]models.SupplierProduct, 0)
+	for rows.Next() {
+		var item models.SupplierProduct
+		if err := scanSupplierProduct(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read supplier product")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": items})
+}
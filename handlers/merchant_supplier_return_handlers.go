@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var supplierReturnSortFields = map[string]string{"createdAt": "r.created_at", "totalAmount": "r.total_amount"}
+
+const supplierReturnColumns = "r.id,r.shop_id,r.supplier_id,r.goods_receipt_id,r.reason,r.total_amount::float8,cn.id,r.created_by,r.created_at"
+
+const supplierReturnFrom = " FROM supplier_returns r LEFT JOIN supplier_credit_notes cn ON cn.supplier_return_id=r.id"
+
+func scanSupplierReturn(row pgx.Row, item *models.SupplierReturn) error {
+	return row.Scan(&item.ID, &item.ShopID, &item.SupplierID, &item.GoodsReceiptID, &item.Reason, &item.TotalAmount, &item.CreditNoteID, &item.CreatedBy, &item.CreatedAt)
+}
+
+// receiptLineCost returns the base quantity of a stock item received on a goods receipt
+// and its cost per base unit, or errReturnItemNotOnReceipt.
+func receiptLineCost(ctx context.Context, tx pgx.Tx, receiptID, stockItemID string) (float64, float64, error) {
+	var received, cost *float64
+	if err := tx.QueryRow(ctx, `SELECT SUM(COALESCE(base_quantity,quantity))::float8,(SUM(unit_cost*quantity)/NULLIF(SUM(COALESCE(base_quantity,quantity)),0))::float8 FROM goods_receipt_items WHERE goods_receipt_id=$1 AND stock_item_id::text=$2`, receiptID, stockItemID).Scan(&received, &cost); err != nil {
+		return 0, 0, err
+	}
+	if received == nil || cost == nil {
+		return 0, 0, errReturnItemNotOnReceipt
+	}
+	return *received, *cost, nil
+}
+
+// HandleCreateSupplierReturn sends stock back to a supplier. Each line leaves the shop as
+// an OUT movement referenced RETURN_TO_VENDOR, drawing from the requested batch or
+// first-expiry-first-out, and the credited value is booked as a supplier credit note
+// that offsets the supplier's next invoices.
+func HandleCreateSupplierReturn(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.SupplierReturnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	req.ClientOperationID = strings.TrimSpace(req.ClientOperationID)
+	req.ShopID = strings.TrimSpace(req.ShopID)
+	req.SupplierID = strings.TrimSpace(req.SupplierID)
+	if req.ClientOperationID == "" || req.ShopID == "" || req.SupplierID == "" || len(req.Items) == 0 {
+		return fiber.NewError(400, "clientOperationId, shopId, supplierId and items are required")
+	}
+	if len(req.Items) > 100 {
+		return fiber.NewError(400, "a return may have at most 100 lines")
+	}
+	seen := make(map[string]struct{}, len(req.Items))
+	for i := range req.Items {
+		item := &req.Items[i]
+		item.StockItemID = strings.TrimSpace(item.StockItemID)
+		if item.StockItemID == "" || item.Quantity <= 0 || (item.UnitCost != nil && *item.UnitCost < 0) {
+			return fiber.NewError(400, "each line needs a stockItemId, a positive quantity and a non-negative unitCost")
+		}
+		if _, exists := seen[item.StockItemID]; exists {
+			return fiber.NewError(400, "each stock item may appear only once in a return")
+		}
+		seen[item.StockItemID] = struct{}{}
+	}
+	if err := authorizeShopAccess(c, req.ShopID); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start supplier return")
+	}
+	defer tx.Rollback(ctx)
+	dbtx := pgxTxAdapter{tx: tx}
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "supplier_return", merchantID, &req.ShopID)
+	if err != nil {
+		return fiber.NewError(500, "failed to start supplier return")
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Supplier return already processed"})
+	}
+	ok, err := merchantOwns(ctx, tx, "suppliers", req.SupplierID, merchantID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load supplier")
+	}
+	if !ok {
+		return fiber.NewError(400, "supplier not found")
+	}
+	receiptID := trimmedString(req.GoodsReceiptID)
+	if receiptID != "" {
+		var ok bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM goods_receipts gr JOIN purchase_orders po ON po.id=gr.purchase_order_id WHERE gr.id::text=$1 AND po.merchant_id=$2 AND po.supplier_id=$3 AND po.shop_id=$4)`, receiptID, merchantID, req.SupplierID, req.ShopID).Scan(&ok); err != nil || !ok {
+			return fiber.NewError(400, "goods receipt not found for this supplier and shop")
+		}
+	}
+	ret := models.SupplierReturn{ShopID: req.ShopID, SupplierID: req.SupplierID, GoodsReceiptID: nullableString(receiptID), Reason: nullableString(trimmedString(req.Reason)), CreatedBy: &merchantID}
+	if err = tx.QueryRow(ctx, `INSERT INTO supplier_returns(merchant_id,shop_id,supplier_id,goods_receipt_id,reason,created_by) VALUES($1,$2,$3,$4,$5,$6) RETURNING id,created_at`, merchantID, req.ShopID, req.SupplierID, ret.GoodsReceiptID, ret.Reason, merchantID).Scan(&ret.ID, &ret.CreatedAt); err != nil {
+		return fiber.NewError(500, "failed to create supplier return")
+	}
+	notes := "Returned to supplier"
+	if ret.Reason != nil {
+		notes = fmt.Sprintf("%s: %s", notes, *ret.Reason)
+	}
+	for _, item := range req.Items {
+		var productID string
+		if err = tx.QueryRow(ctx, `SELECT product_id FROM stock_items WHERE id::text=$1 AND merchant_id=$2`, item.StockItemID, merchantID).Scan(&productID); err != nil {
+			return fiber.NewError(400, fmt.Sprintf("stock item %s not found", item.StockItemID))
+		}
+		serialTracked, err := stockItemTracksSerials(ctx, dbtx, item.StockItemID)
+		if err != nil {
+			return fiber.NewError(500, "failed to load stock configuration")
+		}
+		if serialTracked {
+			return fiber.NewError(400, errSerialReturnViaRMA.Error())
+		}
+		qty, err := resolveUnitQuantity(ctx, dbtx, item.StockItemID, item.UnitID, item.Quantity)
+		if err != nil {
+			if isUnitError(err) {
+				return fiber.NewError(400, err.Error())
+			}
+			return fiber.NewError(500, "failed to resolve return unit")
+		}
+		var receiptCost *float64
+		if receiptID != "" {
+			received, cost, err := receiptLineCost(ctx, tx, receiptID, item.StockItemID)
+			if err == errReturnItemNotOnReceipt {
+				return fiber.NewError(400, err.Error())
+			}
+			if err != nil {
+				return fiber.NewError(500, "failed to load goods receipt")
+			}
+			var returned float64
+			if err = tx.QueryRow(ctx, `SELECT COALESCE(SUM(sri.base_quantity),0)::float8 FROM supplier_return_items sri JOIN supplier_returns sr ON sr.id=sri.supplier_return_id WHERE sr.goods_receipt_id::text=$1 AND sri.stock_item_id::text=$2`, receiptID, item.StockItemID).Scan(&returned); err != nil {
+				return fiber.NewError(500, "failed to load earlier returns")
+			}
+			if roundBaseQuantity(returned+qty.BaseQuantity) > roundBaseQuantity(received) {
+				return fiber.NewError(409, errReturnExceedsReceipt.Error())
+			}
+			receiptCost = &cost
+		}
+		var invID string
+		var available float64
+		// Reserved stock is held for orders and cannot go back to the supplier.
+		err = tx.QueryRow(ctx, `SELECT ii.id,`+unreservedStockSQL("ii")+`::float8 FROM inventory_items ii WHERE ii.shop_id=$1 AND ii.stock_item_id::text=$2 FOR UPDATE`, req.ShopID, item.StockItemID).Scan(&invID, &available)
+		if err == pgx.ErrNoRows {
+			return fiber.NewError(404, fmt.Sprintf("stock item %s is not stocked in this shop", item.StockItemID))
+		}
+		if err != nil {
+			return fiber.NewError(500, "failed to lock stock")
+		}
+		if available < qty.BaseQuantity {
+			return fiber.NewError(409, fmt.Sprintf("insufficient stock for stock item %s", item.StockItemID))
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2`, qty.BaseQuantity, invID); err != nil {
+			return fiber.NewError(500, "failed to update stock")
+		}
+		allocations, err := consumeInventoryBatches(ctx, dbtx, item.StockItemID, invID, qty.BaseQuantity, selectedBatchID(item.BatchID))
+		if err != nil {
+			if isBatchConsumptionError(err) {
+				return fiber.NewError(409, fmt.Sprintf("stock item %s: %v", item.StockItemID, err))
+			}
+			return fiber.NewError(500, "failed to update batches")
+		}
+		issueCost, err := issueInventoryCost(ctx, dbtx, merchantID, invID, qty.BaseQuantity, allocations)
+		if err != nil {
+			return fiber.NewError(500, "failed to cost returned stock")
+		}
+		binID, err := resolveMovementBin(ctx, dbtx, invID, item.BinID, false)
+		if err != nil {
+			if err == errStorageBinInvalid {
+				return fiber.NewError(400, err.Error())
+			}
+			return fiber.NewError(500, "failed to resolve bin")
+		}
+		var batchID *string
+		if len(allocations) == 1 {
+			batchID = &allocations[0].BatchID
+		}
+		line := models.SupplierReturnItem{InventoryItemID: invID, StockItemID: &item.StockItemID, BatchID: batchID, UnitID: qty.UnitID, Quantity: item.Quantity, BaseQuantity: qty.BaseQuantity}
+		line.UnitCost = supplierReturnCreditCost(item.UnitCost, qty.Factor, receiptCost, issueCost)
+		line.TotalCost = roundMoney(line.BaseQuantity * line.UnitCost)
+		if err = tx.QueryRow(ctx, `INSERT INTO supplier_return_items(supplier_return_id,inventory_item_id,product_id,stock_item_id,batch_id,unit_id,quantity,base_quantity,unit_cost,total_cost) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING id`, ret.ID, invID, productID, item.StockItemID, batchID, qty.UnitID, item.Quantity, qty.BaseQuantity, line.UnitCost, line.TotalCost).Scan(&line.ID); err != nil {
+			return fiber.NewError(500, "failed to record return line")
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,batch_id,bin_id) VALUES($1,$2,$3,$4,$5,$6,'OUT',$7,$8,$9,'RETURN_TO_VENDOR',$10,$11,$12,$13,$14)`, merchantID, req.ShopID, invID, productID, item.StockItemID, qty.UnitID, item.Quantity, qty.BaseQuantity, issueCost, ret.ID, fmt.Sprintf("%s:%s", req.ClientOperationID, item.StockItemID), notes, batchID, binID); err != nil {
+			return fiber.NewError(500, "failed to record stock movement")
+		}
+		if err = adjustBinBalance(ctx, dbtx, invID, binID, -qty.BaseQuantity); err != nil {
+			return fiber.NewError(500, "failed to update bin balance")
+		}
+		ret.TotalAmount = roundMoney(ret.TotalAmount + line.TotalCost)
+		ret.Items = append(ret.Items, line)
+	}
+	if _, err = tx.Exec(ctx, `UPDATE supplier_returns SET total_amount=$1 WHERE id=$2`, ret.TotalAmount, ret.ID); err != nil {
+		return fiber.NewError(500, "failed to total supplier return")
+	}
+	if ret.TotalAmount > 0 {
+		var creditNoteID string
+		if err = tx.QueryRow(ctx, `INSERT INTO supplier_credit_notes(merchant_id,supplier_id,supplier_return_id,amount) VALUES($1,$2,$3,$4) RETURNING id`, merchantID, req.SupplierID, ret.ID, ret.TotalAmount).Scan(&creditNoteID); err != nil {
+			return fiber.NewError(500, "failed to create supplier credit note")
+		}
+		ret.CreditNoteID = &creditNoteID
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit supplier return")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": ret})
+}
+
+// HandleListSupplierReturns lists returns to suppliers, filtered by supplier or shop.
+func HandleListSupplierReturns(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "createdAt", supplierReturnSortFields)
+	where := " WHERE r.merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, filter := range [][2]string{{"supplierId", "r.supplier_id"}, {"shopId", "r.shop_id"}, {"goodsReceiptId", "r.goods_receipt_id"}} {
+		if v := c.Query(filter[0]); v != "" {
+			where += " AND " + filter[1] + "::text=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM supplier_returns r"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count supplier returns")
+	}
+	rows, err := db.Query(ctx, "SELECT "+supplierReturnColumns+supplierReturnFrom+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list supplier returns")
+	}
+	defer rows.Close()
+	items := make([]models.SupplierReturn, 0)
+	for rows.Next() {
+		var item models.SupplierReturn
+		if err := scanSupplierReturn(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read supplier return")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+// HandleGetSupplierReturn returns one supplier return with its lines.
+func HandleGetSupplierReturn(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var ret models.SupplierReturn
+	err = scanSupplierReturn(db.QueryRow(ctx, "SELECT "+supplierReturnColumns+supplierReturnFrom+" WHERE r.id=$1 AND r.merchant_id=$2", c.Params("returnId"), merchantID), &ret)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "supplier return not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load supplier return")
+	}
+	rows, err := db.Query(ctx, `SELECT id,inventory_item_id,stock_item_id,batch_id,unit_id,quantity::float8,base_quantity::float8,unit_cost::float8,total_cost::float8 FROM supplier_return_items WHERE supplier_return_id=$1 ORDER BY id`, ret.ID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load return lines")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.SupplierReturnItem
+		if err := rows.Scan(&line.ID, &line.InventoryItemID, &line.StockItemID, &line.BatchID, &line.UnitID, &line.Quantity, &line.BaseQuantity, &line.UnitCost, &line.TotalCost); err != nil {
+			return fiber.NewError(500, "failed to read return line")
+		}
+		ret.Items = append(ret.Items, line)
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": ret})
+}
+
+// HandleListSupplierCreditNotes lists supplier credit notes, filtered by supplier or status.
+func HandleListSupplierCreditNotes(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "createdAt", map[string]string{"createdAt": "created_at", "amount": "amount"})
+	where := " WHERE merchant_id=$1"
+	args := []interface{}{merchantID}
+	for _, filter := range [][2]string{{"supplierId", "supplier_id"}, {"status", "status"}} {
+		if v := c.Query(filter[0]); v != "" {
+			where += " AND " + filter[1] + "::text=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM supplier_credit_notes"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count credit notes")
+	}
+	rows, err := db.Query(ctx, "SELECT id,supplier_id,supplier_return_id,amount::float8,amount_applied::float8,status,created_at FROM supplier_credit_notes"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list credit notes")
+	}
+	defer rows.Close()
+	items := make([]models.SupplierCreditNote, 0)
+	for rows.Next() {
+		var item models.SupplierCreditNote
+		if err := rows.Scan(&item.ID, &item.SupplierID, &item.SupplierReturnID, &item.Amount, &item.AmountApplied, &item.Status, &item.CreatedAt); err != nil {
+			return fiber.NewError(500, "failed to read credit note")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
@@ -61,7 +61,7 @@ func HandleListMerchantSuppliers(c *fiber.Ctx) error {
 	// Fetch paginated data
 	offset := (page - 1) * pageSize
 	query := fmt.Sprintf(`
-		SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, created_at, updated_at
+		SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days, created_at, updated_at
 		%s
 		ORDER BY created_at DESC, id DESC
 		LIMIT $%d OFFSET $%d`, baseQuery, argCount, argCount+1)
@@ -78,7 +78,7 @@ func HandleListMerchantSuppliers(c *fiber.Ctx) error {
 	for rows.Next() {
 		var s models.Supplier
 		var contactName, contactEmail, contactPhone, address, notes sql.NullString
-		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.LeadTimeDays, &s.CreatedAt, &s.UpdatedAt); err != nil {
 			log.Printf("Error scanning supplier: %v", err)
 			continue
 		}
@@ -118,11 +118,11 @@ func HandleGetSupplierDetails(c *fiber.Ctx) error {
 
 	var s models.Supplier
 	var contactName, contactEmail, contactPhone, address, notes sql.NullString
-	query := `SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, created_at, updated_at
+	query := `SELECT id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days, created_at, updated_at
 	          FROM suppliers WHERE id = $1 AND merchant_id = $2`
 
 	err = db.QueryRow(ctx, query, supplierId, merchantId).Scan(
-		&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.CreatedAt, &s.UpdatedAt,
+		&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.LeadTimeDays, &s.CreatedAt, &s.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -161,6 +161,9 @@ func HandleCreateNewSupplier(c *fiber.Ctx) error {
 	if strings.TrimSpace(input.ClientOperationID) == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "clientOperationId is required"})
 	}
+	if input.LeadTimeDays != nil && *input.LeadTimeDays < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "leadTimeDays cannot be negative"})
+	}
 	tx, err := db.Begin(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start transaction"})
@@ -174,11 +177,11 @@ func HandleCreateNewSupplier(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusOK).JSON(fiber.Map{"data": input, "message": "Operation already processed"})
 	}
 
-	query := `INSERT INTO suppliers (merchant_id, name, contact_name, contact_email, contact_phone, address, notes) 
-	          VALUES ($1, $2, $3, $4, $5, $6, $7) 
-	          RETURNING id, created_at, updated_at`
+	query := `INSERT INTO suppliers (merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days) 
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, 7)) 
+	          RETURNING id, lead_time_days, created_at, updated_at`
 
-	err = tx.QueryRow(ctx, query, input.MerchantID, input.Name, input.ContactName, input.ContactEmail, input.ContactPhone, input.Address, input.Notes).Scan(&input.ID, &input.CreatedAt, &input.UpdatedAt)
+	err = tx.QueryRow(ctx, query, input.MerchantID, input.Name, input.ContactName, input.ContactEmail, input.ContactPhone, input.Address, input.Notes, input.LeadTimeDays).Scan(&input.ID, &input.LeadTimeDays, &input.CreatedAt, &input.UpdatedAt)
 	if err != nil {
 		if strings.Contains(err.Error(), "suppliers_merchant_id_name_key") {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "A supplier with this name already exists for your account."})
@@ -244,6 +247,11 @@ func HandleUpdateExistingSupplier(c *fiber.Ctx) error {
 			column = "contact_email"
 		case "contactPhone":
 			column = "contact_phone"
+		case "leadTimeDays", "lead_time_days":
+			if days, ok := value.(float64); !ok || days < 0 || days != float64(int(days)) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "leadTimeDays must be a non-negative whole number"})
+			}
+			column = "lead_time_days"
 		case "clientOperationId", "client_operation_id", "id", "merchant_id":
 			continue
 		default:
@@ -259,7 +267,7 @@ func HandleUpdateExistingSupplier(c *fiber.Ctx) error {
 	}
 
 	query := fmt.Sprintf(`UPDATE suppliers SET %s, updated_at = NOW() WHERE id = $%d AND merchant_id = $%d
-	          RETURNING id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, created_at, updated_at`,
+	          RETURNING id, merchant_id, name, contact_name, contact_email, contact_phone, address, notes, lead_time_days, created_at, updated_at`,
 		strings.Join(setParts, ", "), argCount, argCount+1)
 
 	args = append(args, supplierId, merchantId)
@@ -268,7 +276,7 @@ func HandleUpdateExistingSupplier(c *fiber.Ctx) error {
 	var contactName, contactEmail, contactPhone, address, notes sql.NullString
 
 	err = tx.QueryRow(ctx, query, args...).Scan(
-		&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.CreatedAt, &s.UpdatedAt,
+		&s.ID, &s.MerchantID, &s.Name, &contactName, &contactEmail, &contactPhone, &address, &notes, &s.LeadTimeDays, &s.CreatedAt, &s.UpdatedAt,
 	)
 	if err != nil {
 		if err == pgx.ErrNoRows {
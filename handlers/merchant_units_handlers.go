@@ -216,7 +216,7 @@ func HandleGetStockItemConfiguration(c *fiber.Ctx) error {
 		return fiber.NewError(404, "stock item not found")
 	}
 	var item models.StockItemConfiguration
-	err = database.GetDB().QueryRow(context.Background(), `SELECT stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes,created_at FROM stock_item_configurations WHERE stock_item_id=$1`, c.Params("stockItemId")).Scan(&item.StockItemID, &item.TrackBatches, &item.TrackExpiry, &item.TrackUniqueAssets, &item.TrackReservations, &item.AllowUnitConversions, &item.AllowPackBreaking, &item.AllowMultipleBarcodes, &item.CreatedAt)
+	err = database.GetDB().QueryRow(context.Background(), `SELECT stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes,warranty_months,negative_stock_policy,created_at FROM stock_item_configurations WHERE stock_item_id=$1`, c.Params("stockItemId")).Scan(&item.StockItemID, &item.TrackBatches, &item.TrackExpiry, &item.TrackUniqueAssets, &item.TrackReservations, &item.AllowUnitConversions, &item.AllowPackBreaking, &item.AllowMultipleBarcodes, &item.WarrantyMonths, &item.NegativeStockPolicy, &item.CreatedAt)
 	if err == pgx.ErrNoRows {
 		return c.JSON(fiber.Map{"status": "success", "success": true, "data": models.StockItemConfiguration{StockItemID: c.Params("stockItemId")}})
 	}
@@ -248,8 +248,15 @@ func HandleUpsertStockItemConfiguration(c *fiber.Ctx) error {
 		}
 		return false
 	}
+	if req.WarrantyMonths != nil && (*req.WarrantyMonths < 0 || *req.WarrantyMonths > 240) {
+		return fiber.NewError(400, "warrantyMonths must be between 0 and 240")
+	}
+	policy := strings.ToUpper(trimmedString(req.NegativeStockPolicy))
+	if policy != "" && !validStockPolicy(policy) {
+		return fiber.NewError(400, errStockPolicyInvalid.Error())
+	}
 	var item models.StockItemConfiguration
-	err = database.GetDB().QueryRow(context.Background(), `INSERT INTO stock_item_configurations(stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes) VALUES($1,$2,$3,$4,$5,$6,$7,$8) ON CONFLICT(stock_item_id) DO UPDATE SET track_batches=EXCLUDED.track_batches,track_expiry=EXCLUDED.track_expiry,track_unique_assets=EXCLUDED.track_unique_assets,track_reservations=EXCLUDED.track_reservations,allow_unit_conversions=EXCLUDED.allow_unit_conversions,allow_pack_breaking=EXCLUDED.allow_pack_breaking,allow_multiple_barcodes=EXCLUDED.allow_multiple_barcodes RETURNING stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes,created_at`, c.Params("stockItemId"), b(req.TrackBatches), b(req.TrackExpiry), b(req.TrackUniqueAssets), b(req.TrackReservations), b(req.AllowUnitConversions), b(req.AllowPackBreaking), b(req.AllowMultipleBarcodes)).Scan(&item.StockItemID, &item.TrackBatches, &item.TrackExpiry, &item.TrackUniqueAssets, &item.TrackReservations, &item.AllowUnitConversions, &item.AllowPackBreaking, &item.AllowMultipleBarcodes, &item.CreatedAt)
+	err = database.GetDB().QueryRow(context.Background(), `INSERT INTO stock_item_configurations(stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes,warranty_months,negative_stock_policy) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT(stock_item_id) DO UPDATE SET track_batches=EXCLUDED.track_batches,track_expiry=EXCLUDED.track_expiry,track_unique_assets=EXCLUDED.track_unique_assets,track_reservations=EXCLUDED.track_reservations,allow_unit_conversions=EXCLUDED.allow_unit_conversions,allow_pack_breaking=EXCLUDED.allow_pack_breaking,allow_multiple_barcodes=EXCLUDED.allow_multiple_barcodes,warranty_months=EXCLUDED.warranty_months,negative_stock_policy=EXCLUDED.negative_stock_policy RETURNING stock_item_id,track_batches,track_expiry,track_unique_assets,track_reservations,allow_unit_conversions,allow_pack_breaking,allow_multiple_barcodes,warranty_months,negative_stock_policy,created_at`, c.Params("stockItemId"), b(req.TrackBatches), b(req.TrackExpiry), b(req.TrackUniqueAssets), b(req.TrackReservations), b(req.AllowUnitConversions), b(req.AllowPackBreaking), b(req.AllowMultipleBarcodes), req.WarrantyMonths, nullableString(policy)).Scan(&item.StockItemID, &item.TrackBatches, &item.TrackExpiry, &item.TrackUniqueAssets, &item.TrackReservations, &item.AllowUnitConversions, &item.AllowPackBreaking, &item.AllowMultipleBarcodes, &item.WarrantyMonths, &item.NegativeStockPolicy, &item.CreatedAt)
 	if err != nil {
 		return fiber.NewError(500, "failed to save stock configuration")
 	}
@@ -283,7 +290,7 @@ func HandleListStockItemUnits(c *fiber.Ctx) error {
 	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM stock_item_units su JOIN unit_definitions u ON u.id=su.unit_id"+where, args...).Scan(&total); err != nil {
 		return fiber.NewError(500, "failed to count stock item units")
 	}
-	rows, err := db.Query(ctx, "SELECT su.id,su.stock_item_id,su.unit_id,su.conversion_to_base,su.is_base_unit,su.is_sales_unit,su.is_purchase_unit,su.allows_fractional,su.position FROM stock_item_units su JOIN unit_definitions u ON u.id=su.unit_id"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	rows, err := db.Query(ctx, "SELECT su.id,su.stock_item_id,su.unit_id,su.conversion_to_base,su.is_base_unit,su.is_sales_unit,su.is_purchase_unit,su.allows_fractional,su.is_report_unit,su.position FROM stock_item_units su JOIN unit_definitions u ON u.id=su.unit_id"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
 	if err != nil {
 		return fiber.NewError(500, "failed to list stock item units")
 	}
@@ -291,7 +298,7 @@ func HandleListStockItemUnits(c *fiber.Ctx) error {
 	items := make([]models.StockItemUnit, 0)
 	for rows.Next() {
 		var item models.StockItemUnit
-		if err := rows.Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.Position); err != nil {
+		if err := rows.Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.IsReportUnit, &item.Position); err != nil {
 			return fiber.NewError(500, "failed to read stock item unit")
 		}
 		items = append(items, item)
@@ -315,8 +322,19 @@ func HandleCreateStockItemUnit(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.UnitID) == "" || req.ConversionToBase <= 0 {
 		return fiber.NewError(400, "unitId and a positive conversionToBase are required")
 	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to create stock item unit")
+	}
+	defer tx.Rollback(ctx)
+	if req.IsReportUnit {
+		if _, err = tx.Exec(ctx, `UPDATE stock_item_units SET is_report_unit=FALSE WHERE stock_item_id=$1 AND is_report_unit`, c.Params("stockItemId")); err != nil {
+			return fiber.NewError(500, "failed to create stock item unit")
+		}
+	}
 	var item models.StockItemUnit
-	err = database.GetDB().QueryRow(context.Background(), `INSERT INTO stock_item_units(stock_item_id,unit_id,conversion_to_base,is_base_unit,is_sales_unit,is_purchase_unit,allows_fractional,position) SELECT $1,$2,$3,$4,$5,$6,$7,$8 WHERE EXISTS(SELECT 1 FROM unit_definitions WHERE id=$2) RETURNING id,stock_item_id,unit_id,conversion_to_base,is_base_unit,is_sales_unit,is_purchase_unit,allows_fractional,position`, c.Params("stockItemId"), req.UnitID, req.ConversionToBase, req.IsBaseUnit, req.IsSalesUnit, req.IsPurchaseUnit, req.AllowsFractional, req.Position).Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.Position)
+	err = tx.QueryRow(ctx, `INSERT INTO stock_item_units(stock_item_id,unit_id,conversion_to_base,is_base_unit,is_sales_unit,is_purchase_unit,allows_fractional,is_report_unit,position) SELECT $1,$2,$3,$4,$5,$6,$7,$8,$9 WHERE EXISTS(SELECT 1 FROM unit_definitions WHERE id=$2) RETURNING id,stock_item_id,unit_id,conversion_to_base,is_base_unit,is_sales_unit,is_purchase_unit,allows_fractional,is_report_unit,position`, c.Params("stockItemId"), req.UnitID, req.ConversionToBase, req.IsBaseUnit, req.IsSalesUnit, req.IsPurchaseUnit, req.AllowsFractional, req.IsReportUnit, req.Position).Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.IsReportUnit, &item.Position)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "unit definition not found")
 	}
@@ -326,6 +344,9 @@ func HandleCreateStockItemUnit(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(500, "failed to create stock item unit")
 	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to create stock item unit")
+	}
 	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
 }
 
@@ -338,8 +359,19 @@ func HandleUpdateStockItemUnit(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.UnitID) == "" || req.ConversionToBase <= 0 {
 		return fiber.NewError(400, "unitId and a positive conversionToBase are required")
 	}
+	ctx := context.Background()
+	tx, err := database.GetDB().Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to update stock item unit")
+	}
+	defer tx.Rollback(ctx)
+	if req.IsReportUnit {
+		if _, err = tx.Exec(ctx, `UPDATE stock_item_units SET is_report_unit=FALSE WHERE is_report_unit AND id<>$1 AND stock_item_id=(SELECT stock_item_id FROM stock_item_units WHERE id=$1)`, c.Params("stockUnitId")); err != nil {
+			return fiber.NewError(500, "failed to update stock item unit")
+		}
+	}
 	var item models.StockItemUnit
-	err = database.GetDB().QueryRow(context.Background(), `UPDATE stock_item_units su SET unit_id=$1,conversion_to_base=$2,is_base_unit=$3,is_sales_unit=$4,is_purchase_unit=$5,allows_fractional=$6,position=$7 WHERE su.id=$8 AND EXISTS(SELECT 1 FROM stock_items si WHERE si.id=su.stock_item_id AND si.merchant_id=$9) AND EXISTS(SELECT 1 FROM unit_definitions WHERE id=$1) RETURNING su.id,su.stock_item_id,su.unit_id,su.conversion_to_base,su.is_base_unit,su.is_sales_unit,su.is_purchase_unit,su.allows_fractional,su.position`, req.UnitID, req.ConversionToBase, req.IsBaseUnit, req.IsSalesUnit, req.IsPurchaseUnit, req.AllowsFractional, req.Position, c.Params("stockUnitId"), merchantID).Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.Position)
+	err = tx.QueryRow(ctx, `UPDATE stock_item_units su SET unit_id=$1,conversion_to_base=$2,is_base_unit=$3,is_sales_unit=$4,is_purchase_unit=$5,allows_fractional=$6,is_report_unit=$7,position=$8 WHERE su.id=$9 AND EXISTS(SELECT 1 FROM stock_items si WHERE si.id=su.stock_item_id AND si.merchant_id=$10) AND EXISTS(SELECT 1 FROM unit_definitions WHERE id=$1) RETURNING su.id,su.stock_item_id,su.unit_id,su.conversion_to_base,su.is_base_unit,su.is_sales_unit,su.is_purchase_unit,su.allows_fractional,su.is_report_unit,su.position`, req.UnitID, req.ConversionToBase, req.IsBaseUnit, req.IsSalesUnit, req.IsPurchaseUnit, req.AllowsFractional, req.IsReportUnit, req.Position, c.Params("stockUnitId"), merchantID).Scan(&item.ID, &item.StockItemID, &item.UnitID, &item.ConversionToBase, &item.IsBaseUnit, &item.IsSalesUnit, &item.IsPurchaseUnit, &item.AllowsFractional, &item.IsReportUnit, &item.Position)
 	if err == pgx.ErrNoRows {
 		return fiber.NewError(404, "stock item unit not found")
 	}
@@ -349,6 +381,9 @@ func HandleUpdateStockItemUnit(c *fiber.Ctx) error {
 		}
 		return fiber.NewError(500, "failed to update stock item unit")
 	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to update stock item unit")
+	}
 	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
 }
 
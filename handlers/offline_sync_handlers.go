@@ -41,10 +41,14 @@ type OfflineSaleData struct {
 // OfflineSaleItem represents an item in an offline sale
 type OfflineSaleItem struct {
 	ProductID           string   `json:"productId"`
-	Quantity            int      `json:"quantity"`
+	Quantity            float64  `json:"quantity"`
+	UnitID              *string  `json:"unitId,omitempty"`
 	SellingPriceAtSale  float64  `json:"sellingPriceAtSale"`
 	OriginalPriceAtSale *float64 `json:"originalPriceAtSale"`
 	DiscountAmount      *float64 `json:"discountAmount"`
+	BatchID             *string  `json:"batchId,omitempty"`
+	SerialNumbers       []string `json:"serialNumbers,omitempty"`
+	ScaleBarcode        *string  `json:"scaleBarcode,omitempty"`
 }
 
 func ptrString(value string) *string { return &value }
@@ -56,6 +60,7 @@ type SyncResult struct {
 	Status          string     `json:"status"` // "synced" or "failed"
 	Error           *string    `json:"error"`
 	ServerTimestamp *time.Time `json:"serverTimestamp"`
+	StockWarnings   []string   `json:"stockWarnings,omitempty"`
 }
 
 // BatchSyncResponse represents the response for a batch sync
@@ -211,9 +216,9 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 	}
 
 	// Validate shop ownership
-	var foundMerchantID string
-	shopCheckQuery := "SELECT merchant_id FROM shops WHERE id = $1"
-	if err := tx.QueryRow(ctx, shopCheckQuery, offlineSale.ShopID).Scan(&foundMerchantID); err != nil {
+	var foundMerchantID, locationType string
+	shopCheckQuery := "SELECT merchant_id, location_type FROM shops WHERE id = $1"
+	if err := tx.QueryRow(ctx, shopCheckQuery, offlineSale.ShopID).Scan(&foundMerchantID, &locationType); err != nil {
 		errMsg := fmt.Sprintf("Shop not found: %s", offlineSale.ShopID)
 		result.Error = &errMsg
 		log.Printf("❌ [SYNC ITEM] Shop validation failed: %v", err)
@@ -226,6 +231,10 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 		log.Printf("❌ [SYNC ITEM] Access denied for sale %s", offlineSale.ID)
 		return result
 	}
+	if locationType == locationWarehouse {
+		result.Error = ptrString(errNotSellingLocation.Error())
+		return result
+	}
 
 	// Check for duplicate sale using local_id (prevents re-syncing same sale)
 	var existingSaleID string
@@ -256,6 +265,17 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 		result.Error = &errMsg
 		return result
 	}
+	for i, item := range offlineSale.Items {
+		scale, err := checkoutScaleLine(ctx, tx, merchantID, item.ScaleBarcode)
+		if err != nil {
+			result.Error = ptrString(fmt.Sprintf("Invalid scale barcode: %v", err))
+			return result
+		}
+		if scale != nil {
+			offlineSale.Items[i].ProductID, offlineSale.Items[i].Quantity, offlineSale.Items[i].UnitID = scale.StockItemID, scale.Quantity, scale.Format.UnitID
+			offlineSale.Items[i].SellingPriceAtSale = scale.sellingPrice(item.SellingPriceAtSale)
+		}
+	}
 	seenProducts := make(map[string]struct{}, len(offlineSale.Items))
 	var calculatedTotal float64
 	for _, item := range offlineSale.Items {
@@ -270,7 +290,7 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 			return result
 		}
 		seenProducts[item.ProductID] = struct{}{}
-		calculatedTotal += float64(item.Quantity) * item.SellingPriceAtSale
+		calculatedTotal += item.Quantity * item.SellingPriceAtSale
 	}
 	if calculatedTotal < offlineSale.TotalAmount-0.01 || calculatedTotal > offlineSale.TotalAmount+0.01 {
 		errMsg := "Offline sale total does not match its items"
@@ -285,12 +305,13 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 	itemStockItemIDs := make(map[string]string)
 	itemNames := make(map[string]string)
 	itemSKUs := make(map[string]*string)
+	itemQuantities := make(map[string]unitQuantity)
 	for _, item := range offlineSale.Items {
 		var currentPrice float64
 		var inventoryID, productID, stockItemID, itemName string
 		var itemSKU *string
-		priceQuery := `SELECT ii.id,si.product_id,si.id,si.name,si.sku,COALESCE(pp.selling_price,0) FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id LEFT JOIN LATERAL(SELECT selling_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1)pp ON TRUE WHERE ii.shop_id=$1 AND (ii.stock_item_id=$2 OR ii.product_id=$2) AND ii.merchant_id=$3 AND ii.quantity_on_hand >= $4 FOR UPDATE OF ii,si`
-		if err := tx.QueryRow(ctx, priceQuery, offlineSale.ShopID, item.ProductID, merchantID, item.Quantity).Scan(&inventoryID, &productID, &stockItemID, &itemName, &itemSKU, &currentPrice); err != nil {
+		priceQuery := `SELECT ii.id,si.product_id,si.id,si.name,si.sku,COALESCE(pp.selling_price,0) FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id LEFT JOIN LATERAL(SELECT selling_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1)pp ON TRUE WHERE ii.shop_id=$1 AND (ii.stock_item_id=$2 OR ii.product_id=$2) AND ii.merchant_id=$3 FOR UPDATE OF ii,si`
+		if err := tx.QueryRow(ctx, priceQuery, offlineSale.ShopID, item.ProductID, merchantID).Scan(&inventoryID, &productID, &stockItemID, &itemName, &itemSKU, &currentPrice); err != nil {
 			if isNoRows(err) {
 				errMsg := fmt.Sprintf("Item not found or unavailable: %s", item.ProductID)
 				result.Error = &errMsg
@@ -308,6 +329,12 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 		itemStockItemIDs[item.ProductID] = stockItemID
 		itemNames[item.ProductID] = itemName
 		itemSKUs[item.ProductID] = itemSKU
+		qty, err := resolveUnitQuantity(ctx, tx, stockItemID, item.UnitID, item.Quantity)
+		if err != nil {
+			result.Error = ptrString(fmt.Sprintf("Invalid unit for item %s: %v", item.ProductID, err))
+			return result
+		}
+		itemQuantities[item.ProductID] = qty
 	}
 
 	// Create sale
@@ -337,17 +364,19 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 	}
 
 	// Create sale items
+	saleItemIDs := make(map[string]string, len(offlineSale.Items))
 	for _, item := range offlineSale.Items {
 		itemID := generateUUID()
+		saleItemIDs[item.ProductID] = itemID
 		createItemQuery := `
-			INSERT INTO sale_items (id, sale_id, inventory_item_id, product_id, stock_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			INSERT INTO sale_items (id, sale_id, inventory_item_id, product_id, stock_item_id, unit_id, item_name, item_sku, quantity_sold, base_quantity, selling_price_at_sale, original_price_at_sale, subtotal, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		`
 
-		subtotal := float64(item.Quantity) * item.SellingPriceAtSale
+		subtotal := item.Quantity * item.SellingPriceAtSale
 
 		if _, err := tx.Exec(ctx, createItemQuery,
-			itemID, saleID, itemInventoryIDs[item.ProductID], itemProductIDs[item.ProductID], itemStockItemIDs[item.ProductID], itemNames[item.ProductID], itemSKUs[item.ProductID], item.Quantity,
+			itemID, saleID, itemInventoryIDs[item.ProductID], itemProductIDs[item.ProductID], itemStockItemIDs[item.ProductID], itemQuantities[item.ProductID].UnitID, itemNames[item.ProductID], itemSKUs[item.ProductID], item.Quantity, itemQuantities[item.ProductID].BaseQuantity,
 			item.SellingPriceAtSale, item.OriginalPriceAtSale, subtotal, now, now,
 		); err != nil {
 			errMsg := fmt.Sprintf("Failed to create sale item: %v", err)
@@ -359,23 +388,59 @@ func processSaleSyncWithDB(ctx context.Context, tx DBTx, merchantID string, offl
 
 	// Update inventory (deduct quantities)
 	for _, item := range offlineSale.Items {
-		updateStockQuery := `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=$2 WHERE id=$3 AND merchant_id=$4 AND quantity_on_hand >= $1`
-
-		updateResult, err := tx.Exec(ctx, updateStockQuery,
-			item.Quantity, now, itemInventoryIDs[item.ProductID], merchantID,
-		)
+		qty := itemQuantities[item.ProductID]
+		kit, err := loadStockItemKit(ctx, tx, itemStockItemIDs[item.ProductID])
+		if err == nil && kit != nil {
+			var kitCost float64
+			var kitWarnings []string
+			kitCost, kitWarnings, err = sellKitComponents(ctx, tx, kit, merchantID, offlineSale.ShopID, saleID, saleItemIDs[item.ProductID], "OFFLINE_SALE", qty.BaseQuantity)
+			if err == nil {
+				err = recordSaleItemCost(ctx, tx, saleItemIDs[item.ProductID], qty.BaseQuantity, kitCost)
+			}
+			if err == nil {
+				result.StockWarnings = append(result.StockWarnings, kitWarnings...)
+				continue
+			}
+		}
+		if err != nil {
+			result.Error = ptrString(fmt.Sprintf("Failed to deduct kit components for item %s: %v", item.ProductID, err))
+			return result
+		}
+		deduction, err := deductSaleStock(ctx, tx, itemInventoryIDs[item.ProductID], qty.BaseQuantity, "OFFLINE_SALE", saleID)
+		if err == errInsufficientStock {
+			errMsg := fmt.Sprintf("Insufficient stock for item: %s", item.ProductID)
+			result.Error = &errMsg
+			return result
+		}
 		if err != nil {
 			errMsg := fmt.Sprintf("Failed to update inventory: %v", err)
 			result.Error = &errMsg
 			log.Printf("❌ [SYNC ITEM] Inventory update failed: %v", err)
 			return result
 		}
-		if updateResult != 1 {
-			errMsg := fmt.Sprintf("Insufficient stock for item: %s", item.ProductID)
-			result.Error = &errMsg
+		if warning := deduction.warning("Product " + item.ProductID); warning != "" {
+			result.StockWarnings = append(result.StockWarnings, warning)
+		}
+		batches, err := consumeInventoryBatches(ctx, tx, itemStockItemIDs[item.ProductID], itemInventoryIDs[item.ProductID], deduction.batched(qty.BaseQuantity), selectedBatchID(item.BatchID))
+		if err == nil {
+			err = recordSaleItemBatches(ctx, tx, saleItemIDs[item.ProductID], batches)
+		}
+		if err == nil {
+			_, err = sellInventorySerials(ctx, tx, itemStockItemIDs[item.ProductID], itemInventoryIDs[item.ProductID], offlineSale.ShopID, saleID, merchantID, qty.BaseQuantity, item.SerialNumbers)
+		}
+		if err != nil {
+			result.Error = ptrString(fmt.Sprintf("Failed to allocate batches or serials for item %s: %v", item.ProductID, err))
+			return result
+		}
+		unitCost, err := issueInventoryCost(ctx, tx, merchantID, itemInventoryIDs[item.ProductID], qty.BaseQuantity, batches)
+		if err == nil {
+			err = recordSaleItemCost(ctx, tx, saleItemIDs[item.ProductID], qty.BaseQuantity, saleLineCost(qty.BaseQuantity, unitCost))
+		}
+		if err != nil {
+			result.Error = ptrString(fmt.Sprintf("Failed to cost item %s: %v", item.ProductID, err))
 			return result
 		}
-		if _, err := tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,'OUT',$6,$6,'OFFLINE_SALE',$7,$8,'Offline sale sync')`, merchantID, offlineSale.ShopID, itemInventoryIDs[item.ProductID], itemProductIDs[item.ProductID], itemStockItemIDs[item.ProductID], item.Quantity, saleID, fmt.Sprintf("%s:%s", offlineSale.ID, item.ProductID)); err != nil {
+		if _, err := tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,$6,'OUT',$7,$8,$9,'OFFLINE_SALE',$10,$11,'Offline sale sync')`, merchantID, offlineSale.ShopID, itemInventoryIDs[item.ProductID], itemProductIDs[item.ProductID], itemStockItemIDs[item.ProductID], qty.UnitID, item.Quantity, qty.BaseQuantity, unitCost, saleID, fmt.Sprintf("%s:%s", offlineSale.ID, item.ProductID)); err != nil {
 			result.Error = ptrString(fmt.Sprintf("Failed to record inventory movement: %v", err))
 			return result
 		}
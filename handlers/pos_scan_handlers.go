@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// scanCodeCandidates normalises a scanned code into the forms it may be stored under:
+// scanner symbology prefixes (]E0, ]C1, ...) and whitespace are dropped, letters are
+// upper-cased, and UPC-A/EAN-13 codes are also tried in the other length.
+func scanCodeCandidates(raw string) []string {
+	code := strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+	if len(code) > 3 && code[0] == ']' {
+		code = code[3:]
+	}
+	code = strings.ToUpper(code)
+	if code == "" {
+		return nil
+	}
+	candidates := []string{code}
+	if isDigits(code) {
+		if len(code) == 12 {
+			if _, err := normalizeEAN13("0" + code); err == nil {
+				candidates = append(candidates, "0"+code)
+			}
+		}
+		if len(code) == 13 && code[0] == '0' {
+			candidates = append(candidates, code[1:])
+		}
+	}
+	return candidates
+}
+
+// assessScanLine decides whether a resolved line can go straight into the cart and
+// explains why not.
+func assessScanLine(line *models.ScanResolution, today time.Time) {
+	line.Warnings = []string{}
+	if line.InventoryItemID == nil {
+		line.Warnings = append(line.Warnings, "item is not stocked in this shop")
+	} else if line.AvailableQuantity <= 0 {
+		line.Warnings = append(line.Warnings, "no available stock in this shop")
+	} else if line.Quantity != nil && line.AvailableQuantity < *line.Quantity*line.UnitFactor {
+		line.Warnings = append(line.Warnings, "less than the weighed quantity is available")
+	} else if line.AvailableQuantity < line.UnitFactor {
+		line.Warnings = append(line.Warnings, "less than one scanned unit is available")
+	}
+	if b := line.Batch; b != nil {
+		switch {
+		case !b.InShop:
+			line.Warnings = append(line.Warnings, "batch belongs to another shop")
+		case b.ExpiryDate != nil && b.ExpiryDate.Before(today):
+			line.Warnings = append(line.Warnings, "batch is expired")
+		case b.QuantityRemaining <= 0:
+			line.Warnings = append(line.Warnings, "batch is empty")
+		}
+	}
+	if a := line.Asset; a != nil {
+		if !a.InShop {
+			line.Warnings = append(line.Warnings, "asset belongs to another shop")
+		} else if a.Status != "AVAILABLE" {
+			line.Warnings = append(line.Warnings, "asset is "+strings.ToLower(a.Status))
+		}
+	}
+	if sr := line.Serial; sr != nil {
+		if !sr.InShop {
+			line.Warnings = append(line.Warnings, "serial belongs to another shop")
+		} else if sr.Status != "AVAILABLE" && sr.Status != "RETURNED" {
+			line.Warnings = append(line.Warnings, "serial is "+strings.ToLower(sr.Status))
+		}
+	}
+	line.Sellable = len(line.Warnings) == 0
+}
+
+// scanResolveQuery gathers every identifier source that matches one of the candidate
+// codes ($3) and joins the stock item, shop balance ($2), unit and retail price.
+// A decoded scale label ($4 stock item, $5 unit) wins, then registry codes, variant
+// barcodes, SKUs, batch codes, serials and assets.
+var scanResolveQuery = `WITH codes AS (SELECT unnest($3::text[]) AS code),
+hits AS (
+	SELECT 0 AS priority, 'SCALE_PLU' AS source, $4::uuid AS stock_item_id, $5::uuid AS unit_id,
+		NULL::uuid AS batch_id, NULL::uuid AS asset_id, NULL::uuid AS serial_id
+	WHERE $4::uuid IS NOT NULL
+	UNION ALL
+	SELECT 1, 'BARCODE_'||b.owner_type,
+		CASE b.owner_type
+			WHEN 'STOCK_ITEM' THEN b.owner_id
+			WHEN 'UNIT' THEN NULLIF(b.metadata->>'stockItemId','')::uuid
+			WHEN 'PRODUCT' THEN (SELECT s.id FROM stock_items s WHERE s.product_id=b.owner_id AND s.variant_id IS NULL AND s.merchant_id=b.merchant_id ORDER BY s.created_at LIMIT 1)
+			WHEN 'VARIANT' THEN (SELECT s.id FROM stock_items s WHERE s.variant_id=b.owner_id AND s.merchant_id=b.merchant_id ORDER BY s.created_at LIMIT 1)
+			WHEN 'BATCH' THEN (SELECT ib.stock_item_id FROM inventory_batches ib WHERE ib.id=b.owner_id)
+			WHEN 'ASSET' THEN (SELECT ii.stock_item_id FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id WHERE a.id=b.owner_id)
+		END,
+		CASE b.owner_type WHEN 'UNIT' THEN b.owner_id WHEN 'STOCK_ITEM' THEN NULLIF(b.metadata->>'unitId','')::uuid END,
+		CASE WHEN b.owner_type='BATCH' THEN b.owner_id END,
+		CASE WHEN b.owner_type='ASSET' THEN b.owner_id END,
+		NULL::uuid
+	FROM barcode_registry b JOIN codes ON b.normalized_code=codes.code
+	WHERE b.merchant_id=$1 AND b.is_active
+	UNION ALL
+	SELECT 2, 'VARIANT_BARCODE', s.id, NULL, NULL, NULL, NULL
+	FROM product_variants v JOIN stock_items s ON s.variant_id=v.id JOIN codes ON UPPER(v.barcode)=codes.code
+	WHERE v.merchant_id=$1 AND v.deleted_at IS NULL AND v.is_active
+	UNION ALL
+	SELECT 3, 'SKU', s.id, NULL, NULL, NULL, NULL
+	FROM stock_items s JOIN codes ON UPPER(s.sku)=codes.code WHERE s.merchant_id=$1
+	UNION ALL
+	SELECT 4, 'BATCH', ib.stock_item_id, NULL, ib.id, NULL, NULL
+	FROM inventory_batches ib JOIN codes ON UPPER(ib.batch_code)=codes.code WHERE ib.merchant_id=$1 AND ib.shop_id=$2
+	UNION ALL
+	SELECT 5, 'SERIAL', sr.stock_item_id, NULL, NULL, NULL, sr.id
+	FROM inventory_serials sr JOIN codes ON UPPER(sr.serial_number)=codes.code WHERE sr.merchant_id=$1
+	UNION ALL
+	SELECT 6, 'ASSET_TAG', ii.stock_item_id, NULL, a.batch_id, a.id, NULL
+	FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id JOIN codes ON UPPER(a.asset_tag)=codes.code WHERE a.merchant_id=$1
+	UNION ALL
+	SELECT 7, 'ASSET_IDENTIFIER', ii.stock_item_id, NULL, a.batch_id, a.id, NULL
+	FROM inventory_asset_identifiers ai JOIN inventory_assets a ON a.id=ai.asset_id JOIN inventory_items ii ON ii.id=a.inventory_item_id JOIN codes ON ai.normalized_value=codes.code
+	WHERE a.merchant_id=$1
+)
+SELECT h.source, si.id, si.name, si.sku, si.tracking_mode, ii.id,
+	COALESCE(stock.available,0),
+	COALESCE(h.unit_id,si.base_unit_id), u.code, COALESCE(su.conversion_to_base,1)::float8,
+	COALESCE(pp.selling_price,0)::float8,
+	ib.id, ib.batch_code, ib.expiry_date, COALESCE(ib.quantity_remaining,0)::float8, COALESCE(ib.shop_id=$2,FALSE),
+	a.id, a.asset_tag, a.status, COALESCE(a.shop_id=$2,FALSE),
+	sr.serial_number, sr.status, COALESCE(sr.shop_id=$2,FALSE)
+FROM hits h
+JOIN stock_items si ON si.id=h.stock_item_id AND si.merchant_id=$1
+LEFT JOIN inventory_items ii ON ii.stock_item_id=si.id AND ii.shop_id=$2
+` + availableStockJoin + `
+LEFT JOIN unit_definitions u ON u.id=COALESCE(h.unit_id,si.base_unit_id)
+LEFT JOIN stock_item_units su ON su.stock_item_id=si.id AND su.unit_id=COALESCE(h.unit_id,si.base_unit_id)
+LEFT JOIN LATERAL (SELECT selling_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1) pp ON TRUE
+LEFT JOIN inventory_batches ib ON ib.id=h.batch_id
+LEFT JOIN inventory_assets a ON a.id=h.asset_id
+LEFT JOIN inventory_serials sr ON sr.id=h.serial_id
+ORDER BY h.priority, si.name`
+
+// HandleResolveShopScan resolves a scanned code to the sellable line for a shop.
+// The best match is returned as data; any other matches follow in alternatives.
+// Scale labels also carry the weighed quantity or the printed line price.
+func HandleResolveShopScan(c *fiber.Ctx) error {
+	db := database.GetDB()
+	shopID, merchantID, err := resolveShopPOSScope(c, db, c.Params("shopId"))
+	if err != nil {
+		return err
+	}
+	candidates := scanCodeCandidates(c.Query("code"))
+	if len(candidates) == 0 {
+		return fiber.NewError(400, "code is required")
+	}
+	ctx := context.Background()
+	scale, err := resolveScaleBarcode(ctx, pgxPoolAdapter{pool: db}, merchantID, candidates[0])
+	if err != nil && err != errScaleBarcodeUnknown {
+		if isScaleBarcodeError(err) {
+			return fiber.NewError(422, err.Error())
+		}
+		return fiber.NewError(500, "failed to resolve scale barcode")
+	}
+	var scaleItemID, scaleUnitID *string
+	if scale != nil {
+		scaleItemID, scaleUnitID = &scale.StockItemID, scale.Format.UnitID
+	}
+	rows, err := db.Query(ctx, scanResolveQuery, merchantID, shopID, candidates, scaleItemID, scaleUnitID)
+	if err != nil {
+		return fiber.NewError(500, "failed to resolve scanned code")
+	}
+	defer rows.Close()
+	today := time.Now().Truncate(24 * time.Hour)
+	lines := make([]models.ScanResolution, 0)
+	seen := map[string]bool{}
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	for rows.Next() {
+		line := models.ScanResolution{Code: candidates[0]}
+		var batchID, batchCode, assetID, assetTag, assetStatus, serial, serialStatus *string
+		var expiry *time.Time
+		var batchRemaining float64
+		var batchInShop, assetInShop, serialInShop bool
+		if err := rows.Scan(&line.Source, &line.StockItemID, &line.Name, &line.SKU, &line.TrackingMode, &line.InventoryItemID,
+			&line.AvailableQuantity, &line.UnitID, &line.UnitCode, &line.UnitFactor, &line.Price,
+			&batchID, &batchCode, &expiry, &batchRemaining, &batchInShop,
+			&assetID, &assetTag, &assetStatus, &assetInShop,
+			&serial, &serialStatus, &serialInShop); err != nil {
+			return fiber.NewError(500, "failed to read scan match")
+		}
+		key := line.StockItemID + "|" + value(line.UnitID) + "|" + value(batchID) + "|" + value(assetID) + "|" + value(serial)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if line.UnitFactor <= 0 {
+			line.UnitFactor = 1
+		}
+		line.Price = math.Round(line.Price*line.UnitFactor*100) / 100
+		line.AvailableInUnit = reportQuantity(line.AvailableQuantity, line.UnitFactor)
+		if batchID != nil {
+			line.Batch = &models.ScanBatch{ID: *batchID, BatchCode: value(batchCode), ExpiryDate: expiry, QuantityRemaining: batchRemaining, InShop: batchInShop}
+		}
+		if assetID != nil {
+			line.Asset = &models.ScanAsset{ID: *assetID, AssetTag: value(assetTag), Status: value(assetStatus), InShop: assetInShop}
+		}
+		if serial != nil {
+			line.Serial = &models.ScanSerial{SerialNumber: *serial, Status: value(serialStatus), InShop: serialInShop}
+		}
+		if line.Source == "SCALE_PLU" {
+			line.Scale = &models.ScanScale{FormatID: scale.Format.ID, PLUCode: scale.PLU, ValueMode: scale.Format.ValueMode, Value: scale.Value}
+			line.Quantity, line.LinePrice = &scale.Quantity, scale.LinePrice
+		}
+		line.RequiresBatch = line.Batch != nil
+		line.RequiresSerial = line.TrackingMode == "SERIAL"
+		assessScanLine(&line, today)
+		lines = append(lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return fiber.NewError(500, "failed to resolve scanned code")
+	}
+	if len(lines) == 0 {
+		return fiber.NewError(404, "no item matches this code")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": lines[0], "alternatives": lines[1:]})
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"app/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestScanCodeCandidates(t *testing.T) {
+	cases := map[string][]string{
+		" abc-12 \n":       {"ABC-12"},
+		"]E04006381333931": {"4006381333931"},
+		"036000291452":     {"036000291452", "0036000291452"},
+		"0036000291452":    {"0036000291452", "036000291452"},
+		"   ":              nil,
+	}
+	for raw, want := range cases {
+		if got := scanCodeCandidates(raw); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: expected %v, got %v", raw, want, got)
+		}
+	}
+}
+
+func TestAssessScanLine(t *testing.T) {
+	today := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	inv := "inv-1"
+	line := models.ScanResolution{InventoryItemID: &inv, AvailableQuantity: 24, UnitFactor: 12}
+	assessScanLine(&line, today)
+	if !line.Sellable || len(line.Warnings) != 0 {
+		t.Fatalf("expected sellable line, got %v", line.Warnings)
+	}
+
+	line = models.ScanResolution{InventoryItemID: &inv, AvailableQuantity: 6, UnitFactor: 12}
+	assessScanLine(&line, today)
+	if line.Sellable {
+		t.Fatalf("expected pack larger than available stock to be unsellable")
+	}
+
+	expired := today.AddDate(0, 0, -1)
+	line = models.ScanResolution{InventoryItemID: &inv, AvailableQuantity: 5, UnitFactor: 1,
+		Batch:  &models.ScanBatch{InShop: true, ExpiryDate: &expired, QuantityRemaining: 5},
+		Serial: &models.ScanSerial{Status: "RETURNED", InShop: true}}
+	assessScanLine(&line, today)
+	if line.Sellable || len(line.Warnings) != 1 || line.Warnings[0] != "batch is expired" {
+		t.Fatalf("expected only the expired batch warning, got %v", line.Warnings)
+	}
+
+	line = models.ScanResolution{UnitFactor: 1, Asset: &models.ScanAsset{Status: "SOLD", InShop: true}}
+	assessScanLine(&line, today)
+	if len(line.Warnings) != 2 {
+		t.Fatalf("expected not stocked and sold asset warnings, got %v", line.Warnings)
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"app/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A4 in points, which is what suppliers print purchase orders on.
+const (
+	purchaseOrderPageWidth  = 595.28
+	purchaseOrderPageHeight = 841.89
+	purchaseOrderMargin     = 40.0
+	purchaseOrderRowHeight  = 14.0
+)
+
+// purchaseOrderParty is a name with the address lines printed under it.
+type purchaseOrderParty struct {
+	Name  string
+	Lines []string
+}
+
+type purchaseOrderDocumentLine struct {
+	Description string
+	SupplierSKU string
+	Quantity    float64
+	Unit        string
+	UnitCost    float64
+	TotalCost   float64
+}
+
+// purchaseOrderDocument is everything printed on a purchase order, already resolved
+// from the merchant's letterhead, the supplier and the delivering shop.
+type purchaseOrderDocument struct {
+	Number     string
+	Status     string
+	Date       time.Time
+	Letterhead purchaseOrderParty
+	Supplier   purchaseOrderParty
+	DeliverTo  purchaseOrderParty
+	Lines      []purchaseOrderDocumentLine
+	Subtotal   float64
+	Tax        float64
+	Total      float64
+}
+
+// purchaseOrderNumber is the short reference printed on an order and quoted by suppliers.
+func purchaseOrderNumber(orderID string) string {
+	id := strings.ReplaceAll(orderID, "-", "")
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return "PO-" + strings.ToUpper(id)
+}
+
+// partyLines drops empty values and splits multi-line addresses.
+func partyLines(values ...string) []string {
+	lines := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// purchaseOrderColumn is a table column starting at X; numeric columns are right
+// aligned to X+Width.
+type purchaseOrderColumn struct {
+	Title    string
+	X, Width float64
+	Right    bool
+}
+
+var purchaseOrderColumns = []purchaseOrderColumn{
+	{Title: "Item", X: purchaseOrderMargin, Width: 195},
+	{Title: "Supplier SKU", X: 240, Width: 85},
+	{Title: "Qty", X: 325, Width: 50, Right: true},
+	{Title: "Unit", X: 385, Width: 40},
+	{Title: "Unit cost", X: 425, Width: 60, Right: true},
+	{Title: "Total", X: 485, Width: 70, Right: true},
+}
+
+// renderPurchaseOrderPDF lays the order out on A4 pages: letterhead and order details,
+// supplier and delivery addresses, then the lines, continuing onto further pages with
+// the table header repeated, and the totals after the last line.
+func renderPurchaseOrderPDF(doc purchaseOrderDocument) []byte {
+	var pages []*strings.Builder
+	text := func(page *strings.Builder, font string, size, x, y float64, value string) {
+		fmt.Fprintf(page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfText(value))
+	}
+	right := func(page *strings.Builder, font string, size, x, y float64, value string) {
+		text(page, font, size, x-float64(len([]rune(value)))*size*0.5, y, value)
+	}
+	cell := func(page *strings.Builder, col purchaseOrderColumn, font string, y float64, value string) {
+		value = fitLabelText(value, col.Width, 9)
+		if col.Right {
+			right(page, font, 9, col.X+col.Width, y, value)
+			return
+		}
+		text(page, font, 9, col.X, y, value)
+	}
+	party := func(page *strings.Builder, title string, p purchaseOrderParty, x, y float64) float64 {
+		text(page, "F2", 9, x, y, title)
+		y -= 13
+		text(page, "F2", 10, x, y, fitLabelText(p.Name, 240, 10))
+		for _, line := range p.Lines {
+			y -= 12
+			text(page, "F1", 9, x, y, fitLabelText(line, 240, 9))
+		}
+		return y
+	}
+	tableHeader := func(page *strings.Builder, y float64) float64 {
+		for _, col := range purchaseOrderColumns {
+			cell(page, col, "F2", y, col.Title)
+		}
+		fmt.Fprintf(page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", purchaseOrderMargin, y-4, purchaseOrderPageWidth-purchaseOrderMargin, y-4)
+		return y - 4 - purchaseOrderRowHeight
+	}
+	newPage := func() (*strings.Builder, float64) {
+		page := &strings.Builder{}
+		pages = append(pages, page)
+		top := purchaseOrderPageHeight - purchaseOrderMargin
+		if len(pages) > 1 {
+			text(page, "F2", 10, purchaseOrderMargin, top-10, fitLabelText(doc.Letterhead.Name, 300, 10))
+			right(page, "F2", 10, purchaseOrderPageWidth-purchaseOrderMargin, top-10, "Purchase order "+doc.Number)
+			return page, tableHeader(page, top-40)
+		}
+		text(page, "F2", 16, purchaseOrderMargin, top-16, fitLabelText(doc.Letterhead.Name, 300, 16))
+		y := top - 16
+		for _, line := range doc.Letterhead.Lines {
+			y -= 12
+			text(page, "F1", 9, purchaseOrderMargin, y, fitLabelText(line, 300, 9))
+		}
+		edge := purchaseOrderPageWidth - purchaseOrderMargin
+		right(page, "F2", 16, edge, top-16, "PURCHASE ORDER")
+		right(page, "F1", 10, edge, top-34, "Number: "+doc.Number)
+		right(page, "F1", 10, edge, top-48, "Date: "+doc.Date.Format("2006-01-02"))
+		right(page, "F1", 10, edge, top-62, "Status: "+doc.Status)
+		if top-62 < y {
+			y = top - 62
+		}
+		y -= 30
+		low := party(page, "SUPPLIER", doc.Supplier, purchaseOrderMargin, y)
+		if deliver := party(page, "DELIVER TO", doc.DeliverTo, 310, y); deliver < low {
+			low = deliver
+		}
+		return page, tableHeader(page, low-30)
+	}
+
+	page, y := newPage()
+	bottom := purchaseOrderMargin + 20
+	for _, line := range doc.Lines {
+		if y < bottom {
+			page, y = newPage()
+		}
+		values := []string{line.Description, line.SupplierSKU, strconv.FormatFloat(line.Quantity, 'f', -1, 64), line.Unit, utils.FormatCurrency(line.UnitCost), utils.FormatCurrency(line.TotalCost)}
+		for i, col := range purchaseOrderColumns {
+			cell(page, col, "F1", y, values[i])
+		}
+		y -= purchaseOrderRowHeight
+	}
+	if y-3*purchaseOrderRowHeight < bottom {
+		page, y = newPage()
+	}
+	fmt.Fprintf(page, "0.5 w %.2f %.2f m %.2f %.2f l S\n", 385.0, y+purchaseOrderRowHeight-4, purchaseOrderPageWidth-purchaseOrderMargin, y+purchaseOrderRowHeight-4)
+	for _, total := range []struct {
+		label string
+		value float64
+		font  string
+	}{{"Subtotal", doc.Subtotal, "F1"}, {"Tax", doc.Tax, "F1"}, {"Total", doc.Total, "F2"}} {
+		text(page, total.font, 10, 385, y-2, total.label)
+		right(page, total.font, 10, purchaseOrderPageWidth-purchaseOrderMargin, y-2, utils.FormatCurrency(total.value))
+		y -= purchaseOrderRowHeight
+	}
+
+	contents := make([]string, len(pages))
+	for i, page := range pages {
+		right(page, "F1", 8, purchaseOrderPageWidth-purchaseOrderMargin, purchaseOrderMargin-16, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+		contents[i] = page.String()
+	}
+	return buildPDF(contents, purchaseOrderPageWidth, purchaseOrderPageHeight)
+}
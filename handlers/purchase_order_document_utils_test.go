@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestPurchaseOrderNumber(t *testing.T) {
+	if got := purchaseOrderNumber("3f2a9c1e-7b4d-4e2a-9f00-aabbccddeeff"); got != "PO-3F2A9C1E" {
+		t.Fatalf("unexpected number %q", got)
+	}
+	if got := purchaseOrderNumber("ab"); got != "PO-AB" {
+		t.Fatalf("unexpected number %q", got)
+	}
+}
+
+func TestPartyLinesSplitsAndSkipsBlanks(t *testing.T) {
+	lines := partyLines("12 Market St\n\n Springfield ", "", "Tel: 555")
+	if len(lines) != 3 || lines[1] != "Springfield" || lines[2] != "Tel: 555" {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+}
+
+func TestRenderPurchaseOrderPDF(t *testing.T) {
+	doc := purchaseOrderDocument{
+		Number: "PO-1234ABCD", Status: "APPROVED", Date: time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC),
+		Letterhead: purchaseOrderParty{Name: "Corner (Deli)", Lines: []string{"1 High St"}},
+		Supplier:   purchaseOrderParty{Name: "Acme Foods"},
+		DeliverTo:  purchaseOrderParty{Name: "Main shop", Lines: []string{"2 Low Rd"}},
+		Lines:      []purchaseOrderDocumentLine{{Description: "Flour", SupplierSKU: "FL-25", Quantity: 2.5, Unit: "kg", UnitCost: 3, TotalCost: 7.5}},
+		Subtotal:   7.5, Total: 7.5,
+	}
+	pdf := renderPurchaseOrderPDF(doc)
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("not a complete PDF")
+	}
+	for _, want := range []string{"(Corner \\(Deli\\))", "(Number: PO-1234ABCD)", "(Flour)", "(FL-25)", "(2.5)", "(2 Low Rd)", "(Page 1 of 1)", "/Count 1"} {
+		if !bytes.Contains(pdf, []byte(want)) {
+			t.Fatalf("missing %s", want)
+		}
+	}
+
+	for i := 0; i < 120; i++ {
+		doc.Lines = append(doc.Lines, purchaseOrderDocumentLine{Description: fmt.Sprintf("Item %d", i), Quantity: 1})
+	}
+	pdf = renderPurchaseOrderPDF(doc)
+	if !bytes.Contains(pdf, []byte("/Count 3")) || !bytes.Contains(pdf, []byte("(Page 3 of 3)")) || !bytes.Contains(pdf, []byte("(Item 119)")) {
+		t.Fatal("long orders should continue over further pages")
+	}
+}
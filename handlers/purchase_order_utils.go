@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+var (
+	errPurchaseOrderTransition = errors.New("purchase order cannot move between these statuses")
+	errPurchaseOrderNotDraft   = errors.New("only draft purchase orders can be edited")
+	errPurchaseOrderNotOpen    = errors.New("purchase order must be approved and not fully received or cancelled to receive goods")
+	errReceiptNotOnOrder       = errors.New("receipt item is not on this purchase order")
+	errOverReceipt             = errors.New("receipt exceeds the outstanding quantity beyond the over-receipt tolerance")
+)
+
+// purchaseOrderTransitions lists the statuses a purchase order may move to from each
+// status. Receipts move an order to PARTIALLY_RECEIVED or RECEIVED; cancelling a
+// partially received order closes its outstanding quantities.
+var purchaseOrderTransitions = map[string][]string{
+	"DRAFT":              {"APPROVED", "CANCELLED"},
+	"APPROVED":           {"PARTIALLY_RECEIVED", "RECEIVED", "CANCELLED"},
+	"PARTIALLY_RECEIVED": {"PARTIALLY_RECEIVED", "RECEIVED", "CANCELLED"},
+	"RECEIVED":           {},
+	"CANCELLED":          {},
+}
+
+func validatePurchaseOrderTransition(from, to string) error {
+	for _, next := range purchaseOrderTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return errPurchaseOrderTransition
+}
+
+// receiptWithinTolerance reports whether incoming units fit on a line with ordered and
+// already received units, allowing tolerancePercent over the ordered quantity.
+func receiptWithinTolerance(ordered, received, incoming, tolerancePercent float64) bool {
+	limit := ordered * (1 + tolerancePercent/100)
+	return roundBaseQuantity(received+incoming) <= roundBaseQuantity(limit)
+}
+
+// purchaseOrderReceiptStatus is the status an order takes after a receipt.
+func purchaseOrderReceiptStatus(complete bool) string {
+	if complete {
+		return "RECEIVED"
+	}
+	return "PARTIALLY_RECEIVED"
+}
+
+// overReceiptTolerance returns the merchant's over-receipt tolerance in percent.
+func overReceiptTolerance(ctx context.Context, tx DBTx, merchantID string) (float64, error) {
+	var tolerance float64
+	err := tx.QueryRow(ctx, `SELECT COALESCE((SELECT over_receipt_tolerance_percent::float8 FROM merchant_purchasing_settings WHERE merchant_id=$1),0)`, merchantID).Scan(&tolerance)
+	return tolerance, err
+}
+
+// purchaseOrderLine is the part of an order line a receipt is checked against, in base units.
+type purchaseOrderLine struct {
+	ID            string
+	Quantity      float64
+	OrderedBase   float64
+	ReceivedBase  float64
+	OrderUnitRate float64 // ordered units per base unit
+}
+
+// lockPurchaseOrderLine finds the order line a receipt item books against: the given line,
+// or else the first line for the stock item (or its product, on lines ordered without a
+// stock item) that still has quantity outstanding.
+func lockPurchaseOrderLine(ctx context.Context, tx DBTx, orderID, lineID, stockItemID, productID string) (purchaseOrderLine, error) {
+	var line purchaseOrderLine
+	var received float64
+	err := tx.QueryRow(ctx, `SELECT id,quantity::float8,COALESCE(base_quantity,quantity)::float8,received_quantity::float8 FROM purchase_order_items
+		WHERE purchase_order_id=$1 AND (id::text=$2 OR ($2='' AND (stock_item_id::text=$3 OR (stock_item_id IS NULL AND product_id::text=$4))))
+		ORDER BY received_quantity>=quantity,id LIMIT 1 FOR UPDATE`, orderID, lineID, stockItemID, productID).Scan(&line.ID, &line.Quantity, &line.OrderedBase, &received)
+	if isNoRows(err) {
+		return line, errReceiptNotOnOrder
+	}
+	if err != nil {
+		return line, err
+	}
+	line.OrderUnitRate = line.Quantity / line.OrderedBase
+	line.ReceivedBase = roundBaseQuantity(received / line.OrderUnitRate)
+	return line, nil
+}
+
+// receivePurchaseOrderLine books base units against a locked line, refusing receipts
+// beyond the tolerance.
+func receivePurchaseOrderLine(ctx context.Context, tx DBTx, line purchaseOrderLine, baseQuantity, tolerancePercent float64) error {
+	if !receiptWithinTolerance(line.OrderedBase, line.ReceivedBase, baseQuantity, tolerancePercent) {
+		return errOverReceipt
+	}
+	_, err := tx.Exec(ctx, `UPDATE purchase_order_items SET received_quantity=received_quantity+$1 WHERE id=$2`, math.Round(baseQuantity*line.OrderUnitRate*1000)/1000, line.ID)
+	return err
+}
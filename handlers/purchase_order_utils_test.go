@@ -0,0 +1,54 @@
+package handlers
+
+import "testing"
+
+func TestValidatePurchaseOrderTransition(t *testing.T) {
+	cases := []struct {
+		from, to string
+		wantErr  error
+	}{
+		{"DRAFT", "APPROVED", nil},
+		{"DRAFT", "CANCELLED", nil},
+		{"DRAFT", "RECEIVED", errPurchaseOrderTransition},
+		{"APPROVED", "PARTIALLY_RECEIVED", nil},
+		{"APPROVED", "DRAFT", errPurchaseOrderTransition},
+		{"PARTIALLY_RECEIVED", "RECEIVED", nil},
+		{"PARTIALLY_RECEIVED", "CANCELLED", nil},
+		{"RECEIVED", "CANCELLED", errPurchaseOrderTransition},
+		{"CANCELLED", "APPROVED", errPurchaseOrderTransition},
+		{"UNKNOWN", "APPROVED", errPurchaseOrderTransition},
+	}
+	for _, tc := range cases {
+		if err := validatePurchaseOrderTransition(tc.from, tc.to); err != tc.wantErr {
+			t.Fatalf("%s -> %s: expected %v, got %v", tc.from, tc.to, tc.wantErr, err)
+		}
+	}
+}
+
+func TestReceiptWithinTolerance(t *testing.T) {
+	cases := []struct {
+		ordered, received, incoming, tolerance float64
+		want                                   bool
+	}{
+		{10, 0, 10, 0, true},
+		{10, 0, 10.5, 0, false},
+		{10, 4, 6, 0, true},
+		{10, 4, 6.5, 5, true},
+		{10, 4, 7.5, 5, false},
+		{3, 0, 3.0000000001, 0, true},
+	}
+	for _, tc := range cases {
+		if got := receiptWithinTolerance(tc.ordered, tc.received, tc.incoming, tc.tolerance); got != tc.want {
+			t.Fatalf("%+v: expected %v, got %v", tc, tc.want, got)
+		}
+	}
+}
+
+func TestPurchaseOrderReceiptStatus(t *testing.T) {
+	if got := purchaseOrderReceiptStatus(true); got != "RECEIVED" {
+		t.Fatalf("expected RECEIVED, got %s", got)
+	}
+	if got := purchaseOrderReceiptStatus(false); got != "PARTIALLY_RECEIVED" {
+		t.Fatalf("expected PARTIALLY_RECEIVED, got %s", got)
+	}
+}
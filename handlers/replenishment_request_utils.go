@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"app/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+var (
+	errReplenishmentItems    = errors.New("items must list each stockItemId once with a positive quantity")
+	errReplenishmentNeededBy = errors.New("neededBy must be a date in YYYY-MM-DD format")
+	errReplenishmentApproved = errors.New("items may only adjust lines on the request, to a quantity of zero or more, and must leave something to fill")
+	errReplenishmentClosed   = errors.New("only submitted replenishment requests can be changed")
+)
+
+// validateReplenishmentRequest checks a staff request's lines, trims its notes and
+// parses the date it is needed by.
+func validateReplenishmentRequest(req *models.CreateReplenishmentRequest) (*time.Time, error) {
+	if len(req.Items) == 0 {
+		return nil, errReplenishmentItems
+	}
+	seen := map[string]bool{}
+	for n := range req.Items {
+		line := &req.Items[n]
+		line.StockItemID = strings.TrimSpace(line.StockItemID)
+		if line.StockItemID == "" || line.Quantity <= 0 || seen[line.StockItemID] {
+			return nil, errReplenishmentItems
+		}
+		seen[line.StockItemID] = true
+		line.Note = nullableString(trimmedString(line.Note))
+	}
+	req.Notes = nullableString(trimmedString(req.Notes))
+	if req.NeededBy == nil || strings.TrimSpace(*req.NeededBy) == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(*req.NeededBy))
+	if err != nil {
+		return nil, errReplenishmentNeededBy
+	}
+	return &date, nil
+}
+
+// replenishmentLine is a requested stock item and the quantity it will be filled with.
+// BatchID and SerialNumbers pick the units a transfer moves.
+type replenishmentLine struct {
+	ItemID        string
+	StockItemID   string
+	ProductID     string
+	Quantity      float64
+	Approved      float64
+	BatchID       string
+	SerialNumbers []string
+}
+
+// approveReplenishmentLines sets what each line is filled with: the requested quantity
+// unless overrides names the stock item, with the batch and serials the override picks.
+// At least one line must remain above zero.
+func approveReplenishmentLines(lines []replenishmentLine, overrides []models.ReplenishmentRequestLine) error {
+	index := make(map[string]int, len(lines))
+	for n := range lines {
+		lines[n].Approved = lines[n].Quantity
+		index[lines[n].StockItemID] = n
+	}
+	for _, o := range overrides {
+		n, ok := index[strings.TrimSpace(o.StockItemID)]
+		if !ok || o.Quantity < 0 {
+			return errReplenishmentApproved
+		}
+		lines[n].Approved = o.Quantity
+		lines[n].BatchID = trimmedString(o.BatchID)
+		lines[n].SerialNumbers = o.SerialNumbers
+	}
+	for _, line := range lines {
+		if line.Approved > 0 {
+			return nil
+		}
+	}
+	return errReplenishmentApproved
+}
+
+// lockReplenishmentRequest locks a submitted request of the merchant and returns its
+// shop's id and name with its lines, ordered by item name.
+func lockReplenishmentRequest(ctx context.Context, tx pgx.Tx, requestID, merchantID string) (string, string, []replenishmentLine, error) {
+	var shopID, shopName, status string
+	if err := tx.QueryRow(ctx, `SELECT r.shop_id,sh.name,r.status FROM replenishment_requests r JOIN shops sh ON sh.id=r.shop_id WHERE r.id::text=$1 AND r.merchant_id=$2 FOR UPDATE OF r`, requestID, merchantID).Scan(&shopID, &shopName, &status); err != nil {
+		return "", "", nil, err
+	}
+	if status != "SUBMITTED" {
+		return "", "", nil, errReplenishmentClosed
+	}
+	rows, err := tx.Query(ctx, `SELECT ri.id,ri.stock_item_id,si.product_id,ri.quantity::float8 FROM replenishment_request_items ri JOIN stock_items si ON si.id=ri.stock_item_id WHERE ri.request_id::text=$1 ORDER BY si.name,ri.id`, requestID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer rows.Close()
+	lines := make([]replenishmentLine, 0)
+	for rows.Next() {
+		var line replenishmentLine
+		if err := rows.Scan(&line.ItemID, &line.StockItemID, &line.ProductID, &line.Quantity); err != nil {
+			return "", "", nil, err
+		}
+		lines = append(lines, line)
+	}
+	return shopID, shopName, lines, rows.Err()
+}
+
+// notifyReplenishmentParties tells everyone involved in a request that it changed: the
+// merchant, the staff member who asked and, for transfers, the staff of the shop the
+// stock leaves. The user who made the change is not notified.
+func notifyReplenishmentParties(ctx context.Context, tx DBTx, requestID, actorID, title, message string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO notifications(recipient_user_id,title,message,notification_type,related_entity_type,related_entity_id)
+		SELECT DISTINCT u.id,$3,$4,'REPLENISHMENT_REQUEST','REPLENISHMENT_REQUEST',r.id
+		FROM replenishment_requests r JOIN users u ON u.id=r.merchant_id OR u.id=r.requested_by
+			OR (u.role='staff' AND u.is_active AND u.merchant_id=r.merchant_id AND u.assigned_shop_id=r.source_shop_id)
+		WHERE r.id=$1 AND u.id<>$2`, requestID, actorID, title, message)
+	return err
+}
+
+const replenishmentRequestColumns = `r.id,r.shop_id,sh.name,r.requested_by,ru.name,r.status,r.notes,r.needed_by,r.review_note,r.reviewed_by,r.reviewed_at,r.purchase_order_id,r.source_shop_id,(SELECT COUNT(*) FROM replenishment_request_items ri WHERE ri.request_id=r.id),r.created_at,r.updated_at`
+
+const replenishmentRequestFrom = ` FROM replenishment_requests r JOIN shops sh ON sh.id=r.shop_id LEFT JOIN users ru ON ru.id=r.requested_by`
+
+func scanReplenishmentRequest(row DBRow, item *models.ReplenishmentRequest) error {
+	return row.Scan(&item.ID, &item.ShopID, &item.ShopName, &item.RequestedBy, &item.RequestedByName, &item.Status, &item.Notes, &item.NeededBy, &item.ReviewNote, &item.ReviewedBy, &item.ReviewedAt, &item.PurchaseOrderID, &item.SourceShopID, &item.ItemCount, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// loadReplenishmentRequest reads a request from row, which the caller's query has
+// already scoped to the merchant or the staff member's shop, and then its lines.
+func loadReplenishmentRequest(ctx context.Context, db rowQuerier, row DBRow, requestID string) (models.ReplenishmentRequest, error) {
+	var item models.ReplenishmentRequest
+	if err := scanReplenishmentRequest(row, &item); err != nil {
+		return item, err
+	}
+	rows, err := db.Query(ctx, `SELECT ri.id,ri.stock_item_id,si.name,si.sku,ri.quantity::float8,ri.approved_quantity::float8,COALESCE(ii.quantity_on_hand,0)::float8,ri.note
+		FROM replenishment_request_items ri JOIN replenishment_requests r ON r.id=ri.request_id JOIN stock_items si ON si.id=ri.stock_item_id
+		LEFT JOIN inventory_items ii ON ii.shop_id=r.shop_id AND ii.stock_item_id=ri.stock_item_id
+		WHERE ri.request_id::text=$1 ORDER BY si.name,ri.id`, requestID)
+	if err != nil {
+		return item, err
+	}
+	defer rows.Close()
+	item.Items = make([]models.ReplenishmentRequestItem, 0)
+	for rows.Next() {
+		var line models.ReplenishmentRequestItem
+		if err := rows.Scan(&line.ID, &line.StockItemID, &line.StockItemName, &line.SKU, &line.Quantity, &line.ApprovedQuantity, &line.OnHand, &line.Note); err != nil {
+			return item, err
+		}
+		item.Items = append(item.Items, line)
+	}
+	return item, rows.Err()
+}
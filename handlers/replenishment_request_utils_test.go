@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"app/models"
+	"testing"
+)
+
+func TestValidateReplenishmentRequest(t *testing.T) {
+	note, blank, date := "  for the weekend ", "   ", "2026-11-02"
+	req := models.CreateReplenishmentRequest{Notes: &note, NeededBy: &date, Items: []models.ReplenishmentRequestLine{
+		{StockItemID: " a ", Quantity: 3, Note: &blank},
+		{StockItemID: "b", Quantity: 0.5},
+	}}
+	neededBy, err := validateReplenishmentRequest(&req)
+	if err != nil || neededBy == nil || neededBy.Format("2006-01-02") != date {
+		t.Fatalf("unexpected result %v, %v", neededBy, err)
+	}
+	if *req.Notes != "for the weekend" || req.Items[0].StockItemID != "a" || req.Items[0].Note != nil {
+		t.Fatalf("request was not normalised: %+v", req)
+	}
+	for _, items := range [][]models.ReplenishmentRequestLine{
+		nil,
+		{{StockItemID: "a", Quantity: 0}},
+		{{StockItemID: "", Quantity: 1}},
+		{{StockItemID: "a", Quantity: 1}, {StockItemID: " a", Quantity: 2}},
+	} {
+		if _, err := validateReplenishmentRequest(&models.CreateReplenishmentRequest{Items: items}); err != errReplenishmentItems {
+			t.Fatalf("%+v: expected items error, got %v", items, err)
+		}
+	}
+	bad := "02/11/2026"
+	if _, err := validateReplenishmentRequest(&models.CreateReplenishmentRequest{NeededBy: &bad, Items: []models.ReplenishmentRequestLine{{StockItemID: "a", Quantity: 1}}}); err != errReplenishmentNeededBy {
+		t.Fatalf("expected neededBy error, got %v", err)
+	}
+}
+
+func TestApproveReplenishmentLines(t *testing.T) {
+	lines := []replenishmentLine{{StockItemID: "a", Quantity: 4}, {StockItemID: "b", Quantity: 2}, {StockItemID: "c", Quantity: 1}}
+	if err := approveReplenishmentLines(lines, []models.ReplenishmentRequestLine{{StockItemID: "a", Quantity: 6}, {StockItemID: "b", Quantity: 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if lines[0].Approved != 6 || lines[1].Approved != 0 || lines[2].Approved != 1 {
+		t.Fatalf("unexpected approvals %+v", lines)
+	}
+	batch := " b1 "
+	if err := approveReplenishmentLines(lines, []models.ReplenishmentRequestLine{{StockItemID: "c", Quantity: 1, BatchID: &batch, SerialNumbers: []string{"SN1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if lines[2].BatchID != "b1" || len(lines[2].SerialNumbers) != 1 || lines[0].BatchID != "" {
+		t.Fatalf("unexpected unit picks %+v", lines)
+	}
+	for _, overrides := range [][]models.ReplenishmentRequestLine{
+		{{StockItemID: "z", Quantity: 1}},
+		{{StockItemID: "a", Quantity: -1}},
+		{{StockItemID: "a", Quantity: 0}, {StockItemID: "b", Quantity: 0}, {StockItemID: "c", Quantity: 0}},
+	} {
+		if err := approveReplenishmentLines(lines, overrides); err != errReplenishmentApproved {
+			t.Fatalf("%+v: expected approval error, got %v", overrides, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	errRMAStatusInvalid      = errors.New("status must be OPEN, SENT_TO_SUPPLIER, REPAIRED, REPLACED, REJECTED or CLOSED")
+	errRMAStatusUnchanged    = errors.New("case already has this status")
+	errRMATransition         = errors.New("case cannot move between these statuses")
+	errRMAReplacementOnly    = errors.New("cases are marked REPLACED by issuing a replacement unit")
+	errRMASupplierRequired   = errors.New("supplierId is required to send a case to the supplier")
+	errRMACaseNotFound       = errors.New("RMA case not found")
+	errRMAReplacementUnit    = errors.New("replacement unit is not available in the case's shop")
+	errRMAReplacementStock   = errors.New("insufficient stock to issue the replacement unit")
+	errRMAReplacementMissing = errors.New("serialNumber is required for serial cases and assetId for asset cases")
+)
+
+// rmaTransitions lists the statuses a case may move to from each status. REPLACED is
+// entered only by issuing a replacement unit.
+var rmaTransitions = map[string][]string{
+	"OPEN":             {"SENT_TO_SUPPLIER", "REPLACED", "REJECTED"},
+	"SENT_TO_SUPPLIER": {"REPAIRED", "REPLACED", "REJECTED"},
+	"REPAIRED":         {"CLOSED"},
+	"REPLACED":         {"CLOSED"},
+	"REJECTED":         {"CLOSED"},
+	"CLOSED":           {},
+}
+
+func isRMAError(err error) bool {
+	return errors.Is(err, errRMAStatusInvalid) || errors.Is(err, errRMAStatusUnchanged) || errors.Is(err, errRMATransition) ||
+		errors.Is(err, errRMAReplacementOnly) || errors.Is(err, errRMASupplierRequired) || errors.Is(err, errRMAReplacementUnit) ||
+		errors.Is(err, errRMAReplacementStock) || errors.Is(err, errRMAReplacementMissing)
+}
+
+// validateRMATransition checks a case status change; replacement is true when a
+// replacement unit is being issued.
+func validateRMATransition(from, to string, replacement bool) error {
+	if _, ok := rmaTransitions[to]; !ok {
+		return errRMAStatusInvalid
+	}
+	if from == to {
+		return errRMAStatusUnchanged
+	}
+	if (to == "REPLACED") != replacement {
+		return errRMAReplacementOnly
+	}
+	for _, next := range rmaTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return errRMATransition
+}
+
+// rmaCaseRef is the part of a case the status and replacement flows work with.
+type rmaCaseRef struct {
+	ID         string
+	MerchantID string
+	ShopID     string
+	SerialID   *string
+	AssetID    *string
+	SaleID     *string
+	SupplierID *string
+	Status     string
+	// Cover carried over to a replacement unit, from the case's warranty.
+	WarrantyStartsOn *time.Time
+	WarrantyEndsOn   *time.Time
+}
+
+// lockRMACase loads and locks a merchant's case for update.
+func lockRMACase(ctx context.Context, tx DBTx, caseID, merchantID string) (rmaCaseRef, error) {
+	ref := rmaCaseRef{ID: caseID, MerchantID: merchantID}
+	err := tx.QueryRow(ctx, `SELECT r.shop_id,r.serial_id,r.asset_id,r.sale_id,r.supplier_id,r.status,w.starts_on,w.ends_on
+		FROM rma_cases r LEFT JOIN inventory_warranties w ON w.id=r.warranty_id
+		WHERE r.id=$1 AND r.merchant_id=$2 FOR UPDATE OF r`, caseID, merchantID).
+		Scan(&ref.ShopID, &ref.SerialID, &ref.AssetID, &ref.SaleID, &ref.SupplierID, &ref.Status, &ref.WarrantyStartsOn, &ref.WarrantyEndsOn)
+	if isNoRows(err) {
+		return ref, errRMACaseNotFound
+	}
+	return ref, err
+}
+
+// setRMAStatus moves a locked case to a new status and logs the change as a case note
+// and on the faulty unit's history.
+func setRMAStatus(ctx context.Context, tx DBTx, ref rmaCaseRef, to, actorID, note string) error {
+	if _, err := tx.Exec(ctx, `UPDATE rma_cases SET status=$1,updated_at=NOW(),closed_at=CASE WHEN $1='CLOSED' THEN NOW() END WHERE id=$2`, to, ref.ID); err != nil {
+		return err
+	}
+	if note == "" {
+		note = "Status changed to " + to
+	}
+	if err := addRMANote(ctx, tx, ref.ID, ref.Status, to, note, actorID); err != nil {
+		return err
+	}
+	return recordRMAUnitEvent(ctx, tx, ref, actorID, note)
+}
+
+// addRMANote appends a note to a case; fromStatus and toStatus are empty for plain notes.
+func addRMANote(ctx context.Context, tx DBTx, caseID, fromStatus, toStatus, note, actorID string) error {
+	_, err := tx.Exec(ctx, `INSERT INTO rma_case_notes(rma_case_id,from_status,to_status,note,author_id) VALUES($1,$2,$3,$4,$5)`, caseID, nullableString(fromStatus), nullableString(toStatus), note, nullableString(actorID))
+	return err
+}
+
+// recordRMAUnitEvent logs case activity on the faulty serial's or asset's own history.
+// The asset keeps its status, so the event records it as both from and to.
+func recordRMAUnitEvent(ctx context.Context, tx DBTx, ref rmaCaseRef, actorID, notes string) error {
+	if ref.SerialID != nil {
+		return recordSerialEvent(ctx, tx, *ref.SerialID, "RMA", ref.ShopID, "RMA", ref.ID, actorID, notes)
+	}
+	var status string
+	if err := tx.QueryRow(ctx, `SELECT status FROM inventory_assets WHERE id=$1`, *ref.AssetID).Scan(&status); err != nil {
+		return err
+	}
+	return recordAssetEvent(ctx, tx, *ref.AssetID, "RMA", status, status, ref.ShopID, "RMA", ref.ID, actorID, notes)
+}
+
+// issueRMAReplacementSerial hands a serial from the case's shop to the customer in place
+// of the faulty unit, takes it out of stock and moves the remaining warranty cover to it.
+func issueRMAReplacementSerial(ctx context.Context, tx DBTx, ref rmaCaseRef, serialNumber, actorID string) (string, error) {
+	var serialID, inventoryItemID, stockItemID string
+	err := tx.QueryRow(ctx, `UPDATE inventory_serials SET status='SOLD',reference_id=$1 WHERE merchant_id=$2 AND shop_id=$3 AND serial_number=$4 AND status IN ('AVAILABLE','RETURNED') AND stock_item_id IS NOT NULL
+		RETURNING id,inventory_item_id,stock_item_id`, nullableStringValue(ref.SaleID), ref.MerchantID, ref.ShopID, serialNumber).Scan(&serialID, &inventoryItemID, &stockItemID)
+	if isNoRows(err) {
+		return "", errRMAReplacementUnit
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := issueRMAReplacementStock(ctx, tx, ref, inventoryItemID, stockItemID, ""); err != nil {
+		return "", err
+	}
+	if err := recordSerialEvent(ctx, tx, serialID, "RMA", ref.ShopID, "RMA", ref.ID, actorID, "Issued as an RMA replacement"); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(ctx, `UPDATE inventory_warranties SET status='VOID' WHERE serial_id=$1 AND status='ACTIVE'`, *ref.SerialID); err != nil {
+		return "", err
+	}
+	saleID := ""
+	if ref.SaleID != nil {
+		saleID = *ref.SaleID
+	}
+	return serialID, startWarranty(ctx, tx, serialID, "", saleID, ref.WarrantyStartsOn, ref.WarrantyEndsOn)
+}
+
+// issueRMAReplacementAsset sells an asset from the case's shop as the replacement for the
+// faulty one and takes it out of stock.
+func issueRMAReplacementAsset(ctx context.Context, tx DBTx, ref rmaCaseRef, assetID, actorID string) (string, error) {
+	var inventoryItemID, stockItemID string
+	var batchID *string
+	err := tx.QueryRow(ctx, `SELECT a.id,a.inventory_item_id,ii.stock_item_id,a.batch_id FROM inventory_assets a JOIN inventory_items ii ON ii.id=a.inventory_item_id
+		WHERE (a.id::text=$1 OR a.asset_tag=$1) AND a.merchant_id=$2 AND a.shop_id=$3 AND a.id<>$4`, assetID, ref.MerchantID, ref.ShopID, *ref.AssetID).Scan(&assetID, &inventoryItemID, &stockItemID, &batchID)
+	if isNoRows(err) {
+		return "", errRMAReplacementUnit
+	}
+	if err != nil {
+		return "", err
+	}
+	t := assetTransition{AssetID: assetID, MerchantID: ref.MerchantID, ToStatus: "SOLD", EventType: "RMA", ReferenceType: "RMA", ReferenceID: ref.ID, ActorID: actorID, Notes: "Issued as an RMA replacement", WarrantyStartsOn: ref.WarrantyStartsOn, WarrantyEndsOn: ref.WarrantyEndsOn}
+	if _, _, err := transitionInventoryAsset(ctx, tx, t); err != nil {
+		if isAssetError(err) {
+			return "", errRMAReplacementUnit
+		}
+		return "", err
+	}
+	if err := issueRMAReplacementStock(ctx, tx, ref, inventoryItemID, stockItemID, selectedBatchID(batchID)); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(ctx, `UPDATE inventory_warranties SET status='VOID' WHERE asset_id=$1 AND status='ACTIVE'`, *ref.AssetID)
+	return assetID, err
+}
+
+// issueRMAReplacementStock takes one unit off the balance and posts the OUT movement at
+// the balance's issue cost, referencing the case.
+func issueRMAReplacementStock(ctx context.Context, tx DBTx, ref rmaCaseRef, inventoryItemID, stockItemID, batchID string) error {
+	var productID string
+	err := tx.QueryRow(ctx, `UPDATE inventory_items ii SET quantity_on_hand=ii.quantity_on_hand-1,updated_at=NOW() WHERE ii.id=$1 AND `+unreservedStockSQL("ii")+`>=1 RETURNING ii.product_id`, inventoryItemID).Scan(&productID)
+	if isNoRows(err) {
+		return errRMAReplacementStock
+	}
+	if err != nil {
+		return err
+	}
+	batches, err := consumeInventoryBatches(ctx, tx, stockItemID, inventoryItemID, 1, batchID)
+	if err != nil {
+		if isBatchConsumptionError(err) {
+			return errRMAReplacementStock
+		}
+		return err
+	}
+	unitCost, err := issueInventoryCost(ctx, tx, ref.MerchantID, inventoryItemID, 1, batches)
+	if err != nil {
+		return err
+	}
+	binID, err := moveDefaultBinStock(ctx, tx, inventoryItemID, -1)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES($1,$2,$3,$4,$5,'OUT',1,1,$6,'RMA',$7,$8,'RMA replacement issued',$9)`, ref.MerchantID, ref.ShopID, inventoryItemID, productID, stockItemID, unitCost, ref.ID, "rma:"+ref.ID, binID)
+	return err
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import "testing"
+
+func TestValidateRMATransition(t *testing.T) {
+	cases := []struct {
+		from, to    string
+		replacement bool
+		want        error
+	}{
+		{"OPEN", "SENT_TO_SUPPLIER", false, nil},
+		{"OPEN", "REJECTED", false, nil},
+		{"OPEN", "REPAIRED", false, errRMATransition},
+		{"OPEN", "REPLACED", false, errRMAReplacementOnly},
+		{"OPEN", "REPLACED", true, nil},
+		{"SENT_TO_SUPPLIER", "REPAIRED", false, nil},
+		{"SENT_TO_SUPPLIER", "REPLACED", true, nil},
+		{"REPAIRED", "CLOSED", false, nil},
+		{"REPAIRED", "REPLACED", true, errRMATransition},
+		{"CLOSED", "OPEN", false, errRMATransition},
+		{"OPEN", "OPEN", false, errRMAStatusUnchanged},
+		{"OPEN", "LOST", false, errRMAStatusInvalid},
+		{"OPEN", "CLOSED", true, errRMAReplacementOnly},
+	}
+	for _, tc := range cases {
+		if got := validateRMATransition(tc.from, tc.to, tc.replacement); got != tc.want {
+			t.Fatalf("%s -> %s (replacement %v): expected %v, got %v", tc.from, tc.to, tc.replacement, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"app/database"
+	"app/middleware"
+	"app/models"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// attachSaleItemBatches loads the batch lines consumed by each item of a sale.
+func attachSaleItemBatches(ctx context.Context, db *pgxpool.Pool, sale *models.Sale) error {
+	if len(sale.Items) == 0 {
+		return nil
+	}
+	rows, err := db.Query(ctx, `SELECT sib.id,sib.sale_item_id,sib.batch_id,b.batch_code,b.expiry_date,sib.quantity,sib.quantity_returned FROM sale_item_batches sib JOIN inventory_batches b ON b.id=sib.batch_id JOIN sale_items si ON si.id=sib.sale_item_id WHERE si.sale_id=$1 ORDER BY sib.created_at,sib.id`, sale.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	byItem := make(map[string][]models.SaleItemBatch)
+	for rows.Next() {
+		var line models.SaleItemBatch
+		if err := rows.Scan(&line.ID, &line.SaleItemID, &line.BatchID, &line.BatchCode, &line.ExpiryDate, &line.Quantity, &line.QuantityReturned); err != nil {
+			return err
+		}
+		byItem[line.SaleItemID] = append(byItem[line.SaleItemID], line)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range sale.Items {
+		sale.Items[i].Batches = byItem[sale.Items[i].ID]
+	}
+	return nil
+}
+
+// HandleCreateSaleReturn puts returned sale items back into stock.
+// Batch-tracked items are restored to the batches the sale consumed,
+// serial-tracked items must name the serials coming back and kit lines
+// restock their components.
+func HandleCreateSaleReturn(c *fiber.Ctx) error {
+	saleID := c.Params("saleId")
+	if err := authorizeSaleAccess(c, saleID); err != nil {
+		return err
+	}
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.SaleReturnRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid request body"})
+	}
+	req.ClientOperationID = strings.TrimSpace(req.ClientOperationID)
+	if req.ClientOperationID == "" || len(req.Items) == 0 || len(req.Items) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "clientOperationId and between 1 and 100 return items are required"})
+	}
+	seen := make(map[string]struct{}, len(req.Items))
+	for _, item := range req.Items {
+		if strings.TrimSpace(item.SaleItemID) == "" || item.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid return item"})
+		}
+		if _, exists := seen[item.SaleItemID]; exists {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Duplicate sale item lines are not allowed"})
+		}
+		seen[item.SaleItemID] = struct{}{}
+	}
+
+	db, ctx := database.GetDB(), context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to start return"})
+	}
+	defer tx.Rollback(ctx)
+	var shopID, merchantID string
+	if err = tx.QueryRow(ctx, `SELECT shop_id,merchant_id FROM sales WHERE id=$1 FOR UPDATE`, saleID).Scan(&shopID, &merchantID); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Sale not found"})
+	}
+	claimed, err := claimInventoryOperation(ctx, tx, req.ClientOperationID, "sale_return", claims.UserID, &shopID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to start return"})
+	}
+	if !claimed {
+		return c.JSON(fiber.Map{"status": "success", "message": "Return already processed"})
+	}
+	notes := fmt.Sprintf("Return for sale #%s", saleID)
+	if req.Reason != nil && strings.TrimSpace(*req.Reason) != "" {
+		notes = fmt.Sprintf("%s: %s", notes, strings.TrimSpace(*req.Reason))
+	}
+	for _, item := range req.Items {
+		var inventoryID, productID, stockItemID string
+		var unitID *string
+		var unitCost *float64
+		var sold, soldBase, returned float64
+		err = tx.QueryRow(ctx, `SELECT si.inventory_item_id,ii.product_id,ii.stock_item_id,si.unit_id::text,si.quantity_sold,COALESCE(si.base_quantity,si.quantity_sold),si.quantity_returned,si.unit_cost::float8 FROM sale_items si JOIN inventory_items ii ON ii.id=si.inventory_item_id WHERE si.id=$1 AND si.sale_id=$2 FOR UPDATE OF si,ii`, item.SaleItemID, saleID).Scan(&inventoryID, &productID, &stockItemID, &unitID, &sold, &soldBase, &returned, &unitCost)
+		if err == pgx.ErrNoRows {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Sale item %s not found", item.SaleItemID)})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to load sale item"})
+		}
+		if item.Quantity > sold-returned {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Return quantity exceeds the unreturned quantity for sale item %s", item.SaleItemID)})
+		}
+		// Returns are entered in the unit the line was sold in; stock is restored in base units.
+		baseReturned := baseQuantityFor(item.Quantity, soldBase/sold)
+		if _, err = tx.Exec(ctx, `UPDATE sale_items SET quantity_returned=quantity_returned+$1,updated_at=NOW() WHERE id=$2`, item.Quantity, item.SaleItemID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record return"})
+		}
+		kitReturned, err := returnKitComponents(ctx, pgxTxAdapter{tx: tx}, merchantID, shopID, saleID, item.SaleItemID, req.ClientOperationID, notes, baseReturned)
+		if err != nil {
+			log.Printf("Failed to restock kit components for sale item %s: %v", item.SaleItemID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to restock kit components"})
+		}
+		if kitReturned {
+			continue
+		}
+		if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand+$1,updated_at=NOW() WHERE id=$2`, baseReturned, inventoryID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to restock returned item"})
+		}
+		if err = restoreSaleItemBatches(ctx, pgxTxAdapter{tx: tx}, item.SaleItemID, baseReturned); err != nil {
+			log.Printf("Failed to restore batches for sale item %s: %v", item.SaleItemID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to restock returned batches"})
+		}
+		if _, err = returnInventorySerials(ctx, pgxTxAdapter{tx: tx}, stockItemID, inventoryID, shopID, saleID, claims.UserID, baseReturned, item.SerialNumbers); err != nil {
+			if isSerialError(err) {
+				return c.Status(serialErrorStatus(err)).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Sale item %s: %v", item.SaleItemID, err)})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record returned serials"})
+		}
+		// Returned stock goes back in at the cost it was sold at.
+		restockCost, err := receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, inventoryID, baseReturned, unitCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to cost returned item"})
+		}
+		binID, err := moveDefaultBinStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, baseReturned)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to restock returned item bin"})
+		}
+		if _, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes,bin_id) VALUES($1,$2,$3,$4,$5,$6,'RETURN',$7,$8,$9,'SALE_RETURN',$10,$11,$12,$13)`, merchantID, shopID, inventoryID, productID, stockItemID, unitID, item.Quantity, baseReturned, restockCost, saleID, fmt.Sprintf("%s:%s", req.ClientOperationID, item.SaleItemID), notes, binID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record stock movement"})
+		}
+		if err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, inventoryID); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to settle backorders"})
+		}
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to commit return"})
+	}
+	sale, err := getSaleByID(ctx, db, saleID)
+	if err != nil {
+		// The return is committed; answer with the sale as far as the return knows it.
+		log.Printf("Failed to reload sale %s after return: %v", saleID, err)
+		sale = &models.Sale{ID: saleID, ShopID: shopID, MerchantID: merchantID}
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": sale})
+}
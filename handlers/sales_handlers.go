@@ -69,6 +69,9 @@ func HandleCreateSale(c *fiber.Ctx) error {
 	if err := authorizeShopAccess(c, input.ShopID); err != nil {
 		return err
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, input.ShopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 
 	tx, err := db.Begin(ctx)
 	if err != nil {
@@ -92,7 +95,7 @@ func HandleCreateSale(c *fiber.Ctx) error {
 		if item.InventoryItemID == "" || item.QuantitySold <= 0 || item.SellingPriceAtSale < 0 {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid sale item"})
 		}
-		totalAmount += float64(item.QuantitySold) * item.SellingPriceAtSale
+		totalAmount += item.QuantitySold * item.SellingPriceAtSale
 	}
 
 	// Create the sale
@@ -113,6 +116,7 @@ func HandleCreateSale(c *fiber.Ctx) error {
 	}
 
 	// Create sale items
+	stockWarnings := make([]string, 0)
 	for _, item := range input.Items {
 		// fetch item details to denormalize into sale_items
 		var itemName string
@@ -125,23 +129,78 @@ func HandleCreateSale(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Product not found"})
 		}
 
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, item.InventoryItemID, item.UnitID, item.QuantitySold)
+		if err != nil {
+			if isUnitError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create sale item"})
+		}
+
 		saleItemQuery := `
-			INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, unit_id, item_name, item_sku, quantity_sold, base_quantity, selling_price_at_sale, original_price_at_sale, subtotal)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id
 		`
-		subtotal := float64(item.QuantitySold) * item.SellingPriceAtSale
-		if _, err := tx.Exec(ctx, saleItemQuery, sale.ID, inventoryID, productID, item.InventoryItemID, itemName, itemSKU, item.QuantitySold, item.SellingPriceAtSale, originalPrice, subtotal); err != nil {
+		subtotal := item.QuantitySold * item.SellingPriceAtSale
+		var saleItemID string
+		if err := tx.QueryRow(ctx, saleItemQuery, sale.ID, inventoryID, productID, item.InventoryItemID, qty.UnitID, itemName, itemSKU, item.QuantitySold, qty.BaseQuantity, item.SellingPriceAtSale, originalPrice, subtotal).Scan(&saleItemID); err != nil {
 			log.Printf("Error creating sale item: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create sale item"})
 		}
-		updated, err := tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2 AND quantity_on_hand >= $1`, item.QuantitySold, inventoryID)
+		kit, err := loadStockItemKit(ctx, pgxTxAdapter{tx: tx}, item.InventoryItemID)
+		if err == nil && kit != nil {
+			var kitCost float64
+			var kitWarnings []string
+			kitCost, kitWarnings, err = sellKitComponents(ctx, pgxTxAdapter{tx: tx}, kit, sale.MerchantID, input.ShopID, sale.ID, saleItemID, "SALE", qty.BaseQuantity)
+			if err == nil {
+				err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, kitCost)
+			}
+			if err == nil {
+				stockWarnings = append(stockWarnings, kitWarnings...)
+				continue
+			}
+		}
 		if err != nil {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Insufficient stock"})
+			if isKitSaleError(err) || isBatchConsumptionError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
 		}
-		if updated.RowsAffected() != 1 {
+		deduction, err := deductSaleStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, qty.BaseQuantity, "SALE", sale.ID)
+		if err == errInsufficientStock {
 			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": "Insufficient stock"})
 		}
-		if _, err := tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,'OUT',$6,$6,'SALE',$7,$8,'Sale')`, sale.MerchantID, input.ShopID, inventoryID, productID, item.InventoryItemID, item.QuantitySold, sale.ID, fmt.Sprintf("%s:%s", sale.ID, item.InventoryItemID)); err != nil {
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		if warning := deduction.warning(itemName); warning != "" {
+			stockWarnings = append(stockWarnings, warning)
+		}
+		batches, err := consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, item.InventoryItemID, inventoryID, deduction.batched(qty.BaseQuantity), selectedBatchID(item.BatchID))
+		if err == nil {
+			err = recordSaleItemBatches(ctx, pgxTxAdapter{tx: tx}, saleItemID, batches)
+		}
+		if err == nil {
+			_, err = sellInventorySerials(ctx, pgxTxAdapter{tx: tx}, item.InventoryItemID, inventoryID, input.ShopID, sale.ID, claims.UserID, qty.BaseQuantity, item.SerialNumbers)
+		}
+		if err == nil {
+			err = sellInventoryAsset(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.AssetID), sale.MerchantID, inventoryID, sale.ID, claims.UserID, qty.BaseQuantity)
+		}
+		if err != nil {
+			if isBatchConsumptionError(err) || isSerialError(err) || err == errAssetNotFound || isAssetError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		unitCost, err := issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, sale.MerchantID, inventoryID, qty.BaseQuantity, batches)
+		if err == nil {
+			err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, saleLineCost(qty.BaseQuantity, unitCost))
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes) VALUES($1,$2,$3,$4,$5,$6,'OUT',$7,$8,$9,'SALE',$10,$11,'Sale')`, sale.MerchantID, input.ShopID, inventoryID, productID, item.InventoryItemID, qty.UnitID, item.QuantitySold, qty.BaseQuantity, unitCost, sale.ID, fmt.Sprintf("%s:%s", sale.ID, item.InventoryItemID)); err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to record stock movement"})
 		}
 	}
@@ -195,7 +254,7 @@ func HandleCreateSale(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to commit transaction"})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "data": sale})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "data": sale, "stockWarnings": stockWarnings})
 }
 
 // HandleListSalesForShop lists sales for a specific shop.
@@ -282,7 +341,7 @@ func HandleListSalesForShop(c *fiber.Ctx) error {
 				if item.OriginalPriceAtSale != nil {
 					orig = *item.OriginalPriceAtSale
 				}
-				log.Printf("   📦 [SALES HANDLER] Item: %s, Qty: %d, SellingPrice: %.2f, OriginalPrice: %.2f, Subtotal: %.2f",
+				log.Printf("   📦 [SALES HANDLER] Item: %s, Qty: %g, SellingPrice: %.2f, OriginalPrice: %.2f, Subtotal: %.2f",
 					item.InventoryItemID, item.QuantitySold, item.SellingPriceAtSale, orig, item.Subtotal)
 				items = append(items, item)
 			}
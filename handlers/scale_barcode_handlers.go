@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"app/database"
+	"app/models"
+	"context"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+var scaleFormatSortFields = map[string]string{"prefix": "prefix", "name": "name", "createdAt": "created_at"}
+var scalePLUSortFields = map[string]string{"pluCode": "lpad(plu_code,8,'0')", "name": "stock_item_name", "createdAt": "created_at"}
+
+const scaleFormatColumns = "id,merchant_id,name,prefix,item_code_length,value_mode,value_decimals,unit_id,is_active,created_at,updated_at"
+
+func scanScaleFormat(row pgx.Row, item *models.ScaleBarcodeFormat) error {
+	if err := row.Scan(&item.ID, &item.MerchantID, &item.Name, &item.Prefix, &item.ItemCodeLength, &item.ValueMode, &item.ValueDecimals, &item.UnitID, &item.IsActive, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return err
+	}
+	item.ValueLength = scaleValueLength(item.Prefix, item.ItemCodeLength)
+	return nil
+}
+
+// parseScaleFormatRequest validates the layout and defaults the value to 3 decimals (grams
+// in a kilogram label) and the format to active.
+func parseScaleFormatRequest(c *fiber.Ctx) (models.ScaleBarcodeFormatRequest, int, bool, error) {
+	var req models.ScaleBarcodeFormatRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return req, 0, false, fiber.NewError(400, "name is required")
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Prefix = strings.TrimSpace(req.Prefix)
+	req.ValueMode = strings.ToUpper(strings.TrimSpace(req.ValueMode))
+	decimals := 3
+	if req.ValueDecimals != nil {
+		decimals = *req.ValueDecimals
+	}
+	if err := validateScaleFormat(req.Prefix, req.ItemCodeLength, req.ValueMode, decimals); err != nil {
+		return req, 0, false, fiber.NewError(400, err.Error())
+	}
+	if req.UnitID != nil && strings.TrimSpace(*req.UnitID) == "" {
+		req.UnitID = nil
+	}
+	if req.UnitID != nil {
+		var exists bool
+		if err := database.GetDB().QueryRow(context.Background(), `SELECT EXISTS(SELECT 1 FROM unit_definitions WHERE id=$1)`, *req.UnitID).Scan(&exists); err != nil || !exists {
+			return req, 0, false, fiber.NewError(400, "unitId is invalid")
+		}
+	}
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+	return req, decimals, active, nil
+}
+
+func HandleListScaleBarcodeFormats(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "prefix", scaleFormatSortFields)
+	where := " WHERE merchant_id=$1"
+	args := []interface{}{merchantID}
+	if q.Search != "" {
+		where += " AND (name ILIKE $2 OR prefix ILIKE $2)"
+		args = append(args, "%"+q.Search+"%")
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM scale_barcode_formats"+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count scale formats")
+	}
+	rows, err := db.Query(ctx, "SELECT "+scaleFormatColumns+" FROM scale_barcode_formats"+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list scale formats")
+	}
+	defer rows.Close()
+	items := make([]models.ScaleBarcodeFormat, 0)
+	for rows.Next() {
+		var item models.ScaleBarcodeFormat
+		if err := scanScaleFormat(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read scale format")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleCreateScaleBarcodeFormat(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	req, decimals, active, err := parseScaleFormatRequest(c)
+	if err != nil {
+		return err
+	}
+	var item models.ScaleBarcodeFormat
+	row := database.GetDB().QueryRow(context.Background(), `INSERT INTO scale_barcode_formats(merchant_id,name,prefix,item_code_length,value_mode,value_decimals,unit_id,is_active) VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING `+scaleFormatColumns, merchantID, req.Name, req.Prefix, req.ItemCodeLength, req.ValueMode, decimals, req.UnitID, active)
+	if err := scanScaleFormat(row, &item); err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "a scale format with this prefix already exists")
+		}
+		return fiber.NewError(500, "failed to create scale format")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleUpdateScaleBarcodeFormat(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	req, decimals, active, err := parseScaleFormatRequest(c)
+	if err != nil {
+		return err
+	}
+	var item models.ScaleBarcodeFormat
+	row := database.GetDB().QueryRow(context.Background(), `UPDATE scale_barcode_formats SET name=$1,prefix=$2,item_code_length=$3,value_mode=$4,value_decimals=$5,unit_id=$6,is_active=$7,updated_at=NOW() WHERE id=$8 AND merchant_id=$9 RETURNING `+scaleFormatColumns, req.Name, req.Prefix, req.ItemCodeLength, req.ValueMode, decimals, req.UnitID, active, c.Params("formatId"), merchantID)
+	err = scanScaleFormat(row, &item)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "scale format not found")
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "a scale format with this prefix already exists")
+		}
+		return fiber.NewError(500, "failed to update scale format")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleDeleteScaleBarcodeFormat(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	result, err := database.GetDB().Exec(context.Background(), `DELETE FROM scale_barcode_formats WHERE id=$1 AND merchant_id=$2`, c.Params("formatId"), merchantID)
+	if err != nil {
+		return fiber.NewError(500, "failed to delete scale format")
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "scale format not found")
+	}
+	return c.SendStatus(204)
+}
+
+func HandleListScalePLUCodes(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	q := getCatalogListQuery(c, "pluCode", scalePLUSortFields)
+	base := "SELECT p.id,p.merchant_id,p.plu_code,p.stock_item_id,si.name AS stock_item_name,p.created_at FROM scale_plu_codes p JOIN stock_items si ON si.id=p.stock_item_id WHERE p.merchant_id=$1"
+	args := []interface{}{merchantID}
+	if q.Search != "" {
+		base += " AND (p.plu_code ILIKE $2 OR si.name ILIKE $2)"
+		args = append(args, "%"+q.Search+"%")
+	}
+	if stockItemID := strings.TrimSpace(c.Query("stockItemId")); stockItemID != "" {
+		base += " AND p.stock_item_id=$" + itoa(len(args)+1)
+		args = append(args, stockItemID)
+	}
+	db := database.GetDB()
+	ctx := context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*) FROM ("+base+") x", args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count scale PLU codes")
+	}
+	rows, err := db.Query(ctx, "SELECT * FROM ("+base+") x"+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list scale PLU codes")
+	}
+	defer rows.Close()
+	items := make([]models.ScalePLUCode, 0)
+	for rows.Next() {
+		var item models.ScalePLUCode
+		if err := rows.Scan(&item.ID, &item.MerchantID, &item.PLUCode, &item.StockItemID, &item.StockItemName, &item.CreatedAt); err != nil {
+			return fiber.NewError(500, "failed to read scale PLU code")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+func HandleCreateScalePLUCode(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.ScalePLUCodeRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.StockItemID) == "" {
+		return fiber.NewError(400, "pluCode and stockItemId are required")
+	}
+	code := strings.TrimSpace(req.PLUCode)
+	if code == "" || len(code) > 8 || !isDigits(code) {
+		return fiber.NewError(400, "pluCode must be 1 to 8 digits")
+	}
+	var item models.ScalePLUCode
+	err = database.GetDB().QueryRow(context.Background(), `WITH ins AS (INSERT INTO scale_plu_codes(merchant_id,plu_code,stock_item_id)
+		SELECT $1,$2,si.id FROM stock_items si WHERE si.id=$3 AND si.merchant_id=$1
+		RETURNING id,merchant_id,plu_code,stock_item_id,created_at)
+		SELECT ins.id,ins.merchant_id,ins.plu_code,ins.stock_item_id,si.name,ins.created_at FROM ins JOIN stock_items si ON si.id=ins.stock_item_id`, merchantID, normalizePLU(code), req.StockItemID).
+		Scan(&item.ID, &item.MerchantID, &item.PLUCode, &item.StockItemID, &item.StockItemName, &item.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return fiber.NewError(404, "stock item not found")
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return duplicateResponse(c, "PLU code is already mapped")
+		}
+		return fiber.NewError(500, "failed to create scale PLU code")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+func HandleDeleteScalePLUCode(c *fiber.Ctx) error {
+	merchantID, err := getMerchantIDFromClaims(c)
+	if err != nil {
+		return err
+	}
+	result, err := database.GetDB().Exec(context.Background(), `DELETE FROM scale_plu_codes WHERE id=$1 AND merchant_id=$2`, c.Params("pluId"), merchantID)
+	if err != nil {
+		return fiber.NewError(500, "failed to delete scale PLU code")
+	}
+	if result.RowsAffected() == 0 {
+		return fiber.NewError(404, "scale PLU code not found")
+	}
+	return c.SendStatus(204)
+}
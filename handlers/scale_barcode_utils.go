@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	scaleModeWeight = "WEIGHT"
+	scaleModePrice  = "PRICE"
+)
+
+var (
+	errScaleFormatPrefix   = errors.New("prefix must be 1 to 3 digits starting with 2")
+	errScaleFormatLayout   = errors.New("itemCodeLength must leave at least 3 digits for the value")
+	errScaleFormatMode     = errors.New("valueMode must be WEIGHT or PRICE")
+	errScaleFormatDecimals = errors.New("valueDecimals must be between 0 and 4")
+	errScaleBarcodeUnknown = errors.New("scale barcode does not match a configured format and PLU")
+	errScaleBarcodeValue   = errors.New("scale barcode has no usable weight or price")
+)
+
+func isScaleBarcodeError(err error) bool {
+	return errors.Is(err, errScaleBarcodeUnknown) || errors.Is(err, errScaleBarcodeValue)
+}
+
+// scaleBarcodeFormat is the layout of a 2x-prefixed scale label: prefix, PLU, value and
+// the EAN-13 check digit, in that order.
+type scaleBarcodeFormat struct {
+	ID             string
+	Prefix         string
+	ItemCodeLength int
+	ValueMode      string
+	ValueDecimals  int
+	UnitID         *string
+}
+
+// scaleValueLength is the number of value digits left between the PLU and the check digit.
+func scaleValueLength(prefix string, itemCodeLength int) int {
+	return 12 - len(prefix) - itemCodeLength
+}
+
+func validateScaleFormat(prefix string, itemCodeLength int, mode string, decimals int) error {
+	if len(prefix) < 1 || len(prefix) > 3 || prefix[0] != '2' || !isDigits(prefix) {
+		return errScaleFormatPrefix
+	}
+	if itemCodeLength < 1 || scaleValueLength(prefix, itemCodeLength) < 3 {
+		return errScaleFormatLayout
+	}
+	if mode != scaleModeWeight && mode != scaleModePrice {
+		return errScaleFormatMode
+	}
+	if decimals < 0 || decimals > 4 {
+		return errScaleFormatDecimals
+	}
+	return nil
+}
+
+// normalizePLU drops leading zeros so a PLU matches however wide the scale prints it.
+func normalizePLU(code string) string {
+	if trimmed := strings.TrimLeft(strings.TrimSpace(code), "0"); trimmed != "" {
+		return trimmed
+	}
+	return "0"
+}
+
+// parseScaleBarcode splits a scale label into its PLU and embedded value. ok is false
+// when the code is not a valid EAN-13 or does not carry the format's prefix.
+func parseScaleBarcode(code string, format scaleBarcodeFormat) (plu string, value float64, ok bool) {
+	valueLength := scaleValueLength(format.Prefix, format.ItemCodeLength)
+	if len(code) != 13 || !isDigits(code) || !strings.HasPrefix(code, format.Prefix) || valueLength < 1 {
+		return "", 0, false
+	}
+	if _, err := normalizeEAN13(code); err != nil {
+		return "", 0, false
+	}
+	start := len(format.Prefix)
+	raw, err := strconv.Atoi(code[start+format.ItemCodeLength : 12])
+	if err != nil {
+		return "", 0, false
+	}
+	return normalizePLU(code[start : start+format.ItemCodeLength]), float64(raw) / math.Pow10(format.ValueDecimals), true
+}
+
+// scaleLineQuantity turns a decoded value into a quantity in the format's unit. Weight
+// labels carry the quantity itself; price labels fix the line total and the quantity is
+// derived from the unit price, rounded to the 3 decimals sale lines keep.
+func scaleLineQuantity(mode string, value, unitPrice float64) (quantity float64, linePrice *float64, ok bool) {
+	if value <= 0 {
+		return 0, nil, false
+	}
+	if mode == scaleModeWeight {
+		return value, nil, true
+	}
+	if unitPrice <= 0 {
+		return 0, nil, false
+	}
+	quantity = math.Max(math.Round(value/unitPrice*1000)/1000, 0.001)
+	return quantity, &value, true
+}
+
+// scaleLine is a sale line decoded from a scale label.
+type scaleLine struct {
+	Format      scaleBarcodeFormat
+	PLU         string
+	Value       float64
+	StockItemID string
+	UnitPrice   float64
+	Quantity    float64
+	LinePrice   *float64
+}
+
+// sellingPrice keeps the entered price for weighed lines and spreads a printed line
+// price over the derived quantity so the line total matches the label.
+func (l scaleLine) sellingPrice(entered float64) float64 {
+	if l.LinePrice == nil {
+		return entered
+	}
+	return *l.LinePrice / l.Quantity
+}
+
+// resolveScaleBarcode decodes code with the merchant's longest matching active format and
+// maps its PLU to a stock item. It returns nil without error when code is not a scale label,
+// including codes registered as barcodes: generated EAN-13s share the 2x prefix range, and
+// an exact registry match wins over decoding.
+func resolveScaleBarcode(ctx context.Context, tx DBTx, merchantID, code string) (*scaleLine, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != 13 || code[0] != '2' || !isDigits(code) {
+		return nil, nil
+	}
+	var format scaleBarcodeFormat
+	err := tx.QueryRow(ctx, `SELECT id,prefix,item_code_length,value_mode,value_decimals,unit_id FROM scale_barcode_formats
+		WHERE merchant_id=$1 AND is_active AND $2 LIKE prefix||'%'
+			AND NOT EXISTS (SELECT 1 FROM barcode_registry b WHERE b.merchant_id=$1 AND b.normalized_code=$2 AND b.is_active)
+		ORDER BY length(prefix) DESC LIMIT 1`, merchantID, code).
+		Scan(&format.ID, &format.Prefix, &format.ItemCodeLength, &format.ValueMode, &format.ValueDecimals, &format.UnitID)
+	if isNoRows(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plu, value, ok := parseScaleBarcode(code, format)
+	if !ok {
+		return nil, nil
+	}
+	line := &scaleLine{Format: format, PLU: plu, Value: value}
+	var factor, price float64
+	err = tx.QueryRow(ctx, `SELECT si.id, COALESCE(su.conversion_to_base,1)::float8, COALESCE(pp.selling_price,0)::float8
+		FROM scale_plu_codes p
+		JOIN stock_items si ON si.id=p.stock_item_id AND si.merchant_id=p.merchant_id
+		LEFT JOIN stock_item_units su ON su.stock_item_id=si.id AND su.unit_id=COALESCE($3::uuid,si.base_unit_id)
+		LEFT JOIN LATERAL (SELECT selling_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1) pp ON TRUE
+		WHERE p.merchant_id=$1 AND p.plu_code=$2`, merchantID, plu, format.UnitID).Scan(&line.StockItemID, &factor, &price)
+	if isNoRows(err) {
+		return nil, errScaleBarcodeUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	line.UnitPrice = math.Round(price*factor*100) / 100
+	if line.Quantity, line.LinePrice, ok = scaleLineQuantity(format.ValueMode, value, line.UnitPrice); !ok {
+		return nil, errScaleBarcodeValue
+	}
+	return line, nil
+}
+
+// checkoutScaleLine resolves the scale label sent with a checkout line. A label that
+// does not decode is rejected rather than sold as whatever the client sent.
+func checkoutScaleLine(ctx context.Context, tx DBTx, merchantID string, code *string) (*scaleLine, error) {
+	if code == nil || strings.TrimSpace(*code) == "" {
+		return nil, nil
+	}
+	line, err := resolveScaleBarcode(ctx, tx, merchantID, *code)
+	if err == nil && line == nil {
+		err = errScaleBarcodeUnknown
+	}
+	return line, err
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import "testing"
+
+func TestValidateScaleFormat(t *testing.T) {
+	if err := validateScaleFormat("21", 5, scaleModeWeight, 3); err != nil {
+		t.Fatalf("expected valid format, got %v", err)
+	}
+	if err := validateScaleFormat("40", 5, scaleModeWeight, 3); err != errScaleFormatPrefix {
+		t.Fatalf("expected prefix error, got %v", err)
+	}
+	if err := validateScaleFormat("2", 9, scaleModePrice, 2); err != errScaleFormatLayout {
+		t.Fatalf("expected layout error, got %v", err)
+	}
+}
+
+func TestParseScaleBarcode(t *testing.T) {
+	weight := scaleBarcodeFormat{Prefix: "21", ItemCodeLength: 5, ValueMode: scaleModeWeight, ValueDecimals: 3}
+	// 21 | 00123 | 01250 | check digit: PLU 123 weighing 1.250.
+	plu, value, ok := parseScaleBarcode("2100123012503", weight)
+	if !ok || plu != "123" || value != 1.25 {
+		t.Fatalf("unexpected parse %q %v %v", plu, value, ok)
+	}
+	if _, _, ok := parseScaleBarcode("2100123012504", weight); ok {
+		t.Fatalf("expected bad check digit to be rejected")
+	}
+	if _, _, ok := parseScaleBarcode("2200123012500", weight); ok {
+		t.Fatalf("expected other prefix to be rejected")
+	}
+
+	price := scaleBarcodeFormat{Prefix: "2", ItemCodeLength: 6, ValueMode: scaleModePrice, ValueDecimals: 0}
+	plu, value, ok = parseScaleBarcode("2000042045005", price)
+	if !ok || plu != "42" || value != 4500 {
+		t.Fatalf("unexpected parse %q %v %v", plu, value, ok)
+	}
+}
+
+func TestScaleLineQuantity(t *testing.T) {
+	if q, linePrice, ok := scaleLineQuantity(scaleModeWeight, 0.75, 0); !ok || q != 0.75 || linePrice != nil {
+		t.Fatalf("unexpected weight line %v %v %v", q, linePrice, ok)
+	}
+	q, linePrice, ok := scaleLineQuantity(scaleModePrice, 4500, 12000)
+	if !ok || q != 0.375 || linePrice == nil || *linePrice != 4500 {
+		t.Fatalf("unexpected price line %v %v %v", q, linePrice, ok)
+	}
+	line := scaleLine{Quantity: q, LinePrice: linePrice}
+	if total := line.sellingPrice(0) * q; total < 4499.999 || total > 4500.001 {
+		t.Fatalf("expected line total to match the label, got %v", total)
+	}
+	if _, _, ok := scaleLineQuantity(scaleModePrice, 4500, 0); ok {
+		t.Fatalf("expected price label without a unit price to fail")
+	}
+	if normalizePLU("000") != "0" || normalizePLU("00123") != "123" {
+		t.Fatalf("unexpected PLU normalisation")
+	}
+}
@@ -126,6 +126,9 @@ func listCanonicalShopItems(c *fiber.Ctx, shopID string) error {
 
 type UpdateStockRequest struct {
 	Quantity int `json:"quantity"`
+	// BinID is the counted bin: the quantity is that bin's count and the balance moves by
+	// the difference. Omitted, the quantity is the whole balance.
+	BinID *string `json:"binId,omitempty"`
 }
 
 func HandleUpdateShopItemStock(c *fiber.Ctx) error {
@@ -177,12 +180,28 @@ func HandleUpdateShopItemStock(c *fiber.Ctx) error {
 	if err = tx.QueryRow(ctx, `SELECT id,quantity_on_hand FROM inventory_items WHERE shop_id=$1 AND stock_item_id=$2 FOR UPDATE`, shopID, c.Params("itemId")).Scan(&invID, &old); err != nil {
 		return c.Status(404).JSON(fiber.Map{"status": "error", "message": "Item not found in shop inventory"})
 	}
-	delta := float64(req.Quantity) - old
+	// A count of one bin is compared with that bin's balance and moves the whole
+	// balance by the difference; otherwise the count replaces the whole balance.
+	counted := old
+	var countedBin *string
+	if trimmedString(req.BinID) != "" {
+		if countedBin, err = resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, invID, req.BinID, true); err == errStorageBinInvalid {
+			return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+		}
+		if err == nil {
+			counted, err = binBalance(ctx, pgxTxAdapter{tx: tx}, invID, *countedBin)
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve counted bin"})
+		}
+	}
+	delta := float64(req.Quantity) - counted
+	newQty := old + delta
 	var merchantID, productID string
 	if err = tx.QueryRow(ctx, `SELECT merchant_id,product_id FROM inventory_items WHERE id=$1`, invID).Scan(&merchantID, &productID); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve item"})
 	}
-	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, req.Quantity, invID); err != nil {
+	if _, err = tx.Exec(ctx, `UPDATE inventory_items SET quantity_on_hand=$1,updated_at=NOW() WHERE id=$2`, newQty, invID); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to update quantity"})
 	}
 	if delta != 0 {
@@ -194,7 +213,25 @@ func HandleUpdateShopItemStock(c *fiber.Ctx) error {
 		} else {
 			typ = "IN"
 		}
-		_, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,notes) VALUES($1,$2,$3,$4,$5,$6,$7,$7,'STAFF_ADJUSTMENT',$8)`, merchantID, shopID, invID, productID, c.Params("itemId"), typ, qty, "Staff stock quantity update")
+		binID := countedBin
+		if binID == nil {
+			binID, err = resolveMovementBin(ctx, pgxTxAdapter{tx: tx}, invID, nil, typ == "IN")
+		}
+		var unitCost float64
+		if err == nil && typ == "IN" {
+			unitCost, err = receiveInventoryCost(ctx, pgxTxAdapter{tx: tx}, invID, qty, nil)
+		} else if err == nil {
+			unitCost, err = issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, invID, qty, nil)
+		}
+		if err == nil {
+			_, err = tx.Exec(ctx, `INSERT INTO inventory_movements(merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,unit_cost,reference_type,notes,bin_id) VALUES($1,$2,$3,$4,$5,$6,$7,$7,$8,'STAFF_ADJUSTMENT',$9,$10)`, merchantID, shopID, invID, productID, c.Params("itemId"), typ, qty, unitCost, "Staff stock quantity update", binID)
+		}
+		if err == nil {
+			err = adjustBinBalance(ctx, pgxTxAdapter{tx: tx}, invID, binID, delta)
+		}
+		if err == nil && typ == "IN" {
+			err = settleBackorders(ctx, pgxTxAdapter{tx: tx}, invID)
+		}
 	}
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to record stock update"})
@@ -202,5 +239,5 @@ func HandleUpdateShopItemStock(c *fiber.Ctx) error {
 	if err = tx.Commit(ctx); err != nil {
 		return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to commit stock update"})
 	}
-	return c.JSON(fiber.Map{"status": "success", "success": true, "quantity": req.Quantity})
+	return c.JSON(fiber.Map{"status": "success", "success": true, "quantity": newQty})
 }
@@ -6,6 +6,7 @@ import (
 	"app/models"
 	"app/utils"
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -49,6 +50,13 @@ func resolveShopPOSScope(c *fiber.Ctx, db *pgxpool.Pool, requestedShopID string)
 	if err != nil {
 		return "", "", fiber.NewError(404, "Shop not found")
 	}
+	warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, shopID)
+	if err != nil {
+		return "", "", fiber.NewError(500, "Failed to resolve shop")
+	}
+	if warehouse {
+		return "", "", fiber.NewError(409, errNotSellingLocation.Error())
+	}
 	return shopID, merchantID, nil
 }
 
@@ -79,10 +87,11 @@ func HandleSearchShopProducts(c *fiber.Ctx) error {
 
 	baseQuery := `
 		SELECT si.id, ii.merchant_id, si.name, si.sku, COALESCE(pp.selling_price,0), COALESCE(pp.cost_price,0),
-			   ii.quantity_on_hand, ii.shop_id, si.created_at, si.updated_at
+			   stock.available, ii.shop_id, si.created_at, si.updated_at
 		FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN products p ON p.id=si.product_id
 		LEFT JOIN LATERAL(SELECT selling_price,cost_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1)pp ON TRUE
-		WHERE ii.shop_id = $1 AND ii.quantity_on_hand > 0 AND p.is_active=TRUE
+		` + availableStockJoin + `
+		WHERE ii.shop_id = $1 AND stock.available > 0 AND p.is_active=TRUE
 		  AND (si.name ILIKE $2 OR si.sku ILIKE $2)
 	`
 
@@ -122,14 +131,16 @@ func HandleSearchShopProducts(c *fiber.Ctx) error {
 	for rows.Next() {
 		var item models.InventoryItem
 		var stock models.ShopStock
+		var available float64
 		err := rows.Scan(
 			&item.ID, &item.MerchantID, &item.Name, &item.SKU, &item.SellingPrice, &item.OriginalPrice,
-			&stock.Quantity, &stock.ShopID, &item.CreatedAt, &item.UpdatedAt,
+			&available, &stock.ShopID, &item.CreatedAt, &item.UpdatedAt,
 		)
 		if err != nil {
 			log.Printf("Error scanning product row: %v", err)
 			continue
 		}
+		stock.Quantity = int(available)
 		item.Stock = &stock
 		items = append(items, item)
 	}
@@ -159,6 +170,23 @@ func HandleShopCheckout(c *fiber.Ctx) error {
 	if len(req.Items) == 0 || len(req.Items) > 100 || req.TotalAmount < 0 || req.DiscountAmount < 0 || req.DeliveryCharge < 0 || req.DiscountAmount > req.TotalAmount {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Invalid sale totals or item count"})
 	}
+	shopID, merchantID, err := resolveShopPOSScope(c, db, c.Params("shopId"))
+	if err != nil {
+		return err
+	}
+	for i, item := range req.Items {
+		scale, err := checkoutScaleLine(ctx, pgxPoolAdapter{pool: db}, merchantID, item.ScaleBarcode)
+		if err != nil {
+			if isScaleBarcodeError(err) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve scale barcode"})
+		}
+		if scale != nil {
+			req.Items[i].ProductID, req.Items[i].Quantity, req.Items[i].UnitID = scale.StockItemID, scale.Quantity, scale.Format.UnitID
+			req.Items[i].SellingPriceAtSale = scale.sellingPrice(item.SellingPriceAtSale)
+		}
+	}
 	var calculatedTotal float64
 	seenProducts := make(map[string]struct{}, len(req.Items))
 	for _, item := range req.Items {
@@ -169,7 +197,7 @@ func HandleShopCheckout(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Duplicate product lines are not allowed"})
 		}
 		seenProducts[item.ProductID] = struct{}{}
-		calculatedTotal += float64(item.Quantity) * item.SellingPriceAtSale
+		calculatedTotal += item.Quantity * item.SellingPriceAtSale
 	}
 	if calculatedTotal-req.DiscountAmount+req.DeliveryCharge < req.TotalAmount-0.01 || calculatedTotal-req.DiscountAmount+req.DeliveryCharge > req.TotalAmount+0.01 {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Sale total does not match item totals"})
@@ -178,11 +206,6 @@ func HandleShopCheckout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "clientSaleId is required"})
 	}
 
-	shopID, merchantID, err := resolveShopPOSScope(c, db, c.Params("shopId"))
-	if err != nil {
-		return err
-	}
-
 	tx, err := db.Begin(ctx)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not start transaction"})
@@ -219,11 +242,25 @@ func HandleShopCheckout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Could not create sale record"})
 	}
 
+	stockWarnings := make([]string, 0)
 	for _, item := range req.Items {
-		if err := processSaleItem(ctx, tx, saleID, shopID, staffID, item); err != nil {
+		warning, err := processSaleItem(ctx, tx, saleID, shopID, staffID, item)
+		if err != nil {
 			log.Printf("Error processing sale item %s: %v", item.ProductID, err)
+			if errors.Is(err, errInsufficientStock) || errors.Is(err, errReservationUnavailable) || errors.Is(err, errAssetNotFound) || isAssetError(err) || isBatchConsumptionError(err) || isKitSaleError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, errors.Unwrap(err))})
+			}
+			if isSerialError(err) {
+				return c.Status(serialErrorStatus(err)).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, errors.Unwrap(err))})
+			}
+			if isUnitError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, errors.Unwrap(err))})
+			}
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Error processing item %s", item.ProductID)})
 		}
+		if warning != "" {
+			stockWarnings = append(stockWarnings, warning)
+		}
 	}
 
 	// Generate invoice number and create invoice
@@ -267,10 +304,10 @@ func HandleShopCheckout(c *fiber.Ctx) error {
 	sale, err := getFullSaleDetails(ctx, db, saleID)
 	if err != nil {
 		log.Printf("Error retrieving final sale details: %v", err)
-		return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "message": "Checkout successful, but failed to retrieve final details"})
+		return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "message": "Checkout successful, but failed to retrieve final details", "stockWarnings": stockWarnings})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": sale})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": sale, "stockWarnings": stockWarnings})
 }
 
 func getMerchantIDFromShopID(ctx context.Context, db *pgxpool.Pool, shopID string) (string, error) {
@@ -292,46 +329,87 @@ func createSaleRecord(ctx context.Context, tx pgx.Tx, shopID, merchantID, staffI
 	return saleID, err
 }
 
-func processSaleItem(ctx context.Context, tx pgx.Tx, saleID, shopID, staffID string, item models.CheckoutItem) error {
+// processSaleItem records one checkout line and takes its stock. It returns the cashier
+// warning for a line sold beyond available stock under the WARN policy.
+func processSaleItem(ctx context.Context, tx pgx.Tx, saleID, shopID, staffID string, item models.CheckoutItem) (string, error) {
 	var current models.InventoryItem
 	var inventoryID, productID, merchantID string
 	lockQuery := `SELECT ii.id,si.product_id,s.name,si.sku,pp.cost_price,s.merchant_id FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN shops s ON s.id=ii.shop_id LEFT JOIN LATERAL(SELECT cost_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1)pp ON TRUE WHERE ii.shop_id=$1 AND ii.stock_item_id=$2 FOR UPDATE OF ii,si,s`
 	err := tx.QueryRow(ctx, lockQuery, shopID, item.ProductID).Scan(&inventoryID, &productID, &current.Name, &current.SKU, &current.OriginalPrice, &merchantID)
 	if err != nil {
-		return fmt.Errorf("could not find or lock inventory item %s: %w", item.ProductID, err)
+		return "", fmt.Errorf("could not find or lock inventory item %s: %w", item.ProductID, err)
+	}
+
+	qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, item.ProductID, item.UnitID, item.Quantity)
+	if err != nil {
+		return "", fmt.Errorf("could not resolve unit for item %s: %w", item.ProductID, err)
+	}
+	if err = consumeInventoryReservation(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.ReservationID), inventoryID, saleID); err != nil {
+		return "", fmt.Errorf("could not consume reservation for item %s: %w", item.ProductID, err)
 	}
 
 	saleItemQuery := `
-        INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, unit_id, item_name, item_sku, quantity_sold, base_quantity, selling_price_at_sale, original_price_at_sale, subtotal)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+        RETURNING id
     `
-	subtotal := float64(item.Quantity) * item.SellingPriceAtSale
-	_, err = tx.Exec(ctx, saleItemQuery, saleID, inventoryID, productID, item.ProductID, current.Name, current.SKU, item.Quantity, item.SellingPriceAtSale, current.OriginalPrice, subtotal)
+	subtotal := item.Quantity * item.SellingPriceAtSale
+	var saleItemID string
+	err = tx.QueryRow(ctx, saleItemQuery, saleID, inventoryID, productID, item.ProductID, qty.UnitID, current.Name, current.SKU, item.Quantity, qty.BaseQuantity, item.SellingPriceAtSale, current.OriginalPrice, subtotal).Scan(&saleItemID)
 	if err != nil {
-		return fmt.Errorf("could not create sale item record for %s: %w", item.ProductID, err)
+		return "", fmt.Errorf("could not create sale item record for %s: %w", item.ProductID, err)
 	}
-
-	stockUpdateQuery := `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2 AND quantity_on_hand >= $1 RETURNING quantity_on_hand`
-	var newQuantity float64
-	err = tx.QueryRow(ctx, stockUpdateQuery, item.Quantity, inventoryID).Scan(&newQuantity)
+	kit, err := loadStockItemKit(ctx, pgxTxAdapter{tx: tx}, item.ProductID)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return fmt.Errorf("insufficient stock for product %s", item.ProductID)
+		return "", fmt.Errorf("could not load kit for item %s: %w", item.ProductID, err)
+	}
+	if kit != nil {
+		kitCost, kitWarnings, err := sellKitComponents(ctx, pgxTxAdapter{tx: tx}, kit, merchantID, shopID, saleID, saleItemID, "SALE", qty.BaseQuantity)
+		if err != nil {
+			return "", fmt.Errorf("could not deduct kit components for item %s: %w", item.ProductID, err)
+		}
+		if err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, kitCost); err != nil {
+			return "", fmt.Errorf("could not record kit cost for item %s: %w", item.ProductID, err)
 		}
-		return fmt.Errorf("could not update stock for item %s: %w", item.ProductID, err)
+		return strings.Join(kitWarnings, "; "), nil
+	}
+
+	deduction, err := deductSaleStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, qty.BaseQuantity, "SALE", saleID)
+	if err != nil {
+		return "", fmt.Errorf("could not update stock for item %s: %w", item.ProductID, err)
+	}
+	batches, err := consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, item.ProductID, inventoryID, deduction.batched(qty.BaseQuantity), selectedBatchID(item.BatchID))
+	if err != nil {
+		return "", fmt.Errorf("could not consume batches for item %s: %w", item.ProductID, err)
+	}
+	if err = recordSaleItemBatches(ctx, pgxTxAdapter{tx: tx}, saleItemID, batches); err != nil {
+		return "", fmt.Errorf("could not record batch lines for item %s: %w", item.ProductID, err)
+	}
+	unitCost, err := issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, inventoryID, qty.BaseQuantity, batches)
+	if err != nil {
+		return "", fmt.Errorf("could not cost stock for item %s: %w", item.ProductID, err)
+	}
+	if err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, saleLineCost(qty.BaseQuantity, unitCost)); err != nil {
+		return "", fmt.Errorf("could not record cost for item %s: %w", item.ProductID, err)
+	}
+	if _, err = sellInventorySerials(ctx, pgxTxAdapter{tx: tx}, item.ProductID, inventoryID, shopID, saleID, staffID, qty.BaseQuantity, item.SerialNumbers); err != nil {
+		return "", fmt.Errorf("could not capture serials for item %s: %w", item.ProductID, err)
+	}
+	if err = sellInventoryAsset(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.AssetID), merchantID, inventoryID, saleID, staffID, qty.BaseQuantity); err != nil {
+		return "", fmt.Errorf("could not sell asset for item %s: %w", item.ProductID, err)
 	}
 
 	movementQuery := `
-        INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes)
-        VALUES ($1,$2,$3,$4,$5,'OUT',$6,$6,'SALE',$7,$8,$9)
+        INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes)
+        VALUES ($1,$2,$3,$4,$5,$6,'OUT',$7,$8,$9,'SALE',$10,$11,$12)
     `
 	reason := fmt.Sprintf("Sale #%s", saleID)
-	_, err = tx.Exec(ctx, movementQuery, merchantID, shopID, inventoryID, productID, item.ProductID, item.Quantity, saleID, fmt.Sprintf("%s:%s", saleID, item.ProductID), reason)
+	_, err = tx.Exec(ctx, movementQuery, merchantID, shopID, inventoryID, productID, item.ProductID, qty.UnitID, item.Quantity, qty.BaseQuantity, unitCost, saleID, fmt.Sprintf("%s:%s", saleID, item.ProductID), reason)
 	if err != nil {
-		return fmt.Errorf("could not log stock movement for item %s in sale %s: %w", item.ProductID, saleID, err)
+		return "", fmt.Errorf("could not log stock movement for item %s in sale %s: %w", item.ProductID, saleID, err)
 	}
 
-	return nil
+	return deduction.warning("Product " + item.ProductID), nil
 }
 
 func getFullSaleDetails(ctx context.Context, db *pgxpool.Pool, saleID string) (*models.Sale, error) {
@@ -344,7 +422,7 @@ func getFullSaleDetails(ctx context.Context, db *pgxpool.Pool, saleID string) (*
 		return nil, err
 	}
 
-	itemsQuery := "SELECT id, sale_id, inventory_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal, created_at, updated_at FROM sale_items WHERE sale_id = $1"
+	itemsQuery := "SELECT id, sale_id, inventory_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal, quantity_returned, created_at, updated_at FROM sale_items WHERE sale_id = $1"
 	rows, err := db.Query(ctx, itemsQuery, saleID)
 	if err != nil {
 		return nil, err
@@ -354,13 +432,17 @@ func getFullSaleDetails(ctx context.Context, db *pgxpool.Pool, saleID string) (*
 	for rows.Next() {
 		var item models.SaleItem
 		err := rows.Scan(
-			&item.ID, &item.SaleID, &item.InventoryItemID, &item.ItemName, &item.ItemSKU, &item.QuantitySold, &item.SellingPriceAtSale, &item.OriginalPriceAtSale, &item.Subtotal, &item.CreatedAt, &item.UpdatedAt,
+			&item.ID, &item.SaleID, &item.InventoryItemID, &item.ItemName, &item.ItemSKU, &item.QuantitySold, &item.SellingPriceAtSale, &item.OriginalPriceAtSale, &item.Subtotal, &item.QuantityReturned, &item.CreatedAt, &item.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 		sale.Items = append(sale.Items, item)
 	}
+	rows.Close()
+	if err := attachSaleItemBatches(ctx, db, &sale); err != nil {
+		return nil, err
+	}
 
 	return &sale, nil
 }
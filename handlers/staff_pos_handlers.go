@@ -12,7 +12,6 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jackc/pgx/v4"
 )
 
 // HandleSearchProductsForStaff godoc
@@ -42,6 +41,9 @@ func HandleSearchProductsForStaff(c *fiber.Ctx) error {
 	if err := db.QueryRow(ctx, userQuery, userID).Scan(&assignedShopID); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Assigned shop not found for this user"})
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, assignedShopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 
 	searchTerm := c.Query("searchTerm")
 
@@ -62,7 +64,8 @@ func HandleSearchProductsForStaff(c *fiber.Ctx) error {
 		SELECT si.id, si.merchant_id, si.name, si.sku, COALESCE(pp.selling_price,0), COALESCE(pp.cost_price,0), si.created_at, si.updated_at
 		FROM inventory_items ii JOIN stock_items si ON si.id=ii.stock_item_id JOIN products p ON p.id=si.product_id
 		LEFT JOIN LATERAL(SELECT selling_price,cost_price FROM product_prices WHERE product_id=si.product_id AND shop_id IS NULL AND price_type='RETAIL' ORDER BY created_at DESC LIMIT 1)pp ON TRUE
-		WHERE ii.shop_id = $1 AND ii.quantity_on_hand > 0 AND p.is_active=TRUE
+		` + availableStockJoin + `
+		WHERE ii.shop_id = $1 AND stock.available > 0 AND p.is_active=TRUE
 		AND (si.name ILIKE $2 OR si.sku ILIKE $2)
 	`
 
@@ -139,6 +142,9 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 	if err = db.QueryRow(ctx, userQuery, userID).Scan(&assignedShopID, &merchantID); err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"status": "error", "message": "Assigned shop not found for this user"})
 	}
+	if warehouse, err := isWarehouseLocation(ctx, pgxPoolAdapter{pool: db}, assignedShopID); err != nil || warehouse {
+		return rejectNonSellingLocation(c, err)
+	}
 
 	var req models.StaffCheckoutRequest
 	if err := c.BodyParser(&req); err != nil {
@@ -151,6 +157,19 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 	if len(req.Items) == 0 || len(req.Items) > 100 || req.TotalAmount < 0 || req.DeliveryCharge < 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": "Invalid sale totals or item count"})
 	}
+	for i, item := range req.Items {
+		scale, err := checkoutScaleLine(ctx, pgxPoolAdapter{pool: db}, merchantID, item.ScaleBarcode)
+		if err != nil {
+			if isScaleBarcodeError(err) {
+				return c.Status(400).JSON(fiber.Map{"status": "error", "message": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"status": "error", "message": "Failed to resolve scale barcode"})
+		}
+		if scale != nil {
+			req.Items[i].ProductID, req.Items[i].Quantity, req.Items[i].UnitID = scale.StockItemID, scale.Quantity, scale.Format.UnitID
+			req.Items[i].SellingPriceAtSale = scale.sellingPrice(item.SellingPriceAtSale)
+		}
+	}
 	var calculatedTotal float64
 	seenProducts := make(map[string]struct{}, len(req.Items))
 	for _, item := range req.Items {
@@ -161,7 +180,7 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 			return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Duplicate product lines are not allowed"})
 		}
 		seenProducts[item.ProductID] = struct{}{}
-		calculatedTotal += float64(item.Quantity) * item.SellingPriceAtSale
+		calculatedTotal += item.Quantity * item.SellingPriceAtSale
 	}
 	if calculatedTotal-req.DeliveryCharge < req.TotalAmount-0.01 || calculatedTotal-req.DeliveryCharge > req.TotalAmount+0.01 {
 		return c.Status(400).JSON(fiber.Map{"status": "error", "message": "Sale total does not match item totals"})
@@ -223,6 +242,7 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create sale"})
 	}
 
+	stockWarnings := make([]string, 0)
 	for _, item := range req.Items {
 		// Resolve canonical stock item and shop balance.
 		var itemName string
@@ -236,34 +256,107 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s not found", item.ProductID)})
 		}
 
-		subtotal := float64(item.Quantity) * item.SellingPriceAtSale
+		qty, err := resolveUnitQuantity(ctx, pgxTxAdapter{tx: tx}, item.ProductID, item.UnitID, item.Quantity)
+		if err != nil {
+			if isUnitError(err) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error resolving sale unit: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create sale item"})
+		}
+		if err = consumeInventoryReservation(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.ReservationID), inventoryID, sale.ID); err != nil {
+			if err == errReservationUnavailable {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error consuming reservation: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+
+		subtotal := item.Quantity * item.SellingPriceAtSale
 		saleItemQuery := `
-            INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, item_name, item_sku, quantity_sold, selling_price_at_sale, original_price_at_sale, subtotal)
-            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+            INSERT INTO sale_items (sale_id, inventory_item_id, product_id, stock_item_id, unit_id, item_name, item_sku, quantity_sold, base_quantity, selling_price_at_sale, original_price_at_sale, subtotal)
+            VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+            RETURNING id
         `
-		_, err = tx.Exec(ctx, saleItemQuery, sale.ID, inventoryID, productID, item.ProductID, itemName, itemSKU, item.Quantity, item.SellingPriceAtSale, originalPrice, subtotal)
+		var saleItemID string
+		err = tx.QueryRow(ctx, saleItemQuery, sale.ID, inventoryID, productID, item.ProductID, qty.UnitID, itemName, itemSKU, item.Quantity, qty.BaseQuantity, item.SellingPriceAtSale, originalPrice, subtotal).Scan(&saleItemID)
 		if err != nil {
 			log.Printf("Error creating sale item: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create sale item"})
 		}
 
-		updateStockQuery := `UPDATE inventory_items SET quantity_on_hand=quantity_on_hand-$1,updated_at=NOW() WHERE id=$2 AND quantity_on_hand >= $1 RETURNING quantity_on_hand`
-		var currentQuantity float64
-		err = tx.QueryRow(ctx, updateStockQuery, item.Quantity, inventoryID).Scan(&currentQuantity)
+		kit, err := loadStockItemKit(ctx, pgxTxAdapter{tx: tx}, item.ProductID)
+		if err == nil && kit != nil {
+			var kitCost float64
+			var kitWarnings []string
+			kitCost, kitWarnings, err = sellKitComponents(ctx, pgxTxAdapter{tx: tx}, kit, merchantID, assignedShopID, sale.ID, saleItemID, "SALE", qty.BaseQuantity)
+			if err == nil {
+				err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, kitCost)
+			}
+			if err == nil {
+				stockWarnings = append(stockWarnings, kitWarnings...)
+				continue
+			}
+		}
 		if err != nil {
-			if err == pgx.ErrNoRows {
+			if isKitSaleError(err) || isBatchConsumptionError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error deducting kit components: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+
+		deduction, err := deductSaleStock(ctx, pgxTxAdapter{tx: tx}, inventoryID, qty.BaseQuantity, "SALE", sale.ID)
+		if err != nil {
+			if err == errInsufficientStock {
 				log.Printf("Insufficient stock for product %s", item.ProductID)
 				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Insufficient stock for product %s", item.ProductID)})
 			}
 			log.Printf("Error updating stock: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
 		}
+		if warning := deduction.warning(itemName); warning != "" {
+			stockWarnings = append(stockWarnings, warning)
+		}
+		batches, err := consumeInventoryBatches(ctx, pgxTxAdapter{tx: tx}, item.ProductID, inventoryID, deduction.batched(qty.BaseQuantity), selectedBatchID(item.BatchID))
+		if err == nil {
+			err = recordSaleItemBatches(ctx, pgxTxAdapter{tx: tx}, saleItemID, batches)
+		}
+		if err != nil {
+			if isBatchConsumptionError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error consuming batches: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		unitCost, err := issueInventoryCost(ctx, pgxTxAdapter{tx: tx}, merchantID, inventoryID, qty.BaseQuantity, batches)
+		if err == nil {
+			err = recordSaleItemCost(ctx, pgxTxAdapter{tx: tx}, saleItemID, qty.BaseQuantity, saleLineCost(qty.BaseQuantity, unitCost))
+		}
+		if err != nil {
+			log.Printf("Error costing sold stock: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		if _, err = sellInventorySerials(ctx, pgxTxAdapter{tx: tx}, item.ProductID, inventoryID, assignedShopID, sale.ID, userID, qty.BaseQuantity, item.SerialNumbers); err != nil {
+			if isSerialError(err) {
+				return c.Status(serialErrorStatus(err)).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error marking serials sold: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
+		if err = sellInventoryAsset(ctx, pgxTxAdapter{tx: tx}, trimmedString(item.AssetID), merchantID, inventoryID, sale.ID, userID, qty.BaseQuantity); err != nil {
+			if err == errAssetNotFound || isAssetError(err) {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": fmt.Sprintf("Product %s: %v", item.ProductID, err)})
+			}
+			log.Printf("Error marking asset sold: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to update stock"})
+		}
 
 		stockMovementQuery := `
-            INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,movement_type,quantity,base_quantity,reference_type,reference_id,event_key,notes)
-            VALUES ($1,$2,$3,$4,$5,'OUT',$6,$6,'SALE',$7,$8,'Sale')
+            INSERT INTO inventory_movements (merchant_id,shop_id,inventory_item_id,product_id,stock_item_id,unit_id,movement_type,quantity,base_quantity,unit_cost,reference_type,reference_id,event_key,notes)
+            VALUES ($1,$2,$3,$4,$5,$6,'OUT',$7,$8,$9,'SALE',$10,$11,'Sale')
         `
-		_, err = tx.Exec(ctx, stockMovementQuery, merchantID, assignedShopID, inventoryID, productID, item.ProductID, item.Quantity, sale.ID, fmt.Sprintf("%s:%s", sale.ID, item.ProductID))
+		_, err = tx.Exec(ctx, stockMovementQuery, merchantID, assignedShopID, inventoryID, productID, item.ProductID, qty.UnitID, item.Quantity, qty.BaseQuantity, unitCost, sale.ID, fmt.Sprintf("%s:%s", sale.ID, item.ProductID))
 		if err != nil {
 			log.Printf("Error creating stock movement: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to create stock movement"})
@@ -302,7 +395,7 @@ func HandleStaffCheckout(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to commit transaction"})
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": sale})
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"status": "success", "success": true, "data": sale, "stockWarnings": stockWarnings})
 }
 
 // HandleGetActivePromotionsForStaff godoc
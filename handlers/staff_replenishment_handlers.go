@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"app/database"
+	"app/middleware"
+	"app/models"
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+var replenishmentRequestSortFields = map[string]string{"createdAt": "r.created_at", "neededBy": "r.needed_by", "status": "r.status"}
+
+// staffShop returns the shop a staff member is assigned to and the merchant owning it.
+func staffShop(ctx context.Context, db *pgxpool.Pool, staffID string) (string, string, error) {
+	var shopID, merchantID string
+	err := db.QueryRow(ctx, `SELECT sh.id,sh.merchant_id FROM users u JOIN shops sh ON sh.id=u.assigned_shop_id WHERE u.id=$1 AND u.role='staff'`, staffID).Scan(&shopID, &merchantID)
+	return shopID, merchantID, err
+}
+
+// HandleListStaffReplenishmentRequests lists the requests raised for the staff member's shop.
+func HandleListStaffReplenishmentRequests(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	shopID, _, err := staffShop(ctx, db, claims.UserID)
+	if err != nil {
+		return fiber.NewError(404, "assigned shop not found")
+	}
+	return listReplenishmentRequests(c, " WHERE r.shop_id=$1", shopID)
+}
+
+// listReplenishmentRequests lists requests matching where, whose first placeholder is
+// scope, narrowed by the status and shopId query filters.
+func listReplenishmentRequests(c *fiber.Ctx, where, scope string) error {
+	q := getCatalogListQuery(c, "createdAt", replenishmentRequestSortFields)
+	args := []interface{}{scope}
+	for _, filter := range [][2]string{{"status", "r.status"}, {"shopId", "r.shop_id::text"}} {
+		if v := c.Query(filter[0]); v != "" {
+			where += " AND " + filter[1] + "=$" + itoa(len(args)+1)
+			args = append(args, v)
+		}
+	}
+	db, ctx := database.GetDB(), context.Background()
+	var total int64
+	if err := db.QueryRow(ctx, "SELECT COUNT(*)"+replenishmentRequestFrom+where, args...).Scan(&total); err != nil {
+		return fiber.NewError(500, "failed to count replenishment requests")
+	}
+	rows, err := db.Query(ctx, "SELECT "+replenishmentRequestColumns+replenishmentRequestFrom+where+q.orderBy()+" LIMIT $"+itoa(len(args)+1)+" OFFSET $"+itoa(len(args)+2), append(args, q.PageSize, q.Offset)...)
+	if err != nil {
+		return fiber.NewError(500, "failed to list replenishment requests")
+	}
+	defer rows.Close()
+	items := make([]models.ReplenishmentRequest, 0)
+	for rows.Next() {
+		var item models.ReplenishmentRequest
+		if err := scanReplenishmentRequest(rows, &item); err != nil {
+			return fiber.NewError(500, "failed to read replenishment request")
+		}
+		items = append(items, item)
+	}
+	return c.JSON(paginatedResponse(items, total, q))
+}
+
+// HandleGetStaffReplenishmentRequest returns a request of the staff member's shop with its lines.
+func HandleGetStaffReplenishmentRequest(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	shopID, _, err := staffShop(ctx, db, claims.UserID)
+	if err != nil {
+		return fiber.NewError(404, "assigned shop not found")
+	}
+	requestID := c.Params("requestId")
+	item, err := loadReplenishmentRequest(ctx, db, db.QueryRow(ctx, "SELECT "+replenishmentRequestColumns+replenishmentRequestFrom+" WHERE r.id::text=$1 AND r.shop_id=$2", requestID, shopID), requestID)
+	if isNoRows(err) {
+		return fiber.NewError(404, "replenishment request not found")
+	}
+	if err != nil {
+		return fiber.NewError(500, "failed to load replenishment request")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleCreateStaffReplenishmentRequest asks the merchant for stock for the staff
+// member's shop and notifies the merchant.
+func HandleCreateStaffReplenishmentRequest(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	var req models.CreateReplenishmentRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(400, "invalid request body")
+	}
+	neededBy, err := validateReplenishmentRequest(&req)
+	if err != nil {
+		return fiber.NewError(400, err.Error())
+	}
+	db, ctx := database.GetDB(), context.Background()
+	shopID, merchantID, err := staffShop(ctx, db, claims.UserID)
+	if err != nil {
+		return fiber.NewError(404, "assigned shop not found")
+	}
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start replenishment request")
+	}
+	defer tx.Rollback(ctx)
+	var requestID, shopName string
+	if err = tx.QueryRow(ctx, `INSERT INTO replenishment_requests(merchant_id,shop_id,requested_by,notes,needed_by) VALUES($1,$2,$3,$4,$5) RETURNING id,(SELECT name FROM shops WHERE id=$2)`, merchantID, shopID, claims.UserID, req.Notes, neededBy).Scan(&requestID, &shopName); err != nil {
+		return fiber.NewError(500, "failed to create replenishment request")
+	}
+	for _, line := range req.Items {
+		tag, err := tx.Exec(ctx, `INSERT INTO replenishment_request_items(request_id,stock_item_id,quantity,note) SELECT $1,id,$3,$4 FROM stock_items WHERE id::text=$2 AND merchant_id=$5`, requestID, line.StockItemID, line.Quantity, line.Note, merchantID)
+		if err != nil {
+			return fiber.NewError(500, "failed to save replenishment request item")
+		}
+		if tag.RowsAffected() == 0 {
+			return fiber.NewError(400, "stock item "+line.StockItemID+" not found")
+		}
+	}
+	if err = notifyReplenishmentParties(ctx, pgxTxAdapter{tx: tx}, requestID, claims.UserID, "Stock requested for "+shopName, itoa(len(req.Items))+" item(s) requested by "+shopName+" are waiting for review."); err != nil {
+		return fiber.NewError(500, "failed to notify merchant")
+	}
+	item, err := loadReplenishmentRequest(ctx, tx, tx.QueryRow(ctx, "SELECT "+replenishmentRequestColumns+replenishmentRequestFrom+" WHERE r.id=$1", requestID), requestID)
+	if err != nil {
+		return fiber.NewError(500, "failed to load replenishment request")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit replenishment request")
+	}
+	return c.Status(201).JSON(fiber.Map{"status": "success", "success": true, "data": item})
+}
+
+// HandleCancelStaffReplenishmentRequest withdraws a request its requester no longer needs.
+func HandleCancelStaffReplenishmentRequest(c *fiber.Ctx) error {
+	claims, err := middleware.ExtractClaims(c)
+	if err != nil {
+		return err
+	}
+	db, ctx := database.GetDB(), context.Background()
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return fiber.NewError(500, "failed to start replenishment request update")
+	}
+	defer tx.Rollback(ctx)
+	var status, shopName string
+	if err = tx.QueryRow(ctx, `SELECT r.status,sh.name FROM replenishment_requests r JOIN shops sh ON sh.id=r.shop_id WHERE r.id::text=$1 AND r.requested_by=$2 FOR UPDATE OF r`, c.Params("requestId"), claims.UserID).Scan(&status, &shopName); err != nil {
+		if isNoRows(err) {
+			return fiber.NewError(404, "replenishment request not found")
+		}
+		return fiber.NewError(500, "failed to load replenishment request")
+	}
+	if status != "SUBMITTED" {
+		return fiber.NewError(409, errReplenishmentClosed.Error())
+	}
+	if _, err = tx.Exec(ctx, `UPDATE replenishment_requests SET status='CANCELLED',updated_at=NOW() WHERE id::text=$1`, c.Params("requestId")); err != nil {
+		return fiber.NewError(500, "failed to cancel replenishment request")
+	}
+	if err = notifyReplenishmentParties(ctx, pgxTxAdapter{tx: tx}, c.Params("requestId"), claims.UserID, "Stock request cancelled", "The stock request from "+shopName+" was cancelled by its requester."); err != nil {
+		return fiber.NewError(500, "failed to notify merchant")
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fiber.NewError(500, "failed to commit replenishment request update")
+	}
+	return c.JSON(fiber.Map{"status": "success", "success": true, "data": fiber.Map{"id": c.Params("requestId"), "status": "CANCELLED"}})
+}
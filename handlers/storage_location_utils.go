@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Stock location types. Warehouses hold and move stock but never sell at the POS.
+const (
+	locationStore     = "STORE"
+	locationWarehouse = "WAREHOUSE"
+	locationVan       = "VAN"
+)
+
+var (
+	errLocationTypeInvalid = errors.New("locationType must be STORE, WAREHOUSE or VAN")
+	errNotSellingLocation  = errors.New("warehouse locations cannot sell at the POS")
+	errStorageBinInvalid   = errors.New("bin is not an active bin in this location")
+)
+
+// normalizeLocationType upper-cases a requested location type, defaulting to STORE.
+func normalizeLocationType(value string) (string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return locationStore, nil
+	case locationStore, locationWarehouse, locationVan:
+		return value, nil
+	}
+	return "", errLocationTypeInvalid
+}
+
+// isWarehouseLocation reports whether the shop is a warehouse. Unknown shops are not,
+// so callers keep their own not-found handling.
+func isWarehouseLocation(ctx context.Context, tx DBTx, shopID string) (bool, error) {
+	var warehouse bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM shops WHERE id=$1 AND location_type='WAREHOUSE')`, shopID).Scan(&warehouse)
+	return warehouse, err
+}
+
+// validateStorageBin checks that binID is an active bin in the shop.
+func validateStorageBin(ctx context.Context, tx DBTx, shopID, binID string) error {
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM storage_bins WHERE id::text=$1 AND shop_id=$2 AND is_active)`, binID, shopID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errStorageBinInvalid
+	}
+	return nil
+}
+
+// resolveMovementBin picks the bin a movement on a balance targets: the requested bin,
+// which must be active in the balance's location, or else the balance's put-away bin
+// for stock coming in and its pick bin for stock going out. It returns nil when the
+// location does not use bins for this balance.
+func resolveMovementBin(ctx context.Context, tx DBTx, inventoryItemID string, requested *string, inbound bool) (*string, error) {
+	var shopID string
+	var putaway, pick *string
+	if err := tx.QueryRow(ctx, `SELECT shop_id,putaway_bin_id,pick_bin_id FROM inventory_items WHERE id=$1`, inventoryItemID).Scan(&shopID, &putaway, &pick); err != nil {
+		return nil, err
+	}
+	if binID := trimmedString(requested); binID != "" {
+		if err := validateStorageBin(ctx, tx, shopID, binID); err != nil {
+			return nil, err
+		}
+		return &binID, nil
+	}
+	if inbound {
+		return putaway, nil
+	}
+	return pick, nil
+}
+
+// adjustBinBalance moves a balance's quantity in one bin by delta. Movements outside any
+// bin leave bin balances alone.
+func adjustBinBalance(ctx context.Context, tx DBTx, inventoryItemID string, binID *string, delta float64) error {
+	if binID == nil || delta == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO inventory_bin_balances(inventory_item_id,bin_id,quantity) VALUES($1,$2,$3)
+		ON CONFLICT (inventory_item_id,bin_id) DO UPDATE SET quantity=inventory_bin_balances.quantity+EXCLUDED.quantity,updated_at=NOW()`, inventoryItemID, *binID, delta)
+	return err
+}
+
+// moveDefaultBinStock books delta against the balance's default bin, its put-away bin
+// for stock coming in and its pick bin for stock going out, and returns that bin for
+// the movement to carry.
+func moveDefaultBinStock(ctx context.Context, tx DBTx, inventoryItemID string, delta float64) (*string, error) {
+	binID, err := resolveMovementBin(ctx, tx, inventoryItemID, nil, delta > 0)
+	if err != nil {
+		return nil, err
+	}
+	return binID, adjustBinBalance(ctx, tx, inventoryItemID, binID, delta)
+}
+
+// binBalance is the quantity of a balance held in one bin, zero when none was put there.
+func binBalance(ctx context.Context, tx DBTx, inventoryItemID, binID string) (float64, error) {
+	var quantity float64
+	err := tx.QueryRow(ctx, `SELECT COALESCE((SELECT quantity FROM inventory_bin_balances WHERE inventory_item_id=$1 AND bin_id::text=$2 FOR UPDATE),0)::float8`, inventoryItemID, binID).Scan(&quantity)
+	return quantity, err
+}
+
+// rejectNonSellingLocation answers a POS request aimed at a warehouse, or the failure
+// to check the location type.
+func rejectNonSellingLocation(c *fiber.Ctx, err error) error {
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"status": "error", "message": "Failed to resolve shop"})
+	}
+	return c.Status(fiber.StatusConflict).JSON(fiber.Map{"status": "error", "message": errNotSellingLocation.Error()})
+}
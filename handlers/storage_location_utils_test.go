@@ -0,0 +1,22 @@
+package handlers
+
+import "testing"
+
+func TestNormalizeLocationType(t *testing.T) {
+	cases := []struct {
+		in, want string
+		wantErr  error
+	}{
+		{"", locationStore, nil},
+		{" warehouse ", locationWarehouse, nil},
+		{"Van", locationVan, nil},
+		{"STORE", locationStore, nil},
+		{"depot", "", errLocationTypeInvalid},
+	}
+	for _, tc := range cases {
+		got, err := normalizeLocationType(tc.in)
+		if got != tc.want || err != tc.wantErr {
+			t.Fatalf("%q: expected %q/%v, got %q/%v", tc.in, tc.want, tc.wantErr, got, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"app/models"
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	errInvoiceOrderNotBillable = errors.New("draft and cancelled purchase orders cannot be billed")
+	errInvoiceLineNotOnOrder   = errors.New("invoice line is not on this purchase order")
+	errPaymentExceedsBalance   = errors.New("payment exceeds the invoice balance")
+	errInvoiceDateInvalid      = errors.New("dates must be in YYYY-MM-DD format")
+)
+
+// billableOrderStatus reports whether a purchase order in status can take supplier
+// invoices: it must have been approved and not cancelled since.
+func billableOrderStatus(status string) bool {
+	return status != "DRAFT" && status != "CANCELLED"
+}
+
+// invoiceLineVariance is how a billed line differs from what was received and ordered.
+// Quantity variance is billed minus received-but-not-yet-billed; price variance is the
+// billed unit cost minus the ordered one.
+type invoiceLineVariance struct {
+	Quantity float64
+	Price    float64
+}
+
+// matchInvoiceLine performs the three-way match of one billed line. Billing less than
+// was received is not a variance, since the rest may arrive on a later invoice.
+func matchInvoiceLine(billable, quantity, orderedCost, unitCost float64) invoiceLineVariance {
+	variance := invoiceLineVariance{Price: roundMoney(unitCost - orderedCost)}
+	if over := math.Round((quantity-billable)*1000) / 1000; over > 0 {
+		variance.Quantity = over
+	}
+	return variance
+}
+
+func (v invoiceLineVariance) flagged() bool {
+	return v.Quantity != 0 || v.Price != 0
+}
+
+// supplierInvoiceStatus derives the payment status from the amount paid so far.
+func supplierInvoiceStatus(total, paid float64) string {
+	switch {
+	case paid <= 0:
+		return "UNPAID"
+	case roundMoney(paid) >= roundMoney(total):
+		return "PAID"
+	}
+	return "PARTIAL"
+}
+
+// addAgedAmount puts an outstanding amount into the row's bucket for days past due.
+// Invoices not yet due count as 0-30.
+func addAgedAmount(row *models.APAgingRow, daysPastDue int, amount float64) {
+	switch {
+	case daysPastDue <= 30:
+		row.Days0To30 = roundMoney(row.Days0To30 + amount)
+	case daysPastDue <= 60:
+		row.Days31To60 = roundMoney(row.Days31To60 + amount)
+	case daysPastDue <= 90:
+		row.Days61To90 = roundMoney(row.Days61To90 + amount)
+	default:
+		row.Days90Plus = roundMoney(row.Days90Plus + amount)
+	}
+	row.Total = roundMoney(row.Total + amount)
+}
+
+// daysBetween counts whole calendar days from one date to another.
+func daysBetween(from, to time.Time) int {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// parseOptionalDate validates an optional YYYY-MM-DD value.
+func parseOptionalDate(value *string) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	day, err := time.Parse("2006-01-02", *value)
+	if err != nil {
+		return nil, errInvoiceDateInvalid
+	}
+	return &day, nil
+}
+
+func roundMoney(value float64) float64 {
+	return math.Round(value*100) / 100
+}
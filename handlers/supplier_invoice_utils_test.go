@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"app/models"
+	"testing"
+	"time"
+)
+
+func TestBillableOrderStatus(t *testing.T) {
+	for status, want := range map[string]bool{"DRAFT": false, "CANCELLED": false, "APPROVED": true, "PARTIALLY_RECEIVED": true, "RECEIVED": true} {
+		if billableOrderStatus(status) != want {
+			t.Fatalf("%s: expected billable %v", status, want)
+		}
+	}
+}
+
+func TestMatchInvoiceLine(t *testing.T) {
+	cases := []struct {
+		billable, quantity, orderedCost, unitCost float64
+		want                                      invoiceLineVariance
+	}{
+		{10, 10, 2.5, 2.5, invoiceLineVariance{}},
+		{10, 6, 2.5, 2.5, invoiceLineVariance{}},
+		{10, 12, 2.5, 2.5, invoiceLineVariance{Quantity: 2}},
+		{10, 10, 2.5, 2.75, invoiceLineVariance{Price: 0.25}},
+		{4, 5, 3, 2.9, invoiceLineVariance{Quantity: 1, Price: -0.1}},
+	}
+	for _, tc := range cases {
+		got := matchInvoiceLine(tc.billable, tc.quantity, tc.orderedCost, tc.unitCost)
+		if got != tc.want {
+			t.Fatalf("%+v: expected %+v, got %+v", tc, tc.want, got)
+		}
+		if got.flagged() != (tc.want != invoiceLineVariance{}) {
+			t.Fatalf("%+v: unexpected flag %v", tc, got.flagged())
+		}
+	}
+}
+
+func TestSupplierInvoiceStatus(t *testing.T) {
+	cases := []struct {
+		total, paid float64
+		want        string
+	}{
+		{100, 0, "UNPAID"},
+		{100, 40, "PARTIAL"},
+		{100, 99.999, "PAID"},
+		{100, 100, "PAID"},
+	}
+	for _, tc := range cases {
+		if got := supplierInvoiceStatus(tc.total, tc.paid); got != tc.want {
+			t.Fatalf("%v/%v: expected %s, got %s", tc.total, tc.paid, tc.want, got)
+		}
+	}
+}
+
+func TestAddAgedAmountBuckets(t *testing.T) {
+	asOf := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+	var row models.APAgingRow
+	for _, due := range []time.Time{
+		asOf.AddDate(0, 0, 10),
+		asOf.AddDate(0, 0, -30),
+		asOf.AddDate(0, 0, -31),
+		asOf.AddDate(0, 0, -90),
+		asOf.AddDate(0, 0, -91),
+	} {
+		addAgedAmount(&row, daysBetween(due, asOf), 100)
+	}
+	if row.Days0To30 != 200 || row.Days31To60 != 100 || row.Days61To90 != 100 || row.Days90Plus != 100 || row.Total != 500 {
+		t.Fatalf("unexpected buckets %+v", row)
+	}
+}
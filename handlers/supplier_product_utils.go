@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"app/models"
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	errSupplierProductQuantity = errors.New("minOrderQuantity and lastCost cannot be negative and packSize must be positive")
+	errSupplierProductLeadTime = errors.New("leadTimeDays must be between 0 and 365")
+)
+
+// validateSupplierProduct checks the figures of a supplier catalog entry and trims its SKU.
+func validateSupplierProduct(req *models.SupplierProductRequest) error {
+	if (req.LastCost != nil && *req.LastCost < 0) || (req.MinOrderQuantity != nil && *req.MinOrderQuantity < 0) || (req.PackSize != nil && *req.PackSize <= 0) {
+		return errSupplierProductQuantity
+	}
+	if req.LeadTimeDays != nil && (*req.LeadTimeDays < 0 || *req.LeadTimeDays > 365) {
+		return errSupplierProductLeadTime
+	}
+	if req.SupplierSKU != nil {
+		sku := strings.TrimSpace(*req.SupplierSKU)
+		req.SupplierSKU = &sku
+	}
+	return nil
+}
+
+// supplierProductColumns reads a catalog entry as sp joined to its supplier s and stock
+// item si; the base unit cost divides the last cost by the purchase unit's factor.
+const supplierProductColumns = `sp.id,sp.supplier_id,s.name,sp.stock_item_id,si.name,sp.supplier_sku,sp.unit_id,sp.last_cost::float8,(sp.last_cost/COALESCE(su.conversion_to_base,1))::float8,sp.min_order_quantity::float8,sp.pack_size::float8,COALESCE(sp.lead_time_days,s.lead_time_days),sp.is_preferred,sp.created_at,sp.updated_at`
+
+const supplierProductFrom = ` FROM supplier_products sp JOIN suppliers s ON s.id=sp.supplier_id JOIN stock_items si ON si.id=sp.stock_item_id LEFT JOIN stock_item_units su ON su.stock_item_id=sp.stock_item_id AND su.unit_id=sp.unit_id`
+
+func scanSupplierProduct(row DBRow, item *models.SupplierProduct) error {
+	return row.Scan(&item.ID, &item.SupplierID, &item.SupplierName, &item.StockItemID, &item.StockItemName, &item.SupplierSKU, &item.UnitID, &item.LastCost, &item.BaseUnitCost, &item.MinOrderQuantity, &item.PackSize, &item.LeadTimeDays, &item.IsPreferred, &item.CreatedAt, &item.UpdatedAt)
+}
+
+// prefillPurchaseItemCosts fills in order lines sent without a unit cost from the
+// supplier's catalog, ordering in the catalog's purchase unit unless one was given.
+// Lines the catalog has no cost for are ordered at zero, as before.
+func prefillPurchaseItemCosts(ctx context.Context, tx DBTx, supplierID string, items []purchaseItemRequest) error {
+	for n := range items {
+		item := &items[n]
+		if item.UnitCost != nil {
+			continue
+		}
+		cost := 0.0
+		item.UnitCost = &cost
+		if item.StockItemID == "" {
+			continue
+		}
+		var unitID *string
+		var baseCost float64
+		err := tx.QueryRow(ctx, `SELECT sp.unit_id::text,(sp.last_cost/COALESCE(su.conversion_to_base,1))::float8 FROM supplier_products sp LEFT JOIN stock_item_units su ON su.stock_item_id=sp.stock_item_id AND su.unit_id=sp.unit_id WHERE sp.supplier_id::text=$1 AND sp.stock_item_id::text=$2 AND sp.last_cost IS NOT NULL`, supplierID, item.StockItemID).Scan(&unitID, &baseCost)
+		if isNoRows(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if item.UnitID == nil {
+			item.UnitID = unitID
+		}
+		// An unknown unit is left for resolvePurchaseItems to reject.
+		if qty, err := resolveUnitQuantity(ctx, tx, item.StockItemID, item.UnitID, 1); err == nil {
+			cost = roundMoney(baseCost * qty.Factor)
+		} else if !isUnitError(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordSupplierLastCost keeps a supplier's catalog cost for a stock item at what it was
+// last received at, converted from the base unit to the catalog's purchase unit.
+func recordSupplierLastCost(ctx context.Context, tx DBTx, supplierID, stockItemID string, baseCost float64) error {
+	_, err := tx.Exec(ctx, `UPDATE supplier_products sp SET last_cost=ROUND(($3*COALESCE((SELECT su.conversion_to_base FROM stock_item_units su WHERE su.stock_item_id=sp.stock_item_id AND su.unit_id=sp.unit_id),1))::numeric,4),updated_at=NOW() WHERE sp.supplier_id::text=$1 AND sp.stock_item_id::text=$2`, supplierID, stockItemID, baseCost)
+	return err
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"app/models"
+	"testing"
+)
+
+func TestValidateSupplierProduct(t *testing.T) {
+	sku, cost, moq, pack, lead := "  AB-12 ", 4.5, 10.0, 6.0, 14
+	req := models.SupplierProductRequest{SupplierSKU: &sku, LastCost: &cost, MinOrderQuantity: &moq, PackSize: &pack, LeadTimeDays: &lead}
+	if err := validateSupplierProduct(&req); err != nil || *req.SupplierSKU != "AB-12" {
+		t.Fatalf("unexpected result %v, sku %q", err, *req.SupplierSKU)
+	}
+	negative, zero, late := -1.0, 0.0, 400
+	for _, bad := range []models.SupplierProductRequest{
+		{LastCost: &negative},
+		{MinOrderQuantity: &negative},
+		{PackSize: &zero},
+	} {
+		if err := validateSupplierProduct(&bad); err != errSupplierProductQuantity {
+			t.Fatalf("%+v: expected quantity error, got %v", bad, err)
+		}
+	}
+	if err := validateSupplierProduct(&models.SupplierProductRequest{LeadTimeDays: &late}); err != errSupplierProductLeadTime {
+		t.Fatalf("expected lead time error, got %v", err)
+	}
+}
+
+func TestPurchaseItemCostDefaultsToZero(t *testing.T) {
+	cost := 2.75
+	if got := (purchaseItemRequest{}).cost(); got != 0 {
+		t.Fatalf("expected 0 for an unpriced line, got %v", got)
+	}
+	if got := (purchaseItemRequest{UnitCost: &cost}).cost(); got != 2.75 {
+		t.Fatalf("expected 2.75, got %v", got)
+	}
+}
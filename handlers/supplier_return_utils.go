@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var (
+	errSerialReturnViaRMA     = errors.New("serial-tracked items go back to the supplier through RMA cases")
+	errReturnItemNotOnReceipt = errors.New("returned item is not on this goods receipt")
+	errReturnExceedsReceipt   = errors.New("return exceeds the quantity received on this goods receipt")
+)
+
+// supplierReturnCreditCost picks the credit per base unit of a returned line: the
+// requested cost per returned unit, else the cost the goods were received at, else what
+// the stock was issued at.
+func supplierReturnCreditCost(requested *float64, factor float64, receiptCost *float64, issueCost float64) float64 {
+	switch {
+	case requested != nil:
+		return roundCost(*requested / factor)
+	case receiptCost != nil:
+		return roundCost(*receiptCost)
+	}
+	return roundCost(issueCost)
+}
+
+// openSupplierCredit is the part of a credit note still available to offset invoices.
+type openSupplierCredit struct {
+	ID        string  `json:"id"`
+	Remaining float64 `json:"remaining"`
+}
+
+// planCreditApplication spends open credits, oldest first, against amount and returns
+// how much each credit contributes.
+func planCreditApplication(credits []openSupplierCredit, amount float64) []float64 {
+	applied := make([]float64, len(credits))
+	remaining := roundMoney(amount)
+	for i, credit := range credits {
+		if remaining <= 0 {
+			break
+		}
+		take := credit.Remaining
+		if take > remaining {
+			take = remaining
+		}
+		applied[i] = take
+		remaining = roundMoney(remaining - take)
+	}
+	return applied
+}
+
+// applySupplierCredits offsets a new invoice with the supplier's open credit notes and
+// returns the amount credited.
+func applySupplierCredits(ctx context.Context, tx DBTx, merchantID, supplierID, invoiceID string, amount float64) (float64, error) {
+	var raw string
+	if err := tx.QueryRow(ctx, `SELECT COALESCE(json_agg(c ORDER BY c.created_at,c.id),'[]'::json)::text FROM (SELECT id,(amount-amount_applied)::float8 AS remaining,created_at FROM supplier_credit_notes WHERE merchant_id=$1 AND supplier_id=$2 AND status='OPEN' ORDER BY created_at,id FOR UPDATE) c`, merchantID, supplierID).Scan(&raw); err != nil {
+		return 0, err
+	}
+	var credits []openSupplierCredit
+	if err := json.Unmarshal([]byte(raw), &credits); err != nil {
+		return 0, err
+	}
+	credited := 0.0
+	for i, take := range planCreditApplication(credits, amount) {
+		if take <= 0 {
+			continue
+		}
+		if _, err := tx.Exec(ctx, `UPDATE supplier_credit_notes SET amount_applied=amount_applied+$1,status=CASE WHEN amount_applied+$1>=amount THEN 'APPLIED' ELSE 'OPEN' END WHERE id=$2`, take, credits[i].ID); err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(ctx, `INSERT INTO supplier_credit_applications(credit_note_id,supplier_invoice_id,amount) VALUES($1,$2,$3)`, credits[i].ID, invoiceID, take); err != nil {
+			return 0, err
+		}
+		credited = roundMoney(credited + take)
+	}
+	return credited, nil
+}
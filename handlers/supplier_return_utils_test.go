@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestPlanCreditApplication(t *testing.T) {
+	credits := []openSupplierCredit{{ID: "a", Remaining: 30}, {ID: "b", Remaining: 50}, {ID: "c", Remaining: 20}}
+	got := planCreditApplication(credits, 60)
+	want := []float64{30, 30, 0}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	got = planCreditApplication(credits, 500)
+	if got[0] != 30 || got[1] != 50 || got[2] != 20 {
+		t.Fatalf("expected every credit spent, got %v", got)
+	}
+}
+
+func TestSupplierReturnCreditCost(t *testing.T) {
+	requested, receipt := 12.0, 2.5
+	if got := supplierReturnCreditCost(&requested, 6, &receipt, 1.9); got != 2 {
+		t.Fatalf("expected requested cost per base unit 2, got %v", got)
+	}
+	if got := supplierReturnCreditCost(nil, 6, &receipt, 1.9); got != 2.5 {
+		t.Fatalf("expected receipt cost 2.5, got %v", got)
+	}
+	if got := supplierReturnCreditCost(nil, 1, nil, 1.9); got != 1.9 {
+		t.Fatalf("expected issue cost 1.9, got %v", got)
+	}
+}
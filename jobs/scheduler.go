@@ -0,0 +1,26 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Every runs fn once immediately and then on every interval until ctx is cancelled.
+// Failures are logged and retried on the next tick.
+func Every(ctx context.Context, name string, interval time.Duration, fn func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := fn(ctx); err != nil {
+				log.Printf("background job %s failed: %v", name, err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
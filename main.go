@@ -3,10 +3,13 @@ package main
 import (
 	"app/config"
 	"app/database"
+	"app/handlers"
+	"app/jobs"
 	"app/routes"
 	"context"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -38,6 +41,10 @@ func main() {
 	if config.AppConfig.LocalStorageOnly {
 		log.Println("LOCAL_STORAGE_ONLY=true: cloud sync endpoints are disabled")
 	}
+	config.AppConfig.ExpiryAlertLeadDays = config.LoadIntListEnv("EXPIRY_ALERT_LEAD_DAYS", []int{30, 7, 1})
+	config.AppConfig.ExpiryScanInterval = config.LoadDurationEnv("EXPIRY_SCAN_INTERVAL", 6*time.Hour)
+	config.AppConfig.ReconciliationInterval = config.LoadDurationEnv("INVENTORY_RECONCILIATION_INTERVAL", 24*time.Hour)
+	config.AppConfig.ReservationSweepInterval = config.LoadDurationEnv("RESERVATION_SWEEP_INTERVAL", 5*time.Minute)
 
 	// Initialize database
 	database.InitDB(databaseURL)
@@ -62,6 +69,11 @@ func main() {
 	// Setup routes
 	routes.SetupRoutes(app)
 
+	// Background jobs
+	jobs.Every(context.Background(), "expiry-alerts", config.AppConfig.ExpiryScanInterval, handlers.RunExpiryAlertScan)
+	jobs.Every(context.Background(), "inventory-reconciliation", config.AppConfig.ReconciliationInterval, handlers.RunInventoryReconciliation)
+	jobs.Every(context.Background(), "reservation-expiry", config.AppConfig.ReservationSweepInterval, handlers.RunReservationExpirySweep)
+
 	// Get port from environment variable, default to 3000
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -43,17 +43,21 @@ type StockItemConfiguration struct {
 	AllowUnitConversions  bool      `json:"allowUnitConversions"`
 	AllowPackBreaking     bool      `json:"allowPackBreaking"`
 	AllowMultipleBarcodes bool      `json:"allowMultipleBarcodes"`
+	WarrantyMonths        *int      `json:"warrantyMonths,omitempty"`
+	NegativeStockPolicy   *string   `json:"negativeStockPolicy,omitempty"`
 	CreatedAt             time.Time `json:"createdAt"`
 }
 
 type StockItemConfigurationRequest struct {
-	TrackBatches          *bool `json:"trackBatches,omitempty"`
-	TrackExpiry           *bool `json:"trackExpiry,omitempty"`
-	TrackUniqueAssets     *bool `json:"trackUniqueAssets,omitempty"`
-	TrackReservations     *bool `json:"trackReservations,omitempty"`
-	AllowUnitConversions  *bool `json:"allowUnitConversions,omitempty"`
-	AllowPackBreaking     *bool `json:"allowPackBreaking,omitempty"`
-	AllowMultipleBarcodes *bool `json:"allowMultipleBarcodes,omitempty"`
+	TrackBatches          *bool   `json:"trackBatches,omitempty"`
+	TrackExpiry           *bool   `json:"trackExpiry,omitempty"`
+	TrackUniqueAssets     *bool   `json:"trackUniqueAssets,omitempty"`
+	TrackReservations     *bool   `json:"trackReservations,omitempty"`
+	AllowUnitConversions  *bool   `json:"allowUnitConversions,omitempty"`
+	AllowPackBreaking     *bool   `json:"allowPackBreaking,omitempty"`
+	AllowMultipleBarcodes *bool   `json:"allowMultipleBarcodes,omitempty"`
+	WarrantyMonths        *int    `json:"warrantyMonths,omitempty"`
+	NegativeStockPolicy   *string `json:"negativeStockPolicy,omitempty"`
 }
 
 type StockItemUnit struct {
@@ -65,6 +69,7 @@ type StockItemUnit struct {
 	IsSalesUnit      bool    `json:"isSalesUnit"`
 	IsPurchaseUnit   bool    `json:"isPurchaseUnit"`
 	AllowsFractional bool    `json:"allowsFractional"`
+	IsReportUnit     bool    `json:"isReportUnit"`
 	Position         int     `json:"position"`
 }
 
@@ -75,6 +80,7 @@ type StockItemUnitRequest struct {
 	IsSalesUnit      bool    `json:"isSalesUnit"`
 	IsPurchaseUnit   bool    `json:"isPurchaseUnit"`
 	AllowsFractional bool    `json:"allowsFractional"`
+	IsReportUnit     bool    `json:"isReportUnit"`
 	Position         int     `json:"position"`
 }
 
@@ -136,6 +142,68 @@ type BarcodeRegistryRequest struct {
 	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
+type BarcodeGenerateRequest struct {
+	OwnerType string `json:"ownerType"`
+	OwnerID   string `json:"ownerId"`
+	Symbology string `json:"symbology"`
+	Prefix    string `json:"prefix,omitempty"`
+	IsPrimary bool   `json:"isPrimary"`
+}
+
+type BarcodeLabelRequest struct {
+	Format   string             `json:"format"`
+	Template string             `json:"template"`
+	ShopID   string             `json:"shopId,omitempty"`
+	Items    []BarcodeLabelItem `json:"items"`
+}
+
+type BarcodeLabelItem struct {
+	BarcodeID string `json:"barcodeId"`
+	Copies    int    `json:"copies"`
+}
+
+// ScaleBarcodeFormat describes how a scale lays out the PLU and embedded value
+// in a 2x-prefixed EAN-13 label.
+type ScaleBarcodeFormat struct {
+	ID             string    `json:"id"`
+	MerchantID     string    `json:"merchantId"`
+	Name           string    `json:"name"`
+	Prefix         string    `json:"prefix"`
+	ItemCodeLength int       `json:"itemCodeLength"`
+	ValueLength    int       `json:"valueLength"`
+	ValueMode      string    `json:"valueMode"`
+	ValueDecimals  int       `json:"valueDecimals"`
+	UnitID         *string   `json:"unitId,omitempty"`
+	IsActive       bool      `json:"isActive"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+type ScaleBarcodeFormatRequest struct {
+	Name           string  `json:"name"`
+	Prefix         string  `json:"prefix"`
+	ItemCodeLength int     `json:"itemCodeLength"`
+	ValueMode      string  `json:"valueMode"`
+	ValueDecimals  *int    `json:"valueDecimals,omitempty"`
+	UnitID         *string `json:"unitId,omitempty"`
+	IsActive       *bool   `json:"isActive,omitempty"`
+}
+
+// ScalePLUCode maps the item code printed by a scale to a stock item.
+type ScalePLUCode struct {
+	ID            string    `json:"id"`
+	MerchantID    string    `json:"merchantId"`
+	PLUCode       string    `json:"pluCode"`
+	StockItemID   string    `json:"stockItemId"`
+	StockItemName string    `json:"stockItemName"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type ScalePLUCodeRequest struct {
+	PLUCode     string `json:"pluCode"`
+	StockItemID string `json:"stockItemId"`
+}
+
 type InventoryBatch struct {
 	ID                string     `json:"id"`
 	MerchantID        string     `json:"merchantId"`
@@ -173,18 +241,22 @@ type InventoryReservation struct {
 	Quantity        float64    `json:"quantity"`
 	BaseQuantity    *float64   `json:"baseQuantity,omitempty"`
 	Status          string     `json:"status"`
+	ExpiresAt       *time.Time `json:"expiresAt,omitempty"`
+	SaleID          *string    `json:"saleId,omitempty"`
 	ReleasedAt      *time.Time `json:"releasedAt,omitempty"`
 	CreatedAt       time.Time  `json:"createdAt"`
 	UpdatedAt       time.Time  `json:"updatedAt"`
 }
 
 type InventoryReservationRequest struct {
-	ShopID         string   `json:"shopId"`
-	ReservationKey string   `json:"reservationKey"`
-	Quantity       float64  `json:"quantity"`
-	BaseQuantity   *float64 `json:"baseQuantity,omitempty"`
-	UnitID         *string  `json:"unitId,omitempty"`
-	ReferenceID    *string  `json:"referenceId,omitempty"`
+	ShopID         string     `json:"shopId"`
+	ReservationKey string     `json:"reservationKey"`
+	Quantity       float64    `json:"quantity"`
+	BaseQuantity   *float64   `json:"baseQuantity,omitempty"`
+	UnitID         *string    `json:"unitId,omitempty"`
+	ReferenceID    *string    `json:"referenceId,omitempty"`
+	TTLMinutes     *int       `json:"ttlMinutes,omitempty"`
+	ExpiresAt      *time.Time `json:"expiresAt,omitempty"`
 }
 
 type InventorySerial struct {
@@ -200,6 +272,19 @@ type InventorySerial struct {
 	CreatedAt       time.Time `json:"createdAt"`
 }
 
+type InventorySerialEvent struct {
+	ID            string    `json:"id"`
+	SerialID      string    `json:"serialId"`
+	EventType     string    `json:"eventType"`
+	ShopID        *string   `json:"shopId,omitempty"`
+	ShopName      *string   `json:"shopName,omitempty"`
+	ReferenceType *string   `json:"referenceType,omitempty"`
+	ReferenceID   *string   `json:"referenceId,omitempty"`
+	ActorID       *string   `json:"actorId,omitempty"`
+	Notes         *string   `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
 type InventorySerialRequest struct {
 	ShopID       string  `json:"shopId"`
 	SerialNumber string  `json:"serialNumber"`
@@ -241,3 +326,361 @@ type InventoryAssetIdentifierRequest struct {
 	Value            string `json:"value"`
 	IsPrimary        bool   `json:"isPrimary"`
 }
+
+type InventoryAssetEvent struct {
+	ID            string    `json:"id"`
+	AssetID       string    `json:"assetId"`
+	EventType     string    `json:"eventType"`
+	FromStatus    *string   `json:"fromStatus,omitempty"`
+	ToStatus      string    `json:"toStatus"`
+	ShopID        *string   `json:"shopId,omitempty"`
+	ShopName      *string   `json:"shopName,omitempty"`
+	ReferenceType *string   `json:"referenceType,omitempty"`
+	ReferenceID   *string   `json:"referenceId,omitempty"`
+	ActorID       *string   `json:"actorId,omitempty"`
+	Notes         *string   `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// InventoryAssetTransitionRequest moves an asset to a new status. Warranty dates
+// override the stock item's warranty period when the asset is sold.
+type InventoryAssetTransitionRequest struct {
+	Status           string     `json:"status"`
+	SaleID           *string    `json:"saleId,omitempty"`
+	Notes            *string    `json:"notes,omitempty"`
+	WarrantyStartsOn *time.Time `json:"warrantyStartsOn,omitempty"`
+	WarrantyEndsOn   *time.Time `json:"warrantyEndsOn,omitempty"`
+}
+
+type InventoryWarranty struct {
+	ID           string    `json:"id"`
+	StockItemID  *string   `json:"stockItemId,omitempty"`
+	ItemName     *string   `json:"itemName,omitempty"`
+	SerialID     *string   `json:"serialId,omitempty"`
+	SerialNumber *string   `json:"serialNumber,omitempty"`
+	AssetID      *string   `json:"assetId,omitempty"`
+	AssetTag     *string   `json:"assetTag,omitempty"`
+	SaleID       *string   `json:"saleId,omitempty"`
+	CustomerID   *string   `json:"customerId,omitempty"`
+	CustomerName *string   `json:"customerName,omitempty"`
+	StartsOn     time.Time `json:"startsOn"`
+	EndsOn       time.Time `json:"endsOn"`
+	Status       string    `json:"status"`
+	Coverage     string    `json:"coverage"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+type AssetRental struct {
+	ID           string     `json:"id"`
+	MerchantID   string     `json:"merchantId"`
+	ShopID       string     `json:"shopId"`
+	AssetID      string     `json:"assetId"`
+	AssetTag     string     `json:"assetTag"`
+	CustomerID   string     `json:"customerId"`
+	CustomerName string     `json:"customerName"`
+	Status       string     `json:"status"`
+	CheckedOutAt time.Time  `json:"checkedOutAt"`
+	DueAt        time.Time  `json:"dueAt"`
+	ReturnedAt   *time.Time `json:"returnedAt,omitempty"`
+	Overdue      bool       `json:"overdue"`
+	CheckedOutBy *string    `json:"checkedOutBy,omitempty"`
+	ReturnedBy   *string    `json:"returnedBy,omitempty"`
+	Notes        *string    `json:"notes,omitempty"`
+	ReturnNotes  *string    `json:"returnNotes,omitempty"`
+}
+
+type AssetRentalRequest struct {
+	CustomerID string    `json:"customerId"`
+	DueAt      time.Time `json:"dueAt"`
+	Notes      *string   `json:"notes,omitempty"`
+}
+
+// AssetRentalReturnRequest closes a rental; Condition INACTIVE takes a damaged asset
+// out of circulation instead of making it available again.
+type AssetRentalReturnRequest struct {
+	Condition *string `json:"condition,omitempty"`
+	Notes     *string `json:"notes,omitempty"`
+}
+
+// RMACase is a warranty return of a faulty serial or asset, from intake through the
+// supplier to repair, replacement or rejection.
+type RMACase struct {
+	ID                      string              `json:"id"`
+	MerchantID              string              `json:"merchantId"`
+	ShopID                  string              `json:"shopId"`
+	StockItemID             *string             `json:"stockItemId,omitempty"`
+	ItemName                *string             `json:"itemName,omitempty"`
+	SerialID                *string             `json:"serialId,omitempty"`
+	SerialNumber            *string             `json:"serialNumber,omitempty"`
+	AssetID                 *string             `json:"assetId,omitempty"`
+	AssetTag                *string             `json:"assetTag,omitempty"`
+	SaleID                  *string             `json:"saleId,omitempty"`
+	CustomerID              *string             `json:"customerId,omitempty"`
+	CustomerName            *string             `json:"customerName,omitempty"`
+	WarrantyID              *string             `json:"warrantyId,omitempty"`
+	WarrantyEndsOn          *time.Time          `json:"warrantyEndsOn,omitempty"`
+	UnderWarranty           bool                `json:"underWarranty"`
+	SupplierID              *string             `json:"supplierId,omitempty"`
+	SupplierName            *string             `json:"supplierName,omitempty"`
+	SupplierReference       *string             `json:"supplierReference,omitempty"`
+	Status                  string              `json:"status"`
+	FaultDescription        string              `json:"faultDescription"`
+	ReplacementSerialID     *string             `json:"replacementSerialId,omitempty"`
+	ReplacementSerialNumber *string             `json:"replacementSerialNumber,omitempty"`
+	ReplacementAssetID      *string             `json:"replacementAssetId,omitempty"`
+	ReplacementAssetTag     *string             `json:"replacementAssetTag,omitempty"`
+	OpenedBy                *string             `json:"openedBy,omitempty"`
+	CreatedAt               time.Time           `json:"createdAt"`
+	UpdatedAt               time.Time           `json:"updatedAt"`
+	ClosedAt                *time.Time          `json:"closedAt,omitempty"`
+	Notes                   []RMACaseNote       `json:"notes,omitempty"`
+	Attachments             []RMACaseAttachment `json:"attachments,omitempty"`
+}
+
+type RMACaseNote struct {
+	ID         string    `json:"id"`
+	FromStatus *string   `json:"fromStatus,omitempty"`
+	ToStatus   *string   `json:"toStatus,omitempty"`
+	Note       string    `json:"note"`
+	AuthorID   *string   `json:"authorId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type RMACaseAttachment struct {
+	ID              string    `json:"id"`
+	Filename        string    `json:"filename"`
+	ContentType     string    `json:"contentType"`
+	SizeBytes       int64     `json:"sizeBytes"`
+	URL             string    `json:"url"`
+	StorageProvider string    `json:"storageProvider"`
+	UploadedBy      *string   `json:"uploadedBy,omitempty"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// RMACaseRequest opens a case for one unit, identified by SerialNumber or AssetID (id
+// or asset tag). Sale and customer default to the unit's sale and warranty.
+type RMACaseRequest struct {
+	SerialNumber     *string `json:"serialNumber,omitempty"`
+	AssetID          *string `json:"assetId,omitempty"`
+	SaleID           *string `json:"saleId,omitempty"`
+	CustomerID       *string `json:"customerId,omitempty"`
+	SupplierID       *string `json:"supplierId,omitempty"`
+	FaultDescription string  `json:"faultDescription"`
+}
+
+type RMACaseStatusRequest struct {
+	Status            string  `json:"status"`
+	SupplierID        *string `json:"supplierId,omitempty"`
+	SupplierReference *string `json:"supplierReference,omitempty"`
+	Note              *string `json:"note,omitempty"`
+}
+
+type RMACaseNoteRequest struct {
+	Note string `json:"note"`
+}
+
+// RMAReplacementRequest issues a replacement unit from the case's shop stock: a serial
+// for serial cases, an asset (id or tag) for asset cases.
+type RMAReplacementRequest struct {
+	SerialNumber *string `json:"serialNumber,omitempty"`
+	AssetID      *string `json:"assetId,omitempty"`
+	Note         *string `json:"note,omitempty"`
+}
+
+type InventoryTransformation struct {
+	ID                 string                        `json:"id"`
+	MerchantID         string                        `json:"merchantId"`
+	ShopID             string                        `json:"shopId"`
+	TransformationType string                        `json:"transformationType"`
+	ReferenceID        *string                       `json:"referenceId,omitempty"`
+	Notes              *string                       `json:"notes,omitempty"`
+	CreatedBy          string                        `json:"createdBy"`
+	CreatedAt          time.Time                     `json:"createdAt"`
+	Lines              []InventoryTransformationLine `json:"lines,omitempty"`
+}
+
+type InventoryTransformationLine struct {
+	ID               string   `json:"id"`
+	TransformationID string   `json:"transformationId"`
+	InventoryItemID  string   `json:"inventoryItemId"`
+	StockItemID      *string  `json:"stockItemId,omitempty"`
+	UnitID           *string  `json:"unitId,omitempty"`
+	Direction        string   `json:"direction"`
+	Quantity         float64  `json:"quantity"`
+	BaseQuantity     *float64 `json:"baseQuantity,omitempty"`
+	UnitCost         *float64 `json:"unitCost,omitempty"`
+}
+
+type InventoryTransformationLineRequest struct {
+	StockItemID string  `json:"stockItemId"`
+	UnitID      *string `json:"unitId,omitempty"`
+	Quantity    float64 `json:"quantity"`
+	BatchID     *string `json:"batchId,omitempty"`
+}
+
+type InventoryTransformationRequest struct {
+	ClientOperationID  string                               `json:"clientOperationId"`
+	TransformationType string                               `json:"transformationType"`
+	ReferenceID        *string                              `json:"referenceId,omitempty"`
+	Notes              *string                              `json:"notes,omitempty"`
+	Inputs             []InventoryTransformationLineRequest `json:"inputs"`
+	Outputs            []InventoryTransformationLineRequest `json:"outputs"`
+}
+
+type ProductKit struct {
+	ID          string                `json:"id"`
+	MerchantID  string                `json:"merchantId"`
+	ProductID   string                `json:"productId"`
+	StockItemID string                `json:"stockItemId"`
+	Name        string                `json:"name"`
+	IsActive    bool                  `json:"isActive"`
+	Available   *float64              `json:"available,omitempty"`
+	Components  []ProductKitComponent `json:"components"`
+	CreatedAt   time.Time             `json:"createdAt"`
+	UpdatedAt   time.Time             `json:"updatedAt"`
+}
+
+type ProductKitComponent struct {
+	ID            string   `json:"id"`
+	StockItemID   string   `json:"stockItemId"`
+	StockItemName string   `json:"stockItemName"`
+	Quantity      float64  `json:"quantity"`
+	Available     *float64 `json:"available,omitempty"`
+}
+
+type ProductKitComponentRequest struct {
+	StockItemID string  `json:"stockItemId"`
+	Quantity    float64 `json:"quantity"`
+}
+
+type ProductKitRequest struct {
+	ProductID  string                       `json:"productId"`
+	Name       *string                      `json:"name,omitempty"`
+	IsActive   *bool                        `json:"isActive,omitempty"`
+	Components []ProductKitComponentRequest `json:"components"`
+}
+
+// ReorderSuggestion is the replenishment position of one shop balance.
+type ReorderSuggestion struct {
+	InventoryItemID   string   `json:"inventoryItemId"`
+	StockItemID       string   `json:"stockItemId"`
+	ProductID         string   `json:"productId"`
+	Name              string   `json:"name"`
+	SKU               *string  `json:"sku,omitempty"`
+	QuantityOnHand    float64  `json:"quantityOnHand"`
+	QuantityOnOrder   float64  `json:"quantityOnOrder"`
+	DailyVelocity     float64  `json:"dailyVelocity"`
+	LeadTimeDays      int      `json:"leadTimeDays"`
+	SafetyStock       float64  `json:"safetyStock"`
+	ReorderPoint      float64  `json:"reorderPoint"`
+	SuggestedQuantity float64  `json:"suggestedQuantity"`
+	SupplierID        *string  `json:"supplierId,omitempty"`
+	SupplierName      *string  `json:"supplierName,omitempty"`
+	LastUnitCost      *float64 `json:"lastUnitCost,omitempty"`
+}
+
+type ReorderPurchaseOrderRequest struct {
+	ClientOperationID string   `json:"clientOperationId"`
+	StockItemIDs      []string `json:"stockItemIds,omitempty"`
+}
+
+type ReorderSettingsRequest struct {
+	LowStockThreshold *float64 `json:"lowStockThreshold"`
+	SafetyStock       *float64 `json:"safetyStock"`
+}
+
+type CostingMethodRequest struct {
+	CostingMethod string `json:"costingMethod"`
+}
+
+// InventoryValuationLine is the quantity and cost value of one shop balance at a point in time.
+type InventoryValuationLine struct {
+	InventoryItemID string   `json:"inventoryItemId"`
+	StockItemID     string   `json:"stockItemId"`
+	Name            string   `json:"name"`
+	SKU             *string  `json:"sku,omitempty"`
+	Quantity        float64  `json:"quantity"`
+	ReportQuantity  float64  `json:"reportQuantity"`
+	ReportUnit      *string  `json:"reportUnit,omitempty"`
+	Value           float64  `json:"value"`
+	UnitCost        *float64 `json:"unitCost,omitempty"`
+}
+
+// InventoryReconciliationException is a shop balance whose on-hand quantity disagrees with its movement ledger.
+type InventoryReconciliationException struct {
+	ID               string     `json:"id"`
+	ShopID           string     `json:"shopId"`
+	InventoryItemID  string     `json:"inventoryItemId"`
+	StockItemID      *string    `json:"stockItemId,omitempty"`
+	StockItemName    *string    `json:"stockItemName,omitempty"`
+	RecordedQuantity float64    `json:"recordedQuantity"`
+	LedgerQuantity   float64    `json:"ledgerQuantity"`
+	Difference       float64    `json:"difference"`
+	Status           string     `json:"status"`
+	Resolution       *string    `json:"resolution,omitempty"`
+	Notes            *string    `json:"notes,omitempty"`
+	DetectedAt       time.Time  `json:"detectedAt"`
+	LastCheckedAt    time.Time  `json:"lastCheckedAt"`
+	ResolvedAt       *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// InventoryReconciliationRun summarizes one pass of the ledger integrity check.
+type InventoryReconciliationRun struct {
+	Checked    int `json:"checked"`
+	Mismatched int `json:"mismatched"`
+	Opened     int `json:"opened"`
+	Cleared    int `json:"cleared"`
+}
+
+type ReconciliationResolveRequest struct {
+	ClientOperationID string  `json:"clientOperationId"`
+	Action            string  `json:"action"`
+	Notes             *string `json:"notes,omitempty"`
+}
+
+// InventoryBackorder is a quantity sold beyond available stock under a WARN or
+// ALLOW_NEGATIVE policy, settled as stock is received into the balance.
+type InventoryBackorder struct {
+	ID              string     `json:"id"`
+	ShopID          string     `json:"shopId"`
+	InventoryItemID string     `json:"inventoryItemId"`
+	StockItemID     *string    `json:"stockItemId,omitempty"`
+	StockItemName   *string    `json:"stockItemName,omitempty"`
+	ReferenceType   string     `json:"referenceType"`
+	ReferenceID     *string    `json:"referenceId,omitempty"`
+	Quantity        float64    `json:"quantity"`
+	SettledQuantity float64    `json:"settledQuantity"`
+	Status          string     `json:"status"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	SettledAt       *time.Time `json:"settledAt,omitempty"`
+}
+
+type ShopStockPolicyRequest struct {
+	NegativeStockPolicy string `json:"negativeStockPolicy"`
+}
+
+// StorageBin is a bin or shelf inside a stock location.
+type StorageBin struct {
+	ID         string    `json:"id"`
+	MerchantID string    `json:"merchantId"`
+	ShopID     string    `json:"shopId"`
+	Code       string    `json:"code"`
+	Name       *string   `json:"name,omitempty"`
+	BinType    string    `json:"binType"`
+	IsActive   bool      `json:"isActive"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type StorageBinRequest struct {
+	Code     string  `json:"code"`
+	Name     *string `json:"name,omitempty"`
+	BinType  string  `json:"binType"`
+	IsActive *bool   `json:"isActive,omitempty"`
+}
+
+// InventoryBinsRequest sets a balance's default bins; empty values clear them.
+type InventoryBinsRequest struct {
+	PutawayBinID *string `json:"putawayBinId"`
+	PickBinID    *string `json:"pickBinId"`
+}
@@ -94,6 +94,7 @@ type Shop struct {
 	IsActive       bool      `json:"isActive"`
 	DeliveryCharge float64   `json:"deliveryCharge"`
 	IsPrimary      bool      `json:"isPrimary"`
+	LocationType   string    `json:"locationType"`
 	CreatedAt      time.Time `json:"createdAt"`
 	UpdatedAt      time.Time `json:"updatedAt"`
 }
@@ -140,6 +141,7 @@ type Supplier struct {
 	ContactPhone      *string   `json:"contactPhone,omitempty"`
 	Address           *string   `json:"address,omitempty"`
 	Notes             *string   `json:"notes,omitempty"`
+	LeadTimeDays      *int      `json:"leadTimeDays,omitempty"`
 	CreatedAt         time.Time `json:"createdAt"`
 	UpdatedAt         time.Time `json:"updatedAt"`
 }
@@ -232,10 +234,15 @@ type StockMovement struct {
 	UserID          string    `json:"userId"`
 	MovementType    string    `json:"movementType"`
 	QuantityChanged int       `json:"quantityChanged"`
+	UnitID          *string   `json:"unitId,omitempty"`
+	BaseQuantity    float64   `json:"baseQuantity"`
+	ReportQuantity  float64   `json:"reportQuantity"`
+	ReportUnit      *string   `json:"reportUnit,omitempty"`
 	NewQuantity     int       `json:"newQuantity"`
 	Reason          *string   `json:"reason,omitempty"`
 	MovementDate    time.Time `json:"movementDate"`
 	Notes           *string   `json:"notes,omitempty"`
+	BinID           *string   `json:"binId,omitempty"`
 }
 
 // ShopCustomer represents a customer associated with a specific shop.
@@ -316,17 +323,38 @@ type Invoice struct {
 
 // SaleItem is an individual item within a Sale.
 type SaleItem struct {
-	ID                  string    `json:"id"`
-	SaleID              string    `json:"saleId"`
-	InventoryItemID     string    `json:"inventoryItemId"`
-	QuantitySold        int       `json:"quantitySold"`
-	SellingPriceAtSale  float64   `json:"sellingPriceAtSale"`
-	OriginalPriceAtSale *float64  `json:"originalPriceAtSale,omitempty"`
-	Subtotal            float64   `json:"subtotal"`
-	CreatedAt           time.Time `json:"createdAt"`
-	UpdatedAt           time.Time `json:"updatedAt"`
-	ItemName            *string   `json:"itemName,omitempty"`
-	ItemSKU             *string   `json:"itemSku,omitempty"`
+	ID                  string          `json:"id"`
+	SaleID              string          `json:"saleId"`
+	InventoryItemID     string          `json:"inventoryItemId"`
+	QuantitySold        float64         `json:"quantitySold"`
+	UnitID              *string         `json:"unitId,omitempty"`
+	BaseQuantity        *float64        `json:"baseQuantity,omitempty"`
+	UnitCost            *float64        `json:"unitCost,omitempty"`
+	CostTotal           *float64        `json:"costTotal,omitempty"`
+	SellingPriceAtSale  float64         `json:"sellingPriceAtSale"`
+	OriginalPriceAtSale *float64        `json:"originalPriceAtSale,omitempty"`
+	Subtotal            float64         `json:"subtotal"`
+	CreatedAt           time.Time       `json:"createdAt"`
+	UpdatedAt           time.Time       `json:"updatedAt"`
+	ItemName            *string         `json:"itemName,omitempty"`
+	ItemSKU             *string         `json:"itemSku,omitempty"`
+	QuantityReturned    float64         `json:"quantityReturned"`
+	Batches             []SaleItemBatch `json:"batches,omitempty"`
+	// BatchID picks the batch a new sale line sells from; omitted, batches go first-expiry-first-out.
+	BatchID       *string  `json:"batchId,omitempty"`
+	SerialNumbers []string `json:"serialNumbers,omitempty"`
+	AssetID       *string  `json:"assetId,omitempty"`
+}
+
+// SaleItemBatch records the batch quantity consumed by a sale item.
+type SaleItemBatch struct {
+	ID               string     `json:"id"`
+	SaleItemID       string     `json:"saleItemId"`
+	BatchID          string     `json:"batchId"`
+	BatchCode        string     `json:"batchCode"`
+	ExpiryDate       *time.Time `json:"expiryDate,omitempty"`
+	Quantity         float64    `json:"quantity"`
+	QuantityReturned float64    `json:"quantityReturned"`
 }
 
 // Salary represents a salary payment to a staff member.
@@ -372,6 +400,8 @@ type CreateShopRequest struct {
 	TaxRate    *float64 `json:"taxRate,omitempty"`
 	IsActive   bool     `json:"isActive"`
 	IsPrimary  bool     `json:"isPrimary"`
+	// LocationType is STORE (default), WAREHOUSE or VAN.
+	LocationType *string `json:"locationType,omitempty"`
 }
 
 // AdminDashboardSummary defines the structure for the admin dashboard summary.
@@ -395,7 +425,8 @@ type KpiData struct {
 type ProductSummary struct {
 	ProductID    string  `json:"productId"`
 	ProductName  string  `json:"productName"`
-	QuantitySold int     `json:"quantitySold"`
+	QuantitySold float64 `json:"quantitySold"`
+	ReportUnit   *string `json:"reportUnit,omitempty"`
 	Revenue      float64 `json:"revenue"`
 }
 
@@ -527,6 +558,10 @@ type ShopStockItem struct {
 	ItemSku         string    `json:"itemSku"`
 	ItemUnitPrice   float64   `json:"itemUnitPrice"`
 	Quantity        int       `json:"quantity"`
+	ReportQuantity  float64   `json:"reportQuantity"`
+	ReportUnit      *string   `json:"reportUnit,omitempty"`
+	PutawayBinID    *string   `json:"putawayBinId,omitempty"`
+	PickBinID       *string   `json:"pickBinId,omitempty"`
 	LastStockedInAt time.Time `json:"lastStockedInAt"`
 	CreatedAt       time.Time `json:"createdAt"`
 	UpdatedAt       time.Time `json:"updatedAt"`
@@ -578,9 +613,15 @@ type StaffRecentActivity struct {
 
 // CheckoutItem represents a single item in the checkout request.
 type CheckoutItem struct {
-	ProductID          string  `json:"productId"`
-	Quantity           int     `json:"quantity"`
-	SellingPriceAtSale float64 `json:"sellingPriceAtSale"`
+	ProductID          string   `json:"productId"`
+	Quantity           float64  `json:"quantity"`
+	UnitID             *string  `json:"unitId,omitempty"`
+	SellingPriceAtSale float64  `json:"sellingPriceAtSale"`
+	BatchID            *string  `json:"batchId,omitempty"`
+	SerialNumbers      []string `json:"serialNumbers,omitempty"`
+	ScaleBarcode       *string  `json:"scaleBarcode,omitempty"`
+	ReservationID      *string  `json:"reservationId,omitempty"`
+	AssetID            *string  `json:"assetId,omitempty"`
 }
 
 // CheckoutRequest is the full request body for the checkout endpoint.
@@ -601,6 +642,20 @@ type CheckoutRequest struct {
 	StripePaymentIntentID *string        `json:"stripePaymentIntentId,omitempty"`
 }
 
+// SaleReturnItem identifies a sale line and the quantity coming back.
+type SaleReturnItem struct {
+	SaleItemID    string   `json:"saleItemId"`
+	Quantity      float64  `json:"quantity"`
+	SerialNumbers []string `json:"serialNumbers,omitempty"`
+}
+
+// SaleReturnRequest is the request body for returning items from a sale.
+type SaleReturnRequest struct {
+	ClientOperationID string           `json:"clientOperationId"`
+	Reason            *string          `json:"reason,omitempty"`
+	Items             []SaleReturnItem `json:"items"`
+}
+
 // ShopInventoryItem is a simplified view of an inventory item for the shop interface.
 type ShopInventoryItem struct {
 	ID           string  `json:"id"`
@@ -613,8 +668,13 @@ type ShopInventoryItem struct {
 
 // StockInItem represents a single item in a stock-in request.
 type StockInItem struct {
-	ProductID string `json:"productId"`
-	Quantity  int    `json:"quantity"`
+	ProductID string  `json:"productId"`
+	Quantity  float64 `json:"quantity"`
+	UnitID    *string `json:"unitId,omitempty"`
+	// UnitCost is the cost per entered unit; omitted stock is received at the current average cost.
+	UnitCost *float64 `json:"unitCost,omitempty"`
+	// BinID is the bin the stock is put into; omitted stock goes to the balance's put-away bin.
+	BinID *string `json:"binId,omitempty"`
 }
 
 // StockInRequest is the request body for the stock-in endpoint.
@@ -625,9 +685,15 @@ type StockInRequest struct {
 
 // StaffCheckoutItem represents a single item in a staff checkout request.
 type StaffCheckoutItem struct {
-	ProductID          string  `json:"productId"`
-	Quantity           int     `json:"quantity"`
-	SellingPriceAtSale float64 `json:"sellingPriceAtSale"`
+	ProductID          string   `json:"productId"`
+	Quantity           float64  `json:"quantity"`
+	UnitID             *string  `json:"unitId,omitempty"`
+	SellingPriceAtSale float64  `json:"sellingPriceAtSale"`
+	BatchID            *string  `json:"batchId,omitempty"`
+	SerialNumbers      []string `json:"serialNumbers,omitempty"`
+	ScaleBarcode       *string  `json:"scaleBarcode,omitempty"`
+	ReservationID      *string  `json:"reservationId,omitempty"`
+	AssetID            *string  `json:"assetId,omitempty"`
 }
 
 // StaffCheckoutRequest is the request body for the staff checkout endpoint.
@@ -643,3 +709,358 @@ type StaffCheckoutRequest struct {
 	CustomerID         *string             `json:"customerId,omitempty"`
 	CustomerName       *string             `json:"customerName,omitempty"`
 }
+
+// ScanResolution is the sellable line a scanned code resolves to in a shop. StockItemID
+// is the productId a checkout line expects; Price is per scanned unit.
+type ScanResolution struct {
+	Code              string      `json:"code"`
+	Source            string      `json:"source"`
+	StockItemID       string      `json:"stockItemId"`
+	Name              string      `json:"name"`
+	SKU               *string     `json:"sku,omitempty"`
+	TrackingMode      string      `json:"trackingMode"`
+	InventoryItemID   *string     `json:"inventoryItemId,omitempty"`
+	UnitID            *string     `json:"unitId,omitempty"`
+	UnitCode          *string     `json:"unitCode,omitempty"`
+	UnitFactor        float64     `json:"unitFactor"`
+	Price             float64     `json:"price"`
+	AvailableQuantity float64     `json:"availableQuantity"`
+	AvailableInUnit   float64     `json:"availableInUnit"`
+	Batch             *ScanBatch  `json:"batch,omitempty"`
+	Asset             *ScanAsset  `json:"asset,omitempty"`
+	Serial            *ScanSerial `json:"serial,omitempty"`
+	Scale             *ScanScale  `json:"scale,omitempty"`
+	Quantity          *float64    `json:"quantity,omitempty"`
+	LinePrice         *float64    `json:"linePrice,omitempty"`
+	RequiresBatch     bool        `json:"requiresBatch"`
+	RequiresSerial    bool        `json:"requiresSerial"`
+	Sellable          bool        `json:"sellable"`
+	Warnings          []string    `json:"warnings"`
+}
+
+// ScanScale describes the PLU and value decoded from a scale label.
+type ScanScale struct {
+	FormatID  string  `json:"formatId"`
+	PLUCode   string  `json:"pluCode"`
+	ValueMode string  `json:"valueMode"`
+	Value     float64 `json:"value"`
+}
+
+type ScanBatch struct {
+	ID                string     `json:"id"`
+	BatchCode         string     `json:"batchCode"`
+	ExpiryDate        *time.Time `json:"expiryDate,omitempty"`
+	QuantityRemaining float64    `json:"quantityRemaining"`
+	InShop            bool       `json:"inShop"`
+}
+
+type ScanSerial struct {
+	SerialNumber string `json:"serialNumber"`
+	Status       string `json:"status"`
+	InShop       bool   `json:"inShop"`
+}
+
+type ScanAsset struct {
+	ID       string `json:"id"`
+	AssetTag string `json:"assetTag"`
+	Status   string `json:"status"`
+	InShop   bool   `json:"inShop"`
+}
+
+// SupplierInvoice is a supplier bill matched against a purchase order and its receipts.
+type SupplierInvoice struct {
+	ID              string                `json:"id"`
+	SupplierID      string                `json:"supplierId"`
+	PurchaseOrderID string                `json:"purchaseOrderId"`
+	InvoiceNumber   *string               `json:"invoiceNumber,omitempty"`
+	InvoiceDate     time.Time             `json:"invoiceDate"`
+	DueDate         *time.Time            `json:"dueDate,omitempty"`
+	TaxAmount       float64               `json:"taxAmount"`
+	TotalAmount     float64               `json:"totalAmount"`
+	AmountPaid      float64               `json:"amountPaid"`
+	AmountCredited  float64               `json:"amountCredited"`
+	Outstanding     float64               `json:"outstanding"`
+	Status          string                `json:"status"`
+	MatchStatus     string                `json:"matchStatus"`
+	Notes           *string               `json:"notes,omitempty"`
+	CreatedAt       time.Time             `json:"createdAt"`
+	Items           []SupplierInvoiceItem `json:"items,omitempty"`
+	Payments        []SupplierPayment     `json:"payments,omitempty"`
+}
+
+type SupplierInvoiceItem struct {
+	ID                  string  `json:"id"`
+	PurchaseOrderItemID string  `json:"purchaseOrderItemId"`
+	Quantity            float64 `json:"quantity"`
+	UnitCost            float64 `json:"unitCost"`
+	TotalCost           float64 `json:"totalCost"`
+	ReceivedQuantity    float64 `json:"receivedQuantity"`
+	OrderedUnitCost     float64 `json:"orderedUnitCost"`
+	QuantityVariance    float64 `json:"quantityVariance"`
+	PriceVariance       float64 `json:"priceVariance"`
+}
+
+type SupplierInvoiceItemRequest struct {
+	PurchaseOrderItemID string  `json:"purchaseOrderItemId"`
+	Quantity            float64 `json:"quantity"`
+	UnitCost            float64 `json:"unitCost"`
+}
+
+type SupplierInvoiceRequest struct {
+	PurchaseOrderID string                       `json:"purchaseOrderId"`
+	InvoiceNumber   *string                      `json:"invoiceNumber,omitempty"`
+	InvoiceDate     *string                      `json:"invoiceDate,omitempty"`
+	DueDate         *string                      `json:"dueDate,omitempty"`
+	TaxAmount       float64                      `json:"taxAmount"`
+	Notes           *string                      `json:"notes,omitempty"`
+	Items           []SupplierInvoiceItemRequest `json:"items"`
+}
+
+type SupplierPayment struct {
+	ID                string    `json:"id"`
+	SupplierInvoiceID string    `json:"supplierInvoiceId"`
+	Amount            float64   `json:"amount"`
+	PaymentMethod     string    `json:"paymentMethod"`
+	Reference         *string   `json:"reference,omitempty"`
+	PaymentDate       time.Time `json:"paymentDate"`
+}
+
+type SupplierPaymentRequest struct {
+	Amount        float64 `json:"amount"`
+	PaymentMethod string  `json:"paymentMethod"`
+	Reference     *string `json:"reference,omitempty"`
+	PaymentDate   *string `json:"paymentDate,omitempty"`
+}
+
+// SupplierStatementEntry is an invoice, payment or credit note on a supplier statement
+// with the running balance owed.
+type SupplierStatementEntry struct {
+	Date       time.Time `json:"date"`
+	EntryType  string    `json:"entryType"`
+	DocumentID string    `json:"documentId"`
+	InvoiceID  *string   `json:"invoiceId,omitempty"`
+	Reference  *string   `json:"reference,omitempty"`
+	Amount     float64   `json:"amount"`
+	Balance    float64   `json:"balance"`
+}
+
+// APAgingRow is the amount owed to a supplier by days past due.
+type APAgingRow struct {
+	SupplierID   string  `json:"supplierId"`
+	SupplierName string  `json:"supplierName"`
+	Days0To30    float64 `json:"days0To30"`
+	Days31To60   float64 `json:"days31To60"`
+	Days61To90   float64 `json:"days61To90"`
+	Days90Plus   float64 `json:"days90Plus"`
+	Total        float64 `json:"total"`
+}
+
+// SupplierReturn is stock sent back to a supplier, credited through a supplier credit note.
+type SupplierReturn struct {
+	ID             string               `json:"id"`
+	ShopID         string               `json:"shopId"`
+	SupplierID     string               `json:"supplierId"`
+	GoodsReceiptID *string              `json:"goodsReceiptId,omitempty"`
+	Reason         *string              `json:"reason,omitempty"`
+	TotalAmount    float64              `json:"totalAmount"`
+	CreditNoteID   *string              `json:"creditNoteId,omitempty"`
+	CreatedBy      *string              `json:"createdBy,omitempty"`
+	CreatedAt      time.Time            `json:"createdAt"`
+	Items          []SupplierReturnItem `json:"items,omitempty"`
+}
+
+type SupplierReturnItem struct {
+	ID              string  `json:"id"`
+	InventoryItemID string  `json:"inventoryItemId"`
+	StockItemID     *string `json:"stockItemId,omitempty"`
+	BatchID         *string `json:"batchId,omitempty"`
+	UnitID          *string `json:"unitId,omitempty"`
+	Quantity        float64 `json:"quantity"`
+	BaseQuantity    float64 `json:"baseQuantity"`
+	UnitCost        float64 `json:"unitCost"`
+	TotalCost       float64 `json:"totalCost"`
+}
+
+type SupplierReturnItemRequest struct {
+	StockItemID string  `json:"stockItemId"`
+	Quantity    float64 `json:"quantity"`
+	UnitID      *string `json:"unitId,omitempty"`
+	BatchID     *string `json:"batchId,omitempty"`
+	BinID       *string `json:"binId,omitempty"`
+	// UnitCost is the credit per returned unit; omitted, the receipt cost or the stock cost is credited.
+	UnitCost *float64 `json:"unitCost,omitempty"`
+}
+
+type SupplierReturnRequest struct {
+	ClientOperationID string                      `json:"clientOperationId"`
+	ShopID            string                      `json:"shopId"`
+	SupplierID        string                      `json:"supplierId"`
+	GoodsReceiptID    *string                     `json:"goodsReceiptId,omitempty"`
+	Reason            *string                     `json:"reason,omitempty"`
+	Items             []SupplierReturnItemRequest `json:"items"`
+}
+
+type SupplierCreditNote struct {
+	ID               string    `json:"id"`
+	SupplierID       string    `json:"supplierId"`
+	SupplierReturnID *string   `json:"supplierReturnId,omitempty"`
+	Amount           float64   `json:"amount"`
+	AmountApplied    float64   `json:"amountApplied"`
+	Status           string    `json:"status"`
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// GoodsReceiptCostRequest is a landed cost line such as freight or duty paid on a receipt.
+type GoodsReceiptCostRequest struct {
+	CostType    string  `json:"costType"`
+	Description *string `json:"description,omitempty"`
+	Amount      float64 `json:"amount"`
+	// AllocationMethod spreads the amount over the receipt lines by VALUE (default),
+	// QUANTITY or WEIGHT.
+	AllocationMethod string `json:"allocationMethod,omitempty"`
+}
+
+type GoodsReceiptCost struct {
+	ID               string                       `json:"id"`
+	GoodsReceiptID   string                       `json:"goodsReceiptId"`
+	CostType         string                       `json:"costType"`
+	Description      *string                      `json:"description,omitempty"`
+	Amount           float64                      `json:"amount"`
+	AllocationMethod string                       `json:"allocationMethod"`
+	CreatedAt        time.Time                    `json:"createdAt"`
+	UpdatedAt        time.Time                    `json:"updatedAt"`
+	Allocations      []GoodsReceiptCostAllocation `json:"allocations"`
+}
+
+type GoodsReceiptCostAllocation struct {
+	GoodsReceiptItemID string  `json:"goodsReceiptItemId"`
+	StockItemID        *string `json:"stockItemId,omitempty"`
+	Amount             float64 `json:"amount"`
+}
+
+// InventoryCostAdjustment revalues stock already received. InventoryAmount landed on
+// stock still on hand; ConsumedAmount belongs to stock issued before the change.
+type InventoryCostAdjustment struct {
+	ID                 string    `json:"id"`
+	ShopID             string    `json:"shopId"`
+	InventoryItemID    string    `json:"inventoryItemId"`
+	GoodsReceiptItemID *string   `json:"goodsReceiptItemId,omitempty"`
+	BatchID            *string   `json:"batchId,omitempty"`
+	ReferenceType      string    `json:"referenceType"`
+	ReferenceID        *string   `json:"referenceId,omitempty"`
+	UnitCostDelta      float64   `json:"unitCostDelta"`
+	RevaluedQuantity   float64   `json:"revaluedQuantity"`
+	InventoryAmount    float64   `json:"inventoryAmount"`
+	ConsumedQuantity   float64   `json:"consumedQuantity"`
+	ConsumedAmount     float64   `json:"consumedAmount"`
+	CreatedAt          time.Time `json:"createdAt"`
+}
+
+// SupplierProduct maps a stock item to a supplier's catalog. LastCost, MinOrderQuantity
+// and PackSize are in UnitID, the stock item's base unit when unset.
+type SupplierProduct struct {
+	ID               string    `json:"id"`
+	SupplierID       string    `json:"supplierId"`
+	SupplierName     string    `json:"supplierName,omitempty"`
+	StockItemID      string    `json:"stockItemId"`
+	StockItemName    string    `json:"stockItemName,omitempty"`
+	SupplierSKU      *string   `json:"supplierSku,omitempty"`
+	UnitID           *string   `json:"unitId,omitempty"`
+	LastCost         *float64  `json:"lastCost,omitempty"`
+	BaseUnitCost     *float64  `json:"baseUnitCost,omitempty"`
+	MinOrderQuantity float64   `json:"minOrderQuantity"`
+	PackSize         float64   `json:"packSize"`
+	LeadTimeDays     int       `json:"leadTimeDays"`
+	IsPreferred      bool      `json:"isPreferred"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+type SupplierProductRequest struct {
+	SupplierSKU      *string  `json:"supplierSku,omitempty"`
+	UnitID           *string  `json:"unitId,omitempty"`
+	LastCost         *float64 `json:"lastCost,omitempty"`
+	MinOrderQuantity *float64 `json:"minOrderQuantity,omitempty"`
+	PackSize         *float64 `json:"packSize,omitempty"`
+	// LeadTimeDays overrides the supplier's lead time for this item.
+	LeadTimeDays *int  `json:"leadTimeDays,omitempty"`
+	IsPreferred  *bool `json:"isPreferred,omitempty"`
+}
+
+// PurchaseOrderSend records one attempt to deliver a purchase order to its supplier.
+// Reference is the SMTP Message-ID or the dropped file; Error is set when it failed.
+type PurchaseOrderSend struct {
+	ID              string    `json:"id"`
+	PurchaseOrderID string    `json:"purchaseOrderId"`
+	Channel         string    `json:"channel"`
+	Recipient       string    `json:"recipient"`
+	Status          string    `json:"status"`
+	Reference       *string   `json:"reference,omitempty"`
+	Error           *string   `json:"error,omitempty"`
+	DocumentSHA256  string    `json:"documentSha256"`
+	SentBy          *string   `json:"sentBy,omitempty"`
+	SentAt          time.Time `json:"sentAt"`
+}
+
+// ReplenishmentRequest is stock a shop's staff asked the merchant for. Once filled it
+// links to the purchase order raised or names the shop the stock was moved from.
+type ReplenishmentRequest struct {
+	ID              string                     `json:"id"`
+	ShopID          string                     `json:"shopId"`
+	ShopName        string                     `json:"shopName"`
+	RequestedBy     *string                    `json:"requestedBy,omitempty"`
+	RequestedByName *string                    `json:"requestedByName,omitempty"`
+	Status          string                     `json:"status"`
+	Notes           *string                    `json:"notes,omitempty"`
+	NeededBy        *time.Time                 `json:"neededBy,omitempty"`
+	ReviewNote      *string                    `json:"reviewNote,omitempty"`
+	ReviewedBy      *string                    `json:"reviewedBy,omitempty"`
+	ReviewedAt      *time.Time                 `json:"reviewedAt,omitempty"`
+	PurchaseOrderID *string                    `json:"purchaseOrderId,omitempty"`
+	SourceShopID    *string                    `json:"sourceShopId,omitempty"`
+	ItemCount       int                        `json:"itemCount"`
+	Items           []ReplenishmentRequestItem `json:"items,omitempty"`
+	CreatedAt       time.Time                  `json:"createdAt"`
+	UpdatedAt       time.Time                  `json:"updatedAt"`
+}
+
+// ReplenishmentRequestItem quantities are in the stock item's base unit. OnHand is the
+// requesting shop's current stock of it.
+type ReplenishmentRequestItem struct {
+	ID               string   `json:"id"`
+	StockItemID      string   `json:"stockItemId"`
+	StockItemName    string   `json:"stockItemName"`
+	SKU              *string  `json:"sku,omitempty"`
+	Quantity         float64  `json:"quantity"`
+	ApprovedQuantity *float64 `json:"approvedQuantity,omitempty"`
+	OnHand           float64  `json:"onHand"`
+	Note             *string  `json:"note,omitempty"`
+}
+
+type ReplenishmentRequestLine struct {
+	StockItemID string  `json:"stockItemId"`
+	Quantity    float64 `json:"quantity"`
+	Note        *string `json:"note,omitempty"`
+	// BatchID and SerialNumbers pick the units moved when the request is filled by a
+	// transfer; serial-tracked lines need one serial per unit.
+	BatchID       *string  `json:"batchId,omitempty"`
+	SerialNumbers []string `json:"serialNumbers,omitempty"`
+}
+
+type CreateReplenishmentRequest struct {
+	Notes *string `json:"notes,omitempty"`
+	// NeededBy is a date, YYYY-MM-DD.
+	NeededBy *string                    `json:"neededBy,omitempty"`
+	Items    []ReplenishmentRequestLine `json:"items"`
+}
+
+// FulfillReplenishmentRequest fills a request with a purchase order from SupplierID or a
+// transfer from FromShopID. Items overrides the requested quantities; a line left out is
+// filled as requested and a zero quantity skips it.
+type FulfillReplenishmentRequest struct {
+	ClientOperationID string                     `json:"clientOperationId"`
+	SupplierID        string                     `json:"supplierId,omitempty"`
+	FromShopID        string                     `json:"fromShopId,omitempty"`
+	Note              *string                    `json:"note,omitempty"`
+	Items             []ReplenishmentRequestLine `json:"items,omitempty"`
+}
@@ -0,0 +1,341 @@
+// Package outbound delivers documents to people outside the system, such as
+// purchase orders to suppliers, over a channel chosen by configuration.
+package outbound
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	To          []string
+	ReplyTo     string
+	Subject     string
+	Body        string
+	Attachments []Attachment
+}
+
+// Receipt identifies a delivered message: the SMTP Message-ID, or the dropped file.
+type Receipt struct {
+	Channel   string
+	Reference string
+}
+
+type Channel interface {
+	Name() string
+	Send(context.Context, Message) (Receipt, error)
+}
+
+type Config struct {
+	Channel      string
+	From         string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	FileDropDir  string
+}
+
+func LoadConfig() Config {
+	channel := strings.ToLower(strings.TrimSpace(os.Getenv("OUTBOUND_CHANNEL")))
+	if channel == "" {
+		channel = "smtp"
+	}
+	port := strings.TrimSpace(os.Getenv("SMTP_PORT"))
+	if port == "" {
+		port = "587"
+	}
+	return Config{
+		Channel: channel, From: os.Getenv("OUTBOUND_FROM"),
+		SMTPHost: os.Getenv("SMTP_HOST"), SMTPPort: port, SMTPUsername: os.Getenv("SMTP_USERNAME"), SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		FileDropDir: os.Getenv("OUTBOUND_FILE_DIR"),
+	}
+}
+
+func NewFromEnv() (Channel, error) {
+	cfg := LoadConfig()
+	switch cfg.Channel {
+	case "smtp":
+		return NewSMTP(cfg)
+	case "file":
+		return NewFileDrop(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported outbound channel %q", cfg.Channel)
+	}
+}
+
+// smtpTimeout bounds one delivery, from dialing the server to QUIT, when the caller's
+// context has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+type smtpChannel struct {
+	addr string
+	host string
+	from string
+	auth smtp.Auth
+}
+
+func NewSMTP(cfg Config) (Channel, error) {
+	if cfg.SMTPHost == "" || cfg.From == "" {
+		return nil, fmt.Errorf("smtp configuration is incomplete")
+	}
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return &smtpChannel{addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort), host: cfg.SMTPHost, from: cfg.From, auth: auth}, nil
+}
+
+func (s *smtpChannel) Name() string { return "SMTP" }
+
+func (s *smtpChannel) Send(ctx context.Context, msg Message) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	data, id, err := Compose(s.from, msg, time.Now())
+	if err != nil {
+		return Receipt{}, err
+	}
+	if err := s.deliver(ctx, envelopeRecipients(msg.To), data); err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{Channel: s.Name(), Reference: id}, nil
+}
+
+// deliver runs one SMTP transaction the way smtp.SendMail does, but dials and talks to
+// the server under a deadline and gives up when ctx is cancelled.
+func (s *smtpChannel) deliver(ctx context.Context, to []string, data []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	if err = conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	c, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		if ok, _ := c.Extension("AUTH"); ok {
+			if err = c.Auth(s.auth); err != nil {
+				return err
+			}
+		}
+	}
+	if err = c.Mail(addressOnly(s.from)); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// fileDropChannel writes each message as an .eml file instead of sending it, for
+// development and tests.
+type fileDropChannel struct {
+	dir  string
+	from string
+}
+
+func NewFileDrop(cfg Config) (Channel, error) {
+	if cfg.FileDropDir == "" {
+		return nil, fmt.Errorf("file drop directory is not configured")
+	}
+	if err := os.MkdirAll(cfg.FileDropDir, 0o755); err != nil {
+		return nil, err
+	}
+	from := cfg.From
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+	return &fileDropChannel{dir: cfg.FileDropDir, from: from}, nil
+}
+
+func (f *fileDropChannel) Name() string { return "FILE" }
+
+func (f *fileDropChannel) Send(ctx context.Context, msg Message) (Receipt, error) {
+	if err := ctx.Err(); err != nil {
+		return Receipt{}, err
+	}
+	now := time.Now()
+	data, _, err := Compose(f.from, msg, now)
+	if err != nil {
+		return Receipt{}, err
+	}
+	path := filepath.Join(f.dir, fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405"), randomToken(4)))
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return Receipt{}, err
+	}
+	return Receipt{Channel: f.Name(), Reference: path}, nil
+}
+
+// Compose renders msg as a MIME message with a text body and base64 attachments,
+// returning it with its Message-ID.
+func Compose(from string, msg Message, date time.Time) ([]byte, string, error) {
+	if len(recipients(msg.To)) == 0 {
+		return nil, "", fmt.Errorf("message has no recipients")
+	}
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	text, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}, "Content-Transfer-Encoding": {"quoted-printable"}})
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err = text.Write([]byte(quotedPrintable(msg.Body))); err != nil {
+		return nil, "", err
+	}
+	for _, a := range msg.Attachments {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(wrapBase64(a.Data)); err != nil {
+			return nil, "", err
+		}
+	}
+	if err = parts.Close(); err != nil {
+		return nil, "", err
+	}
+	domain := "localhost"
+	if at := strings.LastIndex(addressOnly(from), "@"); at >= 0 {
+		domain = addressOnly(from)[at+1:]
+	}
+	id := fmt.Sprintf("<%d.%s@%s>", date.UnixNano(), randomToken(8), domain)
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", strings.Join(recipients(msg.To), ", "))
+	if msg.ReplyTo != "" {
+		fmt.Fprintf(&out, "Reply-To: %s\r\n", msg.ReplyTo)
+	}
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: %s\r\n", id)
+	out.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", parts.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), id, nil
+}
+
+// ValidAddress reports whether value is a single usable email address.
+func ValidAddress(value string) bool {
+	addr, err := mail.ParseAddress(value)
+	return err == nil && strings.Contains(addr.Address, "@")
+}
+
+func recipients(to []string) []string {
+	out := make([]string, 0, len(to))
+	for _, addr := range to {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// envelopeRecipients is the bare address of each recipient, as RCPT TO expects; the
+// To header keeps any display names.
+func envelopeRecipients(to []string) []string {
+	out := recipients(to)
+	for i, addr := range out {
+		out[i] = addressOnly(addr)
+	}
+	return out
+}
+
+func addressOnly(value string) string {
+	if addr, err := mail.ParseAddress(value); err == nil {
+		return addr.Address
+	}
+	return value
+}
+
+func wrapBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b bytes.Buffer
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
+
+func quotedPrintable(value string) string {
+	var b bytes.Buffer
+	for _, line := range strings.Split(strings.ReplaceAll(value, "\r\n", "\n"), "\n") {
+		var out strings.Builder
+		width := 0
+		for _, c := range []byte(line) {
+			chunk := string(c)
+			if c == '=' || c > 126 || (c < 32 && c != '\t') {
+				chunk = fmt.Sprintf("=%02X", c)
+			}
+			if width+len(chunk) > 75 {
+				out.WriteString("=\r\n")
+				width = 0
+			}
+			out.WriteString(chunk)
+			width += len(chunk)
+		}
+		b.WriteString(out.String() + "\r\n")
+	}
+	return b.String()
+}
+
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
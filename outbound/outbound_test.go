@@ -0,0 +1,91 @@
+package outbound
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileDropWritesReadableMessage(t *testing.T) {
+	channel, err := NewFileDrop(Config{FileDropDir: t.TempDir(), From: "Shop <orders@shop.example>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pdf := []byte("%PDF-1.4 test document")
+	receipt, err := channel.Send(context.Background(), Message{
+		To:          []string{"sales@supplier.example", " "},
+		Subject:     "Purchase order PO-1",
+		Body:        "Please find our order attached.",
+		Attachments: []Attachment{{Filename: "PO-1.pdf", ContentType: "application/pdf", Data: pdf}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.Channel != "FILE" || !strings.HasSuffix(receipt.Reference, ".eml") {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+	raw, err := os.ReadFile(receipt.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("To") != "sales@supplier.example" || msg.Header.Get("Message-Id") == "" {
+		t.Fatalf("unexpected headers %v", msg.Header)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	var found bool
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FileName() != "PO-1.pdf" {
+			continue
+		}
+		encoded, _ := io.ReadAll(part)
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+		if err != nil || string(decoded) != string(pdf) {
+			t.Fatalf("attachment did not round-trip: %v", err)
+		}
+		found = true
+	}
+	if !found {
+		t.Fatal("attachment missing")
+	}
+}
+
+func TestComposeRequiresRecipient(t *testing.T) {
+	if _, _, err := Compose("orders@shop.example", Message{To: []string{""}}, time.Now()); err == nil {
+		t.Fatal("expected an error without recipients")
+	}
+}
+
+func TestValidAddress(t *testing.T) {
+	if !ValidAddress("Supplier <sales@supplier.example>") || ValidAddress("not an address") {
+		t.Fatal("unexpected address validation")
+	}
+}
+
+func TestEnvelopeRecipientsDropDisplayNames(t *testing.T) {
+	got := envelopeRecipients([]string{"Supplier <sales@supplier.example>", " ", "ap@supplier.example"})
+	if len(got) != 2 || got[0] != "sales@supplier.example" || got[1] != "ap@supplier.example" {
+		t.Fatalf("unexpected envelope recipients %v", got)
+	}
+}
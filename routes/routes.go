@@ -101,6 +101,26 @@ func SetupRoutes(app *fiber.App) {
 	merchantShops.Post("/:shopId/inventory/:inventoryItemId/stock-in", handlers.HandleStockInItem)
 	merchantShops.Patch("/:shopId/inventory/:inventoryItemId/adjust-stock", handlers.HandleAdjustStockItem)
 	merchantShops.Get("/:shopId/sales", handlers.HandleListSalesForShop)
+	merchantShops.Get("/:shopId/expiring-stock", handlers.HandleListExpiringStock)
+	merchantShops.Post("/:shopId/expiring-stock/write-offs", handlers.HandleWriteOffExpiredStock)
+	merchantShops.Get("/:shopId/transformations", handlers.HandleListInventoryTransformations)
+	merchantShops.Post("/:shopId/transformations", handlers.HandleCreateInventoryTransformation)
+	merchantShops.Get("/:shopId/transformations/:transformationId", handlers.HandleGetInventoryTransformation)
+	merchantShops.Get("/:shopId/reorder-suggestions", handlers.HandleListReorderSuggestions)
+	merchantShops.Post("/:shopId/reorder-suggestions/purchase-orders", handlers.HandleCreateReorderPurchaseOrders)
+	merchantShops.Put("/:shopId/inventory/:inventoryItemId/reorder-settings", handlers.HandleUpdateReorderSettings)
+	merchantShops.Get("/:shopId/inventory-valuation", handlers.HandleGetInventoryValuation)
+	merchantShops.Post("/:shopId/reconciliation/run", handlers.HandleRunInventoryReconciliation)
+	merchantShops.Get("/:shopId/reconciliation/exceptions", handlers.HandleListReconciliationExceptions)
+	merchantShops.Post("/:shopId/reconciliation/exceptions/:exceptionId/resolve", handlers.HandleResolveReconciliationException)
+	merchantShops.Get("/:shopId/stock-policy", handlers.HandleGetShopStockPolicy)
+	merchantShops.Put("/:shopId/stock-policy", handlers.HandleUpdateShopStockPolicy)
+	merchantShops.Get("/:shopId/backorders", handlers.HandleListShopBackorders)
+	merchantShops.Get("/:shopId/bins", handlers.HandleListStorageBins)
+	merchantShops.Post("/:shopId/bins", handlers.HandleCreateStorageBin)
+	merchantShops.Put("/:shopId/bins/:binId", handlers.HandleUpdateStorageBin)
+	merchantShops.Delete("/:shopId/bins/:binId", handlers.HandleDeleteStorageBin)
+	merchantShops.Put("/:shopId/inventory/:inventoryItemId/bins", handlers.HandleUpdateInventoryBins)
 
 	// New routes for stock adjustment and history
 	merchantShops.Post("/:shopId/inventory/:itemId/adjust", handlers.HandleAdjustStock)
@@ -111,6 +131,7 @@ func SetupRoutes(app *fiber.App) {
 	merchantSales.Post("/", handlers.HandleCreateSale)
 	merchantSales.Get("/:saleId", handlers.HandleGetSaleByID)
 	merchantSales.Get("/:saleId/receipt", handlers.HandleGetReceipt)
+	merchantSales.Post("/:saleId/returns", handlers.HandleCreateSaleReturn)
 
 	// Merchant Promotions
 	promotions := merchant.Group("/promotions")
@@ -175,7 +196,32 @@ func SetupRoutes(app *fiber.App) {
 	procurement := merchant.Group("/purchasing")
 	procurement.Get("/orders", handlers.HandleListPurchaseOrders)
 	procurement.Post("/orders", handlers.HandleCreatePurchaseOrder)
+	procurement.Get("/orders/:orderId", handlers.HandleGetPurchaseOrder)
+	procurement.Put("/orders/:orderId", handlers.HandleUpdatePurchaseOrder)
+	procurement.Post("/orders/:orderId/approve", handlers.HandleApprovePurchaseOrder)
+	procurement.Post("/orders/:orderId/cancel", handlers.HandleCancelPurchaseOrder)
 	procurement.Post("/orders/:orderId/receive", handlers.HandleReceivePurchaseOrder)
+	procurement.Get("/orders/:orderId/pdf", handlers.HandleGetPurchaseOrderPDF)
+	procurement.Post("/orders/:orderId/send", handlers.HandleSendPurchaseOrder)
+	procurement.Get("/orders/:orderId/sends", handlers.HandleListPurchaseOrderSends)
+	procurement.Get("/settings", handlers.HandleGetPurchasingSettings)
+	procurement.Put("/settings", handlers.HandleUpdatePurchasingSettings)
+	procurement.Get("/invoices", handlers.HandleListSupplierInvoices)
+	procurement.Post("/invoices", handlers.HandleCreateSupplierInvoice)
+	procurement.Get("/invoices/:invoiceId", handlers.HandleGetSupplierInvoice)
+	procurement.Post("/invoices/:invoiceId/payments", handlers.HandleCreateSupplierPayment)
+	procurement.Get("/suppliers/:supplierId/statement", handlers.HandleGetSupplierStatement)
+	procurement.Get("/ap-aging", handlers.HandleGetAPAging)
+	procurement.Get("/returns", handlers.HandleListSupplierReturns)
+	procurement.Post("/returns", handlers.HandleCreateSupplierReturn)
+	procurement.Get("/returns/:returnId", handlers.HandleGetSupplierReturn)
+	procurement.Get("/credit-notes", handlers.HandleListSupplierCreditNotes)
+	procurement.Get("/receipts/:receiptId/landed-costs", handlers.HandleListGoodsReceiptCosts)
+	procurement.Post("/receipts/:receiptId/landed-costs", handlers.HandleCreateGoodsReceiptCost)
+	procurement.Put("/receipts/:receiptId/landed-costs/:costId", handlers.HandleUpdateGoodsReceiptCost)
+	procurement.Delete("/receipts/:receiptId/landed-costs/:costId", handlers.HandleDeleteGoodsReceiptCost)
+	procurement.Get("/cost-adjustments", handlers.HandleListInventoryCostAdjustments)
+	procurement.Get("/stock-items/:stockItemId/suppliers", handlers.HandleCompareSupplierPrices)
 	accounting := merchant.Group("/accounting")
 	accounting.Get("/accounts", handlers.HandleListAccounts)
 	accounting.Post("/accounts", handlers.HandleCreateAccount)
@@ -185,12 +231,20 @@ func SetupRoutes(app *fiber.App) {
 	suppliers.Get("/:supplierId", handlers.HandleGetSupplierDetails)
 	suppliers.Put("/:supplierId", handlers.HandleUpdateExistingSupplier)
 	suppliers.Delete("/:supplierId", handlers.HandleDeleteExistingSupplier)
+	suppliers.Get("/:supplierId/products", handlers.HandleListSupplierProducts)
+	suppliers.Put("/:supplierId/products/:stockItemId", handlers.HandleUpsertSupplierProduct)
+	suppliers.Delete("/:supplierId/products/:stockItemId", handlers.HandleDeleteSupplierProduct)
 
 	inventory := merchant.Group("/inventory")
 	inventory.Get("/", handlers.HandleListInventoryItems)
 	inventory.Post("/", handlers.HandleCreateInventoryItem)
 	inventory.Post("/stock-in", handlers.HandleMerchantStockIn)
 	inventory.Post("/move-stock", handlers.HandleMoveStock)
+	inventory.Get("/replenishment-requests", handlers.HandleListReplenishmentRequests)
+	inventory.Get("/replenishment-requests/:requestId", handlers.HandleGetReplenishmentRequest)
+	inventory.Post("/replenishment-requests/:requestId/reject", handlers.HandleRejectReplenishmentRequest)
+	inventory.Post("/replenishment-requests/:requestId/purchase-order", handlers.HandleOrderReplenishmentRequest)
+	inventory.Post("/replenishment-requests/:requestId/transfer", handlers.HandleTransferReplenishmentRequest)
 	inventory.Get("/measurement-groups", handlers.HandleListMeasurementGroups)
 	inventory.Post("/measurement-groups", handlers.HandleCreateMeasurementGroup)
 	inventory.Put("/measurement-groups/:groupId", handlers.HandleUpdateMeasurementGroup)
@@ -214,8 +268,20 @@ func SetupRoutes(app *fiber.App) {
 	inventory.Delete("/identifier-types/:identifierTypeId", handlers.HandleDeleteInventoryIdentifierType)
 	inventory.Get("/barcodes", handlers.HandleListMerchantBarcodes)
 	inventory.Post("/barcodes", handlers.HandleCreateMerchantBarcode)
+	inventory.Post("/barcodes/generate", handlers.HandleGenerateMerchantBarcode)
+	inventory.Post("/barcodes/labels", handlers.HandleRenderBarcodeLabels)
+	inventory.Get("/barcodes/:barcodeId/image", handlers.HandleRenderMerchantBarcode)
 	inventory.Put("/barcodes/:barcodeId", handlers.HandleUpdateMerchantBarcode)
 	inventory.Delete("/barcodes/:barcodeId", handlers.HandleDeleteMerchantBarcode)
+	inventory.Get("/scale-formats", handlers.HandleListScaleBarcodeFormats)
+	inventory.Post("/scale-formats", handlers.HandleCreateScaleBarcodeFormat)
+	inventory.Put("/scale-formats/:formatId", handlers.HandleUpdateScaleBarcodeFormat)
+	inventory.Delete("/scale-formats/:formatId", handlers.HandleDeleteScaleBarcodeFormat)
+	inventory.Get("/scale-plu-codes", handlers.HandleListScalePLUCodes)
+	inventory.Post("/scale-plu-codes", handlers.HandleCreateScalePLUCode)
+	inventory.Delete("/scale-plu-codes/:pluId", handlers.HandleDeleteScalePLUCode)
+	inventory.Get("/costing-method", handlers.HandleGetCostingMethod)
+	inventory.Put("/costing-method", handlers.HandleUpdateCostingMethod)
 	inventory.Get("/batches", handlers.HandleListInventoryBatches)
 	inventory.Post("/:inventoryItemId/batches", handlers.HandleCreateInventoryBatch)
 	inventory.Put("/batches/:batchId", handlers.HandleUpdateInventoryBatch)
@@ -225,15 +291,35 @@ func SetupRoutes(app *fiber.App) {
 	inventory.Patch("/reservations/:reservationId/release", handlers.HandleReleaseInventoryReservation)
 	inventory.Get("/serials", handlers.HandleListInventorySerials)
 	inventory.Post("/:inventoryItemId/serials", handlers.HandleCreateInventorySerial)
+	inventory.Get("/serials/:serialId/history", handlers.HandleGetInventorySerialHistory)
 	inventory.Put("/serials/:serialId", handlers.HandleUpdateInventorySerial)
 	inventory.Delete("/serials/:serialId", handlers.HandleDeleteInventorySerial)
 	inventory.Get("/assets", handlers.HandleListInventoryAssets)
 	inventory.Post("/:inventoryItemId/assets", handlers.HandleCreateInventoryAsset)
 	inventory.Put("/assets/:assetId", handlers.HandleUpdateInventoryAsset)
 	inventory.Delete("/assets/:assetId", handlers.HandleDeleteInventoryAsset)
+	inventory.Post("/assets/:assetId/status", handlers.HandleTransitionInventoryAsset)
+	inventory.Get("/assets/:assetId/history", handlers.HandleGetInventoryAssetHistory)
+	inventory.Post("/assets/:assetId/rentals", handlers.HandleCreateAssetRental)
+	inventory.Get("/rentals", handlers.HandleListAssetRentals)
+	inventory.Post("/rentals/:rentalId/return", handlers.HandleReturnAssetRental)
+	inventory.Get("/warranties", handlers.HandleLookupWarranty)
+	inventory.Get("/rma-cases", handlers.HandleListRMACases)
+	inventory.Post("/rma-cases", handlers.HandleCreateRMACase)
+	inventory.Get("/rma-cases/:caseId", handlers.HandleGetRMACase)
+	inventory.Post("/rma-cases/:caseId/status", handlers.HandleUpdateRMACaseStatus)
+	inventory.Post("/rma-cases/:caseId/replacement", handlers.HandleIssueRMAReplacement)
+	inventory.Post("/rma-cases/:caseId/notes", handlers.HandleAddRMACaseNote)
+	inventory.Post("/rma-cases/:caseId/attachments", handlers.HandleUploadRMACaseAttachment)
+	inventory.Delete("/rma-cases/:caseId/attachments/:attachmentId", handlers.HandleDeleteRMACaseAttachment)
 	inventory.Get("/assets/:assetId/identifiers", handlers.HandleListInventoryAssetIdentifiers)
 	inventory.Post("/assets/:assetId/identifiers", handlers.HandleCreateInventoryAssetIdentifier)
 	inventory.Delete("/asset-identifiers/:identifierId", handlers.HandleDeleteInventoryAssetIdentifier)
+	inventory.Get("/kits", handlers.HandleListProductKits)
+	inventory.Post("/kits", handlers.HandleCreateProductKit)
+	inventory.Get("/kits/:kitId", handlers.HandleGetProductKit)
+	inventory.Put("/kits/:kitId", handlers.HandleUpdateProductKit)
+	inventory.Delete("/kits/:kitId", handlers.HandleDeleteProductKit)
 	inventory.Get("/:itemId", handlers.HandleGetInventoryItemByID)
 	inventory.Put("/:itemId", handlers.HandleUpdateInventoryItem)
 	inventory.Delete("/:itemId", handlers.HandleDeleteInventoryItem)
@@ -298,6 +384,7 @@ func SetupRoutes(app *fiber.App) {
 	staffPOS.Get("/products", handlers.HandleSearchProductsForStaff)
 	staffPOS.Get("/promotions", handlers.HandleGetActivePromotionsForStaff)
 	staffPOS.Post("/checkout", handlers.HandleStaffCheckout)
+	staffPOS.Post("/sales/:saleId/returns", handlers.HandleCreateSaleReturn)
 
 	// --- Staff Items Routes ---
 	staffItems := staff.Group("/items")
@@ -305,6 +392,15 @@ func SetupRoutes(app *fiber.App) {
 	staffInvoices := staff.Group("/invoices")
 	staffInvoices.Get("/", handlers.HandleListStaffInvoices)
 	staffInvoices.Get("/:invoiceId", handlers.HandleGetStaffInvoiceByID)
+	staffReplenishment := staff.Group("/replenishment-requests")
+	staffReplenishment.Get("/", handlers.HandleListStaffReplenishmentRequests)
+	staffReplenishment.Post("/", handlers.HandleCreateStaffReplenishmentRequest)
+	staffReplenishment.Get("/:requestId", handlers.HandleGetStaffReplenishmentRequest)
+	staffReplenishment.Post("/:requestId/cancel", handlers.HandleCancelStaffReplenishmentRequest)
+	staffNotifications := staff.Group("/notifications")
+	staffNotifications.Get("/", handlers.HandleGetNotifications)
+	staffNotifications.Get("/unread-count", handlers.HandleGetUnreadNotificationCount)
+	staffNotifications.Patch("/:notificationId/read", handlers.HandleMarkNotificationAsRead)
 
 	// --- Shop Routes ---
 	// Shop routes are accessible by both merchants (with shopId param) and staff (with assigned shop)
@@ -317,6 +413,8 @@ func SetupRoutes(app *fiber.App) {
 	shop.Put("/items/:itemId/stock", handlers.HandleUpdateShopItemStock)
 	shop.Get("/inventory", handlers.HandleGetShopInventory)
 	shop.Post("/inventory/stock-in", handlers.HandleStockIn)
+	shop.Get("/shops/:shopId/expiring-stock", handlers.HandleListExpiringStock)
+	shop.Get("/shops/:shopId/kits", handlers.HandleListShopKits)
 
 	// Shop customers routes (accessible by both merchant and staff)
 	shopCustomers := shop.Group("/customers")
@@ -350,6 +448,7 @@ func SetupRoutes(app *fiber.App) {
 	// --- Shop POS Routes ---
 	shopPOS := shop.Group("/pos")
 	shopPOS.Get("/:shopId/products", handlers.HandleSearchShopProducts)
+	shopPOS.Get("/:shopId/scan", handlers.HandleResolveShopScan)
 	shopPOS.Get("/promotions", handlers.HandleGetActivePromotionsForShop)
 	shopPOS.Post("/:shopId/checkout", handlers.HandleShopCheckout)
 
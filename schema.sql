@@ -63,6 +63,11 @@ CREATE TABLE shops (
     settings JSONB NOT NULL DEFAULT '{}'::jsonb,
     opening_hours JSONB,
     supports_delivery BOOLEAN NOT NULL DEFAULT FALSE,
+    -- What checkout does when a sale exceeds available stock: refuse it, or sell ahead
+    -- of receipts (with or without a cashier warning) and record a backorder.
+    negative_stock_policy VARCHAR(20) NOT NULL DEFAULT 'BLOCK' CHECK (negative_stock_policy IN ('BLOCK', 'WARN', 'ALLOW_NEGATIVE')),
+    -- Stock locations are all kept here; warehouses hold stock but never sell at the POS.
+    location_type VARCHAR(20) NOT NULL DEFAULT 'STORE' CHECK (location_type IN ('STORE', 'WAREHOUSE', 'VAN')),
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE (merchant_id, name),
@@ -267,6 +272,10 @@ CREATE TABLE stock_item_configurations (
     allow_unit_conversions BOOLEAN NOT NULL DEFAULT FALSE,
     allow_pack_breaking BOOLEAN NOT NULL DEFAULT FALSE,
     allow_multiple_barcodes BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Warranty granted when a serial or asset of this item is sold.
+    warranty_months INTEGER CHECK (warranty_months BETWEEN 0 AND 240),
+    -- Overrides the shop's negative-stock policy for this item; NULL inherits it.
+    negative_stock_policy VARCHAR(20) CHECK (negative_stock_policy IN ('BLOCK', 'WARN', 'ALLOW_NEGATIVE')),
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
@@ -279,6 +288,8 @@ CREATE TABLE stock_item_units (
     is_sales_unit BOOLEAN NOT NULL DEFAULT FALSE,
     is_purchase_unit BOOLEAN NOT NULL DEFAULT FALSE,
     allows_fractional BOOLEAN NOT NULL DEFAULT FALSE,
+    -- The unit reports display this stock item's quantities in.
+    is_report_unit BOOLEAN NOT NULL DEFAULT FALSE,
     position INTEGER NOT NULL DEFAULT 0,
     UNIQUE (stock_item_id, unit_id)
 );
@@ -292,6 +303,20 @@ CREATE TABLE stock_item_unit_conversions (
     UNIQUE (stock_item_id, from_unit_id, to_unit_id)
 );
 
+-- Bins and shelves inside a stock location.
+CREATE TABLE storage_bins (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    code VARCHAR(50) NOT NULL,
+    name VARCHAR(255),
+    bin_type VARCHAR(10) NOT NULL DEFAULT 'BIN' CHECK (bin_type IN ('BIN', 'SHELF')),
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (shop_id, code)
+);
+
 -- One inventory balance exists for each shop and stock item. Quantities are
 -- changed through inventory_movements, not by arbitrary application updates.
 CREATE TABLE inventory_items (
@@ -301,9 +326,17 @@ CREATE TABLE inventory_items (
     product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
     stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE RESTRICT,
     variant_id UUID REFERENCES product_variants(id) ON DELETE SET NULL,
-    quantity_on_hand NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_on_hand >= 0),
+    -- Negative only when the negative-stock policy let a sale run ahead of receipts.
+    quantity_on_hand NUMERIC(15,3) NOT NULL DEFAULT 0,
     reserved_quantity NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (reserved_quantity >= 0),
     low_stock_threshold NUMERIC(15,3) CHECK (low_stock_threshold >= 0),
+    -- Extra units kept above lead-time demand when computing the reorder point.
+    safety_stock NUMERIC(15,3) CHECK (safety_stock >= 0),
+    -- Moving average cost per base unit, maintained on every receipt.
+    average_cost NUMERIC(15,4) CHECK (average_cost >= 0),
+    -- Default bins receipts are put away into and sales and transfers pick from.
+    putaway_bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL,
+    pick_bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL,
     is_active BOOLEAN NOT NULL DEFAULT TRUE,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -320,13 +353,56 @@ CREATE TABLE inventory_batches (
     batch_code VARCHAR(100) NOT NULL,
     quantity_received NUMERIC(15,3) NOT NULL CHECK (quantity_received >= 0),
     quantity_remaining NUMERIC(15,3) NOT NULL CHECK (quantity_remaining >= 0),
-    unit_cost NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
     manufacture_date DATE,
     expiry_date DATE,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE (shop_id, stock_item_id, batch_code)
 );
 
+-- Units sold beyond available stock, settled oldest first as stock is received.
+CREATE TABLE inventory_backorders (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    reference_type VARCHAR(30) NOT NULL,
+    reference_id UUID,
+    quantity NUMERIC(20,8) NOT NULL CHECK (quantity > 0),
+    settled_quantity NUMERIC(20,8) NOT NULL DEFAULT 0 CHECK (settled_quantity >= 0),
+    status VARCHAR(10) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'SETTLED')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMPTZ
+);
+
+-- Expiry alert stages already sent for a batch; lead_days -1 marks the expired alert.
+CREATE TABLE inventory_expiry_alerts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    batch_id UUID NOT NULL REFERENCES inventory_batches(id) ON DELETE CASCADE,
+    lead_days INTEGER NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (batch_id, lead_days)
+);
+
+-- Open receipts of stock, consumed oldest first when a merchant costs by FIFO.
+CREATE TABLE inventory_cost_layers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+    quantity_received NUMERIC(15,3) NOT NULL CHECK (quantity_received > 0),
+    quantity_remaining NUMERIC(15,3) NOT NULL CHECK (quantity_remaining >= 0),
+    unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    received_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Receipt line the layer came from, so landed cost reallocations can revalue it.
+    goods_receipt_item_id UUID
+);
+
+CREATE TABLE merchant_costing_settings (
+    merchant_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    costing_method VARCHAR(20) NOT NULL DEFAULT 'WEIGHTED_AVERAGE' CHECK (costing_method IN ('WEIGHTED_AVERAGE', 'FIFO')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE inventory_movements (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -338,12 +414,45 @@ CREATE TABLE inventory_movements (
     movement_type VARCHAR(20) NOT NULL CHECK (movement_type IN ('IN', 'OUT', 'ADJUSTMENT', 'RETURN', 'TRANSFER')),
     quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
     base_quantity NUMERIC(20,8),
-    unit_cost NUMERIC(15,2),
+    -- Cost per base unit, recorded when the movement is written.
+    unit_cost NUMERIC(15,4),
     reference_type VARCHAR(30),
     reference_id UUID,
     event_key TEXT UNIQUE,
     movement_date TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    notes TEXT
+    notes TEXT,
+    -- Set when a movement draws from or writes off one specific batch.
+    batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
+    reason_code VARCHAR(50),
+    -- Bin the stock was put into or taken from, when the location uses bins.
+    bin_id UUID REFERENCES storage_bins(id) ON DELETE SET NULL
+);
+
+-- Quantity of a balance held in each bin, kept alongside bin-tagged movements.
+CREATE TABLE inventory_bin_balances (
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+    bin_id UUID NOT NULL REFERENCES storage_bins(id) ON DELETE RESTRICT,
+    quantity NUMERIC(15,3) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (inventory_item_id, bin_id)
+);
+
+-- On-hand balances that disagree with the movement ledger, found by the reconciliation job.
+CREATE TABLE inventory_reconciliation_exceptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    recorded_quantity NUMERIC(15,3) NOT NULL,
+    ledger_quantity NUMERIC(15,3) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'RESOLVED', 'CLEARED')),
+    resolution VARCHAR(30) CHECK (resolution IN ('REBUILD_FROM_LEDGER', 'CORRECTING_MOVEMENT')),
+    notes TEXT,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_checked_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMPTZ,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL
 );
 
 CREATE TABLE inventory_reservations (
@@ -358,7 +467,9 @@ CREATE TABLE inventory_reservations (
     reservation_key TEXT NOT NULL UNIQUE,
     quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
     base_quantity NUMERIC(20,8),
-    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'RELEASED')),
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'RELEASED', 'EXPIRED', 'CONSUMED')),
+    expires_at TIMESTAMPTZ,
+    sale_id UUID,
     released_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
@@ -377,6 +488,19 @@ CREATE TABLE inventory_serials (
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Traceability history for each serial: receipt, transfers, sale, and returns.
+CREATE TABLE inventory_serial_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    serial_id UUID NOT NULL REFERENCES inventory_serials(id) ON DELETE CASCADE,
+    event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('RECEIVED', 'TRANSFERRED', 'SOLD', 'RETURNED', 'ADJUSTED', 'RMA')),
+    shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+    reference_type VARCHAR(30),
+    reference_id UUID,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE barcode_registry (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -392,6 +516,41 @@ CREATE TABLE barcode_registry (
     UNIQUE (merchant_id, normalized_code)
 );
 
+-- Last sequence number issued per merchant, symbology and prefix for generated barcodes.
+CREATE TABLE barcode_sequences (
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    symbology VARCHAR(20) NOT NULL CHECK (symbology IN ('EAN13', 'CODE128')),
+    prefix VARCHAR(20) NOT NULL,
+    last_value BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (merchant_id, symbology, prefix)
+);
+
+CREATE TABLE scale_barcode_formats (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- Leading digits printed by the scale; the PLU and then the value fill the rest of the 12 data digits.
+    prefix VARCHAR(3) NOT NULL CHECK (prefix ~ '^2[0-9]{0,2}$'),
+    item_code_length INTEGER NOT NULL CHECK (item_code_length BETWEEN 1 AND 8),
+    value_mode VARCHAR(10) NOT NULL CHECK (value_mode IN ('WEIGHT', 'PRICE')),
+    value_decimals INTEGER NOT NULL DEFAULT 3 CHECK (value_decimals BETWEEN 0 AND 4),
+    -- Unit the embedded weight is expressed in; NULL means the stock item's base unit.
+    unit_id UUID REFERENCES unit_definitions(id) ON DELETE RESTRICT,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (merchant_id, prefix)
+);
+
+CREATE TABLE scale_plu_codes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    plu_code VARCHAR(8) NOT NULL,
+    stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (merchant_id, plu_code)
+);
+
 CREATE TABLE inventory_identifier_types (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -413,7 +572,7 @@ CREATE TABLE inventory_assets (
     batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
     asset_tag VARCHAR(255) NOT NULL,
     status VARCHAR(20) NOT NULL DEFAULT 'AVAILABLE'
-        CHECK (status IN ('AVAILABLE', 'RESERVED', 'SOLD', 'RETURNED', 'INACTIVE')),
+        CHECK (status IN ('AVAILABLE', 'RESERVED', 'SOLD', 'RETURNED', 'INACTIVE', 'RENTED')),
     metadata JSONB,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE (merchant_id, asset_tag)
@@ -429,6 +588,20 @@ CREATE TABLE inventory_asset_identifiers (
     UNIQUE (identifier_type_id, normalized_value)
 );
 
+CREATE TABLE inventory_asset_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    asset_id UUID NOT NULL REFERENCES inventory_assets(id) ON DELETE CASCADE,
+    event_type VARCHAR(20) NOT NULL CHECK (event_type IN ('RECEIVED', 'STATUS_CHANGED', 'SOLD', 'RENTED', 'RENTAL_RETURNED', 'RMA')),
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+    reference_type VARCHAR(30),
+    reference_id UUID,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    notes TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE inventory_transformations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -453,6 +626,27 @@ CREATE TABLE inventory_transformation_lines (
     unit_cost NUMERIC(15,2)
 );
 
+-- Kits (bills of materials) are sold through their own stock item but deduct
+-- component stock instead; the kit balance itself stays at zero.
+CREATE TABLE product_kits (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    stock_item_id UUID NOT NULL UNIQUE REFERENCES stock_items(id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE product_kit_components (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kit_id UUID NOT NULL REFERENCES product_kits(id) ON DELETE CASCADE,
+    stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE RESTRICT,
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    UNIQUE (kit_id, stock_item_id)
+);
+
 CREATE TABLE inventory_operations (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     client_operation_id TEXT NOT NULL UNIQUE,
@@ -577,6 +771,43 @@ CREATE TABLE sales (
     CONSTRAINT uq_sales_merchant_client_sale UNIQUE (merchant_id, client_sale_id)
 );
 
+ALTER TABLE inventory_reservations ADD CONSTRAINT fk_inventory_reservations_sale
+    FOREIGN KEY (sale_id) REFERENCES sales(id) ON DELETE SET NULL;
+
+-- Warranty cover for a sold serial or asset; VOID once the unit comes back.
+CREATE TABLE inventory_warranties (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    serial_id UUID REFERENCES inventory_serials(id) ON DELETE CASCADE,
+    asset_id UUID REFERENCES inventory_assets(id) ON DELETE CASCADE,
+    sale_id UUID REFERENCES sales(id) ON DELETE SET NULL,
+    customer_id UUID REFERENCES shop_customers(id) ON DELETE SET NULL,
+    starts_on DATE NOT NULL,
+    ends_on DATE NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'ACTIVE' CHECK (status IN ('ACTIVE', 'VOID')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (serial_id IS NOT NULL OR asset_id IS NOT NULL),
+    CHECK (ends_on >= starts_on)
+);
+
+CREATE TABLE asset_rentals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    asset_id UUID NOT NULL REFERENCES inventory_assets(id) ON DELETE CASCADE,
+    customer_id UUID NOT NULL REFERENCES shop_customers(id) ON DELETE RESTRICT,
+    status VARCHAR(10) NOT NULL DEFAULT 'OUT' CHECK (status IN ('OUT', 'RETURNED')),
+    checked_out_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    due_at TIMESTAMPTZ NOT NULL,
+    returned_at TIMESTAMPTZ,
+    checked_out_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    returned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    notes TEXT,
+    return_notes TEXT,
+    CHECK (due_at > checked_out_at)
+);
+
 CREATE TABLE sale_items (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     sale_id UUID NOT NULL REFERENCES sales(id) ON DELETE CASCADE,
@@ -588,13 +819,45 @@ CREATE TABLE sale_items (
     item_name VARCHAR(255) NOT NULL,
     item_sku VARCHAR(100),
     quantity_sold NUMERIC(15,3) NOT NULL CHECK (quantity_sold > 0),
+    -- quantity_sold converted to the stock item's base unit.
+    base_quantity NUMERIC(20,8),
+    -- Cost of goods sold per base unit and for the whole line.
+    unit_cost NUMERIC(15,4),
+    cost_total NUMERIC(15,2),
     selling_price_at_sale NUMERIC(15,2) NOT NULL CHECK (selling_price_at_sale >= 0),
     original_price_at_sale NUMERIC(15,2),
     subtotal NUMERIC(15,2) NOT NULL CHECK (subtotal >= 0),
+    quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_returned >= 0),
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Component stock consumed by a kit sale line.
+CREATE TABLE sale_item_components (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sale_item_id UUID NOT NULL REFERENCES sale_items(id) ON DELETE CASCADE,
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE RESTRICT,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    quantity_per_kit NUMERIC(15,3) NOT NULL CHECK (quantity_per_kit > 0),
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_returned >= 0 AND quantity_returned <= quantity),
+    unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Batch lines consumed by a sale item when the stock item tracks batches.
+CREATE TABLE sale_item_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    sale_item_id UUID NOT NULL REFERENCES sale_items(id) ON DELETE CASCADE,
+    batch_id UUID NOT NULL REFERENCES inventory_batches(id) ON DELETE RESTRICT,
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    quantity_returned NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (quantity_returned >= 0 AND quantity_returned <= quantity),
+    unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- Set when the batch was consumed by a kit component rather than the sale item itself.
+    sale_item_component_id UUID REFERENCES sale_item_components(id) ON DELETE CASCADE
+);
+
 CREATE TABLE pos_terminals (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
@@ -722,12 +985,32 @@ CREATE TABLE suppliers (
     contact_phone VARCHAR(50),
     address TEXT,
     notes TEXT,
+    lead_time_days INTEGER NOT NULL DEFAULT 7 CHECK (lead_time_days >= 0),
     is_active BOOLEAN NOT NULL DEFAULT TRUE,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     UNIQUE (merchant_id, name)
 );
 
+-- What a supplier sells a stock item at. last_cost, min_order_quantity and pack_size are
+-- in unit_id, the base unit when NULL; a NULL lead time falls back to the supplier's.
+CREATE TABLE supplier_products (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE CASCADE,
+    stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+    supplier_sku VARCHAR(100),
+    unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
+    last_cost NUMERIC(15,4) CHECK (last_cost >= 0),
+    min_order_quantity NUMERIC(15,3) NOT NULL DEFAULT 0 CHECK (min_order_quantity >= 0),
+    pack_size NUMERIC(15,3) NOT NULL DEFAULT 1 CHECK (pack_size > 0),
+    lead_time_days INTEGER CHECK (lead_time_days >= 0),
+    is_preferred BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (supplier_id, stock_item_id)
+);
+
 CREATE TABLE purchase_orders (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
@@ -737,6 +1020,12 @@ CREATE TABLE purchase_orders (
     subtotal NUMERIC(15,2) NOT NULL DEFAULT 0,
     tax NUMERIC(15,2) NOT NULL DEFAULT 0,
     total NUMERIC(15,2) NOT NULL DEFAULT 0,
+    approved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    approved_at TIMESTAMPTZ,
+    cancelled_at TIMESTAMPTZ,
+    cancel_reason TEXT,
+    -- Last successful delivery of the order document to the supplier.
+    last_sent_at TIMESTAMPTZ,
     created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
@@ -749,6 +1038,7 @@ CREATE TABLE purchase_order_items (
     unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
     quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
     base_quantity NUMERIC(20,8),
+    -- Received so far, in the ordered unit.
     received_quantity NUMERIC(15,3) NOT NULL DEFAULT 0,
     unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
     total_cost NUMERIC(15,2) NOT NULL CHECK (total_cost >= 0)
@@ -765,6 +1055,7 @@ CREATE TABLE goods_receipts (
 CREATE TABLE goods_receipt_items (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
     goods_receipt_id UUID NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    purchase_order_item_id UUID REFERENCES purchase_order_items(id) ON DELETE SET NULL,
     product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
     stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
     unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
@@ -772,7 +1063,110 @@ CREATE TABLE goods_receipt_items (
     base_quantity NUMERIC(20,8),
     unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
     batch_code VARCHAR(100),
-    expiry_date DATE
+    expiry_date DATE,
+    -- Gross weight of the line, used when landed costs are allocated by weight.
+    weight NUMERIC(15,3) CHECK (weight >= 0),
+    -- Landed costs allocated to the line in total; its stock is costed at unit cost plus this share.
+    landed_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (landed_cost >= 0)
+);
+
+-- Freight, duty and other costs paid on top of the supplier price of a goods receipt.
+CREATE TABLE goods_receipt_costs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    goods_receipt_id UUID NOT NULL REFERENCES goods_receipts(id) ON DELETE CASCADE,
+    cost_type VARCHAR(20) NOT NULL CHECK (cost_type IN ('FREIGHT', 'DUTY', 'CUSTOMS', 'INSURANCE', 'OTHER')),
+    description TEXT,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount >= 0),
+    allocation_method VARCHAR(20) NOT NULL DEFAULT 'VALUE' CHECK (allocation_method IN ('VALUE', 'QUANTITY', 'WEIGHT')),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE goods_receipt_cost_allocations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    goods_receipt_cost_id UUID NOT NULL REFERENCES goods_receipt_costs(id) ON DELETE CASCADE,
+    goods_receipt_item_id UUID NOT NULL REFERENCES goods_receipt_items(id) ON DELETE CASCADE,
+    amount NUMERIC(15,4) NOT NULL CHECK (amount >= 0),
+    UNIQUE (goods_receipt_cost_id, goods_receipt_item_id)
+);
+
+-- Revaluations of stock already received, posted when landed costs are reallocated.
+-- The part of the change on stock already issued is kept apart as consumed_amount.
+CREATE TABLE inventory_cost_adjustments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE CASCADE,
+    goods_receipt_item_id UUID REFERENCES goods_receipt_items(id) ON DELETE SET NULL,
+    batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
+    reference_type VARCHAR(30) NOT NULL,
+    reference_id UUID,
+    unit_cost_delta NUMERIC(15,4) NOT NULL,
+    revalued_quantity NUMERIC(20,8) NOT NULL DEFAULT 0,
+    inventory_amount NUMERIC(15,2) NOT NULL DEFAULT 0,
+    consumed_quantity NUMERIC(20,8) NOT NULL DEFAULT 0,
+    consumed_amount NUMERIC(15,2) NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- How far a receipt may exceed the ordered quantity of a purchase order line.
+CREATE TABLE merchant_purchasing_settings (
+    merchant_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    over_receipt_tolerance_percent NUMERIC(5,2) NOT NULL DEFAULT 0 CHECK (over_receipt_tolerance_percent BETWEEN 0 AND 100),
+    -- Letterhead printed on purchase order documents; the merchant account fills any gaps.
+    letterhead_name VARCHAR(255),
+    letterhead_address TEXT,
+    letterhead_phone VARCHAR(50),
+    letterhead_email VARCHAR(255),
+    letterhead_tax_id VARCHAR(100),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every attempt to deliver a purchase order document to its supplier.
+CREATE TABLE purchase_order_sends (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    purchase_order_id UUID NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('SENT', 'FAILED')),
+    -- Message-ID for SMTP, the written file for the file-drop channel.
+    reference TEXT,
+    error TEXT,
+    document_sha256 CHAR(64) NOT NULL,
+    sent_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Stock a shop's staff ask the merchant for. The merchant rejects the request or fills
+-- it with a purchase order or a transfer from another shop.
+CREATE TABLE replenishment_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE CASCADE,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'SUBMITTED' CHECK (status IN ('SUBMITTED', 'REJECTED', 'CANCELLED', 'ORDERED', 'TRANSFERRED')),
+    notes TEXT,
+    needed_by DATE,
+    review_note TEXT,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMPTZ,
+    purchase_order_id UUID REFERENCES purchase_orders(id) ON DELETE SET NULL,
+    source_shop_id UUID REFERENCES shops(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Quantities are in the stock item's base unit; approved_quantity is what was ordered or moved.
+CREATE TABLE replenishment_request_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    request_id UUID NOT NULL REFERENCES replenishment_requests(id) ON DELETE CASCADE,
+    stock_item_id UUID NOT NULL REFERENCES stock_items(id) ON DELETE CASCADE,
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    approved_quantity NUMERIC(15,3) CHECK (approved_quantity >= 0),
+    note TEXT,
+    UNIQUE (request_id, stock_item_id)
 );
 
 CREATE TABLE supplier_invoices (
@@ -780,9 +1174,34 @@ CREATE TABLE supplier_invoices (
     merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
     purchase_order_id UUID NOT NULL REFERENCES purchase_orders(id) ON DELETE RESTRICT,
+    invoice_number VARCHAR(100),
+    invoice_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    due_date DATE,
+    tax_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (tax_amount >= 0),
     total_amount NUMERIC(15,2) NOT NULL CHECK (total_amount >= 0),
+    amount_paid NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_paid >= 0),
+    -- Supplier credit notes applied against the invoice.
+    amount_credited NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_credited >= 0),
     status VARCHAR(20) NOT NULL DEFAULT 'UNPAID' CHECK (status IN ('UNPAID', 'PARTIAL', 'PAID')),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+    -- VARIANCE when a line bills more than was received or at another price than ordered.
+    match_status VARCHAR(20) NOT NULL DEFAULT 'MATCHED' CHECK (match_status IN ('MATCHED', 'VARIANCE')),
+    notes TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Billed purchase order lines with the received quantity and ordered price they were matched against.
+CREATE TABLE supplier_invoice_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    supplier_invoice_id UUID NOT NULL REFERENCES supplier_invoices(id) ON DELETE CASCADE,
+    purchase_order_item_id UUID NOT NULL REFERENCES purchase_order_items(id) ON DELETE RESTRICT,
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    unit_cost NUMERIC(15,2) NOT NULL CHECK (unit_cost >= 0),
+    total_cost NUMERIC(15,2) NOT NULL CHECK (total_cost >= 0),
+    received_quantity NUMERIC(15,3) NOT NULL DEFAULT 0,
+    ordered_unit_cost NUMERIC(15,2) NOT NULL DEFAULT 0,
+    quantity_variance NUMERIC(15,3) NOT NULL DEFAULT 0,
+    price_variance NUMERIC(15,2) NOT NULL DEFAULT 0
 );
 
 CREATE TABLE supplier_payments (
@@ -790,9 +1209,112 @@ CREATE TABLE supplier_payments (
     supplier_invoice_id UUID NOT NULL REFERENCES supplier_invoices(id) ON DELETE RESTRICT,
     amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
     payment_method VARCHAR(30) NOT NULL,
+    reference VARCHAR(100),
     payment_date TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Stock sent back to a supplier, optionally against the goods receipt it arrived on.
+CREATE TABLE supplier_returns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE RESTRICT,
+    supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+    goods_receipt_id UUID REFERENCES goods_receipts(id) ON DELETE SET NULL,
+    reason TEXT,
+    total_amount NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (total_amount >= 0),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE supplier_return_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    supplier_return_id UUID NOT NULL REFERENCES supplier_returns(id) ON DELETE CASCADE,
+    inventory_item_id UUID NOT NULL REFERENCES inventory_items(id) ON DELETE RESTRICT,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    batch_id UUID REFERENCES inventory_batches(id) ON DELETE SET NULL,
+    unit_id UUID REFERENCES unit_definitions(id) ON DELETE SET NULL,
+    quantity NUMERIC(15,3) NOT NULL CHECK (quantity > 0),
+    base_quantity NUMERIC(20,8) NOT NULL CHECK (base_quantity > 0),
+    -- Credited per base unit.
+    unit_cost NUMERIC(15,4) NOT NULL DEFAULT 0 CHECK (unit_cost >= 0),
+    total_cost NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (total_cost >= 0)
+);
+
+-- Credit owed by a supplier, applied oldest first to the supplier's next invoices.
+CREATE TABLE supplier_credit_notes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    supplier_id UUID NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+    supplier_return_id UUID UNIQUE REFERENCES supplier_returns(id) ON DELETE RESTRICT,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+    amount_applied NUMERIC(15,2) NOT NULL DEFAULT 0 CHECK (amount_applied >= 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN' CHECK (status IN ('OPEN', 'APPLIED')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (amount_applied <= amount)
+);
+
+CREATE TABLE supplier_credit_applications (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    credit_note_id UUID NOT NULL REFERENCES supplier_credit_notes(id) ON DELETE CASCADE,
+    supplier_invoice_id UUID NOT NULL REFERENCES supplier_invoices(id) ON DELETE CASCADE,
+    amount NUMERIC(15,2) NOT NULL CHECK (amount > 0),
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Warranty returns (RMA) of a faulty serialised unit or asset, tracked through the supplier.
+CREATE TABLE rma_cases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merchant_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shop_id UUID NOT NULL REFERENCES shops(id) ON DELETE RESTRICT,
+    stock_item_id UUID REFERENCES stock_items(id) ON DELETE SET NULL,
+    serial_id UUID REFERENCES inventory_serials(id) ON DELETE RESTRICT,
+    asset_id UUID REFERENCES inventory_assets(id) ON DELETE RESTRICT,
+    sale_id UUID REFERENCES sales(id) ON DELETE SET NULL,
+    customer_id UUID REFERENCES shop_customers(id) ON DELETE SET NULL,
+    warranty_id UUID REFERENCES inventory_warranties(id) ON DELETE SET NULL,
+    -- Whether the warranty covered the unit when the case was opened.
+    under_warranty BOOLEAN NOT NULL DEFAULT FALSE,
+    supplier_id UUID REFERENCES suppliers(id) ON DELETE RESTRICT,
+    supplier_reference VARCHAR(100),
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN'
+        CHECK (status IN ('OPEN', 'SENT_TO_SUPPLIER', 'REPAIRED', 'REPLACED', 'REJECTED', 'CLOSED')),
+    fault_description TEXT NOT NULL,
+    -- Unit issued to the customer in place of the faulty one.
+    replacement_serial_id UUID REFERENCES inventory_serials(id) ON DELETE SET NULL,
+    replacement_asset_id UUID REFERENCES inventory_assets(id) ON DELETE SET NULL,
+    opened_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMPTZ,
+    CHECK ((serial_id IS NOT NULL) <> (asset_id IS NOT NULL))
+);
+
+-- Case notes; status changes are logged here with from_status and to_status.
+CREATE TABLE rma_case_notes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rma_case_id UUID NOT NULL REFERENCES rma_cases(id) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20),
+    note TEXT NOT NULL,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE rma_case_attachments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    rma_case_id UUID NOT NULL REFERENCES rma_cases(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL CHECK (size_bytes > 0),
+    url TEXT NOT NULL,
+    storage_provider VARCHAR(20) NOT NULL,
+    storage_public_id TEXT,
+    storage_object_name TEXT,
+    uploaded_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
 -- ================================================================
 -- Accounting
 -- ================================================================
@@ -1059,6 +1581,8 @@ ALTER TABLE purchase_orders ADD CONSTRAINT fk_purchase_orders_supplier_same_merc
     FOREIGN KEY (merchant_id, supplier_id) REFERENCES suppliers (merchant_id, id);
 ALTER TABLE accounts ADD CONSTRAINT fk_accounts_shop_same_merchant
     FOREIGN KEY (merchant_id, shop_id) REFERENCES shops (merchant_id, id);
+ALTER TABLE inventory_cost_layers ADD CONSTRAINT fk_inventory_cost_layers_receipt_item
+    FOREIGN KEY (goods_receipt_item_id) REFERENCES goods_receipt_items (id) ON DELETE SET NULL;
 
 -- ================================================================
 -- Indexes
@@ -1090,9 +1614,33 @@ CREATE INDEX idx_stock_items_product ON stock_items (merchant_id, product_id);
 CREATE INDEX idx_stock_items_merchant_name ON stock_items (merchant_id, name);
 CREATE INDEX idx_inventory_items_shop ON inventory_items (merchant_id, shop_id, is_active);
 CREATE INDEX idx_batches_lookup ON inventory_batches (shop_id, stock_item_id, expiry_date);
+CREATE INDEX idx_batches_expiry ON inventory_batches (expiry_date) WHERE quantity_remaining > 0;
 CREATE INDEX idx_inventory_movements_report ON inventory_movements (merchant_id, shop_id, movement_date);
 CREATE INDEX idx_inventory_reservations_active ON inventory_reservations (shop_id, status);
+CREATE INDEX idx_inventory_reservations_expiry ON inventory_reservations (expires_at) WHERE status = 'ACTIVE' AND expires_at IS NOT NULL;
 CREATE INDEX idx_barcode_lookup ON barcode_registry (merchant_id, normalized_code);
+CREATE INDEX idx_scale_plu_codes_stock_item ON scale_plu_codes (stock_item_id);
+CREATE INDEX idx_inventory_asset_events_asset ON inventory_asset_events (asset_id, created_at);
+CREATE INDEX idx_inventory_warranties_serial ON inventory_warranties (serial_id) WHERE serial_id IS NOT NULL;
+CREATE INDEX idx_inventory_warranties_asset ON inventory_warranties (asset_id) WHERE asset_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_asset_rentals_open ON asset_rentals (asset_id) WHERE status = 'OUT';
+CREATE INDEX idx_asset_rentals_due ON asset_rentals (merchant_id, due_at) WHERE status = 'OUT';
+CREATE INDEX idx_storage_bins_merchant ON storage_bins (merchant_id, shop_id);
+CREATE INDEX idx_inventory_backorders_open ON inventory_backorders (inventory_item_id, created_at) WHERE status = 'OPEN';
+CREATE UNIQUE INDEX idx_supplier_invoices_number ON supplier_invoices (merchant_id, supplier_id, invoice_number) WHERE invoice_number IS NOT NULL;
+CREATE INDEX idx_supplier_invoices_supplier ON supplier_invoices (merchant_id, supplier_id, invoice_date);
+CREATE INDEX idx_supplier_invoice_items_order_item ON supplier_invoice_items (purchase_order_item_id);
+CREATE INDEX idx_supplier_payments_invoice ON supplier_payments (supplier_invoice_id, payment_date);
+CREATE INDEX idx_supplier_returns_merchant ON supplier_returns (merchant_id, supplier_id, created_at DESC);
+CREATE INDEX idx_supplier_return_items_return ON supplier_return_items (supplier_return_id);
+CREATE INDEX idx_supplier_credit_notes_open ON supplier_credit_notes (merchant_id, supplier_id, created_at) WHERE status = 'OPEN';
+CREATE INDEX idx_supplier_credit_applications_invoice ON supplier_credit_applications (supplier_invoice_id);
+CREATE INDEX idx_inventory_backorders_merchant ON inventory_backorders (merchant_id, shop_id, status);
+CREATE INDEX idx_rma_cases_merchant_status ON rma_cases (merchant_id, status, created_at);
+CREATE UNIQUE INDEX idx_rma_cases_open_serial ON rma_cases (serial_id) WHERE status NOT IN ('REJECTED', 'CLOSED') AND serial_id IS NOT NULL;
+CREATE UNIQUE INDEX idx_rma_cases_open_asset ON rma_cases (asset_id) WHERE status NOT IN ('REJECTED', 'CLOSED') AND asset_id IS NOT NULL;
+CREATE INDEX idx_rma_case_notes_case ON rma_case_notes (rma_case_id, created_at);
+CREATE INDEX idx_inventory_serial_events_serial ON inventory_serial_events (serial_id, created_at);
 CREATE INDEX idx_inventory_reconciliation ON inventory_reconciliation_exceptions (merchant_id, shop_id, status);
 CREATE INDEX idx_inventory_assets_shop_status ON inventory_assets (shop_id, status);
 CREATE INDEX idx_inventory_asset_identifiers_lookup ON inventory_asset_identifiers (identifier_type_id, normalized_value);
@@ -1103,6 +1651,18 @@ CREATE INDEX idx_customer_activities_customer ON customer_activities (customer_i
 CREATE INDEX idx_sales_report ON sales (merchant_id, shop_id, sale_date);
 CREATE INDEX idx_sales_client_merchant ON sales (merchant_id, client_sale_id);
 CREATE INDEX idx_sale_items_sale ON sale_items (sale_id);
+CREATE INDEX idx_sale_item_batches_item ON sale_item_batches (sale_item_id);
+CREATE INDEX idx_sale_item_batches_batch ON sale_item_batches (batch_id);
+CREATE INDEX idx_product_kits_merchant ON product_kits (merchant_id, name);
+CREATE INDEX idx_product_kit_components_kit ON product_kit_components (kit_id);
+CREATE INDEX idx_sale_item_components_item ON sale_item_components (sale_item_id);
+CREATE UNIQUE INDEX idx_stock_item_units_report ON stock_item_units (stock_item_id) WHERE is_report_unit;
+CREATE INDEX idx_purchase_order_items_stock_item ON purchase_order_items (stock_item_id);
+CREATE INDEX idx_inventory_cost_layers_open ON inventory_cost_layers (inventory_item_id, received_at) WHERE quantity_remaining > 0;
+CREATE INDEX idx_inventory_movements_item_date ON inventory_movements (shop_id, inventory_item_id, movement_date);
+CREATE INDEX idx_inventory_movements_item ON inventory_movements (inventory_item_id);
+CREATE UNIQUE INDEX idx_inventory_reconciliation_open ON inventory_reconciliation_exceptions (inventory_item_id) WHERE status = 'OPEN';
+CREATE INDEX idx_inventory_reconciliation_shop_status ON inventory_reconciliation_exceptions (shop_id, status, detected_at DESC);
 CREATE INDEX idx_pos_terminals_shop ON pos_terminals (shop_id, is_active);
 CREATE INDEX idx_pos_sessions_shop_status ON pos_sessions (shop_id, status);
 CREATE UNIQUE INDEX idx_pos_sessions_one_open_per_terminal
@@ -1113,7 +1673,16 @@ CREATE INDEX idx_payment_proofs_payment_status ON payment_proofs (payment_id, st
 CREATE INDEX idx_payment_sessions_payment_status ON payment_provider_sessions (payment_id, status);
 CREATE INDEX idx_purchase_orders_shop_status ON purchase_orders (shop_id, status);
 CREATE INDEX idx_purchase_orders_merchant_created ON purchase_orders (merchant_id, created_at DESC);
+CREATE INDEX idx_purchase_order_sends_order ON purchase_order_sends (purchase_order_id, sent_at DESC);
+CREATE INDEX idx_replenishment_requests_merchant_status ON replenishment_requests (merchant_id, status, created_at DESC);
+CREATE INDEX idx_replenishment_requests_shop ON replenishment_requests (shop_id, created_at DESC);
 CREATE INDEX idx_goods_receipts_purchase_order ON goods_receipts (purchase_order_id);
+CREATE INDEX idx_supplier_products_stock_item ON supplier_products (merchant_id, stock_item_id);
+CREATE UNIQUE INDEX idx_supplier_products_preferred ON supplier_products (stock_item_id) WHERE is_preferred;
+CREATE INDEX idx_goods_receipt_costs_receipt ON goods_receipt_costs (goods_receipt_id);
+CREATE INDEX idx_goods_receipt_cost_allocations_item ON goods_receipt_cost_allocations (goods_receipt_item_id);
+CREATE INDEX idx_inventory_cost_layers_receipt_item ON inventory_cost_layers (goods_receipt_item_id) WHERE goods_receipt_item_id IS NOT NULL;
+CREATE INDEX idx_inventory_cost_adjustments_item ON inventory_cost_adjustments (merchant_id, inventory_item_id, created_at);
 CREATE INDEX idx_journal_entries_shop_date ON journal_entries (merchant_id, shop_id, created_at);
 CREATE INDEX idx_journal_lines_account ON journal_lines (account_id);
 CREATE INDEX idx_notifications_recipient ON notifications (recipient_user_id, is_read, created_at);